@@ -0,0 +1,173 @@
+package audit
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCapacity is the number of entries kept in memory if not configured otherwise.
+	DefaultCapacity = 1000
+)
+
+// Action is the kind of call an audit entry has been recorded for.
+type Action string
+
+const (
+	// ActionCreate is recorded when an update is requested.
+	ActionCreate Action = "create"
+	// ActionAbort is recorded when a running update is aborted.
+	ActionAbort Action = "abort"
+	// ActionRollback is recorded when an update is rolled back.
+	ActionRollback Action = "rollback"
+	// ActionDelete is recorded when an update is removed from the manager.
+	ActionDelete Action = "delete"
+	// ActionApprove is recorded when an update waiting for approval is approved.
+	ActionApprove Action = "approve"
+	// ActionReject is recorded when an update waiting for approval is rejected.
+	ActionReject Action = "reject"
+	// ActionCancel is recorded when an update waiting to be started is cancelled.
+	ActionCancel Action = "cancel"
+	// ActionPromote is recorded when the manager creates an update promoting a successful update.
+	ActionPromote Action = "promote"
+)
+
+// Outcome describes how a call has been answered.
+type Outcome string
+
+const (
+	// OutcomeSucceeded is recorded if the call has been executed.
+	OutcomeSucceeded Outcome = "succeeded"
+	// OutcomeRejected is recorded if the call has been refused, for example by a policy or the scope of the requester.
+	OutcomeRejected Outcome = "rejected"
+	// OutcomeConflict is recorded if the call conflicts with the state of the cluster, for example a version guard.
+	OutcomeConflict Outcome = "conflict"
+	// OutcomeNotFound is recorded if the update the call refers to does not exist.
+	OutcomeNotFound Outcome = "not_found"
+	// OutcomeFailed is recorded if the call failed with an unexpected error.
+	OutcomeFailed Outcome = "failed"
+)
+
+// Workload is a deployment or job touched by an update.
+type Workload struct {
+	// Kind is either Deployment or Job.
+	Kind string `json:"kind"`
+	// Namespace is the namespace of the workload.
+	Namespace string `json:"namespace"`
+	// Name is the name of the workload.
+	Name string `json:"name"`
+}
+
+// Entry records a single call against the update manager.
+type Entry struct {
+	// Time is when the call has been handled.
+	Time time.Time `json:"time"`
+	// Action is the kind of the call.
+	Action Action `json:"action"`
+	// Requester is the name of the principal which made the call.
+	Requester string `json:"requester"`
+	// ClientIP is the address the call has been made from.
+	ClientIP string `json:"client_ip"`
+	// UpdateUUID identifies the update the call created or referred to.
+	UpdateUUID string `json:"update_uuid,omitempty"`
+	// Image is the image of the update.
+	Image string `json:"image,omitempty"`
+	// UpdateClassifier is the update classifier of the update.
+	UpdateClassifier string `json:"update_classifier,omitempty"`
+	// Plan lists the workloads touched by the update.
+	Plan []Workload `json:"plan,omitempty"`
+	// FreezeOverride is set if the requester overrode the freeze calendar.
+	FreezeOverride bool `json:"freeze_override,omitempty"`
+	// Outcome describes how the call has been answered.
+	Outcome Outcome `json:"outcome"`
+	// Error is the reason of a call which did not succeed.
+	Error string `json:"error,omitempty"`
+}
+
+// Sink receives every recorded entry, for example to write it to a log.
+type Sink interface {
+	// Record handles the entry. It must not modify it.
+	Record(entry *Entry)
+}
+
+// Filter restricts the entries returned by a query. Empty fields do not restrict the entries.
+type Filter struct {
+	// Action only returns entries of the action.
+	Action Action
+	// Requester only returns entries of the requester.
+	Requester string
+	// Image only returns entries for the image.
+	Image string
+	// UpdateClassifier only returns entries for the update classifier.
+	UpdateClassifier string
+	// UpdateUUID only returns entries for the update.
+	UpdateUUID string
+	// Since only returns entries recorded at or after the time.
+	Since time.Time
+	// Limit is the maximal number of returned entries. The newest entries are returned.
+	Limit int
+}
+
+func (filter *Filter) matches(entry *Entry) bool {
+	return (len(filter.Action) == 0 || entry.Action == filter.Action) &&
+		(len(filter.Requester) == 0 || entry.Requester == filter.Requester) &&
+		(len(filter.Image) == 0 || entry.Image == filter.Image) &&
+		(len(filter.UpdateClassifier) == 0 || entry.UpdateClassifier == filter.UpdateClassifier) &&
+		(len(filter.UpdateUUID) == 0 || entry.UpdateUUID == filter.UpdateUUID) &&
+		!entry.Time.Before(filter.Since)
+}
+
+// NewLog returns an audit log keeping the latest entries up to the capacity in memory and passing every entry to
+// the sinks.
+func NewLog(capacity int, sinks ...Sink) *Log {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	return &Log{
+		capacity: capacity,
+		sinks:    sinks,
+		now:      time.Now,
+	}
+}
+
+// Log records the calls against the update manager.
+type Log struct {
+	mutex    sync.RWMutex
+	capacity int
+	entries  []Entry
+	sinks    []Sink
+	now      func() time.Time
+}
+
+// Record stores the entry and passes it to the sinks. If the time of the entry is not set, the current time is used.
+func (log *Log) Record(entry Entry) {
+	if entry.Time.IsZero() {
+		entry.Time = log.now()
+	}
+	log.mutex.Lock()
+	log.entries = append(log.entries, entry)
+	if len(log.entries) > log.capacity {
+		log.entries = append([]Entry{}, log.entries[len(log.entries)-log.capacity:]...)
+	}
+	log.mutex.Unlock()
+
+	for _, sink := range log.sinks {
+		sink.Record(&entry)
+	}
+}
+
+// Query returns the stored entries matching the filter, newest first.
+func (log *Log) Query(filter Filter) []Entry {
+	log.mutex.RLock()
+	defer log.mutex.RUnlock()
+	entries := make([]Entry, 0)
+	for index := len(log.entries) - 1; index >= 0; index-- {
+		if filter.Limit > 0 && len(entries) >= filter.Limit {
+			break
+		}
+		if filter.matches(&log.entries[index]) {
+			entries = append(entries, log.entries[index])
+		}
+	}
+	return entries
+}
@@ -0,0 +1,124 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	. "gopkg.in/check.v1"
+	apiv1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+type recordingSink struct {
+	entries []Entry
+}
+
+func (sink *recordingSink) Record(entry *Entry) {
+	sink.entries = append(sink.entries, *entry)
+}
+
+type AuditSuite struct {
+	now  time.Time
+	sink *recordingSink
+	log  *Log
+}
+
+var _ = Suite(&AuditSuite{})
+
+func (suite *AuditSuite) SetUpTest(c *C) {
+	suite.now = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	suite.sink = &recordingSink{}
+	suite.log = NewLog(3, suite.sink)
+	suite.log.now = func() time.Time { return suite.now }
+}
+
+func (suite *AuditSuite) record(action Action, requester string) {
+	suite.log.Record(Entry{Action: action, Requester: requester, Image: "xcnt/test:1.0.0", Outcome: OutcomeSucceeded})
+	suite.now = suite.now.Add(time.Minute)
+}
+
+func (suite *AuditSuite) TestRecord(c *C) {
+	suite.record(ActionCreate, "payments-ci")
+	entries := suite.log.Query(Filter{})
+	c.Assert(entries, HasLen, 1)
+	c.Assert(entries[0].Time, Equals, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	c.Assert(suite.sink.entries, DeepEquals, entries)
+}
+
+func (suite *AuditSuite) TestCapacity(c *C) {
+	for _, requester := range []string{"a", "b", "c", "d"} {
+		suite.record(ActionCreate, requester)
+	}
+	entries := suite.log.Query(Filter{})
+	c.Assert(entries, HasLen, 3)
+	c.Assert(entries[0].Requester, Equals, "d")
+	c.Assert(entries[2].Requester, Equals, "b")
+	c.Assert(suite.sink.entries, HasLen, 4)
+}
+
+func (suite *AuditSuite) TestQuery(c *C) {
+	suite.record(ActionCreate, "a")
+	suite.record(ActionDelete, "a")
+	suite.record(ActionCreate, "b")
+
+	c.Assert(suite.log.Query(Filter{Action: ActionCreate}), HasLen, 2)
+	c.Assert(suite.log.Query(Filter{Requester: "a"}), HasLen, 2)
+	c.Assert(suite.log.Query(Filter{Requester: "a", Action: ActionCreate}), HasLen, 1)
+	c.Assert(suite.log.Query(Filter{Image: "xcnt/other:1.0.0"}), HasLen, 0)
+	since := suite.log.Query(Filter{Since: time.Date(2024, 5, 1, 12, 1, 0, 0, time.UTC)})
+	c.Assert(since, HasLen, 2)
+	limited := suite.log.Query(Filter{Limit: 1})
+	c.Assert(limited, HasLen, 1)
+	c.Assert(limited[0].Requester, Equals, "b")
+}
+
+func (suite *AuditSuite) TestJSONSink(c *C) {
+	buffer := &bytes.Buffer{}
+	NewJSONSink(buffer).Record(&Entry{
+		Time:      suite.now,
+		Action:    ActionCreate,
+		Requester: "payments-ci",
+		ClientIP:  "10.0.0.1",
+		Plan:      []Workload{{Kind: "Deployment", Namespace: "payments", Name: "api"}},
+		Outcome:   OutcomeSucceeded,
+	})
+	line := map[string]interface{}{}
+	c.Assert(json.Unmarshal(buffer.Bytes(), &line), IsNil)
+	c.Assert(line["type"], Equals, "audit")
+	c.Assert(line["action"], Equals, "create")
+	c.Assert(line["requester"], Equals, "payments-ci")
+	c.Assert(line["client_ip"], Equals, "10.0.0.1")
+	c.Assert(line["outcome"], Equals, "succeeded")
+	c.Assert(line["plan"], HasLen, 1)
+	c.Assert(bytes.Count(buffer.Bytes(), []byte("\n")), Equals, 1)
+}
+
+func (suite *AuditSuite) TestEventSink(c *C) {
+	clientset := testclient.NewSimpleClientset()
+	NewEventSink(clientset).Record(&Entry{
+		Time:             suite.now,
+		Action:           ActionCreate,
+		Requester:        "payments-ci",
+		ClientIP:         "10.0.0.1",
+		Image:            "xcnt/test:1.0.0",
+		UpdateClassifier: "stable",
+		Plan: []Workload{
+			{Kind: "Deployment", Namespace: "payments", Name: "api"},
+			{Kind: "Job", Namespace: "payments", Name: "migration"},
+		},
+		Outcome: OutcomeRejected,
+		Error:   "not allowed",
+	})
+	events, err := clientset.CoreV1().Events("payments").List(context.Background(), metaV1.ListOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(events.Items, HasLen, 1)
+	event := events.Items[0]
+	c.Assert(event.InvolvedObject.Kind, Equals, "Deployment")
+	c.Assert(event.InvolvedObject.Name, Equals, "api")
+	c.Assert(event.Reason, Equals, "UpdateCreateRefused")
+	c.Assert(event.Type, Equals, apiv1.EventTypeWarning)
+	c.Assert(event.Message, Equals, "payments-ci requested create of xcnt/test:1.0.0 with update classifier stable from 10.0.0.1: rejected: not allowed")
+}
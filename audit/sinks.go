@@ -0,0 +1,115 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	apiv1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// eventSource is the component name set on the kubernetes events of the audit log.
+	eventSource = "kubernetes-update-manager"
+)
+
+// NewJSONSink returns a sink writing every entry as a single line of JSON to the writer.
+func NewJSONSink(writer io.Writer) *JSONSink {
+	return &JSONSink{encoder: json.NewEncoder(writer)}
+}
+
+// JSONSink writes the audit entries as structured JSON log lines.
+type JSONSink struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+}
+
+// Record writes the entry as JSON line.
+func (sink *JSONSink) Record(entry *Entry) {
+	line := struct {
+		Type string `json:"type"`
+		*Entry
+	}{Type: "audit", Entry: entry}
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	err := sink.encoder.Encode(line)
+	if err != nil {
+		log.WithError(err).Warn("Writing the audit entry failed")
+	}
+}
+
+// NewEventSink returns a sink creating a kubernetes event on every deployment of the plan of an entry.
+func NewEventSink(clientset kubernetes.Interface) *EventSink {
+	return &EventSink{clientset: clientset}
+}
+
+// EventSink records the audit entries as kubernetes events on the affected deployments, so they are visible with
+// kubectl describe.
+type EventSink struct {
+	clientset kubernetes.Interface
+}
+
+// Record creates an event for every deployment in the plan of the entry.
+func (sink *EventSink) Record(entry *Entry) {
+	for _, workload := range entry.Plan {
+		if workload.Kind != "Deployment" {
+			continue
+		}
+		event := &apiv1.Event{
+			ObjectMeta: metaV1.ObjectMeta{
+				GenerateName: workload.Name + ".",
+				Namespace:    workload.Namespace,
+			},
+			InvolvedObject: apiv1.ObjectReference{
+				APIVersion: "apps/v1",
+				Kind:       workload.Kind,
+				Namespace:  workload.Namespace,
+				Name:       workload.Name,
+			},
+			Reason:         eventReason(entry),
+			Message:        eventMessage(entry),
+			Type:           eventType(entry),
+			Source:         apiv1.EventSource{Component: eventSource},
+			FirstTimestamp: metaV1.NewTime(entry.Time),
+			LastTimestamp:  metaV1.NewTime(entry.Time),
+			Count:          1,
+		}
+		_, err := sink.clientset.CoreV1().Events(workload.Namespace).Create(context.Background(), event, metaV1.CreateOptions{})
+		if err != nil {
+			log.WithError(err).WithField("deployment", workload.Namespace+"/"+workload.Name).Warn("Creating the audit event failed")
+		}
+	}
+}
+
+func eventReason(entry *Entry) string {
+	reason := "Update"
+	if action := string(entry.Action); len(action) > 0 {
+		reason += strings.ToUpper(action[:1]) + action[1:]
+	}
+	if entry.Outcome != OutcomeSucceeded {
+		reason += "Refused"
+	}
+	return reason
+}
+
+func eventType(entry *Entry) string {
+	if entry.Outcome == OutcomeSucceeded {
+		return apiv1.EventTypeNormal
+	}
+	return apiv1.EventTypeWarning
+}
+
+func eventMessage(entry *Entry) string {
+	message := fmt.Sprintf("%s requested %s of %s with update classifier %s from %s: %s",
+		entry.Requester, entry.Action, entry.Image, entry.UpdateClassifier, entry.ClientIP, entry.Outcome)
+	if len(entry.Error) > 0 {
+		message += ": " + entry.Error
+	}
+	return message
+}
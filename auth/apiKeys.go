@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// APIKey is a named pre-shared key which is restricted to a scope.
+type APIKey struct {
+	// Name identifies the key. It is attached to the updates requested with the key.
+	Name string `json:"name"`
+	// Key is the secret which is sent in the Authorization header.
+	Key string `json:"key"`
+	Scope
+}
+
+// apiKeysFile is the format of the files the API keys are loaded from.
+type apiKeysFile struct {
+	Keys []APIKey `json:"keys"`
+}
+
+// LoadAPIKeys reads the API keys from the passed path. The path may either point to a single YAML file or to a
+// directory, for example a mounted kubernetes secret, in which case all non hidden files inside of it are read.
+func LoadAPIKeys(keyPath string) ([]APIKey, error) {
+	files, err := filesIn(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]APIKey, 0)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		fileKeys, err := ParseAPIKeys(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		keys = append(keys, fileKeys...)
+	}
+	return keys, validateAPIKeys(keys)
+}
+
+// ParseAPIKeys parses the API keys from YAML or JSON data.
+func ParseAPIKeys(data []byte) ([]APIKey, error) {
+	file := &apiKeysFile{}
+	err := yaml.UnmarshalStrict(data, file)
+	if err != nil {
+		return nil, err
+	}
+	return file.Keys, validateAPIKeys(file.Keys)
+}
+
+func validateAPIKeys(keys []APIKey) error {
+	names := map[string]bool{}
+	secrets := map[string]bool{}
+	for _, key := range keys {
+		if len(key.Name) == 0 {
+			return fmt.Errorf("API key without a name")
+		}
+		if len(strings.TrimSpace(key.Key)) == 0 {
+			return fmt.Errorf("API key %s has no key", key.Name)
+		}
+		if names[key.Name] {
+			return fmt.Errorf("API key %s is defined multiple times", key.Name)
+		}
+		if secrets[key.Key] {
+			return fmt.Errorf("API key %s reuses the key of another API key", key.Name)
+		}
+		names[key.Name] = true
+		secrets[key.Key] = true
+		err := key.Scope.validate()
+		if err != nil {
+			return fmt.Errorf("API key %s: %w", key.Name, err)
+		}
+	}
+	return nil
+}
+
+func filesIn(filePath string) ([]string, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{filePath}, nil
+	}
+
+	entries, err := os.ReadDir(filePath)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0)
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") || entry.IsDir() {
+			continue
+		}
+		files = append(files, filepath.Join(filePath, entry.Name()))
+	}
+	return files, nil
+}
+
+// NewAPIKeyAuthenticator returns an authenticator accepting the passed API keys.
+func NewAPIKeyAuthenticator(keys []APIKey) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{keys: keys}
+}
+
+// APIKeyAuthenticator authenticates requests with a pre-shared key in the Authorization header.
+type APIKeyAuthenticator struct {
+	keys []APIKey
+}
+
+// Authenticate returns the principal of the API key sent in the Authorization header of the request.
+func (authenticator *APIKeyAuthenticator) Authenticate(request *http.Request) (*Principal, error) {
+	authorizationData := strings.SplitN(request.Header.Get("Authorization"), " ", 2)
+	sentKey := authorizationData[len(authorizationData)-1]
+	if len(sentKey) == 0 {
+		return nil, ErrUnauthenticated
+	}
+	var found *APIKey
+	for index := range authenticator.keys {
+		key := &authenticator.keys[index]
+		// All keys are compared to not leak the position of a matching key through the response time.
+		if SecureCompare(sentKey, key.Key) && found == nil {
+			found = key
+		}
+	}
+	if found == nil {
+		return nil, ErrUnauthenticated
+	}
+	return &Principal{Name: found.Name, Scope: found.Scope}, nil
+}
+
+// SecureCompare compares two strings in a time constant way to avoid possible timing attacks on the password check.
+// Strings of different lengths never match, even if one is a prefix of the other.
+func SecureCompare(left, right string) bool {
+	sameLength := subtle.ConstantTimeEq(int32(len(left)), int32(len(right)))
+	for len(left) < len(right) {
+		left += " "
+	}
+	left = left[:len(right)]
+	return subtle.ConstantTimeCompare([]byte(left), []byte(right))&sameLength == 1
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+const apiKeysYAML = `
+keys:
+  - name: payments-ci
+    key: payments-secret
+    classifiers: [staging]
+    namespaces: [payments]
+    images: [docker.io/xcnt/payments-*]
+  - name: admin
+    key: admin-secret
+`
+
+type APIKeysSuite struct{}
+
+var _ = Suite(&APIKeysSuite{})
+
+func requestWithAuthorization(authorization string) *http.Request {
+	request, _ := http.NewRequest("GET", "/", nil)
+	if len(authorization) > 0 {
+		request.Header.Set("Authorization", authorization)
+	}
+	return request
+}
+
+func (suite *APIKeysSuite) TestLoadAPIKeysFile(c *C) {
+	file := filepath.Join(c.MkDir(), "keys.yaml")
+	c.Assert(os.WriteFile(file, []byte(apiKeysYAML), 0600), IsNil)
+	keys, err := LoadAPIKeys(file)
+	c.Assert(err, IsNil)
+	c.Assert(keys, HasLen, 2)
+	c.Assert(keys[0].Name, Equals, "payments-ci")
+	c.Assert(keys[0].Namespaces, DeepEquals, []string{"payments"})
+	c.Assert(keys[1].Classifiers, IsNil)
+}
+
+func (suite *APIKeysSuite) TestLoadAPIKeysDirectory(c *C) {
+	directory := c.MkDir()
+	c.Assert(os.WriteFile(filepath.Join(directory, "keys.yaml"), []byte(apiKeysYAML), 0600), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(directory, "more.yaml"), []byte("keys: [{name: other, key: other-secret}]"), 0600), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(directory, ".hidden"), []byte("invalid"), 0600), IsNil)
+	keys, err := LoadAPIKeys(directory)
+	c.Assert(err, IsNil)
+	c.Assert(keys, HasLen, 3)
+}
+
+func (suite *APIKeysSuite) TestLoadAPIKeysDuplicateAcrossFiles(c *C) {
+	directory := c.MkDir()
+	c.Assert(os.WriteFile(filepath.Join(directory, "a.yaml"), []byte(apiKeysYAML), 0600), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(directory, "b.yaml"), []byte("keys: [{name: admin, key: other}]"), 0600), IsNil)
+	_, err := LoadAPIKeys(directory)
+	c.Assert(err, ErrorMatches, "API key admin is defined multiple times")
+}
+
+func (suite *APIKeysSuite) TestParseAPIKeysInvalid(c *C) {
+	for data, expected := range map[string]string{
+		"keys: [{key: secret}]":                                "API key without a name",
+		"keys: [{name: ci}]":                                   "API key ci has no key",
+		"keys: [{name: a, key: s}, {name: b, key: s}]":         "API key b reuses the key of another API key",
+		"keys: [{name: ci, key: s, namespaces: ['[invalid']}]": `API key ci: Invalid pattern "\[invalid".*`,
+		"keys: [{name: ci, key: s, unknown: true}]":            `.*unknown field.*`,
+	} {
+		_, err := ParseAPIKeys([]byte(data))
+		c.Assert(err, ErrorMatches, expected)
+	}
+}
+
+func (suite *APIKeysSuite) TestAuthenticate(c *C) {
+	keys, err := ParseAPIKeys([]byte(apiKeysYAML))
+	c.Assert(err, IsNil)
+	authenticator := NewAPIKeyAuthenticator(keys)
+	principal, err := authenticator.Authenticate(requestWithAuthorization("APIKey payments-secret"))
+	c.Assert(err, IsNil)
+	c.Assert(principal.Name, Equals, "payments-ci")
+	c.Assert(principal.Classifiers, DeepEquals, []string{"staging"})
+}
+
+func (suite *APIKeysSuite) TestAuthenticateUnknownKey(c *C) {
+	keys, _ := ParseAPIKeys([]byte(apiKeysYAML))
+	authenticator := NewAPIKeyAuthenticator(keys)
+	for _, authorization := range []string{"", "APIKey other", "APIKey admin-secret-suffix", "APIKey admin"} {
+		_, err := authenticator.Authenticate(requestWithAuthorization(authorization))
+		c.Assert(err, Equals, ErrUnauthenticated)
+	}
+}
+
+type staticAuthenticator struct {
+	principal *Principal
+	err       error
+}
+
+func (authenticator *staticAuthenticator) Authenticate(request *http.Request) (*Principal, error) {
+	return authenticator.principal, authenticator.err
+}
+
+func (suite *APIKeysSuite) TestAuthenticators(c *C) {
+	authenticators := Authenticators{
+		&staticAuthenticator{err: ErrUnauthenticated},
+		&staticAuthenticator{principal: &Principal{Name: "second"}},
+	}
+	principal, err := authenticators.Authenticate(requestWithAuthorization(""))
+	c.Assert(err, IsNil)
+	c.Assert(principal.Name, Equals, "second")
+}
+
+func (suite *APIKeysSuite) TestAuthenticatorsError(c *C) {
+	expected := errors.New("expired")
+	authenticators := Authenticators{
+		&staticAuthenticator{err: expected},
+		&staticAuthenticator{principal: &Principal{Name: "second"}},
+	}
+	_, err := authenticators.Authenticate(requestWithAuthorization(""))
+	c.Assert(err, Equals, expected)
+}
+
+func (suite *APIKeysSuite) TestAuthenticatorsNoneMatching(c *C) {
+	_, err := Authenticators{}.Authenticate(requestWithAuthorization(""))
+	c.Assert(err, Equals, ErrUnauthenticated)
+}
+
+func (suite *APIKeysSuite) TestSecureCompare(c *C) {
+	c.Assert(SecureCompare("secret", "secret"), Equals, true)
+	c.Assert(SecureCompare("secret-suffix", "secret"), Equals, false)
+	c.Assert(SecureCompare("sec", "secret"), Equals, false)
+}
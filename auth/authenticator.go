@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+)
+
+var (
+	// ErrUnauthenticated is returned by an authenticator if the request does not carry credentials it accepts.
+	ErrUnauthenticated = errors.New("The request could not be authenticated")
+)
+
+// Authenticator identifies the principal which sent a request.
+type Authenticator interface {
+	// Authenticate returns the principal of the request. If the credentials of the request are not accepted,
+	// ErrUnauthenticated is returned.
+	Authenticate(request *http.Request) (*Principal, error)
+}
+
+// Authenticators tries each of the contained authenticators in order and returns the first principal found.
+type Authenticators []Authenticator
+
+// Authenticate returns the principal of the first authenticator accepting the request.
+func (authenticators Authenticators) Authenticate(request *http.Request) (*Principal, error) {
+	for _, authenticator := range authenticators {
+		principal, err := authenticator.Authenticate(request)
+		if errors.Is(err, ErrUnauthenticated) {
+			continue
+		}
+		return principal, err
+	}
+	return nil, ErrUnauthenticated
+}
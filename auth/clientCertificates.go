@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+
+	"sigs.k8s.io/yaml"
+)
+
+// ClientCertificateRule grants the scope to verified client certificates whose subject matches the patterns.
+type ClientCertificateRule struct {
+	// Name identifies the principal of matching certificates. It defaults to the common name of the certificate.
+	Name string `json:"name,omitempty"`
+	// CommonName is a pattern the common name of the certificate subject has to match.
+	CommonName string `json:"commonName"`
+	// Organization is an optional pattern one of the organizations of the certificate subject has to match.
+	Organization string `json:"organization,omitempty"`
+	Scope
+}
+
+// clientCertificatesFile is the format of the file the client certificate rules are loaded from.
+type clientCertificatesFile struct {
+	Certificates []ClientCertificateRule `json:"certificates"`
+}
+
+// LoadClientCertificateRules reads the rules mapping client certificate subjects to scopes from the YAML or JSON file.
+func LoadClientCertificateRules(file string) ([]ClientCertificateRule, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	rulesFile := &clientCertificatesFile{}
+	err = yaml.UnmarshalStrict(data, rulesFile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	for _, rule := range rulesFile.Certificates {
+		if len(rule.CommonName) == 0 {
+			return nil, fmt.Errorf("%s: client certificate rule without a common name", file)
+		}
+		for _, pattern := range []string{rule.CommonName, rule.Organization} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("%s: invalid pattern %q: %w", file, pattern, err)
+			}
+		}
+		if err := rule.Scope.validate(); err != nil {
+			return nil, fmt.Errorf("%s: client certificate %s: %w", file, rule.CommonName, err)
+		}
+	}
+	return rulesFile.Certificates, nil
+}
+
+// NewClientCertificateAuthenticator returns an authenticator accepting verified client certificates matching the rules.
+func NewClientCertificateAuthenticator(rules []ClientCertificateRule) *ClientCertificateAuthenticator {
+	return &ClientCertificateAuthenticator{rules: rules}
+}
+
+// ClientCertificateAuthenticator authenticates requests with the client certificate of the TLS connection. Only
+// certificates which have been verified against the client CA of the server are considered.
+type ClientCertificateAuthenticator struct {
+	rules []ClientCertificateRule
+}
+
+// Authenticate returns the principal of the first rule matching the subject of the verified client certificate.
+// Requests without a verified certificate or with a certificate not matching any rule are not authenticated by this
+// authenticator.
+func (authenticator *ClientCertificateAuthenticator) Authenticate(request *http.Request) (*Principal, error) {
+	if request.TLS == nil || len(request.TLS.VerifiedChains) == 0 || len(request.TLS.VerifiedChains[0]) == 0 {
+		return nil, ErrUnauthenticated
+	}
+	subject := request.TLS.VerifiedChains[0][0].Subject
+	for _, rule := range authenticator.rules {
+		if matched, _ := path.Match(rule.CommonName, subject.CommonName); !matched {
+			continue
+		}
+		if len(rule.Organization) > 0 && !matchesAnyValue(rule.Organization, subject.Organization) {
+			continue
+		}
+		name := rule.Name
+		if len(name) == 0 {
+			name = subject.CommonName
+		}
+		return &Principal{Name: name, Scope: rule.Scope}, nil
+	}
+	return nil, ErrUnauthenticated
+}
+
+func matchesAnyValue(pattern string, values []string) bool {
+	for _, value := range values {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
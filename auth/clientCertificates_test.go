@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+const clientCertificatesYAML = `
+certificates:
+  - name: payments-ci
+    commonName: payments-*
+    organization: xcnt
+    classifiers: [staging]
+  - commonName: platform
+`
+
+type ClientCertificatesSuite struct {
+	authenticator *ClientCertificateAuthenticator
+}
+
+var _ = Suite(&ClientCertificatesSuite{})
+
+func (suite *ClientCertificatesSuite) SetUpTest(c *C) {
+	file := filepath.Join(c.MkDir(), "certificates.yaml")
+	c.Assert(os.WriteFile(file, []byte(clientCertificatesYAML), 0600), IsNil)
+	rules, err := LoadClientCertificateRules(file)
+	c.Assert(err, IsNil)
+	suite.authenticator = NewClientCertificateAuthenticator(rules)
+}
+
+func requestWithClientCertificate(subject pkix.Name) *http.Request {
+	request := requestWithAuthorization("")
+	certificate := &x509.Certificate{Subject: subject}
+	request.TLS = &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{certificate},
+		VerifiedChains:   [][]*x509.Certificate{{certificate}},
+	}
+	return request
+}
+
+func (suite *ClientCertificatesSuite) TestAuthenticate(c *C) {
+	principal, err := suite.authenticator.Authenticate(requestWithClientCertificate(pkix.Name{CommonName: "payments-api", Organization: []string{"xcnt"}}))
+	c.Assert(err, IsNil)
+	c.Assert(principal.Name, Equals, "payments-ci")
+	c.Assert(principal.Classifiers, DeepEquals, []string{"staging"})
+
+	principal, err = suite.authenticator.Authenticate(requestWithClientCertificate(pkix.Name{CommonName: "platform"}))
+	c.Assert(err, IsNil)
+	c.Assert(principal.Name, Equals, "platform")
+	c.Assert(principal.Classifiers, IsNil)
+}
+
+func (suite *ClientCertificatesSuite) TestAuthenticateNoMatchingRule(c *C) {
+	for _, subject := range []pkix.Name{
+		{CommonName: "payments-api", Organization: []string{"other"}},
+		{CommonName: "payments-api"},
+		{CommonName: "search"},
+	} {
+		_, err := suite.authenticator.Authenticate(requestWithClientCertificate(subject))
+		c.Assert(err, Equals, ErrUnauthenticated)
+	}
+}
+
+func (suite *ClientCertificatesSuite) TestAuthenticateUnverifiedCertificate(c *C) {
+	request := requestWithClientCertificate(pkix.Name{CommonName: "platform"})
+	request.TLS.VerifiedChains = nil
+	_, err := suite.authenticator.Authenticate(request)
+	c.Assert(err, Equals, ErrUnauthenticated)
+
+	_, err = suite.authenticator.Authenticate(requestWithAuthorization(""))
+	c.Assert(err, Equals, ErrUnauthenticated)
+}
+
+func (suite *ClientCertificatesSuite) TestLoadClientCertificateRulesInvalid(c *C) {
+	for config, expected := range map[string]string{
+		"certificates: [{classifiers: [a]}]":                  ".*client certificate rule without a common name",
+		"certificates: [{commonName: '['}]":                   `.*invalid pattern "\[".*`,
+		"certificates: [{commonName: a, classifiers: ['[']}]": `.*client certificate a: Invalid pattern "\[".*`,
+	} {
+		file := filepath.Join(c.MkDir(), "certificates.yaml")
+		c.Assert(os.WriteFile(file, []byte(config), 0600), IsNil)
+		_, err := LoadClientCertificateRules(file)
+		c.Assert(err, ErrorMatches, expected)
+	}
+}
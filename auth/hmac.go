@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// HMACScheme is the scheme of the Authorization header of signed requests.
+	HMACScheme = "HMAC-SHA256"
+	// TimestampHeader is the header holding the unix time a signed request has been created at.
+	TimestampHeader = "X-Update-Manager-Timestamp"
+	// NonceHeader is the header holding the random value which makes each signed request unique.
+	NonceHeader = "X-Update-Manager-Nonce"
+	// DefaultMaxClockSkew is the maximal difference between the timestamp of a signed request and the server time.
+	DefaultMaxClockSkew = 5 * time.Minute
+	// maxSignedBodySize is the maximal size of a request body which is read for the signature verification.
+	maxSignedBodySize = 1 << 20
+	// minNonceLength is the minimal length of the nonce of a signed request.
+	minNonceLength = 16
+)
+
+var (
+	// ErrInvalidSignature is returned if the signature of a request does not match any key.
+	ErrInvalidSignature = errors.New("The signature of the request is not valid")
+	// ErrStaleRequest is returned if the timestamp of a signed request is outside of the accepted clock skew.
+	ErrStaleRequest = errors.New("The timestamp of the signed request is too old or in the future")
+	// ErrReplayedRequest is returned if the nonce of a signed request has already been used.
+	ErrReplayedRequest = errors.New("The nonce of the signed request has already been used")
+)
+
+// SignRequest returns the hex encoded HMAC-SHA256 signature of the request parts with the key. The path includes the
+// query of the request.
+func SignRequest(key string, method string, path string, timestamp string, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(strings.Join([]string{
+		strings.ToUpper(method),
+		path,
+		timestamp,
+		nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewHMACAuthenticator returns an authenticator accepting requests signed with one of the passed API keys.
+func NewHMACAuthenticator(keys []APIKey, maxClockSkew time.Duration) *HMACAuthenticator {
+	if maxClockSkew <= 0 {
+		maxClockSkew = DefaultMaxClockSkew
+	}
+	return &HMACAuthenticator{
+		keys:         keys,
+		maxClockSkew: maxClockSkew,
+		nonces:       map[string]time.Time{},
+		now:          time.Now,
+	}
+}
+
+// HMACAuthenticator authenticates requests which are signed with an API key instead of sending it. Each nonce is
+// only accepted once while its timestamp is within the clock skew. The used nonces are held in memory and are
+// therefore not shared between multiple replicas of the server.
+type HMACAuthenticator struct {
+	keys         []APIKey
+	maxClockSkew time.Duration
+	mutex        sync.Mutex
+	nonces       map[string]time.Time
+	now          func() time.Time
+}
+
+// Authenticate returns the principal of the key the request is signed with. Requests which are not signed are not
+// authenticated by this authenticator.
+func (authenticator *HMACAuthenticator) Authenticate(request *http.Request) (*Principal, error) {
+	authorizationData := strings.SplitN(request.Header.Get("Authorization"), " ", 2)
+	if len(authorizationData) != 2 || !strings.EqualFold(authorizationData[0], HMACScheme) {
+		return nil, ErrUnauthenticated
+	}
+	signature := strings.TrimSpace(authorizationData[1])
+	timestamp := request.Header.Get(TimestampHeader)
+	nonce := request.Header.Get(NonceHeader)
+	if len(nonce) < minNonceLength {
+		return nil, fmt.Errorf("%w: the nonce must have at least %d characters", ErrInvalidSignature, minNonceLength)
+	}
+	createdAt, err := parseTimestamp(timestamp)
+	if err != nil {
+		return nil, err
+	}
+	now := authenticator.now()
+	if createdAt.Before(now.Add(-authenticator.maxClockSkew)) || createdAt.After(now.Add(authenticator.maxClockSkew)) {
+		return nil, ErrStaleRequest
+	}
+
+	body, err := readBody(request)
+	if err != nil {
+		return nil, err
+	}
+	var found *APIKey
+	for index := range authenticator.keys {
+		key := &authenticator.keys[index]
+		expected := SignRequest(key.Key, request.Method, request.URL.RequestURI(), timestamp, nonce, body)
+		// All keys are compared to not leak the position of a matching key through the response time.
+		if SecureCompare(signature, expected) && found == nil {
+			found = key
+		}
+	}
+	if found == nil {
+		return nil, ErrInvalidSignature
+	}
+	// The nonce is only recorded for valid signatures, so unauthenticated callers can not fill the nonce store.
+	if !authenticator.useNonce(nonce, createdAt, now) {
+		return nil, ErrReplayedRequest
+	}
+	return &Principal{Name: found.Name, Scope: found.Scope}, nil
+}
+
+// useNonce records the nonce and returns false if it has already been used. Nonces are forgotten once their request
+// would be rejected as stale anyway.
+func (authenticator *HMACAuthenticator) useNonce(nonce string, createdAt time.Time, now time.Time) bool {
+	authenticator.mutex.Lock()
+	defer authenticator.mutex.Unlock()
+	for usedNonce, expiry := range authenticator.nonces {
+		if now.After(expiry) {
+			delete(authenticator.nonces, usedNonce)
+		}
+	}
+	if _, ok := authenticator.nonces[nonce]; ok {
+		return false
+	}
+	authenticator.nonces[nonce] = createdAt.Add(authenticator.maxClockSkew)
+	return true
+}
+
+func parseTimestamp(timestamp string) (time.Time, error) {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: invalid timestamp %q", ErrInvalidSignature, timestamp)
+	}
+	return time.Unix(seconds, 0), nil
+}
+
+// readBody reads the body of the request and replaces it so it can be read again by the request handlers.
+func readBody(request *http.Request) ([]byte, error) {
+	if request.Body == nil {
+		return []byte{}, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(request.Body, maxSignedBodySize+1))
+	request.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxSignedBodySize {
+		return nil, fmt.Errorf("%w: the request body is too large", ErrInvalidSignature)
+	}
+	request.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
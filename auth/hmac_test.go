@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type HMACSuite struct {
+	now           time.Time
+	authenticator *HMACAuthenticator
+}
+
+var _ = Suite(&HMACSuite{})
+
+func (suite *HMACSuite) SetUpTest(c *C) {
+	suite.now = time.Unix(1700000000, 0)
+	suite.authenticator = NewHMACAuthenticator([]APIKey{
+		{Name: "payments-ci", Key: "payments-secret", Scope: Scope{Classifiers: []string{"staging"}}},
+		{Name: "admin", Key: "admin-secret"},
+	}, 0)
+	suite.authenticator.now = func() time.Time { return suite.now }
+}
+
+func (suite *HMACSuite) signedRequest(key string, timestamp time.Time, nonce string, body string) *http.Request {
+	request, _ := http.NewRequest("POST", "/updates?dry=true", strings.NewReader(body))
+	timestampString := strconv.FormatInt(timestamp.Unix(), 10)
+	signature := SignRequest(key, request.Method, request.URL.RequestURI(), timestampString, nonce, []byte(body))
+	request.Header.Set("Authorization", HMACScheme+" "+signature)
+	request.Header.Set(TimestampHeader, timestampString)
+	request.Header.Set(NonceHeader, nonce)
+	return request
+}
+
+func (suite *HMACSuite) TestAuthenticate(c *C) {
+	request := suite.signedRequest("payments-secret", suite.now, "0123456789abcdef", "image=xcnt/test:1.0.0")
+	principal, err := suite.authenticator.Authenticate(request)
+	c.Assert(err, IsNil)
+	c.Assert(principal.Name, Equals, "payments-ci")
+	c.Assert(principal.Classifiers, DeepEquals, []string{"staging"})
+
+	body := make([]byte, 100)
+	n, _ := request.Body.Read(body)
+	c.Assert(string(body[:n]), Equals, "image=xcnt/test:1.0.0")
+}
+
+func (suite *HMACSuite) TestAuthenticateSecondKey(c *C) {
+	principal, err := suite.authenticator.Authenticate(suite.signedRequest("admin-secret", suite.now, "0123456789abcdef", ""))
+	c.Assert(err, IsNil)
+	c.Assert(principal.Name, Equals, "admin")
+}
+
+func (suite *HMACSuite) TestAuthenticateTamperedBody(c *C) {
+	request := suite.signedRequest("payments-secret", suite.now, "0123456789abcdef", "image=xcnt/test:1.0.0")
+	request.Body = http.NoBody
+	_, err := suite.authenticator.Authenticate(request)
+	c.Assert(err, Equals, ErrInvalidSignature)
+}
+
+func (suite *HMACSuite) TestAuthenticateTamperedPath(c *C) {
+	request := suite.signedRequest("payments-secret", suite.now, "0123456789abcdef", "")
+	request.URL.RawQuery = "dry=false"
+	_, err := suite.authenticator.Authenticate(request)
+	c.Assert(err, Equals, ErrInvalidSignature)
+}
+
+func (suite *HMACSuite) TestAuthenticateUnknownKey(c *C) {
+	_, err := suite.authenticator.Authenticate(suite.signedRequest("other-secret", suite.now, "0123456789abcdef", ""))
+	c.Assert(err, Equals, ErrInvalidSignature)
+}
+
+func (suite *HMACSuite) TestAuthenticateStaleTimestamp(c *C) {
+	for _, timestamp := range []time.Time{suite.now.Add(-6 * time.Minute), suite.now.Add(6 * time.Minute)} {
+		_, err := suite.authenticator.Authenticate(suite.signedRequest("admin-secret", timestamp, "0123456789abcdef", ""))
+		c.Assert(err, Equals, ErrStaleRequest)
+	}
+	_, err := suite.authenticator.Authenticate(suite.signedRequest("admin-secret", suite.now.Add(-4*time.Minute), "0123456789abcdef", ""))
+	c.Assert(err, IsNil)
+}
+
+func (suite *HMACSuite) TestAuthenticateReplayedNonce(c *C) {
+	_, err := suite.authenticator.Authenticate(suite.signedRequest("admin-secret", suite.now, "0123456789abcdef", ""))
+	c.Assert(err, IsNil)
+	_, err = suite.authenticator.Authenticate(suite.signedRequest("admin-secret", suite.now, "0123456789abcdef", ""))
+	c.Assert(err, Equals, ErrReplayedRequest)
+	_, err = suite.authenticator.Authenticate(suite.signedRequest("admin-secret", suite.now, "fedcba9876543210", ""))
+	c.Assert(err, IsNil)
+}
+
+func (suite *HMACSuite) TestNoncesAreForgotten(c *C) {
+	_, err := suite.authenticator.Authenticate(suite.signedRequest("admin-secret", suite.now, "0123456789abcdef", ""))
+	c.Assert(err, IsNil)
+	suite.now = suite.now.Add(10 * time.Minute)
+	_, err = suite.authenticator.Authenticate(suite.signedRequest("admin-secret", suite.now, "fedcba9876543210", ""))
+	c.Assert(err, IsNil)
+	c.Assert(suite.authenticator.nonces, HasLen, 1)
+}
+
+func (suite *HMACSuite) TestAuthenticateInvalidHeaders(c *C) {
+	request := suite.signedRequest("admin-secret", suite.now, "short", "")
+	_, err := suite.authenticator.Authenticate(request)
+	c.Assert(errors.Is(err, ErrInvalidSignature), Equals, true)
+
+	request = suite.signedRequest("admin-secret", suite.now, "0123456789abcdef", "")
+	request.Header.Set(TimestampHeader, "yesterday")
+	_, err = suite.authenticator.Authenticate(request)
+	c.Assert(errors.Is(err, ErrInvalidSignature), Equals, true)
+}
+
+func (suite *HMACSuite) TestAuthenticateOtherScheme(c *C) {
+	for _, authorization := range []string{"", "APIKey admin-secret", "Bearer token"} {
+		_, err := suite.authenticator.Authenticate(requestWithAuthorization(authorization))
+		c.Assert(err, Equals, ErrUnauthenticated)
+	}
+}
@@ -0,0 +1,302 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // Registers the hashes of the supported signature algorithms.
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	// jwtLeeway is the clock skew tolerated when checking the time based claims of a token.
+	jwtLeeway = time.Minute
+)
+
+var (
+	// ErrInvalidToken is returned if a token is malformed, its signature can not be verified or its claims are not valid.
+	ErrInvalidToken = errors.New("Invalid token")
+)
+
+// jwtHeader is the decoded header of a JSON web token.
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// jwtClaims are the decoded claims of a JSON web token.
+type jwtClaims map[string]interface{}
+
+// jsonWebToken is a parsed but not yet verified JSON web token.
+type jsonWebToken struct {
+	header    jwtHeader
+	claims    jwtClaims
+	signed    []byte
+	signature []byte
+}
+
+// isJWT returns if the passed string has the structure of a JSON web token.
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2 && strings.HasPrefix(token, "eyJ")
+}
+
+// parseJWT decodes the passed token without verifying it.
+func parseJWT(token string) (*jsonWebToken, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: not a JSON web token", ErrInvalidToken)
+	}
+	parsed := &jsonWebToken{signed: []byte(parts[0] + "." + parts[1])}
+	err := decodeJWTPart(parts[0], &parsed.header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: header: %s", ErrInvalidToken, err.Error())
+	}
+	err = decodeJWTPart(parts[1], &parsed.claims)
+	if err != nil {
+		return nil, fmt.Errorf("%w: claims: %s", ErrInvalidToken, err.Error())
+	}
+	parsed.signature, err = base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %s", ErrInvalidToken, err.Error())
+	}
+	return parsed, nil
+}
+
+func decodeJWTPart(part string, target interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(target)
+}
+
+// verify checks the signature of the token with the passed key.
+func (token *jsonWebToken) verify(key crypto.PublicKey) error {
+	hash, err := hashForAlgorithm(token.header.Algorithm)
+	if err != nil {
+		return err
+	}
+	var digest []byte
+	if hash != 0 {
+		hasher := hash.New()
+		hasher.Write(token.signed)
+		digest = hasher.Sum(nil)
+	}
+
+	valid := false
+	switch publicKey := key.(type) {
+	case *rsa.PublicKey:
+		switch token.header.Algorithm[:2] {
+		case "RS":
+			valid = rsa.VerifyPKCS1v15(publicKey, hash, digest, token.signature) == nil
+		case "PS":
+			valid = rsa.VerifyPSS(publicKey, hash, digest, token.signature, nil) == nil
+		}
+	case *ecdsa.PublicKey:
+		size := (publicKey.Curve.Params().BitSize + 7) / 8
+		if token.header.Algorithm[:2] == "ES" && len(token.signature) == 2*size {
+			r := new(big.Int).SetBytes(token.signature[:size])
+			s := new(big.Int).SetBytes(token.signature[size:])
+			valid = ecdsa.Verify(publicKey, digest, r, s)
+		}
+	case ed25519.PublicKey:
+		valid = token.header.Algorithm == "EdDSA" && ed25519.Verify(publicKey, token.signed, token.signature)
+	}
+	if !valid {
+		return fmt.Errorf("%w: signature does not match", ErrInvalidToken)
+	}
+	return nil
+}
+
+func hashForAlgorithm(algorithm string) (crypto.Hash, error) {
+	switch algorithm {
+	case "RS256", "PS256", "ES256":
+		return crypto.SHA256, nil
+	case "RS384", "PS384", "ES384":
+		return crypto.SHA384, nil
+	case "RS512", "PS512", "ES512":
+		return crypto.SHA512, nil
+	case "EdDSA":
+		return 0, nil
+	}
+	return 0, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, algorithm)
+}
+
+// validateTime checks the expiry, not before and issued at claims of the token. The expiry is required.
+func (claims jwtClaims) validateTime(now time.Time) error {
+	expiry, ok := claims.time("exp")
+	if !ok {
+		return fmt.Errorf("%w: missing expiry", ErrInvalidToken)
+	}
+	if now.After(expiry.Add(jwtLeeway)) {
+		return fmt.Errorf("%w: expired at %s", ErrInvalidToken, expiry.Format(time.RFC3339))
+	}
+	if notBefore, ok := claims.time("nbf"); ok && now.Add(jwtLeeway).Before(notBefore) {
+		return fmt.Errorf("%w: not valid before %s", ErrInvalidToken, notBefore.Format(time.RFC3339))
+	}
+	if issuedAt, ok := claims.time("iat"); ok && now.Add(jwtLeeway).Before(issuedAt) {
+		return fmt.Errorf("%w: issued in the future", ErrInvalidToken)
+	}
+	return nil
+}
+
+// hasAudience returns if one of the passed audiences is included in the audience claim of the token.
+func (claims jwtClaims) hasAudience(audiences []string) bool {
+	tokenAudiences := make([]string, 0)
+	switch audience := claims["aud"].(type) {
+	case string:
+		tokenAudiences = append(tokenAudiences, audience)
+	case []interface{}:
+		for _, item := range audience {
+			if value, ok := item.(string); ok {
+				tokenAudiences = append(tokenAudiences, value)
+			}
+		}
+	}
+	for _, tokenAudience := range tokenAudiences {
+		for _, audience := range audiences {
+			if tokenAudience == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// string returns the claim as a string. Numbers and booleans are formatted, other values are not returned.
+func (claims jwtClaims) string(name string) (string, bool) {
+	switch value := claims[name].(type) {
+	case string:
+		return value, true
+	case json.Number:
+		return value.String(), true
+	case bool:
+		return fmt.Sprint(value), true
+	}
+	return "", false
+}
+
+func (claims jwtClaims) time(name string) (time.Time, bool) {
+	value, ok := claims[name].(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := value.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+// jsonWebKey is a single public key of a JSON web key set.
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+// ParseJSONWebKeySet returns the signing keys of the JSON web key set indexed by their key id. Keys of unsupported
+// types and encryption keys are skipped.
+func ParseJSONWebKeySet(data []byte) (map[string][]crypto.PublicKey, error) {
+	keySet := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+	err := json.Unmarshal(data, &keySet)
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string][]crypto.PublicKey{}
+	for _, webKey := range keySet.Keys {
+		if webKey.Use == "enc" {
+			continue
+		}
+		key, err := webKey.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("Key %q: %w", webKey.KeyID, err)
+		}
+		if key != nil {
+			keys[webKey.KeyID] = append(keys[webKey.KeyID], key)
+		}
+	}
+	return keys, nil
+}
+
+func (webKey *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch webKey.KeyType {
+	case "RSA":
+		n, err := decodeBigInt(webKey.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(webKey.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("Invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch webKey.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, nil
+		}
+		x, err := decodeBigInt(webKey.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(webKey.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("Point is not on curve %s", webKey.Curve)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if webKey.Curve != "Ed25519" {
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(webKey.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("Invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, nil
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("Empty key parameter")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,295 @@
+package auth
+
+import (
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// jwksRefreshInterval is the minimal time between two fetches of the key set of an issuer. Unknown key ids only
+	// trigger a refetch after this interval to not allow callers to flood the issuer.
+	jwksRefreshInterval = time.Minute
+	// jwksMaxAge is the time after which a fetched key set is refreshed even if all key ids are known.
+	jwksMaxAge = time.Hour
+	// defaultNameClaim is the claim used to name the principal of a token if not configured otherwise.
+	defaultNameClaim = "sub"
+)
+
+var (
+	// ErrNoMatchingRule is returned if a valid token does not satisfy the claims of any rule of its issuer.
+	ErrNoMatchingRule = errors.New("The token does not match any rule of its issuer")
+)
+
+// OIDCConfig configures the issuers whose ID tokens are accepted for authentication.
+type OIDCConfig struct {
+	Issuers []IssuerConfig `json:"issuers"`
+}
+
+// IssuerConfig configures an OpenID Connect issuer, for example the GitHub Actions or GitLab token issuer.
+type IssuerConfig struct {
+	// Issuer must match the iss claim of the tokens, for example https://token.actions.githubusercontent.com.
+	Issuer string `json:"issuer"`
+	// Audiences lists the accepted values of the aud claim. At least one is required.
+	Audiences []string `json:"audiences"`
+	// JWKSURL is the URL of the key set of the issuer. If neither it nor JWKSFile is set, the URL is discovered
+	// through the OpenID configuration of the issuer.
+	JWKSURL string `json:"jwksURL,omitempty"`
+	// JWKSFile is the path to a file holding the key set of the issuer.
+	JWKSFile string `json:"jwksFile,omitempty"`
+	// NameClaim is the claim which names the principal of the token. It defaults to sub.
+	NameClaim string `json:"nameClaim,omitempty"`
+	// Rules map the claims of a token to the scope of its principal. The first matching rule applies.
+	Rules []ClaimRule `json:"rules"`
+}
+
+// ClaimRule grants the scope to tokens whose claims match all configured patterns.
+type ClaimRule struct {
+	// Claims maps claim names, for example repository or ref, to patterns the claim value has to match.
+	Claims map[string]string `json:"claims,omitempty"`
+	Scope
+}
+
+// LoadOIDCConfigFile reads the OpenID Connect configuration from the passed YAML or JSON file.
+func LoadOIDCConfigFile(file string) (*OIDCConfig, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	config := &OIDCConfig{}
+	err = yaml.UnmarshalStrict(data, config)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return config, config.validate()
+}
+
+func (config *OIDCConfig) validate() error {
+	for _, issuer := range config.Issuers {
+		if len(issuer.Issuer) == 0 {
+			return fmt.Errorf("Issuer without an issuer URL")
+		}
+		if len(issuer.Audiences) == 0 {
+			return fmt.Errorf("Issuer %s has no audiences", issuer.Issuer)
+		}
+		if len(issuer.JWKSURL) > 0 && len(issuer.JWKSFile) > 0 {
+			return fmt.Errorf("Issuer %s has a JWKS URL and a JWKS file", issuer.Issuer)
+		}
+		for _, rule := range issuer.Rules {
+			for claim, pattern := range rule.Claims {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return fmt.Errorf("Issuer %s: invalid pattern %q for claim %s: %w", issuer.Issuer, pattern, claim, err)
+				}
+			}
+			if err := rule.Scope.validate(); err != nil {
+				return fmt.Errorf("Issuer %s: %w", issuer.Issuer, err)
+			}
+		}
+	}
+	return nil
+}
+
+// NewJWTAuthenticator returns an authenticator accepting bearer ID tokens of the configured issuers. Key set files
+// are read immediately, key set URLs on first use.
+func NewJWTAuthenticator(config *OIDCConfig) (*JWTAuthenticator, error) {
+	authenticator := &JWTAuthenticator{
+		issuers:    map[string]*issuer{},
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		now:        time.Now,
+	}
+	for _, issuerConfig := range config.Issuers {
+		tokenIssuer := &issuer{IssuerConfig: issuerConfig, authenticator: authenticator}
+		if len(issuerConfig.JWKSFile) > 0 {
+			data, err := os.ReadFile(issuerConfig.JWKSFile)
+			if err != nil {
+				return nil, err
+			}
+			tokenIssuer.keys, err = ParseJSONWebKeySet(data)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", issuerConfig.JWKSFile, err)
+			}
+		}
+		authenticator.issuers[issuerConfig.Issuer] = tokenIssuer
+	}
+	return authenticator, nil
+}
+
+// JWTAuthenticator authenticates requests with an ID token sent as bearer token in the Authorization header.
+type JWTAuthenticator struct {
+	issuers    map[string]*issuer
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+// SetHTTPClient replaces the client used to fetch the key sets of the issuers.
+func (authenticator *JWTAuthenticator) SetHTTPClient(client *http.Client) {
+	authenticator.httpClient = client
+}
+
+// Authenticate returns the principal of the ID token sent as bearer token. Requests without a bearer token or with a
+// token of an unknown issuer are not authenticated by this authenticator.
+func (authenticator *JWTAuthenticator) Authenticate(request *http.Request) (*Principal, error) {
+	authorizationData := strings.SplitN(request.Header.Get("Authorization"), " ", 2)
+	if len(authorizationData) != 2 || !strings.EqualFold(authorizationData[0], "Bearer") || !isJWT(authorizationData[1]) {
+		return nil, ErrUnauthenticated
+	}
+	token, err := parseJWT(strings.TrimSpace(authorizationData[1]))
+	if err != nil {
+		return nil, err
+	}
+	issuerName, _ := token.claims.string("iss")
+	tokenIssuer, ok := authenticator.issuers[issuerName]
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+	return tokenIssuer.authenticate(token)
+}
+
+// issuer holds the configuration and the cached key set of a single token issuer.
+type issuer struct {
+	IssuerConfig
+	authenticator *JWTAuthenticator
+	mutex         sync.Mutex
+	keys          map[string][]crypto.PublicKey
+	fetched       time.Time
+}
+
+func (tokenIssuer *issuer) authenticate(token *jsonWebToken) (*Principal, error) {
+	keys, err := tokenIssuer.keysFor(token.header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	err = fmt.Errorf("%w: unknown key %q", ErrInvalidToken, token.header.KeyID)
+	for _, key := range keys {
+		err = token.verify(key)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = token.claims.validateTime(tokenIssuer.authenticator.now())
+	if err != nil {
+		return nil, err
+	}
+	if !token.claims.hasAudience(tokenIssuer.Audiences) {
+		return nil, fmt.Errorf("%w: audience is not accepted", ErrInvalidToken)
+	}
+
+	nameClaim := tokenIssuer.NameClaim
+	if len(nameClaim) == 0 {
+		nameClaim = defaultNameClaim
+	}
+	name, ok := token.claims.string(nameClaim)
+	if !ok || len(name) == 0 {
+		return nil, fmt.Errorf("%w: missing claim %s", ErrInvalidToken, nameClaim)
+	}
+	for _, rule := range tokenIssuer.Rules {
+		if rule.matches(token.claims) {
+			return &Principal{Name: name, Scope: rule.Scope}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNoMatchingRule, name)
+}
+
+func (rule *ClaimRule) matches(claims jwtClaims) bool {
+	for claim, pattern := range rule.Claims {
+		value, ok := claims.string(claim)
+		if !ok {
+			return false
+		}
+		if matched, _ := path.Match(pattern, value); !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// keysFor returns the keys of the issuer for the key id. If the key id is unknown or the key set is outdated, the key
+// set is refetched.
+func (tokenIssuer *issuer) keysFor(keyID string) ([]crypto.PublicKey, error) {
+	tokenIssuer.mutex.Lock()
+	defer tokenIssuer.mutex.Unlock()
+	if len(tokenIssuer.JWKSFile) > 0 {
+		return tokenIssuer.keysWithID(keyID), nil
+	}
+
+	age := tokenIssuer.authenticator.now().Sub(tokenIssuer.fetched)
+	known := len(tokenIssuer.keysWithID(keyID)) > 0
+	if tokenIssuer.keys == nil || age > jwksMaxAge || (!known && age > jwksRefreshInterval) {
+		keys, err := tokenIssuer.fetchKeys()
+		if err != nil && tokenIssuer.keys == nil {
+			return nil, err
+		}
+		if err == nil {
+			tokenIssuer.keys = keys
+			tokenIssuer.fetched = tokenIssuer.authenticator.now()
+		}
+	}
+	return tokenIssuer.keysWithID(keyID), nil
+}
+
+func (tokenIssuer *issuer) keysWithID(keyID string) []crypto.PublicKey {
+	if len(keyID) > 0 {
+		return tokenIssuer.keys[keyID]
+	}
+	keys := make([]crypto.PublicKey, 0)
+	for _, idKeys := range tokenIssuer.keys {
+		keys = append(keys, idKeys...)
+	}
+	return keys
+}
+
+func (tokenIssuer *issuer) fetchKeys() (map[string][]crypto.PublicKey, error) {
+	jwksURL := tokenIssuer.JWKSURL
+	if len(jwksURL) == 0 {
+		discovery := struct {
+			JWKSURI string `json:"jwks_uri"`
+		}{}
+		configurationURL := strings.TrimSuffix(tokenIssuer.Issuer, "/") + "/.well-known/openid-configuration"
+		err := tokenIssuer.getJSON(configurationURL, &discovery)
+		if err != nil {
+			return nil, err
+		}
+		if len(discovery.JWKSURI) == 0 {
+			return nil, fmt.Errorf("The OpenID configuration of %s has no jwks_uri", tokenIssuer.Issuer)
+		}
+		jwksURL = discovery.JWKSURI
+	}
+	data, err := tokenIssuer.get(jwksURL)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJSONWebKeySet(data)
+}
+
+func (tokenIssuer *issuer) getJSON(url string, target interface{}) error {
+	data, err := tokenIssuer.get(url)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
+func (tokenIssuer *issuer) get(url string) ([]byte, error) {
+	response, err := tokenIssuer.authenticator.httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unexpected status code %d fetching %s", response.StatusCode, url)
+	}
+	return io.ReadAll(io.LimitReader(response.Body, 1<<20))
+}
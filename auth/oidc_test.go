@@ -0,0 +1,282 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+const oidcConfigYAML = `
+issuers:
+  - issuer: %s
+    audiences: [kubernetes-update-manager]
+    rules:
+      - claims:
+          repository: xcnt/payments-*
+          ref: refs/heads/main
+        classifiers: [stable]
+        images: [docker.io/xcnt/payments-*]
+      - claims:
+          repository: xcnt/*
+        classifiers: [staging]
+`
+
+type tokenSigner struct {
+	keyID string
+	key   crypto.Signer
+}
+
+func (signer *tokenSigner) sign(c *C, claims map[string]interface{}) string {
+	algorithm := "RS256"
+	if _, ok := signer.key.(*ecdsa.PrivateKey); ok {
+		algorithm = "ES256"
+	}
+	header, _ := json.Marshal(map[string]string{"alg": algorithm, "kid": signer.keyID, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	var signature []byte
+	switch key := signer.key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		c.Assert(err, IsNil)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		c.Assert(err, IsNil)
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (signer *tokenSigner) jwk() map[string]string {
+	encode := func(value *big.Int) string { return base64.RawURLEncoding.EncodeToString(value.Bytes()) }
+	switch key := signer.key.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kty": "RSA", "kid": signer.keyID, "n": encode(key.N), "e": encode(big.NewInt(int64(key.E)))}
+	case *ecdsa.PublicKey:
+		return map[string]string{"kty": "EC", "kid": signer.keyID, "crv": "P-256", "x": encode(key.X), "y": encode(key.Y)}
+	}
+	return nil
+}
+
+func jwks(signers ...*tokenSigner) []byte {
+	keys := make([]map[string]string, 0)
+	for _, signer := range signers {
+		keys = append(keys, signer.jwk())
+	}
+	data, _ := json.Marshal(map[string]interface{}{"keys": keys})
+	return data
+}
+
+type OIDCSuite struct {
+	server        *httptest.Server
+	signers       []*tokenSigner
+	jwksRequests  int
+	authenticator *JWTAuthenticator
+}
+
+var _ = Suite(&OIDCSuite{})
+
+func (suite *OIDCSuite) SetUpTest(c *C) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	suite.signers = []*tokenSigner{{keyID: "rsa", key: rsaKey}, {keyID: "ec", key: ecKey}}
+	suite.jwksRequests = 0
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"jwks_uri": suite.server.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		suite.jwksRequests++
+		w.Write(jwks(suite.signers...))
+	})
+	suite.server = httptest.NewServer(mux)
+	suite.authenticator = suite.newAuthenticator(c, fmt.Sprintf(oidcConfigYAML, suite.server.URL))
+}
+
+func (suite *OIDCSuite) TearDownTest(c *C) {
+	suite.server.Close()
+}
+
+func (suite *OIDCSuite) newAuthenticator(c *C, config string) *JWTAuthenticator {
+	file := filepath.Join(c.MkDir(), "oidc.yaml")
+	c.Assert(os.WriteFile(file, []byte(config), 0600), IsNil)
+	oidcConfig, err := LoadOIDCConfigFile(file)
+	c.Assert(err, IsNil)
+	authenticator, err := NewJWTAuthenticator(oidcConfig)
+	c.Assert(err, IsNil)
+	return authenticator
+}
+
+func (suite *OIDCSuite) claims(overrides map[string]interface{}) map[string]interface{} {
+	claims := map[string]interface{}{
+		"iss":        suite.server.URL,
+		"aud":        "kubernetes-update-manager",
+		"sub":        "repo:xcnt/payments-api:ref:refs/heads/main",
+		"repository": "xcnt/payments-api",
+		"ref":        "refs/heads/main",
+		"iat":        time.Now().Unix(),
+		"exp":        time.Now().Add(5 * time.Minute).Unix(),
+	}
+	for key, value := range overrides {
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+	}
+	return claims
+}
+
+func (suite *OIDCSuite) authenticate(token string) (*Principal, error) {
+	return suite.authenticator.Authenticate(requestWithAuthorization("Bearer " + token))
+}
+
+func (suite *OIDCSuite) TestAuthenticate(c *C) {
+	principal, err := suite.authenticate(suite.signers[0].sign(c, suite.claims(nil)))
+	c.Assert(err, IsNil)
+	c.Assert(principal.Name, Equals, "repo:xcnt/payments-api:ref:refs/heads/main")
+	c.Assert(principal.Classifiers, DeepEquals, []string{"stable"})
+	c.Assert(principal.Images, DeepEquals, []string{"docker.io/xcnt/payments-*"})
+}
+
+func (suite *OIDCSuite) TestAuthenticateECDSA(c *C) {
+	principal, err := suite.authenticate(suite.signers[1].sign(c, suite.claims(nil)))
+	c.Assert(err, IsNil)
+	c.Assert(principal.Classifiers, DeepEquals, []string{"stable"})
+}
+
+func (suite *OIDCSuite) TestAuthenticateSecondRule(c *C) {
+	token := suite.signers[0].sign(c, suite.claims(map[string]interface{}{"ref": "refs/heads/feature"}))
+	principal, err := suite.authenticate(token)
+	c.Assert(err, IsNil)
+	c.Assert(principal.Classifiers, DeepEquals, []string{"staging"})
+}
+
+func (suite *OIDCSuite) TestAuthenticateNoMatchingRule(c *C) {
+	token := suite.signers[0].sign(c, suite.claims(map[string]interface{}{"repository": "other/payments-api"}))
+	_, err := suite.authenticate(token)
+	c.Assert(errors.Is(err, ErrNoMatchingRule), Equals, true)
+}
+
+func (suite *OIDCSuite) TestAuthenticateInvalidClaims(c *C) {
+	for _, overrides := range []map[string]interface{}{
+		{"exp": time.Now().Add(-5 * time.Minute).Unix()},
+		{"exp": nil},
+		{"nbf": time.Now().Add(5 * time.Minute).Unix()},
+		{"aud": "other"},
+		{"aud": []string{"other", "another"}},
+		{"sub": nil},
+	} {
+		_, err := suite.authenticate(suite.signers[0].sign(c, suite.claims(overrides)))
+		c.Assert(errors.Is(err, ErrInvalidToken), Equals, true, Commentf("%v: %v", overrides, err))
+	}
+}
+
+func (suite *OIDCSuite) TestAuthenticateAudienceList(c *C) {
+	token := suite.signers[0].sign(c, suite.claims(map[string]interface{}{"aud": []string{"other", "kubernetes-update-manager"}}))
+	_, err := suite.authenticate(token)
+	c.Assert(err, IsNil)
+}
+
+func (suite *OIDCSuite) TestAuthenticateForgedSignature(c *C) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+	forger := &tokenSigner{keyID: "rsa", key: otherKey}
+	_, err = suite.authenticate(forger.sign(c, suite.claims(nil)))
+	c.Assert(errors.Is(err, ErrInvalidToken), Equals, true)
+}
+
+func (suite *OIDCSuite) TestAuthenticateUnknownIssuer(c *C) {
+	token := suite.signers[0].sign(c, suite.claims(map[string]interface{}{"iss": "https://other.example.com"}))
+	_, err := suite.authenticate(token)
+	c.Assert(err, Equals, ErrUnauthenticated)
+}
+
+func (suite *OIDCSuite) TestAuthenticateNoBearerToken(c *C) {
+	for _, authorization := range []string{"", "APIKey secret", "Bearer secret"} {
+		_, err := suite.authenticator.Authenticate(requestWithAuthorization(authorization))
+		c.Assert(err, Equals, ErrUnauthenticated)
+	}
+}
+
+func (suite *OIDCSuite) TestKeySetIsCached(c *C) {
+	for index := 0; index < 3; index++ {
+		_, err := suite.authenticate(suite.signers[0].sign(c, suite.claims(nil)))
+		c.Assert(err, IsNil)
+	}
+	c.Assert(suite.jwksRequests, Equals, 1)
+}
+
+func (suite *OIDCSuite) TestKeySetRefreshOnRotation(c *C) {
+	_, err := suite.authenticate(suite.signers[0].sign(c, suite.claims(nil)))
+	c.Assert(err, IsNil)
+	rotatedKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	c.Assert(err, IsNil)
+	rotated := &tokenSigner{keyID: "rotated", key: rotatedKey}
+	suite.signers = append(suite.signers, rotated)
+
+	_, err = suite.authenticate(rotated.sign(c, suite.claims(nil)))
+	c.Assert(errors.Is(err, ErrInvalidToken), Equals, true)
+	c.Assert(suite.jwksRequests, Equals, 1)
+
+	suite.authenticator.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	_, err = suite.authenticate(rotated.sign(c, suite.claims(nil)))
+	c.Assert(err, IsNil)
+	c.Assert(suite.jwksRequests, Equals, 2)
+}
+
+func (suite *OIDCSuite) TestKeySetFile(c *C) {
+	file := filepath.Join(c.MkDir(), "jwks.json")
+	c.Assert(os.WriteFile(file, jwks(suite.signers[1]), 0600), IsNil)
+	config := fmt.Sprintf(`
+issuers:
+  - issuer: %s
+    audiences: [kubernetes-update-manager]
+    jwksFile: %s
+    nameClaim: repository
+    rules:
+      - {}
+`, suite.server.URL, file)
+	suite.authenticator = suite.newAuthenticator(c, config)
+	principal, err := suite.authenticate(suite.signers[1].sign(c, suite.claims(nil)))
+	c.Assert(err, IsNil)
+	c.Assert(principal.Name, Equals, "xcnt/payments-api")
+	c.Assert(principal.Classifiers, IsNil)
+	_, err = suite.authenticate(suite.signers[0].sign(c, suite.claims(nil)))
+	c.Assert(errors.Is(err, ErrInvalidToken), Equals, true)
+	c.Assert(suite.jwksRequests, Equals, 0)
+}
+
+func (suite *OIDCSuite) TestLoadOIDCConfigInvalid(c *C) {
+	for config, expected := range map[string]string{
+		"issuers: [{audiences: [a]}]":                                                   "Issuer without an issuer URL",
+		"issuers: [{issuer: https://a}]":                                                "Issuer https://a has no audiences",
+		"issuers: [{issuer: https://a, audiences: [a], jwksURL: u, jwksFile: f}]":       "Issuer https://a has a JWKS URL and a JWKS file",
+		"issuers: [{issuer: https://a, audiences: [a], rules: [{claims: {ref: '['}}]}]": `Issuer https://a: invalid pattern "\[" for claim ref.*`,
+	} {
+		file := filepath.Join(c.MkDir(), "oidc.yaml")
+		c.Assert(os.WriteFile(file, []byte(config), 0600), IsNil)
+		_, err := LoadOIDCConfigFile(file)
+		c.Assert(err, ErrorMatches, expected)
+	}
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"fmt"
+	"path"
+
+	"kubernetes-update-manager/policy"
+	"kubernetes-update-manager/updater"
+)
+
+// Scope restricts which updates a principal may request. An empty list does not restrict the respective property.
+type Scope struct {
+	// Classifiers are patterns of the update classifiers the principal may request updates for, for example staging
+	// or release-*.
+	Classifiers []string `json:"classifiers,omitempty"`
+	// Namespaces are patterns of the namespaces the resources touched by an update may reside in.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Images are patterns of the repositories including their registry, for example docker.io/xcnt/*, the principal
+	// may roll out. A pattern ending with /** matches all repositories below the prefix.
+	Images []string `json:"images,omitempty"`
+	// Privileged principals may override the freeze calendar in emergencies.
+	Privileged bool `json:"privileged,omitempty"`
+}
+
+// Principal is the authenticated identity of a request.
+type Principal struct {
+	// Name identifies the principal, for example the name of the API key.
+	Name string
+	Scope
+}
+
+// CheckUpdate returns an error if the principal must not request an update of the image with the update classifier.
+func (principal *Principal) CheckUpdate(image *updater.Image, updateClassifier string) error {
+	if len(principal.Classifiers) > 0 && !matchesAny(principal.Classifiers, updateClassifier) {
+		return fmt.Errorf("%s is not allowed to update the classifier %s", principal.Name, updateClassifier)
+	}
+	if len(principal.Images) > 0 && !policy.MatchesRepository(principal.Images, image) {
+		return fmt.Errorf("%s is not allowed to update the image %s", principal.Name, image.String())
+	}
+	return nil
+}
+
+// CheckNamespaces returns an error if one of the namespaces is outside of the scope of the principal.
+func (principal *Principal) CheckNamespaces(namespaces []string) error {
+	if len(principal.Namespaces) == 0 {
+		return nil
+	}
+	for _, namespace := range namespaces {
+		if !matchesAny(principal.Namespaces, namespace) {
+			return fmt.Errorf("%s is not allowed to update resources in the namespace %s", principal.Name, namespace)
+		}
+	}
+	return nil
+}
+
+// Restricted returns if the scope restricts the updates in any way.
+func (scope Scope) Restricted() bool {
+	return len(scope.Classifiers) > 0 || len(scope.Namespaces) > 0 || len(scope.Images) > 0
+}
+
+func (scope Scope) validate() error {
+	for _, pattern := range append(append([]string{}, scope.Classifiers...), scope.Namespaces...) {
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			return fmt.Errorf("Invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return policy.ValidateRepositoryPatterns(scope.Images)
+}
+
+func matchesAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
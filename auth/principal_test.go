@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"kubernetes-update-manager/updater"
+
+	. "gopkg.in/check.v1"
+)
+
+type PrincipalSuite struct {
+	principal *Principal
+}
+
+var _ = Suite(&PrincipalSuite{})
+
+func (suite *PrincipalSuite) SetUpTest(c *C) {
+	suite.principal = &Principal{
+		Name: "payments-ci",
+		Scope: Scope{
+			Classifiers: []string{"staging", "release-*"},
+			Namespaces:  []string{"payments", "payments-*"},
+			Images:      []string{"docker.io/xcnt/payments-*"},
+		},
+	}
+}
+
+func (suite *PrincipalSuite) TestCheckUpdate(c *C) {
+	err := suite.principal.CheckUpdate(updater.NewImage("xcnt/payments-api:1.0.0"), "release-1")
+	c.Assert(err, IsNil)
+}
+
+func (suite *PrincipalSuite) TestCheckUpdateClassifierOutOfScope(c *C) {
+	err := suite.principal.CheckUpdate(updater.NewImage("xcnt/payments-api:1.0.0"), "stable")
+	c.Assert(err, ErrorMatches, "payments-ci is not allowed to update the classifier stable")
+}
+
+func (suite *PrincipalSuite) TestCheckUpdateImageOutOfScope(c *C) {
+	err := suite.principal.CheckUpdate(updater.NewImage("xcnt/search:1.0.0"), "staging")
+	c.Assert(err, ErrorMatches, "payments-ci is not allowed to update the image xcnt/search:1.0.0")
+}
+
+func (suite *PrincipalSuite) TestCheckNamespaces(c *C) {
+	c.Assert(suite.principal.CheckNamespaces([]string{"payments", "payments-jobs"}), IsNil)
+}
+
+func (suite *PrincipalSuite) TestCheckNamespacesOutOfScope(c *C) {
+	err := suite.principal.CheckNamespaces([]string{"payments", "search"})
+	c.Assert(err, ErrorMatches, "payments-ci is not allowed to update resources in the namespace search")
+}
+
+func (suite *PrincipalSuite) TestUnrestricted(c *C) {
+	principal := &Principal{Name: "admin"}
+	c.Assert(principal.CheckUpdate(updater.NewImage("nginx:1.21"), "stable"), IsNil)
+	c.Assert(principal.CheckNamespaces([]string{"kube-system"}), IsNil)
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"kubernetes-update-manager/client"
+	"kubernetes-update-manager/updater/manager"
+	"kubernetes-update-manager/web"
+	"strings"
+
+	"github.com/gookit/color"
+	cli "github.com/urfave/cli/v2"
+)
+
+var (
+	// FlagReason describes why an update is rejected
+	FlagReason = &cli.StringFlag{
+		Name:    "reason",
+		Usage:   "Why the update is rejected.",
+		EnvVars: []string{"UPDATE_MANAGER_REJECT_REASON"},
+	}
+
+	// ErrNoUpdateUUID is returned if the uuid of the update to decide about has not been passed
+	ErrNoUpdateUUID = errors.New("The uuid of the update was not provided")
+)
+
+// ApproveCommand approves an update waiting for approval on a remote server
+func ApproveCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "approve",
+		Usage:     "Approves an update waiting for approval on a remote server",
+		ArgsUsage: "<update uuid>",
+		Flags:     ConnectionFlags(),
+		Action:    ApproveAction,
+	}
+}
+
+// RejectCommand rejects an update waiting for approval on a remote server
+func RejectCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "reject",
+		Usage:     "Rejects an update waiting for approval on a remote server",
+		ArgsUsage: "<update uuid>",
+		Flags:     append([]cli.Flag{FlagReason}, ConnectionFlags()...),
+		Action:    RejectAction,
+	}
+}
+
+// ApproveAction is the action which is executed when the approve command is picked.
+func ApproveAction(c *cli.Context) error {
+	updateExecution, err := updateExecutionFromContext(c)
+	if err != nil {
+		return err
+	}
+	updateProgress, err := updateExecution.Approve()
+	if err != nil {
+		return err
+	}
+	printDecision(updateProgress, "Approved")
+	return nil
+}
+
+// RejectAction is the action which is executed when the reject command is picked.
+func RejectAction(c *cli.Context) error {
+	updateExecution, err := updateExecutionFromContext(c)
+	if err != nil {
+		return err
+	}
+	updateProgress, err := updateExecution.Reject(strings.TrimSpace(c.String(FlagReason.Name)))
+	if err != nil {
+		return err
+	}
+	printDecision(updateProgress, "Rejected")
+	return nil
+}
+
+// updateExecutionFromContext returns the execution of the update whose uuid has been passed as argument.
+func updateExecutionFromContext(c *cli.Context) (*client.UpdateExecution, error) {
+	updateUUID := strings.TrimSpace(c.Args().First())
+	if len(updateUUID) == 0 {
+		return nil, ErrNoUpdateUUID
+	}
+	updateCommand, err := updateCommandFromContext(c)
+	if err != nil {
+		return nil, err
+	}
+	err = verifyConnection(updateCommand)
+	if err != nil {
+		return nil, err
+	}
+	return client.NewUpdateExecutionFor(updateCommand, updateUUID), nil
+}
+
+func printDecision(updateProgress *web.UpdateProgressSerialized, decision string) {
+	message := fmt.Sprintf("%s update %s, it is now %s", decision, updateProgress.UUID, updateProgress.Status.State)
+	if approval := updateProgress.Approval; approval != nil && updateProgress.Status.State == string(manager.StatePendingApproval) {
+		message += fmt.Sprintf(" with %d of %d approvals", len(approval.Approvers), approval.RequiredApprovers)
+	}
+	color.FgGreen.Println(message)
+}
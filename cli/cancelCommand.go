@@ -0,0 +1,30 @@
+package cli
+
+import (
+	cli "github.com/urfave/cli/v2"
+)
+
+// CancelCommand cancels an update waiting to be started on a remote server
+func CancelCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "cancel",
+		Usage:     "Cancels an update waiting for approval, its start time or conflicting updates on a remote server",
+		ArgsUsage: "<update uuid>",
+		Flags:     ConnectionFlags(),
+		Action:    CancelAction,
+	}
+}
+
+// CancelAction is the action which is executed when the cancel command is picked.
+func CancelAction(c *cli.Context) error {
+	updateExecution, err := updateExecutionFromContext(c)
+	if err != nil {
+		return err
+	}
+	updateProgress, err := updateExecution.Cancel()
+	if err != nil {
+		return err
+	}
+	printDecision(updateProgress, "Cancelled")
+	return nil
+}
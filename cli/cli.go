@@ -48,6 +48,10 @@ func New() *cli.App {
 		Commands: []*cli.Command{
 			ServerCommand(),
 			UpdateCommand(),
+			ApproveCommand(),
+			RejectCommand(),
+			CancelCommand(),
+			RollbackCommand(),
 		},
 	}
 	return app
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"github.com/gookit/color"
+	cli "github.com/urfave/cli/v2"
+)
+
+var (
+	// FlagForceRollback discards changes of the deployments made since the rolled back update
+	FlagForceRollback = &cli.BoolFlag{
+		Name:    "force",
+		Usage:   "Roll back deployments which have been changed since the update and discard their changes.",
+		EnvVars: []string{"UPDATE_MANAGER_FORCE_ROLLBACK"},
+	}
+)
+
+// RollbackCommand rolls back a successful update on a remote server
+func RollbackCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "rollback",
+		Usage:     "Restores the deployments of a successful update on a remote server to the pod templates they ran before",
+		ArgsUsage: "<update uuid>",
+		Flags:     append([]cli.Flag{FlagForceRollback}, ConnectionFlags()...),
+		Action:    RollbackAction,
+	}
+}
+
+// RollbackAction is the action which is executed when the rollback command is picked.
+func RollbackAction(c *cli.Context) error {
+	updateExecution, err := updateExecutionFromContext(c)
+	if err != nil {
+		return err
+	}
+	updateProgress, err := updateExecution.Rollback(c.Bool(FlagForceRollback.Name))
+	if err != nil {
+		return err
+	}
+	color.FgGreen.Printf("Rolling back update %s with update %s, it is now %s\n", updateProgress.RollbackOf, updateProgress.UUID, updateProgress.Status.State)
+	return nil
+}
@@ -3,7 +3,16 @@ package cli
 import (
 	"errors"
 	"fmt"
+	"kubernetes-update-manager/audit"
+	"kubernetes-update-manager/auth"
+	"kubernetes-update-manager/policy"
+	"kubernetes-update-manager/prometheus"
+	"kubernetes-update-manager/signature"
+	"kubernetes-update-manager/updater"
+	"kubernetes-update-manager/updater/manager"
 	"kubernetes-update-manager/web"
+	"net/http"
+	"os"
 	"strings"
 
 	"github.com/getsentry/raven-go"
@@ -50,12 +59,86 @@ var (
 		Usage:       "A list of namespaces which should be scanned for an update. This is only used if autload of namespaces has been switched off.",
 		EnvVars:     []string{"UPDATE_MANAGER_NAMESPACES"},
 	}
+	// FlagNamespaceSelector chooses the namespaces which should be scanned by their labels.
+	FlagNamespaceSelector = &cli.StringFlag{
+		Name:    "namespace-selector",
+		Usage:   "A kubernetes label selector choosing the namespaces which should be scanned for an update. If set, the autoload and namespaces options are ignored.",
+		EnvVars: []string{"UPDATE_MANAGER_NAMESPACE_SELECTOR"},
+	}
 	// FlagAPIKey specifies the pre-shared API key to use the update manager instance.
 	FlagAPIKey = &cli.StringFlag{
 		Name:    "api-key",
-		Usage:   "The pre-shared API key used to authenticate API calls. This is a required field and must be set.",
+		Usage:   "The pre-shared API key used to authenticate API calls. On the server, requests authenticated with it are not restricted and it is required unless an API keys file or an OIDC configuration is set.",
 		EnvVars: []string{"UPDATE_MANAGER_API_KEY"},
 	}
+	// FlagAPIKeysFile specifies the file or directory the named and scoped API keys are loaded from.
+	FlagAPIKeysFile = &cli.StringFlag{
+		Name:    "api-keys-file",
+		Usage:   "Path to a YAML file or a directory, for example a mounted secret, with named API keys which are restricted to update classifiers, namespaces and images.",
+		EnvVars: []string{"UPDATE_MANAGER_API_KEYS_FILE"},
+	}
+	// FlagRequireSignedRequests rejects requests which send the API key verbatim instead of signing the request.
+	FlagRequireSignedRequests = &cli.BoolFlag{
+		Name:    "require-signed-requests",
+		Usage:   "Only accept API keys as HMAC-SHA256 signatures of the requests and reject requests sending the key itself.",
+		EnvVars: []string{"UPDATE_MANAGER_REQUIRE_SIGNED_REQUESTS"},
+	}
+	// FlagMaxClockSkew configures how old or how far in the future the timestamp of a signed request may be.
+	FlagMaxClockSkew = &cli.DurationFlag{
+		Name:    "max-clock-skew",
+		Value:   auth.DefaultMaxClockSkew,
+		Usage:   "The maximal difference between the timestamp of a signed request and the server time.",
+		EnvVars: []string{"UPDATE_MANAGER_MAX_CLOCK_SKEW"},
+	}
+	// FlagTLSCert specifies the certificate the server uses for TLS.
+	FlagTLSCert = &cli.StringFlag{
+		Name:    "tls-cert",
+		Usage:   "Path to the PEM encoded TLS certificate chain of the server. If set, the server listens with TLS and reloads the certificate once the file changes.",
+		EnvVars: []string{"UPDATE_MANAGER_TLS_CERT"},
+	}
+	// FlagTLSKey specifies the private key of the TLS certificate of the server.
+	FlagTLSKey = &cli.StringFlag{
+		Name:    "tls-key",
+		Usage:   "Path to the PEM encoded private key of the TLS certificate of the server.",
+		EnvVars: []string{"UPDATE_MANAGER_TLS_KEY"},
+	}
+	// FlagTLSClientCA specifies the CA certificates client certificates are verified against.
+	FlagTLSClientCA = &cli.StringFlag{
+		Name:    "tls-client-ca",
+		Usage:   "Path to the PEM encoded CA certificates client certificates are verified against. If set, clients may authenticate with a certificate.",
+		EnvVars: []string{"UPDATE_MANAGER_TLS_CLIENT_CA"},
+	}
+	// FlagTLSRequireClientCert rejects TLS connections without a verified client certificate.
+	FlagTLSRequireClientCert = &cli.BoolFlag{
+		Name:    "tls-require-client-cert",
+		Usage:   "Reject TLS connections without a client certificate issued by the client CA.",
+		EnvVars: []string{"UPDATE_MANAGER_TLS_REQUIRE_CLIENT_CERT"},
+	}
+	// FlagClientCertificatesFile specifies the file mapping client certificate subjects to scopes.
+	FlagClientCertificatesFile = &cli.StringFlag{
+		Name:    "client-certificates-file",
+		Usage:   "Path to a YAML file mapping the subjects of verified client certificates to the update classifiers, namespaces and images they may update.",
+		EnvVars: []string{"UPDATE_MANAGER_CLIENT_CERTIFICATES_FILE"},
+	}
+	// FlagOIDCConfigFile specifies the file configuring the accepted OpenID Connect ID token issuers.
+	FlagOIDCConfigFile = &cli.StringFlag{
+		Name:    "oidc-config-file",
+		Usage:   "Path to a YAML file configuring the OpenID Connect issuers whose ID tokens are accepted as bearer tokens and the scopes granted to their claims.",
+		EnvVars: []string{"UPDATE_MANAGER_OIDC_CONFIG_FILE"},
+	}
+	// FlagAuditLogSize configures how many audit entries are kept in memory for the audit endpoint.
+	FlagAuditLogSize = &cli.IntFlag{
+		Name:    "audit-log-size",
+		Value:   audit.DefaultCapacity,
+		Usage:   "The number of audit entries which are kept in memory and can be queried with the audit endpoint.",
+		EnvVars: []string{"UPDATE_MANAGER_AUDIT_LOG_SIZE"},
+	}
+	// FlagAuditEvents enables kubernetes events for the audit entries.
+	FlagAuditEvents = &cli.BoolFlag{
+		Name:    "audit-events",
+		Usage:   "Additionally record the audit entries as kubernetes events on the affected deployments.",
+		EnvVars: []string{"UPDATE_MANAGER_AUDIT_EVENTS"},
+	}
 	// FlagSentryDSN is used to configure the endpoint where sentry error messages should be sent to if there is an error in the process.
 	FlagSentryDSN = &cli.StringFlag{
 		Name:    "sentry-dsn",
@@ -63,8 +146,108 @@ var (
 		EnvVars: []string{"SENTRY_DSN"},
 	}
 
+	// FlagSignatureVerification specifies the default mode of the image signature verification.
+	FlagSignatureVerification = &cli.StringFlag{
+		Name:        "signature-verification",
+		Value:       string(signature.ModeOff),
+		DefaultText: "off",
+		Usage:       "The default mode of the cosign signature verification of updated images. This can be off, warn or enforce.",
+		EnvVars:     []string{"UPDATE_MANAGER_SIGNATURE_VERIFICATION"},
+	}
+	// FlagSignatureNamespaces configures the signature verification mode for specific namespaces.
+	FlagSignatureNamespaces = &cli.StringSliceFlag{
+		Name:        "signature-verification-namespaces",
+		Value:       cli.NewStringSlice(),
+		DefaultText: "empty",
+		Usage:       "A list of namespace=mode entries overriding the signature verification mode for updates touching the namespace.",
+		EnvVars:     []string{"UPDATE_MANAGER_SIGNATURE_VERIFICATION_NAMESPACES"},
+	}
+	// FlagSignatureClassifiers configures the signature verification mode for specific update classifiers.
+	FlagSignatureClassifiers = &cli.StringSliceFlag{
+		Name:        "signature-verification-classifiers",
+		Value:       cli.NewStringSlice(),
+		DefaultText: "empty",
+		Usage:       "A list of classifier=mode entries overriding the signature verification mode for updates with the classifier.",
+		EnvVars:     []string{"UPDATE_MANAGER_SIGNATURE_VERIFICATION_CLASSIFIERS"},
+	}
+	// FlagSignaturePublicKeys lists the public keys which are accepted for image signatures.
+	FlagSignaturePublicKeys = &cli.StringSliceFlag{
+		Name:        "signature-public-keys",
+		Value:       cli.NewStringSlice(),
+		DefaultText: "empty",
+		Usage:       "Paths to PEM encoded cosign public keys or directories containing them, for example a mounted secret.",
+		EnvVars:     []string{"UPDATE_MANAGER_SIGNATURE_PUBLIC_KEYS"},
+	}
+	// FlagRegistryPolicyFile points to the policy restricting which images can be rolled out.
+	FlagRegistryPolicyFile = &cli.StringFlag{
+		Name:    "registry-policy-file",
+		Usage:   "Path to a YAML file listing the registries and repositories images may be rolled out from, optionally restricted per update classifier.",
+		EnvVars: []string{"UPDATE_MANAGER_REGISTRY_POLICY_FILE"},
+	}
+	// FlagApprovalPolicyFile points to the policy holding updates of protected update classifiers for approval.
+	FlagApprovalPolicyFile = &cli.StringFlag{
+		Name:    "approval-policy-file",
+		Usage:   "Path to a YAML file configuring per update classifier how many other principals need to approve an update before it is started.",
+		EnvVars: []string{"UPDATE_MANAGER_APPROVAL_POLICY_FILE"},
+	}
+	// FlagPromotionPolicyFile points to the policy promoting the images of successful updates to further update classifiers.
+	FlagPromotionPolicyFile = &cli.StringFlag{
+		Name:    "promotion-policy-file",
+		Usage:   "Path to a YAML file configuring per update classifier to which update classifier and after which delay successful updates are promoted.",
+		EnvVars: []string{"UPDATE_MANAGER_PROMOTION_POLICY_FILE"},
+	}
+	// FlagPrometheusURL specifies the Prometheus server the metrics of updated deployments are analyzed with.
+	FlagPrometheusURL = &cli.StringFlag{
+		Name:    "prometheus-url",
+		Usage:   "Base URL of the Prometheus server, for example http://prometheus.monitoring:9090, whose metrics are analyzed before an update finishes.",
+		EnvVars: []string{"UPDATE_MANAGER_PROMETHEUS_URL"},
+	}
+	// FlagAnalysisPolicyFile points to the policy configuring the metric analysis per update classifier.
+	FlagAnalysisPolicyFile = &cli.StringFlag{
+		Name:    "analysis-policy-file",
+		Usage:   "Path to a YAML file configuring per update classifier the Prometheus queries and thresholds updated deployments are analyzed with.",
+		EnvVars: []string{"UPDATE_MANAGER_ANALYSIS_POLICY_FILE"},
+	}
+	// FlagFreezeCalendarFile points to the calendar restricting when updates may be started.
+	FlagFreezeCalendarFile = &cli.StringFlag{
+		Name:    "freeze-calendar-file",
+		Usage:   "Path to a YAML file configuring freeze windows and maintenance windows per update classifier or namespace in which updates are rejected or queued.",
+		EnvVars: []string{"UPDATE_MANAGER_FREEZE_CALENDAR_FILE"},
+	}
+	// FlagConflictMode specifies how updates conflicting with a queued or running update are handled.
+	FlagConflictMode = &cli.StringFlag{
+		Name:    "conflict-mode",
+		Usage:   "Either queue to start updates touching the update classifier or deployments of a queued or running update once it finished, or reject to refuse them.",
+		Value:   string(manager.ConflictModeQueue),
+		EnvVars: []string{"UPDATE_MANAGER_CONFLICT_MODE"},
+	}
+	// FlagRollbackPolicy specifies which deployments of a failed update are rolled back.
+	FlagRollbackPolicy = &cli.StringFlag{
+		Name:    "rollback-policy",
+		Usage:   "Either rollback-all, rollback-failed-only or no-rollback to choose which deployments of a failed update are rolled back, unless a deployment overrides it with the xcnt.io/rollback-policy annotation.",
+		Value:   string(updater.RollbackAll),
+		EnvVars: []string{"UPDATE_MANAGER_ROLLBACK_POLICY"},
+	}
+	// FlagRollbackRetention specifies how long successful updates can be rolled back after they finished.
+	FlagRollbackRetention = &cli.DurationFlag{
+		Name:    "rollback-retention",
+		Usage:   "The time successful updates can be rolled back for after they finished.",
+		Value:   manager.DefaultRollbackRetention,
+		EnvVars: []string{"UPDATE_MANAGER_ROLLBACK_RETENTION"},
+	}
+
 	// ErrNoAPIKey is returned if no API Key has been provided for authentication purposes.
-	ErrNoAPIKey = errors.New("No API key provided for authenticating the server")
+	ErrNoAPIKey = errors.New("No API key, API keys file, client certificates file or OIDC configuration provided for authenticating the server")
+	// ErrIncompleteTLSConfiguration is returned if only one of the TLS certificate and key is provided.
+	ErrIncompleteTLSConfiguration = errors.New("The TLS certificate and key have to be provided together")
+	// ErrClientCertificatesWithoutTLS is returned if client certificates are configured without a client CA.
+	ErrClientCertificatesWithoutTLS = errors.New("Client certificates require the TLS certificate, key and client CA of the server")
+	// ErrNoSignaturePublicKeys is returned if the signature verification is enabled without any public keys.
+	ErrNoSignaturePublicKeys = errors.New("The signature verification is enabled but no public keys have been provided")
+	// ErrInvalidConflictMode is returned if the conflict mode is neither queue nor reject.
+	ErrInvalidConflictMode = errors.New("The conflict mode has to be either queue or reject")
+	// ErrAnalysisWithoutPrometheus is returned if an analysis policy is configured without a Prometheus server.
+	ErrAnalysisWithoutPrometheus = errors.New("The analysis policy requires the URL of a Prometheus server")
 )
 
 // ServerCommand returns the command which shoudl be added to the CLI to run the server.
@@ -93,13 +276,23 @@ func ServerAction(c *cli.Context) error {
 	}
 
 	server := web.GetWeb(config)
+	go web.SweepStandbyDeployments(config)
 	host := c.String(FlagHost.Name)
 	if host != "0.0.0.0" {
 		host = fmt.Sprintf(":%d", c.Int(FlagPort.Name))
 	} else {
 		host = fmt.Sprintf("%s:%d", host, c.Int(FlagPort.Name))
 	}
-	err = server.Run(host)
+	if config.TLS != nil {
+		tlsConfig, tlsErr := web.NewTLSConfig(config.TLS)
+		if tlsErr != nil {
+			return tlsErr
+		}
+		httpServer := &http.Server{Addr: host, Handler: server, TLSConfig: tlsConfig}
+		err = httpServer.ListenAndServeTLS("", "")
+	} else {
+		err = server.Run(host)
+	}
 	if err != nil {
 		fmt.Println(err.Error())
 	}
@@ -110,12 +303,86 @@ func ServerAction(c *cli.Context) error {
 func webConfigFromContext(c *cli.Context) (*web.Config, error) {
 	config := web.Config{}
 	config.APIKey = strings.TrimSpace(c.String(FlagAPIKey.Name))
-	if len(config.APIKey) == 0 {
+	if apiKeysFile := c.String(FlagAPIKeysFile.Name); len(apiKeysFile) > 0 {
+		apiKeys, err := auth.LoadAPIKeys(apiKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		config.APIKeys = apiKeys
+	}
+	config.RequireSignedRequests = c.Bool(FlagRequireSignedRequests.Name)
+	config.MaxClockSkew = c.Duration(FlagMaxClockSkew.Name)
+	err := tlsConfigFromContext(c, &config)
+	if err != nil {
+		return nil, err
+	}
+	if oidcConfigFile := c.String(FlagOIDCConfigFile.Name); len(oidcConfigFile) > 0 {
+		oidcConfig, err := auth.LoadOIDCConfigFile(oidcConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		authenticator, err := auth.NewJWTAuthenticator(oidcConfig)
+		if err != nil {
+			return nil, err
+		}
+		config.Authenticators = append(config.Authenticators, authenticator)
+	}
+	if len(config.APIKey) == 0 && len(config.APIKeys) == 0 && len(config.Authenticators) == 0 {
 		return nil, ErrNoAPIKey
 	}
 
 	config.AutoloadNamespaces = c.Bool(FlagAutoloadNamespaces.Name)
 	config.Namespaces = c.StringSlice(FlagNamespaces.Name)
+	config.NamespaceSelector = strings.TrimSpace(c.String(FlagNamespaceSelector.Name))
+	config.LabelSelector = strings.TrimSpace(c.String(FlagLabelSelector.Name))
+	for _, labelSelector := range []string{config.NamespaceSelector, config.LabelSelector} {
+		err := updater.ValidateLabelSelector(labelSelector)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = signatureConfigFromContext(c, &config)
+	if err != nil {
+		return nil, err
+	}
+	if registryPolicyFile := c.String(FlagRegistryPolicyFile.Name); len(registryPolicyFile) > 0 {
+		config.RegistryPolicy, err = policy.LoadRegistryPolicyFile(registryPolicyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if approvalPolicyFile := c.String(FlagApprovalPolicyFile.Name); len(approvalPolicyFile) > 0 {
+		config.ApprovalPolicy, err = policy.LoadApprovalPolicyFile(approvalPolicyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if promotionPolicyFile := c.String(FlagPromotionPolicyFile.Name); len(promotionPolicyFile) > 0 {
+		config.PromotionPolicy, err = policy.LoadPromotionPolicyFile(promotionPolicyFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = analysisConfigFromContext(c, &config)
+	if err != nil {
+		return nil, err
+	}
+	if freezeCalendarFile := c.String(FlagFreezeCalendarFile.Name); len(freezeCalendarFile) > 0 {
+		config.FreezeCalendar, err = policy.LoadFreezeCalendarFile(freezeCalendarFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	config.ConflictMode = manager.ConflictMode(strings.TrimSpace(c.String(FlagConflictMode.Name)))
+	if config.ConflictMode != manager.ConflictModeQueue && config.ConflictMode != manager.ConflictModeReject {
+		return nil, ErrInvalidConflictMode
+	}
+	config.RollbackPolicy, err = updater.ParseRollbackPolicy(strings.TrimSpace(c.String(FlagRollbackPolicy.Name)))
+	if err != nil {
+		return nil, err
+	}
+	config.RollbackRetention = c.Duration(FlagRollbackRetention.Name)
 
 	kuberneteConfig, err := rest.InClusterConfig()
 	if err != nil {
@@ -126,9 +393,106 @@ func webConfigFromContext(c *cli.Context) (*web.Config, error) {
 		return nil, err
 	}
 	config.Clientset = clientset
+	config.AuditLog = auditLogFromContext(c, clientset)
 	return &config, nil
 }
 
+// auditLogFromContext returns the audit log writing its entries as JSON lines to stdout and, if enabled, as
+// kubernetes events on the affected deployments.
+func auditLogFromContext(c *cli.Context, clientset kubernetes.Interface) *audit.Log {
+	sinks := []audit.Sink{audit.NewJSONSink(os.Stdout)}
+	if c.Bool(FlagAuditEvents.Name) {
+		sinks = append(sinks, audit.NewEventSink(clientset))
+	}
+	return audit.NewLog(c.Int(FlagAuditLogSize.Name), sinks...)
+}
+
+// signatureConfigFromContext reads the signature verification policy and the public keys into the web configuration.
+func signatureConfigFromContext(c *cli.Context, config *web.Config) error {
+	defaultMode, err := signature.ParseMode(c.String(FlagSignatureVerification.Name))
+	if err != nil {
+		return err
+	}
+	policy := signature.NewPolicy(defaultMode)
+	policy.Namespaces, err = signature.ParseModeAssignments(c.StringSlice(FlagSignatureNamespaces.Name))
+	if err != nil {
+		return err
+	}
+	policy.Classifiers, err = signature.ParseModeAssignments(c.StringSlice(FlagSignatureClassifiers.Name))
+	if err != nil {
+		return err
+	}
+	if !policy.IsActive() {
+		return nil
+	}
+
+	keyPaths := c.StringSlice(FlagSignaturePublicKeys.Name)
+	if len(keyPaths) == 0 {
+		return ErrNoSignaturePublicKeys
+	}
+	publicKeys, err := signature.LoadPublicKeys(keyPaths)
+	if err != nil {
+		return err
+	}
+	config.SignaturePolicy = policy
+	config.SignatureVerifier = signature.NewVerifier(publicKeys)
+	return nil
+}
+
+// analysisConfigFromContext configures the Prometheus server and the policy the metrics of updated deployments are
+// analyzed with.
+func analysisConfigFromContext(c *cli.Context, config *web.Config) error {
+	prometheusURL := strings.TrimSpace(c.String(FlagPrometheusURL.Name))
+	analysisPolicyFile := strings.TrimSpace(c.String(FlagAnalysisPolicyFile.Name))
+	if len(prometheusURL) == 0 {
+		if len(analysisPolicyFile) > 0 {
+			return ErrAnalysisWithoutPrometheus
+		}
+		return nil
+	}
+	config.Prometheus = prometheus.NewClient(prometheusURL, nil)
+	if len(analysisPolicyFile) == 0 {
+		return nil
+	}
+	var err error
+	config.AnalysisPolicy, err = policy.LoadAnalysisPolicyFile(analysisPolicyFile)
+	return err
+}
+
+// tlsConfigFromContext configures the TLS listener and the client certificate authentication of the server.
+func tlsConfigFromContext(c *cli.Context, config *web.Config) error {
+	certFile := strings.TrimSpace(c.String(FlagTLSCert.Name))
+	keyFile := strings.TrimSpace(c.String(FlagTLSKey.Name))
+	clientCAFile := strings.TrimSpace(c.String(FlagTLSClientCA.Name))
+	clientCertificatesFile := strings.TrimSpace(c.String(FlagClientCertificatesFile.Name))
+	if (len(certFile) > 0) != (len(keyFile) > 0) {
+		return ErrIncompleteTLSConfiguration
+	}
+	if len(certFile) == 0 {
+		if len(clientCAFile) > 0 || len(clientCertificatesFile) > 0 || c.Bool(FlagTLSRequireClientCert.Name) {
+			return ErrClientCertificatesWithoutTLS
+		}
+		return nil
+	}
+	if len(clientCAFile) == 0 && (len(clientCertificatesFile) > 0 || c.Bool(FlagTLSRequireClientCert.Name)) {
+		return ErrClientCertificatesWithoutTLS
+	}
+	config.TLS = &web.TLSConfig{
+		CertFile:          certFile,
+		KeyFile:           keyFile,
+		ClientCAFile:      clientCAFile,
+		RequireClientCert: c.Bool(FlagTLSRequireClientCert.Name),
+	}
+	if len(clientCertificatesFile) > 0 {
+		rules, err := auth.LoadClientCertificateRules(clientCertificatesFile)
+		if err != nil {
+			return err
+		}
+		config.Authenticators = append(config.Authenticators, auth.NewClientCertificateAuthenticator(rules))
+	}
+	return nil
+}
+
 // ServerFlags returns the cli Flags for the server configuration.
 func ServerFlags() []cli.Flag {
 	return []cli.Flag{
@@ -136,7 +500,33 @@ func ServerFlags() []cli.Flag {
 		FlagPort,
 		FlagAutoloadNamespaces,
 		FlagNamespaces,
+		FlagNamespaceSelector,
+		FlagLabelSelector,
 		FlagAPIKey,
+		FlagAPIKeysFile,
+		FlagRequireSignedRequests,
+		FlagMaxClockSkew,
+		FlagOIDCConfigFile,
+		FlagTLSCert,
+		FlagTLSKey,
+		FlagTLSClientCA,
+		FlagTLSRequireClientCert,
+		FlagClientCertificatesFile,
+		FlagAuditLogSize,
+		FlagAuditEvents,
 		FlagSentryDSN,
+		FlagSignatureVerification,
+		FlagSignatureNamespaces,
+		FlagSignatureClassifiers,
+		FlagSignaturePublicKeys,
+		FlagRegistryPolicyFile,
+		FlagApprovalPolicyFile,
+		FlagPromotionPolicyFile,
+		FlagPrometheusURL,
+		FlagAnalysisPolicyFile,
+		FlagFreezeCalendarFile,
+		FlagConflictMode,
+		FlagRollbackPolicy,
+		FlagRollbackRetention,
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"kubernetes-update-manager/client"
+	"kubernetes-update-manager/updater/manager"
 	"kubernetes-update-manager/web"
 	"os"
 	"strings"
@@ -36,6 +37,104 @@ var (
 		Usage:   "The update classifier which should be sent to the server for update.",
 		EnvVars: []string{"UPDATE_MANAGER_UPDATE_CLASSIFIER", "UPDATE_MANAGER_CLASSIFIER"},
 	}
+	// FlagForce requests the update manager to ignore the semantic version guards of the workloads
+	FlagForce = &cli.BoolFlag{
+		Name:    "force",
+		Usage:   "Ignore the semantic version guards of the workloads and allow downgrades.",
+		EnvVars: []string{"UPDATE_MANAGER_FORCE"},
+	}
+	// FlagLabelSelector restricts the deployments and jobs considered for an update
+	FlagLabelSelector = &cli.StringFlag{
+		Name:    "label-selector",
+		Aliases: []string{"l"},
+		Usage:   "A kubernetes label selector the deployments and jobs need to match to be considered for an update.",
+		EnvVars: []string{"UPDATE_MANAGER_LABEL_SELECTOR"},
+	}
+	// FlagRevision is the revision the image has been built from
+	FlagRevision = &cli.StringFlag{
+		Name:    "revision",
+		Usage:   "The revision, for example the commit SHA, the image has been built from. It is stamped on the updated deployments.",
+		EnvVars: []string{"UPDATE_MANAGER_REVISION"},
+	}
+	// FlagChangeCause describes the update in the rollout history of the updated deployments
+	FlagChangeCause = &cli.StringFlag{
+		Name:    "change-cause",
+		Usage:   "A description of the update shown by kubectl rollout history of the updated deployments.",
+		EnvVars: []string{"UPDATE_MANAGER_CHANGE_CAUSE"},
+	}
+	// FlagNotBefore schedules the update for a later time
+	FlagNotBefore = &cli.StringFlag{
+		Name:    "not-before",
+		Usage:   "The RFC 3339 time, for example 2024-05-03T02:00:00+02:00, the update is scheduled for. The command returns once the update has been scheduled.",
+		EnvVars: []string{"UPDATE_MANAGER_NOT_BEFORE"},
+	}
+	// FlagCanaryWeight soaks the new image in canaries with the percentage of the pods before updating the deployments
+	FlagCanaryWeight = &cli.IntFlag{
+		Name:    "canary-weight",
+		Usage:   "The percentage of the pods, between 1 and 99, which run the new image in canaries before the deployments are updated. The deployments are updated directly if not set.",
+		EnvVars: []string{"UPDATE_MANAGER_CANARY_WEIGHT"},
+	}
+	// FlagCanarySoakTime is the time the canaries have to stay healthy before the deployments are updated
+	FlagCanarySoakTime = &cli.DurationFlag{
+		Name:    "canary-soak-time",
+		Usage:   "The time, for example 10m, the canaries have to stay healthy before the deployments are updated. Defaults to 5m on the update manager.",
+		EnvVars: []string{"UPDATE_MANAGER_CANARY_SOAK_TIME"},
+	}
+	// FlagCanaryMaxRestarts is the number of container restarts of the canary pods which are tolerated
+	FlagCanaryMaxRestarts = &cli.IntFlag{
+		Name:    "canary-max-restarts",
+		Usage:   "The number of container restarts of the canary pods which are tolerated before the update is rolled back.",
+		EnvVars: []string{"UPDATE_MANAGER_CANARY_MAX_RESTARTS"},
+	}
+	// FlagOverrideFreeze starts the update in an emergency even though the freeze calendar blocks it
+	FlagOverrideFreeze = &cli.BoolFlag{
+		Name:    "override-freeze",
+		Usage:   "Start the update in an emergency even though the freeze calendar of the update manager blocks it. Requires privileged credentials.",
+		EnvVars: []string{"UPDATE_MANAGER_OVERRIDE_FREEZE"},
+	}
+	// FlagSignRequests signs the requests with the API key instead of sending the key itself
+	FlagSignRequests = &cli.BoolFlag{
+		Name:    "sign-requests",
+		Usage:   "Sign the requests with the API key using HMAC-SHA256 instead of sending the key itself.",
+		EnvVars: []string{"UPDATE_MANAGER_SIGN_REQUESTS"},
+	}
+	// FlagCACert specifies the CA certificates the update manager is verified against
+	FlagCACert = &cli.StringFlag{
+		Name:    "ca-cert",
+		Usage:   "Path to the PEM encoded CA certificates the TLS certificate of the update manager is verified against instead of the system CA certificates.",
+		EnvVars: []string{"UPDATE_MANAGER_CA_CERT"},
+	}
+	// FlagClientCert specifies the client certificate sent to the update manager
+	FlagClientCert = &cli.StringFlag{
+		Name:    "client-cert",
+		Usage:   "Path to the PEM encoded client certificate used to authenticate against the update manager.",
+		EnvVars: []string{"UPDATE_MANAGER_CLIENT_CERT"},
+	}
+	// FlagClientKey specifies the private key of the client certificate
+	FlagClientKey = &cli.StringFlag{
+		Name:    "client-key",
+		Usage:   "Path to the PEM encoded private key of the client certificate.",
+		EnvVars: []string{"UPDATE_MANAGER_CLIENT_KEY"},
+	}
+	// FlagIDToken is an ID token sent instead of the API key
+	FlagIDToken = &cli.StringFlag{
+		Name:    "id-token",
+		Usage:   "An OIDC ID token, for example of the CI workload identity, used for authentication instead of the API key.",
+		EnvVars: []string{"UPDATE_MANAGER_ID_TOKEN"},
+	}
+	// FlagGitHubOIDC requests an ID token from GitHub Actions for authentication
+	FlagGitHubOIDC = &cli.BoolFlag{
+		Name:    "github-oidc",
+		Usage:   "Request an ID token from GitHub Actions and use it for authentication instead of the API key. The job requires the id-token: write permission.",
+		EnvVars: []string{"UPDATE_MANAGER_GITHUB_OIDC"},
+	}
+	// FlagOIDCAudience is the audience of the ID token requested from GitHub Actions
+	FlagOIDCAudience = &cli.StringFlag{
+		Name:    "oidc-audience",
+		Usage:   "The audience of the ID token requested from GitHub Actions.",
+		Value:   "kubernetes-update-manager",
+		EnvVars: []string{"UPDATE_MANAGER_OIDC_AUDIENCE"},
+	}
 
 	// ErrNoTargetEndpoint is returned if no target endpoint is provided
 	ErrNoTargetEndpoint = errors.New("The target endpoint for the remote update manager is not specified")
@@ -43,6 +142,8 @@ var (
 	ErrNoImage = errors.New("The image for the update was not provided")
 	// ErrNoUpdateClassifier is returned if no update classifier was provided to the update command
 	ErrNoUpdateClassifier = errors.New("The update classifier was not provided to the update command")
+	// ErrNoCredentials is returned if neither an API key nor an ID token was provided to the update command
+	ErrNoCredentials = errors.New("Neither an API key, an ID token nor a client certificate was provided to the update command")
 )
 
 // UpdateCommand can be used to notify a remove server about an update
@@ -58,19 +159,45 @@ func UpdateCommand() *cli.Command {
 
 // UpdateFlags return the flags which are available in the update command.
 func UpdateFlags() []cli.Flag {
-	return []cli.Flag{
-		FlagURL,
+	return append([]cli.Flag{
 		FlagImage,
 		FlagUpdateClassifier,
+		FlagForce,
+		FlagLabelSelector,
+		FlagRevision,
+		FlagChangeCause,
+		FlagNotBefore,
+		FlagCanaryWeight,
+		FlagCanarySoakTime,
+		FlagCanaryMaxRestarts,
+		FlagOverrideFreeze,
+	}, ConnectionFlags()...)
+}
+
+// ConnectionFlags return the flags configuring the remote update manager and the credentials used for it.
+func ConnectionFlags() []cli.Flag {
+	return []cli.Flag{
+		FlagURL,
 		FlagAPIKey,
+		FlagSignRequests,
+		FlagCACert,
+		FlagClientCert,
+		FlagClientKey,
+		FlagIDToken,
+		FlagGitHubOIDC,
+		FlagOIDCAudience,
 	}
 }
 
 // UpdateAction is the action which is executed when the update command is picked.
 func UpdateAction(c *cli.Context) error {
-	updateCommand := updateCommandFromContext(c)
-	if len(updateCommand.TargetEndpoint) == 0 {
-		return ErrNoTargetEndpoint
+	updateCommand, err := updateCommandFromContext(c)
+	if err != nil {
+		return err
+	}
+	err = verifyConnection(updateCommand)
+	if err != nil {
+		return err
 	}
 	if len(updateCommand.Image) == 0 {
 		return ErrNoImage
@@ -78,9 +205,6 @@ func UpdateAction(c *cli.Context) error {
 	if len(updateCommand.UpdateClassifier) == 0 {
 		return ErrNoUpdateClassifier
 	}
-	if len(updateCommand.APIKey) == 0 {
-		return ErrNoAPIKey
-	}
 
 	color.Info.Println(
 		fmt.Sprintf("Updating %s with image %s and update classifier %s",
@@ -91,10 +215,40 @@ func UpdateAction(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	if !updateCommand.NotBefore.IsZero() {
+		return announceSchedule(status)
+	}
 
 	return monitorUpdate(status)
 }
 
+// announceSchedule prints the start time of a scheduled update instead of waiting for it. Updates which have not been
+// scheduled are monitored as usual.
+func announceSchedule(status client.ExecutionStatus) error {
+	currentStatus, err := status.Get()
+	if err != nil {
+		return err
+	}
+	if currentStatus.Status.State != string(manager.StateScheduled) {
+		return monitorUpdate(status)
+	}
+	color.FgGreen.Println(fmt.Sprintf("Update %s is scheduled for %s. Cancel it with: cancel %s",
+		status.UUID().String(), currentStatus.Schedule.StartTime.Format(time.RFC3339), status.UUID().String()))
+	return nil
+}
+
+// verifyConnection returns an error if the remote update manager or the credentials are missing in the command.
+func verifyConnection(updateCommand *client.UpdateCommand) error {
+	if len(updateCommand.TargetEndpoint) == 0 {
+		return ErrNoTargetEndpoint
+	}
+	hasClientCert := updateCommand.TLSConfig != nil && len(updateCommand.TLSConfig.Certificates) > 0
+	if len(updateCommand.APIKey) == 0 && updateCommand.IDTokenSource == nil && !hasClientCert {
+		return ErrNoCredentials
+	}
+	return nil
+}
+
 func monitorUpdate(status client.ExecutionStatus) error {
 	finished := false
 
@@ -102,6 +256,9 @@ func monitorUpdate(status client.ExecutionStatus) error {
 	var currentStatus *web.UpdateProgressSerialized
 	var jobsProgress *uiprogress.Bar
 	var deploymentsProgress *uiprogress.Bar
+	announcedApproval := false
+	announcedSchedule := false
+	announcedPosition := 0
 	uiprogress.Start()
 
 	for !finished {
@@ -110,6 +267,21 @@ func monitorUpdate(status client.ExecutionStatus) error {
 			color.Warn.Println("Update not found, expect it to be already done and deleted.")
 			return nil
 		}
+		if currentStatus.Status.State == string(manager.StatePendingApproval) && !announcedApproval {
+			announcedApproval = true
+			color.Info.Println(fmt.Sprintf("Update %s is waiting for approval until %s. Approve it with: approve %s",
+				status.UUID().String(), currentStatus.Approval.ExpiryTime.Format(time.RFC3339), status.UUID().String()))
+		}
+		if currentStatus.Status.State == string(manager.StateScheduled) && !announcedSchedule {
+			announcedSchedule = true
+			color.Info.Println(fmt.Sprintf("Update %s is scheduled for %s: %s",
+				status.UUID().String(), currentStatus.Schedule.StartTime.Format(time.RFC3339), currentStatus.Schedule.Reason))
+		}
+		if currentStatus.Status.State == string(manager.StateQueued) && currentStatus.Queue.Position != announcedPosition {
+			announcedPosition = currentStatus.Queue.Position
+			color.Info.Println(fmt.Sprintf("Update %s is queued behind %d conflicting updates: %s",
+				status.UUID().String(), announcedPosition, strings.Join(currentStatus.Queue.UpdateUUIDs, ", ")))
+		}
 		jobsCount := currentStatus.Counts.Jobs
 		deploymentsCount := currentStatus.Counts.Deployments
 
@@ -132,7 +304,7 @@ func monitorUpdate(status client.ExecutionStatus) error {
 	}
 
 	if currentStatus.Status.Failed {
-		err = errors.New("Update failed")
+		err = failureOf(currentStatus)
 		color.Error.Println(err.Error())
 		os.Exit(1)
 		return err
@@ -141,13 +313,74 @@ func monitorUpdate(status client.ExecutionStatus) error {
 	return nil
 }
 
-func updateCommandFromContext(c *cli.Context) *client.UpdateCommand {
-	return &client.UpdateCommand{
-		TargetEndpoint:   c.String(FlagURL.Name),
-		Image:            c.String(FlagImage.Name),
-		UpdateClassifier: c.String(FlagUpdateClassifier.Name),
-		APIKey:           strings.TrimSpace(c.String(FlagAPIKey.Name)),
+// failureOf describes why the update has not been rolled out.
+func failureOf(updateProgress *web.UpdateProgressSerialized) error {
+	switch manager.State(updateProgress.Status.State) {
+	case manager.StateRejected:
+		approval := updateProgress.Approval
+		if len(approval.Reason) > 0 {
+			return fmt.Errorf("Update rejected by %s: %s", approval.Rejecter, approval.Reason)
+		}
+		return fmt.Errorf("Update rejected by %s", approval.Rejecter)
+	case manager.StateExpired:
+		return errors.New("Update expired before it has been approved")
+	case manager.StateAborted:
+		return errors.New("Update aborted before it has been started")
+	case manager.StateCancelled:
+		return errors.New("Update cancelled before it has been started")
+	}
+	if schedule := updateProgress.Schedule; schedule != nil && len(schedule.Error) > 0 {
+		return fmt.Errorf("Update could not be started at %s: %s", schedule.StartTime.Format(time.RFC3339), schedule.Error)
+	}
+	if queue := updateProgress.Queue; queue != nil && len(queue.Error) > 0 {
+		return fmt.Errorf("Update could not be started: %s", queue.Error)
+	}
+	return errors.New("Update failed")
+}
+
+func updateCommandFromContext(c *cli.Context) (*client.UpdateCommand, error) {
+	updateCommand := &client.UpdateCommand{
+		TargetEndpoint:    c.String(FlagURL.Name),
+		Image:             c.String(FlagImage.Name),
+		UpdateClassifier:  c.String(FlagUpdateClassifier.Name),
+		APIKey:            strings.TrimSpace(c.String(FlagAPIKey.Name)),
+		SignRequests:      c.Bool(FlagSignRequests.Name),
+		Force:             c.Bool(FlagForce.Name),
+		LabelSelector:     strings.TrimSpace(c.String(FlagLabelSelector.Name)),
+		Revision:          strings.TrimSpace(c.String(FlagRevision.Name)),
+		ChangeCause:       strings.TrimSpace(c.String(FlagChangeCause.Name)),
+		OverrideFreeze:    c.Bool(FlagOverrideFreeze.Name),
+		CanaryWeight:      c.Int(FlagCanaryWeight.Name),
+		CanarySoakTime:    c.Duration(FlagCanarySoakTime.Name),
+		CanaryMaxRestarts: c.Int(FlagCanaryMaxRestarts.Name),
+	}
+	if notBefore := strings.TrimSpace(c.String(FlagNotBefore.Name)); len(notBefore) > 0 {
+		notBeforeTime, err := time.Parse(time.RFC3339, notBefore)
+		if err != nil {
+			return nil, fmt.Errorf("The not before time %q must be in RFC 3339 format: %w", notBefore, err)
+		}
+		updateCommand.NotBefore = notBeforeTime
+	}
+	caCertFile := strings.TrimSpace(c.String(FlagCACert.Name))
+	clientCertFile := strings.TrimSpace(c.String(FlagClientCert.Name))
+	clientKeyFile := strings.TrimSpace(c.String(FlagClientKey.Name))
+	if len(caCertFile) > 0 || len(clientCertFile) > 0 || len(clientKeyFile) > 0 {
+		tlsConfig, err := client.NewTLSConfig(caCertFile, clientCertFile, clientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		updateCommand.TLSConfig = tlsConfig
+	}
+	if idToken := strings.TrimSpace(c.String(FlagIDToken.Name)); len(idToken) > 0 {
+		updateCommand.IDTokenSource = client.StaticTokenSource(idToken)
+	} else if c.Bool(FlagGitHubOIDC.Name) {
+		tokenSource, err := client.NewGitHubTokenSource(c.String(FlagOIDCAudience.Name))
+		if err != nil {
+			return nil, err
+		}
+		updateCommand.IDTokenSource = tokenSource
 	}
+	return updateCommand, nil
 }
 
 func addJobsBar(totalJobs int) *uiprogress.Bar {
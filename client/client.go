@@ -1,5 +1,10 @@
 package client
 
+import (
+	"crypto/tls"
+	"time"
+)
+
 // UpdateCommand holds the configuration to run an update to the client
 type UpdateCommand struct {
 	// TargetEndpoint is used to specify the URL which should be used to communicate with the update manager
@@ -10,6 +15,33 @@ type UpdateCommand struct {
 	UpdateClassifier string
 	// APIKey specifies the api key used for authentication against the kubernetes update manager
 	APIKey string
+	// IDTokenSource returns an ID token, for example of a CI workload identity, used for authentication instead of the API key
+	IDTokenSource TokenSource
+	// TLSConfig configures the CA certificates the update manager is verified against and the client certificate sent
+	// to it. If nil, the system CA certificates are used.
+	TLSConfig *tls.Config
+	// SignRequests signs the requests with the API key instead of sending the key itself
+	SignRequests bool
+	// Force requests the update manager to ignore the semantic version guards of the workloads
+	Force bool
+	// LabelSelector restricts the update to the deployments and jobs matching the kubernetes label selector
+	LabelSelector string
+	// Revision is the revision, for example the commit SHA, stamped on the updated deployments
+	Revision string
+	// ChangeCause describes the update in the rollout history of the updated deployments
+	ChangeCause string
+	// OverrideFreeze starts the update in an emergency even though the freeze calendar of the update manager blocks it.
+	// The credentials need to be privileged.
+	OverrideFreeze bool
+	// NotBefore schedules the update on the update manager for the time. If zero, the update is started immediately.
+	NotBefore time.Time
+	// CanaryWeight is the percentage of the pods which run the new image in canaries before the deployments are
+	// updated. If zero, the deployments are updated directly.
+	CanaryWeight int
+	// CanarySoakTime is the time the canaries have to stay healthy. If zero, the default of the update manager is used.
+	CanarySoakTime time.Duration
+	// CanaryMaxRestarts is the number of container restarts of the canary pods which are tolerated
+	CanaryMaxRestarts int
 }
 
 // Run executes the update command.
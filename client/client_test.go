@@ -2,11 +2,13 @@ package client
 
 import (
 	"errors"
+	"kubernetes-update-manager/auth"
 	"kubernetes-update-manager/web"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
+	"time"
 
 	. "github.com/cbrand/gocheck_matchers"
 	"github.com/google/uuid"
@@ -85,6 +87,143 @@ func (suite *ClientSuite) TestRunErrorInternalServerError(c *C) {
 	c.Assert(err, NotNil)
 }
 
+func (suite *ClientSuite) TestRunForce(c *C) {
+	httpmock.RegisterResponder("POST", "https://localhost/updates/", func(req *http.Request) (*http.Response, error) {
+		c.Assert(req.ParseForm(), IsNil)
+		c.Assert(req.PostForm.Get(ForceParam), Equals, "true")
+		return httpmock.NewJsonResponse(http.StatusCreated, &web.UpdateProgressSerialized{UUID: uuid.New().String()})
+	})
+	suite.updateCommand.Force = true
+	_, err := suite.updateCommand.Run()
+	c.Assert(err, IsNil)
+}
+
+func (suite *ClientSuite) TestRunLabelSelector(c *C) {
+	httpmock.RegisterResponder("POST", "https://localhost/updates/", func(req *http.Request) (*http.Response, error) {
+		c.Assert(req.ParseForm(), IsNil)
+		c.Assert(req.PostForm.Get(LabelSelectorParam), Equals, "tier=web")
+		c.Assert(req.PostForm[ForceParam], IsNil)
+		return httpmock.NewJsonResponse(http.StatusCreated, &web.UpdateProgressSerialized{UUID: uuid.New().String()})
+	})
+	suite.updateCommand.LabelSelector = "tier=web"
+	_, err := suite.updateCommand.Run()
+	c.Assert(err, IsNil)
+}
+
+func (suite *ClientSuite) TestRunReleaseMetadata(c *C) {
+	httpmock.RegisterResponder("POST", "https://localhost/updates/", func(req *http.Request) (*http.Response, error) {
+		c.Assert(req.ParseForm(), IsNil)
+		c.Assert(req.PostForm.Get(RevisionParam), Equals, "4e1c2a9")
+		c.Assert(req.PostForm.Get(ChangeCauseParam), Equals, "Release 1.0.0")
+		return httpmock.NewJsonResponse(http.StatusCreated, &web.UpdateProgressSerialized{UUID: uuid.New().String()})
+	})
+	suite.updateCommand.Revision = "4e1c2a9"
+	suite.updateCommand.ChangeCause = "Release 1.0.0"
+	_, err := suite.updateCommand.Run()
+	c.Assert(err, IsNil)
+}
+
+func (suite *ClientSuite) TestRunOverrideFreeze(c *C) {
+	httpmock.RegisterResponder("POST", "https://localhost/updates/", func(req *http.Request) (*http.Response, error) {
+		c.Assert(req.ParseForm(), IsNil)
+		c.Assert(req.PostForm.Get(OverrideFreezeParam), Equals, "true")
+		return httpmock.NewJsonResponse(http.StatusCreated, &web.UpdateProgressSerialized{UUID: uuid.New().String()})
+	})
+	suite.updateCommand.OverrideFreeze = true
+	_, err := suite.updateCommand.Run()
+	c.Assert(err, IsNil)
+}
+
+func (suite *ClientSuite) TestRunNotBefore(c *C) {
+	httpmock.RegisterResponder("POST", "https://localhost/updates/", func(req *http.Request) (*http.Response, error) {
+		c.Assert(req.ParseForm(), IsNil)
+		c.Assert(req.PostForm.Get(NotBeforeParam), Equals, "2024-05-03T02:00:00+02:00")
+		return httpmock.NewJsonResponse(http.StatusCreated, &web.UpdateProgressSerialized{UUID: uuid.New().String()})
+	})
+	suite.updateCommand.NotBefore = time.Date(2024, 5, 3, 2, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	_, err := suite.updateCommand.Run()
+	c.Assert(err, IsNil)
+}
+
+func (suite *ClientSuite) TestRunCanary(c *C) {
+	httpmock.RegisterResponder("POST", "https://localhost/updates/", func(req *http.Request) (*http.Response, error) {
+		c.Assert(req.ParseForm(), IsNil)
+		c.Assert(req.PostForm.Get(CanaryWeightParam), Equals, "10")
+		c.Assert(req.PostForm.Get(CanarySoakTimeParam), Equals, "10m0s")
+		c.Assert(req.PostForm.Get(CanaryMaxRestartsParam), Equals, "2")
+		return httpmock.NewJsonResponse(http.StatusCreated, &web.UpdateProgressSerialized{UUID: uuid.New().String()})
+	})
+	suite.updateCommand.CanaryWeight = 10
+	suite.updateCommand.CanarySoakTime = 10 * time.Minute
+	suite.updateCommand.CanaryMaxRestarts = 2
+	_, err := suite.updateCommand.Run()
+	c.Assert(err, IsNil)
+}
+
+func (suite *ClientSuite) TestRunAPIKey(c *C) {
+	httpmock.RegisterResponder("POST", "https://localhost/updates/", func(req *http.Request) (*http.Response, error) {
+		c.Assert(req.Header.Get("Authorization"), Equals, "APIKey this-is-a-test-api-key")
+		return httpmock.NewJsonResponse(http.StatusCreated, &web.UpdateProgressSerialized{UUID: uuid.New().String()})
+	})
+	_, err := suite.updateCommand.Run()
+	c.Assert(err, IsNil)
+}
+
+func (suite *ClientSuite) TestRunIDToken(c *C) {
+	httpmock.RegisterResponder("POST", "https://localhost/updates/", func(req *http.Request) (*http.Response, error) {
+		c.Assert(req.Header.Get("Authorization"), Equals, "Bearer id-token")
+		return httpmock.NewJsonResponse(http.StatusCreated, &web.UpdateProgressSerialized{UUID: uuid.New().String()})
+	})
+	suite.updateCommand.IDTokenSource = StaticTokenSource("id-token")
+	_, err := suite.updateCommand.Run()
+	c.Assert(err, IsNil)
+}
+
+func (suite *ClientSuite) TestRunIDTokenError(c *C) {
+	suite.updateCommand.IDTokenSource = func() (string, error) { return "", errors.New("no token") }
+	result, err := suite.updateCommand.Run()
+	c.Assert(result, IsNil)
+	c.Assert(err, ErrorMatches, "no token")
+}
+
+func (suite *ClientSuite) TestSignedRequests(c *C) {
+	authenticator := auth.NewHMACAuthenticator([]auth.APIKey{{Name: "default", Key: suite.updateCommand.APIKey}}, 0)
+	verify := func(req *http.Request) {
+		c.Assert(req.Header.Get("Authorization"), Not(Matches), ".*"+suite.updateCommand.APIKey+".*")
+		principal, err := authenticator.Authenticate(req)
+		c.Assert(err, IsNil)
+		c.Assert(principal.Name, Equals, "default")
+	}
+	httpmock.RegisterResponder("POST", "https://localhost/updates/", func(req *http.Request) (*http.Response, error) {
+		verify(req)
+		c.Assert(req.ParseForm(), IsNil)
+		c.Assert(req.PostForm.Get(ImageParam), Equals, "xcnt/test:1.0.0")
+		return httpmock.NewJsonResponse(http.StatusCreated, &web.UpdateProgressSerialized{UUID: uuid.New().String()})
+	})
+	suite.updateCommand.SignRequests = true
+	status, err := suite.updateCommand.Run()
+	c.Assert(err, IsNil)
+
+	execution := status.(*UpdateExecution)
+	httpmock.RegisterResponder("GET", execution.objectURL().String(), func(req *http.Request) (*http.Response, error) {
+		verify(req)
+		return httpmock.NewJsonResponse(http.StatusOK, &web.UpdateProgressSerialized{UUID: execution.UUID().String()})
+	})
+	_, err = status.Get()
+	c.Assert(err, IsNil)
+	_, err = status.Get()
+	c.Assert(err, IsNil)
+}
+
+func (suite *ClientSuite) TestRunErrorWithDescription(c *C) {
+	httpmock.RegisterResponder("POST", "https://localhost/updates/", func(req *http.Request) (*http.Response, error) {
+		return httpmock.NewJsonResponse(http.StatusConflict, &web.ErrorSerialized{Error: "downgrade"})
+	})
+	result, err := suite.updateCommand.Run()
+	c.Assert(result, IsNil)
+	c.Assert(err, ErrorMatches, "Unexpected status code 409: downgrade")
+}
+
 func (suite *ClientSuite) TestRunErrorNotDeserializable(c *C) {
 	httpmock.RegisterResponder("POST", "https://localhost/updates/", func(req *http.Request) (*http.Response, error) {
 		return httpmock.NewStringResponse(http.StatusCreated, ""), nil
@@ -186,3 +325,66 @@ func (suite *ClientSuite) TestFinishUnauthorized(c *C) {
 	c.Assert(err, NotNil)
 	c.Assert(err, Equals, ErrUnauthorized)
 }
+
+func (suite *ClientSuite) TestApprove(c *C) {
+	updateUUID := uuid.New().String()
+	httpmock.RegisterResponder("POST", "https://localhost/updates/"+updateUUID+"/approve", func(req *http.Request) (*http.Response, error) {
+		c.Assert(req.Header.Get("Authorization"), Equals, "APIKey this-is-a-test-api-key")
+		return httpmock.NewJsonResponse(http.StatusOK, &web.UpdateProgressSerialized{
+			UUID:   updateUUID,
+			Status: web.StatusSerialized{State: "running"},
+		})
+	})
+	response, err := NewUpdateExecutionFor(suite.updateCommand, updateUUID).Approve()
+	c.Assert(err, IsNil)
+	c.Assert(response.Status.State, Equals, "running")
+}
+
+func (suite *ClientSuite) TestReject(c *C) {
+	updateUUID := uuid.New().String()
+	httpmock.RegisterResponder("POST", "https://localhost/updates/"+updateUUID+"/reject", func(req *http.Request) (*http.Response, error) {
+		c.Assert(req.ParseForm(), IsNil)
+		c.Assert(req.PostForm.Get(ReasonParam), Equals, "Not during the sale")
+		return httpmock.NewJsonResponse(http.StatusOK, &web.UpdateProgressSerialized{UUID: updateUUID})
+	})
+	_, err := NewUpdateExecutionFor(suite.updateCommand, updateUUID).Reject("Not during the sale")
+	c.Assert(err, IsNil)
+}
+
+func (suite *ClientSuite) TestApproveForbidden(c *C) {
+	updateUUID := uuid.New().String()
+	httpmock.RegisterResponder("POST", "https://localhost/updates/"+updateUUID+"/approve", func(req *http.Request) (*http.Response, error) {
+		return httpmock.NewJsonResponse(http.StatusForbidden, &web.ErrorSerialized{Error: "ci requested the update and may not approve it"})
+	})
+	_, err := NewUpdateExecutionFor(suite.updateCommand, updateUUID).Approve()
+	c.Assert(err, ErrorMatches, "Unexpected status code 403: ci requested the update and may not approve it")
+}
+
+func (suite *ClientSuite) TestCancel(c *C) {
+	updateUUID := uuid.New().String()
+	httpmock.RegisterResponder("POST", "https://localhost/updates/"+updateUUID+"/cancel", func(req *http.Request) (*http.Response, error) {
+		return httpmock.NewJsonResponse(http.StatusOK, &web.UpdateProgressSerialized{
+			UUID:   updateUUID,
+			Status: web.StatusSerialized{State: "cancelled"},
+		})
+	})
+	response, err := NewUpdateExecutionFor(suite.updateCommand, updateUUID).Cancel()
+	c.Assert(err, IsNil)
+	c.Assert(response.Status.State, Equals, "cancelled")
+}
+
+func (suite *ClientSuite) TestRollback(c *C) {
+	updateUUID := uuid.New().String()
+	rollbackUUID := uuid.New().String()
+	httpmock.RegisterResponder("POST", "https://localhost/updates/"+updateUUID+"/rollback", func(req *http.Request) (*http.Response, error) {
+		return httpmock.NewJsonResponse(http.StatusOK, &web.UpdateProgressSerialized{
+			UUID:       rollbackUUID,
+			RollbackOf: updateUUID,
+			Status:     web.StatusSerialized{State: "running"},
+		})
+	})
+	response, err := NewUpdateExecutionFor(suite.updateCommand, updateUUID).Rollback(false)
+	c.Assert(err, IsNil)
+	c.Assert(response.UUID, Equals, rollbackUUID)
+	c.Assert(response.RollbackOf, Equals, updateUUID)
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var (
+	// ErrNoCACertificates is returned if the CA certificate file does not contain any certificate.
+	ErrNoCACertificates = errors.New("The CA certificate file does not contain any PEM encoded certificate")
+	// ErrIncompleteClientCertificate is returned if only one of the client certificate and key is provided.
+	ErrIncompleteClientCertificate = errors.New("The client certificate and key have to be provided together")
+)
+
+// NewTLSConfig returns the TLS configuration verifying the update manager against the CA certificates in the
+// caCertFile and sending the client certificate. Empty paths keep the system CA certificates respectively do not send
+// a client certificate.
+func NewTLSConfig(caCertFile string, clientCertFile string, clientKeyFile string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if len(caCertFile) > 0 {
+		data, err := os.ReadFile(caCertFile)
+		if err != nil {
+			return nil, err
+		}
+		rootCAs := x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("%s: %w", caCertFile, ErrNoCACertificates)
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+	if (len(clientCertFile) > 0) != (len(clientKeyFile) > 0) {
+		return nil, ErrIncompleteClientCertificate
+	}
+	if len(clientCertFile) > 0 {
+		certificate, err := tls.LoadX509KeyPair(clientCertFile, clientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
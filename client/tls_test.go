@@ -0,0 +1,100 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	. "gopkg.in/check.v1"
+)
+
+type TLSSuite struct {
+	server *httptest.Server
+	caFile string
+}
+
+var _ = Suite(&TLSSuite{})
+
+func (suite *TLSSuite) SetUpTest(c *C) {
+	suite.server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"uuid": "` + uuid.New().String() + `"}`))
+	}))
+	suite.server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	suite.server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	suite.server.StartTLS()
+	suite.caFile = filepath.Join(c.MkDir(), "ca.crt")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: suite.server.Certificate().Raw})
+	c.Assert(os.WriteFile(suite.caFile, caPEM, 0600), IsNil)
+}
+
+func (suite *TLSSuite) TearDownTest(c *C) {
+	suite.server.Close()
+}
+
+// writeClientCertificate writes the certificate and key of the test server, which are also valid for client
+// authentication, to files.
+func (suite *TLSSuite) writeClientCertificate(c *C) (string, string) {
+	certificate := suite.server.TLS.Certificates[0]
+	keyDER, err := x509.MarshalPKCS8PrivateKey(certificate.PrivateKey)
+	c.Assert(err, IsNil)
+	directory := c.MkDir()
+	certFile := filepath.Join(directory, "tls.crt")
+	keyFile := filepath.Join(directory, "tls.key")
+	c.Assert(os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Certificate[0]}), 0600), IsNil)
+	c.Assert(os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600), IsNil)
+	return certFile, keyFile
+}
+
+func (suite *TLSSuite) updateCommand(tlsConfig *tls.Config) *UpdateCommand {
+	return &UpdateCommand{
+		TargetEndpoint:   suite.server.URL + "/updates",
+		Image:            "xcnt/test:1.0.0",
+		UpdateClassifier: "stable",
+		TLSConfig:        tlsConfig,
+	}
+}
+
+func (suite *TLSSuite) TestClientCertificate(c *C) {
+	certFile, keyFile := suite.writeClientCertificate(c)
+	tlsConfig, err := NewTLSConfig(suite.caFile, certFile, keyFile)
+	c.Assert(err, IsNil)
+	status, err := suite.updateCommand(tlsConfig).Run()
+	c.Assert(err, IsNil)
+	c.Assert(status, NotNil)
+}
+
+func (suite *TLSSuite) TestWithoutClientCertificate(c *C) {
+	tlsConfig, err := NewTLSConfig(suite.caFile, "", "")
+	c.Assert(err, IsNil)
+	_, err = suite.updateCommand(tlsConfig).Run()
+	c.Assert(err, Equals, ErrUnauthorized)
+}
+
+func (suite *TLSSuite) TestUnknownCA(c *C) {
+	_, err := suite.updateCommand(&tls.Config{}).Run()
+	c.Assert(err, NotNil)
+	c.Assert(err, Not(Equals), ErrUnauthorized)
+}
+
+func (suite *TLSSuite) TestNewTLSConfigInvalid(c *C) {
+	_, err := NewTLSConfig("", "client.crt", "")
+	c.Assert(err, Equals, ErrIncompleteClientCertificate)
+
+	invalidFile := filepath.Join(c.MkDir(), "ca.crt")
+	c.Assert(os.WriteFile(invalidFile, []byte("no certificate"), 0600), IsNil)
+	_, err = NewTLSConfig(invalidFile, "", "")
+	c.Assert(err, ErrorMatches, ".*"+ErrNoCACertificates.Error())
+}
@@ -0,0 +1,129 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/levigross/grequests"
+)
+
+const (
+	// gitHubTokenRequestURLEnv is the environment variable GitHub Actions provides the ID token endpoint in.
+	gitHubTokenRequestURLEnv = "ACTIONS_ID_TOKEN_REQUEST_URL"
+	// gitHubTokenRequestTokenEnv is the environment variable GitHub Actions provides the token for the ID token endpoint in.
+	gitHubTokenRequestTokenEnv = "ACTIONS_ID_TOKEN_REQUEST_TOKEN"
+	// tokenRefreshMargin is the time before the expiry of an ID token after which a new one is requested.
+	tokenRefreshMargin = time.Minute
+)
+
+var (
+	// ErrNoGitHubTokenEnvironment is returned if a GitHub ID token is requested outside of a GitHub Actions job with
+	// the id-token: write permission.
+	ErrNoGitHubTokenEnvironment = errors.New("The GitHub Actions ID token environment is not available, the job requires the id-token: write permission")
+)
+
+// TokenSource returns the ID token which is sent as bearer token to the update manager.
+type TokenSource func() (string, error)
+
+// StaticTokenSource returns a token source always returning the passed token.
+func StaticTokenSource(token string) TokenSource {
+	return func() (string, error) {
+		return token, nil
+	}
+}
+
+// NewGitHubTokenSource returns a token source requesting ID tokens for the audience from GitHub Actions. Tokens are
+// reused until shortly before they expire.
+func NewGitHubTokenSource(audience string) (TokenSource, error) {
+	requestURL := os.Getenv(gitHubTokenRequestURLEnv)
+	requestToken := os.Getenv(gitHubTokenRequestTokenEnv)
+	if len(requestURL) == 0 || len(requestToken) == 0 {
+		return nil, ErrNoGitHubTokenEnvironment
+	}
+	parsedURL, err := url.Parse(requestURL)
+	if err != nil {
+		return nil, err
+	}
+	if len(audience) > 0 {
+		query := parsedURL.Query()
+		query.Set("audience", audience)
+		parsedURL.RawQuery = query.Encode()
+	}
+
+	source := &cachingTokenSource{
+		fetch: func() (string, error) {
+			response, err := grequests.Get(parsedURL.String(), &grequests.RequestOptions{
+				Headers: map[string]string{"Authorization": fmt.Sprintf("Bearer %s", requestToken)},
+			})
+			if err != nil {
+				return "", err
+			}
+			if !response.Ok {
+				return "", fmt.Errorf("Requesting the GitHub ID token failed with status code %d", response.StatusCode)
+			}
+			tokenResponse := struct {
+				Value string `json:"value"`
+			}{}
+			err = response.JSON(&tokenResponse)
+			if err != nil {
+				return "", err
+			}
+			if len(tokenResponse.Value) == 0 {
+				return "", errors.New("GitHub returned an empty ID token")
+			}
+			return tokenResponse.Value, nil
+		},
+	}
+	return source.Token, nil
+}
+
+// cachingTokenSource reuses a fetched token until shortly before its expiry.
+type cachingTokenSource struct {
+	fetch  func() (string, error)
+	mutex  sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// Token returns the cached token or fetches a new one if it is about to expire.
+func (source *cachingTokenSource) Token() (string, error) {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	if len(source.token) > 0 && time.Now().Add(tokenRefreshMargin).Before(source.expiry) {
+		return source.token, nil
+	}
+	token, err := source.fetch()
+	if err != nil {
+		return "", err
+	}
+	source.token = token
+	source.expiry = tokenExpiry(token)
+	return token, nil
+}
+
+// tokenExpiry returns the expiry of the passed JSON web token without verifying it. If it can not be read, the zero
+// time is returned which causes the token to be fetched again on the next use.
+func tokenExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+	claims := struct {
+		Expiry int64 `json:"exp"`
+	}{}
+	if json.Unmarshal(payload, &claims) != nil {
+		return time.Time{}
+	}
+	return time.Unix(claims.Expiry, 0)
+}
@@ -0,0 +1,87 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/jarcoal/httpmock.v1"
+)
+
+const gitHubTokenURL = "https://token.actions.example.com/token?api-version=2.0"
+
+type TokenSuite struct {
+	requests int
+	expiry   time.Time
+}
+
+var _ = Suite(&TokenSuite{})
+
+func (suite *TokenSuite) SetUpTest(c *C) {
+	httpmock.Activate()
+	os.Setenv(gitHubTokenRequestURLEnv, gitHubTokenURL)
+	os.Setenv(gitHubTokenRequestTokenEnv, "request-token")
+	suite.requests = 0
+	suite.expiry = time.Now().Add(5 * time.Minute)
+	httpmock.RegisterResponder("GET", gitHubTokenURL+"&audience=kubernetes-update-manager", func(req *http.Request) (*http.Response, error) {
+		c.Assert(req.Header.Get("Authorization"), Equals, "Bearer request-token")
+		suite.requests++
+		return httpmock.NewJsonResponse(http.StatusOK, map[string]string{"value": suite.token()})
+	})
+}
+
+func (suite *TokenSuite) TearDownTest(c *C) {
+	httpmock.DeactivateAndReset()
+	os.Unsetenv(gitHubTokenRequestURLEnv)
+	os.Unsetenv(gitHubTokenRequestTokenEnv)
+}
+
+func (suite *TokenSuite) token() string {
+	encode := func(value interface{}) string {
+		data, _ := json.Marshal(value)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	claims := map[string]interface{}{"exp": suite.expiry.Unix(), "jti": fmt.Sprint(suite.requests)}
+	return encode(map[string]string{"alg": "RS256"}) + "." + encode(claims) + ".signature"
+}
+
+func (suite *TokenSuite) TestGitHubTokenSource(c *C) {
+	source, err := NewGitHubTokenSource("kubernetes-update-manager")
+	c.Assert(err, IsNil)
+	first, err := source()
+	c.Assert(err, IsNil)
+	second, err := source()
+	c.Assert(err, IsNil)
+	c.Assert(second, Equals, first)
+	c.Assert(suite.requests, Equals, 1)
+}
+
+func (suite *TokenSuite) TestGitHubTokenSourceRefreshesExpiringToken(c *C) {
+	suite.expiry = time.Now().Add(30 * time.Second)
+	source, err := NewGitHubTokenSource("kubernetes-update-manager")
+	c.Assert(err, IsNil)
+	first, err := source()
+	c.Assert(err, IsNil)
+	second, err := source()
+	c.Assert(err, IsNil)
+	c.Assert(second, Not(Equals), first)
+	c.Assert(suite.requests, Equals, 2)
+}
+
+func (suite *TokenSuite) TestGitHubTokenSourceError(c *C) {
+	httpmock.RegisterResponder("GET", gitHubTokenURL+"&audience=other", httpmock.NewStringResponder(http.StatusForbidden, ""))
+	source, err := NewGitHubTokenSource("other")
+	c.Assert(err, IsNil)
+	_, err = source()
+	c.Assert(err, ErrorMatches, "Requesting the GitHub ID token failed with status code 403")
+}
+
+func (suite *TokenSuite) TestGitHubTokenSourceWithoutEnvironment(c *C) {
+	os.Unsetenv(gitHubTokenRequestTokenEnv)
+	_, err := NewGitHubTokenSource("kubernetes-update-manager")
+	c.Assert(err, Equals, ErrNoGitHubTokenEnvironment)
+}
@@ -1,13 +1,19 @@
 package client
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"kubernetes-update-manager/auth"
 	"kubernetes-update-manager/web"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
+	"strconv"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/levigross/grequests"
@@ -18,6 +24,26 @@ const (
 	ImageParam = web.ImageParam
 	// UpdateClassifierParam is the parameter used to be sent to the client
 	UpdateClassifierParam = web.UpdateClassifierParam
+	// ForceParam is the parameter used to ignore the version guards of the workloads
+	ForceParam = web.ForceParam
+	// LabelSelectorParam is the parameter used to restrict the update to workloads matching a label selector
+	LabelSelectorParam = web.LabelSelectorParam
+	// RevisionParam is the parameter used to pass the revision the update has been built from
+	RevisionParam = web.RevisionParam
+	// ChangeCauseParam is the parameter used to pass the description of the update
+	ChangeCauseParam = web.ChangeCauseParam
+	// NotBeforeParam is the parameter used to schedule the update for a later time
+	NotBeforeParam = web.NotBeforeParam
+	// CanaryWeightParam is the parameter used to soak the new image in canaries with the weight first
+	CanaryWeightParam = web.CanaryWeightParam
+	// CanarySoakTimeParam is the parameter used to pass the time the canaries have to stay healthy
+	CanarySoakTimeParam = web.CanarySoakTimeParam
+	// CanaryMaxRestartsParam is the parameter used to pass the tolerated container restarts of the canaries
+	CanaryMaxRestartsParam = web.CanaryMaxRestartsParam
+	// OverrideFreezeParam is the parameter used to override the freeze calendar in an emergency
+	OverrideFreezeParam = web.OverrideFreezeParam
+	// ReasonParam is the parameter used to pass why an update is rejected
+	ReasonParam = web.ReasonParam
 )
 
 var (
@@ -27,15 +53,31 @@ var (
 
 // NewUpdateExecution returns a newly created, not yet executed instance of the update execution configuration.
 func NewUpdateExecution(command *UpdateCommand) *UpdateExecution {
-	return &UpdateExecution{
+	updateExecution := &UpdateExecution{
 		updateCommand: command,
 	}
+	if command.TLSConfig != nil {
+		updateExecution.httpClient = &http.Client{
+			Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: command.TLSConfig},
+			Timeout:   30 * time.Second,
+		}
+	}
+	return updateExecution
+}
+
+// NewUpdateExecutionFor returns the execution of an already requested update with the uuid on the update manager
+// configured in the command.
+func NewUpdateExecutionFor(command *UpdateCommand, updateUUID string) *UpdateExecution {
+	updateExecution := NewUpdateExecution(command)
+	updateExecution.updateProgressUUID = updateUUID
+	return updateExecution
 }
 
 // UpdateExecution holds informations about a specific update and allows to retrieve the current information from a remote update manager.
 type UpdateExecution struct {
 	updateProgressUUID string
 	updateCommand      *UpdateCommand
+	httpClient         *http.Client
 }
 
 // UUID returns the uuid assigned to the update progress which is represented by the execution status.
@@ -47,29 +89,90 @@ func (updateExecution *UpdateExecution) UUID() uuid.UUID {
 	return u
 }
 
-// authenticatedRequestOptions returns pre authenticated request options.
-func (updateExecution *UpdateExecution) authenticatedRequestOptions() *grequests.RequestOptions {
+// authenticatedRequestOptions returns pre authenticated request options for the request. If an ID token source is
+// configured, the token is sent as bearer token. Otherwise the API key is either sent or, if requests should be signed,
+// used to sign the method, path, timestamp, a nonce and the body of the request. Without an API key, the request is
+// only authenticated by the client certificate of the TLS configuration.
+func (updateExecution *UpdateExecution) authenticatedRequestOptions(method string, requestURL string, body []byte) (*grequests.RequestOptions, error) {
 	updateCommand := updateExecution.updateCommand
-	return &grequests.RequestOptions{
-		Headers: map[string]string{
-			"Authorization": fmt.Sprintf("APIKey %s", updateCommand.APIKey),
-		},
+	options := &grequests.RequestOptions{
+		Headers:    map[string]string{},
+		HTTPClient: updateExecution.httpClient,
+	}
+	if body != nil {
+		options.RequestBody = bytes.NewReader(body)
+		options.Headers["Content-Type"] = "application/x-www-form-urlencoded"
 	}
+
+	switch {
+	case updateCommand.IDTokenSource != nil:
+		token, err := updateCommand.IDTokenSource()
+		if err != nil {
+			return nil, err
+		}
+		options.Headers["Authorization"] = fmt.Sprintf("Bearer %s", token)
+	case updateCommand.SignRequests:
+		parsedURL, err := url.Parse(requestURL)
+		if err != nil {
+			return nil, err
+		}
+		nonce := make([]byte, 16)
+		_, err = rand.Read(nonce)
+		if err != nil {
+			return nil, err
+		}
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		nonceString := hex.EncodeToString(nonce)
+		signature := auth.SignRequest(updateCommand.APIKey, method, parsedURL.RequestURI(), timestamp, nonceString, body)
+		options.Headers["Authorization"] = fmt.Sprintf("%s %s", auth.HMACScheme, signature)
+		options.Headers[auth.TimestampHeader] = timestamp
+		options.Headers[auth.NonceHeader] = nonceString
+	case len(updateCommand.APIKey) > 0:
+		options.Headers["Authorization"] = fmt.Sprintf("APIKey %s", updateCommand.APIKey)
+	}
+	return options, nil
 }
 
 // Start starts the request pipeline for the command configuration. It returns ErrUnauthorized if the authentication with the remote server fails.
 func (updateExecution *UpdateExecution) Start() error {
 	updateCommand := updateExecution.updateCommand
-	request := updateExecution.authenticatedRequestOptions()
-	request.Data = map[string]string{
-		ImageParam:            updateCommand.Image,
-		UpdateClassifierParam: updateCommand.UpdateClassifier,
+	data := url.Values{}
+	data.Set(ImageParam, updateCommand.Image)
+	data.Set(UpdateClassifierParam, updateCommand.UpdateClassifier)
+	if updateCommand.Force {
+		data.Set(ForceParam, strconv.FormatBool(updateCommand.Force))
+	}
+	if len(updateCommand.LabelSelector) > 0 {
+		data.Set(LabelSelectorParam, updateCommand.LabelSelector)
+	}
+	if len(updateCommand.Revision) > 0 {
+		data.Set(RevisionParam, updateCommand.Revision)
+	}
+	if len(updateCommand.ChangeCause) > 0 {
+		data.Set(ChangeCauseParam, updateCommand.ChangeCause)
+	}
+	if !updateCommand.NotBefore.IsZero() {
+		data.Set(NotBeforeParam, updateCommand.NotBefore.Format(time.RFC3339))
+	}
+	if updateCommand.CanaryWeight > 0 {
+		data.Set(CanaryWeightParam, strconv.Itoa(updateCommand.CanaryWeight))
+		if updateCommand.CanarySoakTime > 0 {
+			data.Set(CanarySoakTimeParam, updateCommand.CanarySoakTime.String())
+		}
+		data.Set(CanaryMaxRestartsParam, strconv.Itoa(updateCommand.CanaryMaxRestarts))
+	}
+	if updateCommand.OverrideFreeze {
+		data.Set(OverrideFreezeParam, strconv.FormatBool(updateCommand.OverrideFreeze))
+	}
+	request, err := updateExecution.authenticatedRequestOptions(http.MethodPost, updateCommand.TargetEndpoint, []byte(data.Encode()))
+	if err != nil {
+		return err
 	}
 	response, err := grequests.Post(updateCommand.TargetEndpoint, request)
 	if err != nil {
 		return err
 	}
-	err = verifyRemoteStatusCode(response.StatusCode)
+	err = verifyRemoteResponse(response)
 	if err != nil {
 		return err
 	}
@@ -86,9 +189,12 @@ func (updateExecution *UpdateExecution) Start() error {
 
 // Get retrieves the current information for the update progress to be returned. It returns os.ErrNotExist, if the update progress with the specified uuid does not exist. It returns ErrUnauthorized if the authentication with the remote server fails.
 func (updateExecution *UpdateExecution) Get() (*web.UpdateProgressSerialized, error) {
-	options := updateExecution.authenticatedRequestOptions()
-
 	objectURL := updateExecution.objectURL()
+	options, err := updateExecution.authenticatedRequestOptions(http.MethodGet, objectURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
 	response, err := grequests.Get(objectURL.String(), options)
 	if err != nil {
 		return nil, err
@@ -109,8 +215,11 @@ func (updateExecution *UpdateExecution) Get() (*web.UpdateProgressSerialized, er
 
 // Finish deletes the update progress on the update manager. It should be called when no more information needs to be returned. It returns ErrUnauthorized if the authentication with the remote server fails.
 func (updateExecution *UpdateExecution) Finish() error {
-	options := updateExecution.authenticatedRequestOptions()
 	objectURL := updateExecution.objectURL()
+	options, err := updateExecution.authenticatedRequestOptions(http.MethodDelete, objectURL.String(), nil)
+	if err != nil {
+		return err
+	}
 	response, err := grequests.Delete(objectURL.String(), options)
 	if err != nil {
 		return err
@@ -122,12 +231,82 @@ func (updateExecution *UpdateExecution) Finish() error {
 	return nil
 }
 
+// Approve approves the update waiting for approval with the credentials of the command. It returns os.ErrNotExist if
+// the update does not exist and ErrUnauthorized if the authentication with the remote server fails.
+func (updateExecution *UpdateExecution) Approve() (*web.UpdateProgressSerialized, error) {
+	return updateExecution.decide("approve", url.Values{})
+}
+
+// Reject rejects the update waiting for approval with the credentials of the command, so it is never started. It
+// returns the same errors as Approve.
+func (updateExecution *UpdateExecution) Reject(reason string) (*web.UpdateProgressSerialized, error) {
+	data := url.Values{}
+	if len(reason) > 0 {
+		data.Set(ReasonParam, reason)
+	}
+	return updateExecution.decide("reject", data)
+}
+
+// Cancel cancels the update waiting to be started, so it is never started. It returns the same errors
+// as Approve.
+func (updateExecution *UpdateExecution) Cancel() (*web.UpdateProgressSerialized, error) {
+	return updateExecution.decide("cancel", url.Values{})
+}
+
+// Rollback restores the deployments updated by the successful update to the pod templates they ran before and returns
+// the update tracking the rollback. Deployments changed since the update are only rolled back if forced. It returns
+// the same errors as Approve.
+func (updateExecution *UpdateExecution) Rollback(force bool) (*web.UpdateProgressSerialized, error) {
+	data := url.Values{}
+	if force {
+		data.Set(ForceParam, strconv.FormatBool(force))
+	}
+	return updateExecution.decide("rollback", data)
+}
+
+func (updateExecution *UpdateExecution) decide(decision string, data url.Values) (*web.UpdateProgressSerialized, error) {
+	decisionURL := updateExecution.objectURL()
+	decisionURL.Path = path.Join(decisionURL.Path, decision)
+	options, err := updateExecution.authenticatedRequestOptions(http.MethodPost, decisionURL.String(), []byte(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	response, err := grequests.Post(decisionURL.String(), options)
+	if err != nil {
+		return nil, err
+	}
+	err = verifyRemoteResponse(response)
+	if err != nil {
+		return nil, err
+	}
+	updateProgressSerialized := &web.UpdateProgressSerialized{}
+	err = response.JSON(updateProgressSerialized)
+	if err != nil {
+		return nil, err
+	}
+	return updateProgressSerialized, nil
+}
+
 func (updateExecution *UpdateExecution) objectURL() *url.URL {
 	parsedURL, _ := url.Parse(updateExecution.updateCommand.TargetEndpoint)
 	parsedURL.Path = path.Join(parsedURL.Path, updateExecution.UUID().String())
 	return parsedURL
 }
 
+// verifyRemoteResponse checks the status code of the response and adds the error description of the update manager,
+// if one has been returned, to unexpected status codes.
+func verifyRemoteResponse(response *grequests.Response) error {
+	err := verifyRemoteStatusCode(response.StatusCode)
+	if err == nil || err == ErrUnauthorized || os.IsNotExist(err) {
+		return err
+	}
+	errorSerialized := &web.ErrorSerialized{}
+	if response.JSON(errorSerialized) == nil && len(errorSerialized.Error) > 0 {
+		return fmt.Errorf("%s: %s", err.Error(), errorSerialized.Error)
+	}
+	return err
+}
+
 func verifyRemoteStatusCode(statusCode int) error {
 	switch statusCode {
 	case http.StatusNotFound:
@@ -0,0 +1,78 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+
+	"kubernetes-update-manager/updater"
+
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// LoadAnalysisPolicyFile reads the analysis policy from the passed YAML or JSON file.
+func LoadAnalysisPolicyFile(file string) (*AnalysisPolicy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	analysisPolicy := &AnalysisPolicy{}
+	err = yaml.UnmarshalStrict(data, analysisPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return analysisPolicy, analysisPolicy.validate()
+}
+
+// AnalysisRule configures the queries the deployments of updates with an update classifier are analyzed with.
+type AnalysisRule struct {
+	// Window is the time the metrics of the ready deployments are analyzed for, for example 10m.
+	Window *metaV1.Duration `json:"window,omitempty"`
+	// Interval is the time between two evaluations of the queries, for example 30s.
+	Interval *metaV1.Duration `json:"interval,omitempty"`
+	// Queries are evaluated for every deployment of the update.
+	Queries []updater.AnalysisQuery `json:"queries,omitempty"`
+}
+
+// AnalysisPolicy configures the metric analysis of updates per update classifier.
+type AnalysisPolicy struct {
+	// Classifiers maps the update classifiers to the analysis of their updates.
+	Classifiers map[string]AnalysisRule `json:"classifiers,omitempty"`
+}
+
+// Analysis returns the analysis of updates with the update classifier evaluated by the querier. Deployments with an
+// analysis annotation are analyzed even if the policy is nil or does not configure the update classifier.
+func (analysisPolicy *AnalysisPolicy) Analysis(querier updater.MetricQuerier, updateClassifier string) *updater.Analysis {
+	analysis := &updater.Analysis{
+		Querier:  querier,
+		Window:   updater.DefaultAnalysisWindow,
+		Interval: updater.DefaultAnalysisInterval,
+	}
+	if analysisPolicy == nil {
+		return analysis
+	}
+	rule, ok := analysisPolicy.Classifiers[updateClassifier]
+	if !ok {
+		return analysis
+	}
+	if rule.Window != nil && rule.Window.Duration > 0 {
+		analysis.Window = rule.Window.Duration
+	}
+	if rule.Interval != nil && rule.Interval.Duration > 0 {
+		analysis.Interval = rule.Interval.Duration
+	}
+	analysis.Queries = rule.Queries
+	return analysis
+}
+
+func (analysisPolicy *AnalysisPolicy) validate() error {
+	for updateClassifier, rule := range analysisPolicy.Classifiers {
+		for _, query := range rule.Queries {
+			err := query.Validate()
+			if err != nil {
+				return fmt.Errorf("Invalid analysis query of update classifier %q: %w", updateClassifier, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"kubernetes-update-manager/updater"
+
+	. "gopkg.in/check.v1"
+)
+
+const analysisPolicyYAML = `
+classifiers:
+  production:
+    window: 10m
+    interval: 1m
+    queries:
+      - name: errors
+        query: sum(rate(http_errors_total{namespace="$namespace"}[1m]))
+        max: 1
+  staging:
+    queries:
+      - query: up{deployment="$deployment"}
+        min: 1
+`
+
+type AnalysisPolicySuite struct {
+	analysisPolicy *AnalysisPolicy
+}
+
+var _ = Suite(&AnalysisPolicySuite{})
+
+type nopQuerier struct{}
+
+func (nopQuerier) Query(string) ([]float64, error) {
+	return nil, nil
+}
+
+func (suite *AnalysisPolicySuite) SetUpTest(c *C) {
+	file := filepath.Join(c.MkDir(), "analysis.yaml")
+	c.Assert(os.WriteFile(file, []byte(analysisPolicyYAML), 0600), IsNil)
+	analysisPolicy, err := LoadAnalysisPolicyFile(file)
+	c.Assert(err, IsNil)
+	suite.analysisPolicy = analysisPolicy
+}
+
+func (suite *AnalysisPolicySuite) TestAnalysis(c *C) {
+	analysis := suite.analysisPolicy.Analysis(nopQuerier{}, "production")
+	c.Assert(analysis.Window, Equals, 10*time.Minute)
+	c.Assert(analysis.Interval, Equals, time.Minute)
+	c.Assert(analysis.Queries, HasLen, 1)
+	c.Assert(analysis.Queries[0].Name, Equals, "errors")
+	c.Assert(*analysis.Queries[0].Max, Equals, 1.0)
+	c.Assert(analysis.Queries[0].Min, IsNil)
+}
+
+func (suite *AnalysisPolicySuite) TestDefaults(c *C) {
+	analysis := suite.analysisPolicy.Analysis(nopQuerier{}, "staging")
+	c.Assert(analysis.Window, Equals, updater.DefaultAnalysisWindow)
+	c.Assert(analysis.Interval, Equals, updater.DefaultAnalysisInterval)
+	c.Assert(analysis.Queries, HasLen, 1)
+}
+
+func (suite *AnalysisPolicySuite) TestNoRule(c *C) {
+	analysis := suite.analysisPolicy.Analysis(nopQuerier{}, "stable")
+	c.Assert(analysis.Queries, HasLen, 0)
+	c.Assert(analysis.Querier, NotNil)
+
+	var analysisPolicy *AnalysisPolicy
+	analysis = analysisPolicy.Analysis(nopQuerier{}, "stable")
+	c.Assert(analysis.Queries, HasLen, 0)
+	c.Assert(analysis.Window, Equals, updater.DefaultAnalysisWindow)
+}
+
+func (suite *AnalysisPolicySuite) TestLoadQueryWithoutThreshold(c *C) {
+	file := filepath.Join(c.MkDir(), "analysis.yaml")
+	c.Assert(os.WriteFile(file, []byte("classifiers: {production: {queries: [{query: up}]}}"), 0600), IsNil)
+	_, err := LoadAnalysisPolicyFile(file)
+	c.Assert(err, ErrorMatches, `Invalid analysis query of update classifier "production": The analysis query needs a min or max threshold`)
+}
+
+func (suite *AnalysisPolicySuite) TestLoadUnknownField(c *C) {
+	file := filepath.Join(c.MkDir(), "analysis.yaml")
+	c.Assert(os.WriteFile(file, []byte("classifiers: {production: {duration: 5m}}"), 0600), IsNil)
+	_, err := LoadAnalysisPolicyFile(file)
+	c.Assert(err, NotNil)
+}
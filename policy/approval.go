@@ -0,0 +1,93 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// DefaultApprovalExpiry is the time after which updates which have not been approved expire, if the rule does not
+	// configure an expiry.
+	DefaultApprovalExpiry = time.Hour
+)
+
+// LoadApprovalPolicyFile reads the approval policy from the passed YAML or JSON file.
+func LoadApprovalPolicyFile(file string) (*ApprovalPolicy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	approvalPolicy := &ApprovalPolicy{}
+	err = yaml.UnmarshalStrict(data, approvalPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return approvalPolicy, approvalPolicy.validate()
+}
+
+// ApprovalRule configures the approvals an update with an update classifier needs before it runs.
+type ApprovalRule struct {
+	// RequiredApprovers is the number of principals, different from the requester, which need to approve the update.
+	RequiredApprovers int `json:"requiredApprovers"`
+	// Approvers are patterns of the names of the principals which may approve or reject the update. An empty list
+	// allows every principal whose scope covers the update.
+	Approvers []string `json:"approvers,omitempty"`
+	// Expiry is the duration after which the update expires if it has not been approved, for example 30m.
+	Expiry *metaV1.Duration `json:"expiry,omitempty"`
+}
+
+// ApprovalPolicy holds updates with protected update classifiers until they have been approved.
+type ApprovalPolicy struct {
+	// Classifiers maps the update classifiers to the approvals their updates need.
+	Classifiers map[string]ApprovalRule `json:"classifiers,omitempty"`
+}
+
+// Rule returns the approval rule for updates with the update classifier or nil, if they do not need to be approved.
+func (approvalPolicy *ApprovalPolicy) Rule(updateClassifier string) *ApprovalRule {
+	rule, ok := approvalPolicy.Classifiers[updateClassifier]
+	if !ok || rule.RequiredApprovers <= 0 {
+		return nil
+	}
+	return &rule
+}
+
+// Allows returns if the principal with the name may approve or reject updates of the rule.
+func (rule *ApprovalRule) Allows(name string) bool {
+	if len(rule.Approvers) == 0 {
+		return true
+	}
+	for _, pattern := range rule.Approvers {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpiryDuration returns the duration after which updates which have not been approved expire.
+func (rule *ApprovalRule) ExpiryDuration() time.Duration {
+	if rule.Expiry == nil || rule.Expiry.Duration <= 0 {
+		return DefaultApprovalExpiry
+	}
+	return rule.Expiry.Duration
+}
+
+func (approvalPolicy *ApprovalPolicy) validate() error {
+	for updateClassifier, rule := range approvalPolicy.Classifiers {
+		if rule.RequiredApprovers < 0 {
+			return fmt.Errorf("The required approvers of update classifier %q must not be negative", updateClassifier)
+		}
+		for _, pattern := range rule.Approvers {
+			_, err := path.Match(pattern, "")
+			if err != nil {
+				return fmt.Errorf("Invalid approver pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+const approvalPolicyYAML = `
+classifiers:
+  production:
+    requiredApprovers: 2
+    approvers:
+      - release-*
+    expiry: 30m
+  staging:
+    requiredApprovers: 1
+  develop:
+    requiredApprovers: 0
+`
+
+type ApprovalPolicySuite struct {
+	approvalPolicy *ApprovalPolicy
+}
+
+var _ = Suite(&ApprovalPolicySuite{})
+
+func (suite *ApprovalPolicySuite) SetUpTest(c *C) {
+	file := filepath.Join(c.MkDir(), "approvals.yaml")
+	c.Assert(os.WriteFile(file, []byte(approvalPolicyYAML), 0600), IsNil)
+	approvalPolicy, err := LoadApprovalPolicyFile(file)
+	c.Assert(err, IsNil)
+	suite.approvalPolicy = approvalPolicy
+}
+
+func (suite *ApprovalPolicySuite) TestRule(c *C) {
+	rule := suite.approvalPolicy.Rule("production")
+	c.Assert(rule, NotNil)
+	c.Assert(rule.RequiredApprovers, Equals, 2)
+	c.Assert(rule.ExpiryDuration(), Equals, 30*time.Minute)
+}
+
+func (suite *ApprovalPolicySuite) TestNoRule(c *C) {
+	c.Assert(suite.approvalPolicy.Rule("stable"), IsNil)
+	c.Assert(suite.approvalPolicy.Rule("develop"), IsNil)
+}
+
+func (suite *ApprovalPolicySuite) TestDefaultExpiry(c *C) {
+	c.Assert(suite.approvalPolicy.Rule("staging").ExpiryDuration(), Equals, DefaultApprovalExpiry)
+}
+
+func (suite *ApprovalPolicySuite) TestAllows(c *C) {
+	rule := suite.approvalPolicy.Rule("production")
+	c.Assert(rule.Allows("release-manager"), Equals, true)
+	c.Assert(rule.Allows("ci"), Equals, false)
+	c.Assert(suite.approvalPolicy.Rule("staging").Allows("ci"), Equals, true)
+}
+
+func (suite *ApprovalPolicySuite) TestLoadNegativeApprovers(c *C) {
+	file := filepath.Join(c.MkDir(), "approvals.yaml")
+	c.Assert(os.WriteFile(file, []byte("classifiers: {production: {requiredApprovers: -1}}"), 0600), IsNil)
+	_, err := LoadApprovalPolicyFile(file)
+	c.Assert(err, ErrorMatches, ".*must not be negative")
+}
+
+func (suite *ApprovalPolicySuite) TestLoadUnknownField(c *C) {
+	file := filepath.Join(c.MkDir(), "approvals.yaml")
+	c.Assert(os.WriteFile(file, []byte("classifiers: {production: {approvals: 1}}"), 0600), IsNil)
+	_, err := LoadApprovalPolicyFile(file)
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,147 @@
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSearchLimit is how far in the future the next fire time of a cron schedule is searched.
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// cronField describes the range of values of a field of a cron expression.
+type cronField struct {
+	name string
+	min  int
+	max  int
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	{name: "day of week", min: 0, max: 7},
+}
+
+// cronSchedule is a parsed cron expression with the five fields minute, hour, day of month, month and day of week.
+// Each field holds a bit set of the values it matches.
+type cronSchedule struct {
+	minutes  uint64
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+	// anyDay and anyWeekday are set if the respective field is *. As in cron, a time matches if either the day of month
+	// or the day of week matches, unless one of them is *.
+	anyDay     bool
+	anyWeekday bool
+}
+
+// parseCron parses a cron expression like "0 18 * * 5". Each field may be *, a value, a range like 1-5, a step like */15
+// or 1-5/2 or a comma separated list of those. Sunday is either 0 or 7.
+func parseCron(expression string) (*cronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("Cron expression %q must have %d fields", expression, len(cronFields))
+	}
+	values := make([]uint64, len(fields))
+	for index, field := range fields {
+		bits, err := parseCronField(field, cronFields[index])
+		if err != nil {
+			return nil, fmt.Errorf("Cron expression %q: %w", expression, err)
+		}
+		values[index] = bits
+	}
+	weekdays := values[4]
+	if weekdays&(1<<7) != 0 {
+		weekdays |= 1
+	}
+	return &cronSchedule{
+		minutes:    values[0],
+		hours:      values[1],
+		days:       values[2],
+		months:     values[3],
+		weekdays:   weekdays,
+		anyDay:     fields[2] == "*",
+		anyWeekday: fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(expression string, field cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expression, ",") {
+		rangeExpression, step := part, 1
+		if index := strings.Index(part, "/"); index >= 0 {
+			rangeExpression = part[:index]
+			var err error
+			step, err = strconv.Atoi(part[index+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("Invalid step in %s field %q", field.name, part)
+			}
+		}
+		start, end := field.min, field.max
+		if rangeExpression != "*" {
+			bounds := strings.SplitN(rangeExpression, "-", 2)
+			var err error
+			start, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return 0, fmt.Errorf("Invalid value in %s field %q", field.name, part)
+			}
+			end = start
+			if len(bounds) == 2 {
+				end, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return 0, fmt.Errorf("Invalid value in %s field %q", field.name, part)
+				}
+			} else if step > 1 {
+				end = field.max
+			}
+		}
+		if start < field.min || end > field.max || start > end {
+			return 0, fmt.Errorf("The %s field %q is out of the range %d-%d", field.name, part, field.min, field.max)
+		}
+		for value := start; value <= end; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+// next returns the first time after the passed one, in the location of the passed time, the schedule fires at. False is
+// returned if the schedule does not fire within the search limit, for example on the 31st of February.
+func (schedule *cronSchedule) next(after time.Time) (time.Time, bool) {
+	location := after.Location()
+	current := time.Date(after.Year(), after.Month(), after.Day(), after.Hour(), after.Minute(), 0, 0, location).Add(time.Minute)
+	limit := after.Add(cronSearchLimit)
+	for current.Before(limit) {
+		year, month, day := current.Date()
+		switch {
+		case !hasBit(schedule.months, int(month)):
+			current = time.Date(year, month+1, 1, 0, 0, 0, 0, location)
+		case !schedule.matchesDay(current):
+			current = time.Date(year, month, day+1, 0, 0, 0, 0, location)
+		case !hasBit(schedule.hours, current.Hour()):
+			current = time.Date(year, month, day, current.Hour()+1, 0, 0, 0, location)
+		case !hasBit(schedule.minutes, current.Minute()):
+			current = current.Add(time.Minute)
+		default:
+			return current, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (schedule *cronSchedule) matchesDay(moment time.Time) bool {
+	day := hasBit(schedule.days, moment.Day())
+	weekday := hasBit(schedule.weekdays, int(moment.Weekday()))
+	if schedule.anyDay || schedule.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+func hasBit(bits uint64, value int) bool {
+	return bits&(1<<uint(value)) != 0
+}
@@ -0,0 +1,62 @@
+package policy
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type CronSuite struct{}
+
+var _ = Suite(&CronSuite{})
+
+func (suite *CronSuite) next(c *C, expression string, after time.Time) time.Time {
+	schedule, err := parseCron(expression)
+	c.Assert(err, IsNil)
+	next, ok := schedule.next(after)
+	c.Assert(ok, Equals, true)
+	return next
+}
+
+func (suite *CronSuite) TestNextMinute(c *C) {
+	after := time.Date(2024, 3, 1, 10, 15, 30, 0, time.UTC)
+	c.Assert(suite.next(c, "* * * * *", after), Equals, time.Date(2024, 3, 1, 10, 16, 0, 0, time.UTC))
+}
+
+func (suite *CronSuite) TestNextWeekday(c *C) {
+	// 2024-03-01 is a Friday
+	after := time.Date(2024, 3, 1, 19, 0, 0, 0, time.UTC)
+	c.Assert(suite.next(c, "0 18 * * 5", after), Equals, time.Date(2024, 3, 8, 18, 0, 0, 0, time.UTC))
+	c.Assert(suite.next(c, "30 6 * * 1-5", after), Equals, time.Date(2024, 3, 4, 6, 30, 0, 0, time.UTC))
+}
+
+func (suite *CronSuite) TestSundayAsSeven(c *C) {
+	after := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	c.Assert(suite.next(c, "0 0 * * 7", after), Equals, time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC))
+}
+
+func (suite *CronSuite) TestStepsAndLists(c *C) {
+	after := time.Date(2024, 3, 1, 10, 16, 0, 0, time.UTC)
+	c.Assert(suite.next(c, "*/15 * * * *", after), Equals, time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC))
+	c.Assert(suite.next(c, "0 8,20 * * *", after), Equals, time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC))
+}
+
+func (suite *CronSuite) TestDayOfMonthOrWeekday(c *C) {
+	after := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	c.Assert(suite.next(c, "0 0 24 12 *", after), Equals, time.Date(2024, 12, 24, 0, 0, 0, 0, time.UTC))
+	c.Assert(suite.next(c, "0 0 15 * 1", after), Equals, time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC))
+}
+
+func (suite *CronSuite) TestNeverFires(c *C) {
+	schedule, err := parseCron("0 0 31 2 *")
+	c.Assert(err, IsNil)
+	_, ok := schedule.next(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	c.Assert(ok, Equals, false)
+}
+
+func (suite *CronSuite) TestInvalidExpressions(c *C) {
+	for _, expression := range []string{"* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		_, err := parseCron(expression)
+		c.Assert(err, NotNil, Commentf("expression %q", expression))
+	}
+}
@@ -0,0 +1,332 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// FreezeAction describes how updates requested while a freeze calendar blocks them are handled.
+type FreezeAction string
+
+const (
+	// FreezeActionReject rejects updates requested outside of the allowed windows.
+	FreezeActionReject FreezeAction = "reject"
+	// FreezeActionQueue schedules updates requested outside of the allowed windows for the start of the next allowed window.
+	FreezeActionQueue FreezeAction = "queue"
+
+	// maxFreezeIterations limits the search for the next time no freeze blocks an update.
+	maxFreezeIterations = 1000
+)
+
+// ErrNoAllowedWindow is returned if no time could be found at which the freeze calendar allows an update.
+var ErrNoAllowedWindow = errors.New("No allowed window to start the update could be found")
+
+// LoadFreezeCalendarFile reads the freeze calendar from the passed YAML or JSON file.
+func LoadFreezeCalendarFile(file string) (*FreezeCalendar, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	freezeCalendar := &FreezeCalendar{}
+	err = yaml.UnmarshalStrict(data, freezeCalendar)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return freezeCalendar, freezeCalendar.validate()
+}
+
+// Window is a period of time. It either recurs, starting whenever the cron schedule fires and lasting for the
+// duration, or is an absolute date range.
+type Window struct {
+	// Schedule is a cron expression with the fields minute, hour, day of month, month and day of week, for example
+	// "0 18 * * 5" for every Friday at 18:00.
+	Schedule string `json:"schedule,omitempty"`
+	// Duration is how long the window lasts after the schedule fired, for example 62h.
+	Duration *metaV1.Duration `json:"duration,omitempty"`
+	// From is the start of an absolute window in RFC 3339, for example 2024-12-20T00:00:00+01:00.
+	From *metaV1.Time `json:"from,omitempty"`
+	// Until is the end of an absolute window in RFC 3339. It is not part of the window.
+	Until *metaV1.Time `json:"until,omitempty"`
+	cron  *cronSchedule
+}
+
+// FreezeRule restricts when updates of the matching update classifiers and namespaces may be started.
+type FreezeRule struct {
+	// Name identifies the rule in the reasons given for blocked updates.
+	Name string `json:"name"`
+	// Classifiers are patterns of the update classifiers the rule applies to. An empty list matches all of them.
+	Classifiers []string `json:"classifiers,omitempty"`
+	// Namespaces are patterns of the namespaces the rule applies to. The rule applies if any resource touched by the
+	// update resides in a matching namespace. An empty list matches all of them.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Freezes are the windows in which no updates may be started.
+	Freezes []Window `json:"freezes,omitempty"`
+	// MaintenanceWindows are the windows in which updates may be started. If empty, updates may be started at any
+	// time outside of the freezes.
+	MaintenanceWindows []Window `json:"maintenanceWindows,omitempty"`
+	// Action is either reject or queue and defaults to reject.
+	Action FreezeAction `json:"action,omitempty"`
+}
+
+// FreezeCalendar restricts the times at which updates may be started.
+type FreezeCalendar struct {
+	// Timezone is the IANA name of the location the cron schedules are evaluated in. It defaults to UTC.
+	Timezone string `json:"timezone,omitempty"`
+	// Rules are the freeze rules of the calendar. An update has to be allowed by all matching rules.
+	Rules    []FreezeRule `json:"rules,omitempty"`
+	location *time.Location
+}
+
+// Freeze describes why an update may not be started at the moment.
+type Freeze struct {
+	// Action is reject if any of the blocking rules rejects updates and queue otherwise.
+	Action FreezeAction
+	// Reason describes the blocking rules.
+	Reason string
+	// Until is the earliest time the update may be started at. It is the zero time if no such time could be found.
+	Until time.Time
+}
+
+// Check returns the freeze blocking an update with the update classifier, touching resources in the namespaces, at
+// the passed time or nil, if the update may be started.
+func (freezeCalendar *FreezeCalendar) Check(updateClassifier string, namespaces []string, now time.Time) *Freeze {
+	rules := freezeCalendar.rulesFor(updateClassifier, namespaces)
+	now = now.In(freezeCalendar.timezone())
+	blocking := blockingRules(rules, now)
+	if len(blocking) == 0 {
+		return nil
+	}
+	freeze := &Freeze{Action: FreezeActionQueue, Reason: describeBlocking(blocking)}
+	for _, rule := range blocking {
+		if rule.Action != FreezeActionQueue {
+			freeze.Action = FreezeActionReject
+		}
+	}
+	until, err := nextAllowedTime(rules, now)
+	if err != nil {
+		freeze.Action = FreezeActionReject
+		freeze.Reason = fmt.Sprintf("%s: %s", freeze.Reason, err.Error())
+		return freeze
+	}
+	freeze.Until = until
+	return freeze
+}
+
+func (freezeCalendar *FreezeCalendar) rulesFor(updateClassifier string, namespaces []string) []FreezeRule {
+	rules := make([]FreezeRule, 0)
+	for _, rule := range freezeCalendar.Rules {
+		if rule.matches(updateClassifier, namespaces) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+func (freezeCalendar *FreezeCalendar) timezone() *time.Location {
+	if freezeCalendar.location == nil {
+		return time.UTC
+	}
+	return freezeCalendar.location
+}
+
+func (freezeCalendar *FreezeCalendar) validate() error {
+	if len(freezeCalendar.Timezone) > 0 {
+		location, err := time.LoadLocation(freezeCalendar.Timezone)
+		if err != nil {
+			return fmt.Errorf("Invalid timezone %q: %w", freezeCalendar.Timezone, err)
+		}
+		freezeCalendar.location = location
+	}
+	for index := range freezeCalendar.Rules {
+		rule := &freezeCalendar.Rules[index]
+		if len(rule.Name) == 0 {
+			rule.Name = fmt.Sprintf("rule %d", index+1)
+		}
+		err := rule.validate()
+		if err != nil {
+			return fmt.Errorf("Freeze rule %s: %w", rule.Name, err)
+		}
+	}
+	return nil
+}
+
+func (rule *FreezeRule) matches(updateClassifier string, namespaces []string) bool {
+	if len(rule.Classifiers) > 0 && !matchesAnyPattern(rule.Classifiers, updateClassifier) {
+		return false
+	}
+	if len(rule.Namespaces) == 0 {
+		return true
+	}
+	for _, namespace := range namespaces {
+		if matchesAnyPattern(rule.Namespaces, namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// blockedUntil returns if the rule blocks updates at the passed time and the time until which it blocks them. The zero
+// time is returned if the rule blocks updates for ever.
+func (rule *FreezeRule) blockedUntil(now time.Time) (bool, time.Time) {
+	blocked, until := false, time.Time{}
+	for _, freeze := range rule.Freezes {
+		if active, end := freeze.activeAt(now); active {
+			blocked = true
+			if end.After(until) {
+				until = end
+			}
+		}
+	}
+	if len(rule.MaintenanceWindows) == 0 || blocked {
+		return blocked, until
+	}
+	var nextStart time.Time
+	for _, maintenanceWindow := range rule.MaintenanceWindows {
+		if active, _ := maintenanceWindow.activeAt(now); active {
+			return false, time.Time{}
+		}
+		if start, ok := maintenanceWindow.nextStart(now); ok && (nextStart.IsZero() || start.Before(nextStart)) {
+			nextStart = start
+		}
+	}
+	return true, nextStart
+}
+
+func (rule *FreezeRule) validate() error {
+	switch rule.Action {
+	case "":
+		rule.Action = FreezeActionReject
+	case FreezeActionReject, FreezeActionQueue:
+	default:
+		return fmt.Errorf("Unknown action %q, expected %s or %s", rule.Action, FreezeActionReject, FreezeActionQueue)
+	}
+	for _, pattern := range append(append([]string{}, rule.Classifiers...), rule.Namespaces...) {
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			return fmt.Errorf("Invalid pattern %q: %w", pattern, err)
+		}
+	}
+	for index := range rule.Freezes {
+		err := rule.Freezes[index].validate()
+		if err != nil {
+			return err
+		}
+	}
+	for index := range rule.MaintenanceWindows {
+		err := rule.MaintenanceWindows[index].validate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// activeAt returns if the window contains the passed time and when it ends.
+func (window *Window) activeAt(now time.Time) (bool, time.Time) {
+	if window.cron == nil {
+		if now.Before(window.From.Time) || !now.Before(window.Until.Time) {
+			return false, time.Time{}
+		}
+		return true, window.Until.Time
+	}
+	start, ok := window.cron.next(now.Add(-window.Duration.Duration))
+	if !ok || start.After(now) {
+		return false, time.Time{}
+	}
+	return true, start.Add(window.Duration.Duration)
+}
+
+// nextStart returns the first start of the window after the passed time.
+func (window *Window) nextStart(now time.Time) (time.Time, bool) {
+	if window.cron == nil {
+		return window.From.Time, now.Before(window.From.Time)
+	}
+	return window.cron.next(now)
+}
+
+func (window *Window) validate() error {
+	if len(window.Schedule) > 0 {
+		if window.From != nil || window.Until != nil {
+			return fmt.Errorf("A window must either have a schedule or from and until, not both")
+		}
+		if window.Duration == nil || window.Duration.Duration <= 0 {
+			return fmt.Errorf("The window with schedule %q needs a positive duration", window.Schedule)
+		}
+		cron, err := parseCron(window.Schedule)
+		if err != nil {
+			return err
+		}
+		window.cron = cron
+		return nil
+	}
+	if window.From == nil || window.Until == nil {
+		return fmt.Errorf("A window needs either a schedule and duration or from and until")
+	}
+	if !window.From.Before(window.Until) {
+		return fmt.Errorf("The window from %s must end after it starts", window.From.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// blockingRules returns the rules which block updates at the passed time.
+func blockingRules(rules []FreezeRule, now time.Time) []FreezeRule {
+	blocking := make([]FreezeRule, 0)
+	for _, rule := range rules {
+		if blocked, _ := rule.blockedUntil(now); blocked {
+			blocking = append(blocking, rule)
+		}
+	}
+	return blocking
+}
+
+// nextAllowedTime returns the first time at or after the passed one at which none of the rules blocks updates.
+func nextAllowedTime(rules []FreezeRule, now time.Time) (time.Time, error) {
+	candidate := now
+	for iteration := 0; iteration < maxFreezeIterations; iteration++ {
+		blocked, latestEnd := false, candidate
+		for _, rule := range rules {
+			ruleBlocked, until := rule.blockedUntil(candidate)
+			if !ruleBlocked {
+				continue
+			}
+			if until.IsZero() {
+				return time.Time{}, ErrNoAllowedWindow
+			}
+			blocked = true
+			if until.After(latestEnd) {
+				latestEnd = until
+			}
+		}
+		if !blocked {
+			return candidate, nil
+		}
+		candidate = latestEnd
+	}
+	return time.Time{}, ErrNoAllowedWindow
+}
+
+func describeBlocking(rules []FreezeRule) string {
+	reason := "Updates are frozen by "
+	for index, rule := range rules {
+		if index > 0 {
+			reason += ", "
+		}
+		reason += rule.Name
+	}
+	return reason
+}
+
+func matchesAnyPattern(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
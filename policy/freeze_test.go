@@ -0,0 +1,132 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+const freezeCalendarYAML = `
+timezone: Europe/Berlin
+rules:
+  - name: weekend freeze
+    classifiers:
+      - stable
+    action: queue
+    freezes:
+      - schedule: "0 18 * * 5"
+        duration: 62h
+  - name: holidays
+    namespaces:
+      - shop-*
+    freezes:
+      - from: 2024-12-20T00:00:00+01:00
+        until: 2025-01-02T00:00:00+01:00
+  - name: office hours
+    classifiers:
+      - production
+    action: queue
+    maintenanceWindows:
+      - schedule: "0 9 * * 1-5"
+        duration: 8h
+`
+
+type FreezeCalendarSuite struct {
+	freezeCalendar *FreezeCalendar
+	location       *time.Location
+}
+
+var _ = Suite(&FreezeCalendarSuite{})
+
+func (suite *FreezeCalendarSuite) SetUpTest(c *C) {
+	suite.freezeCalendar = suite.load(c, freezeCalendarYAML)
+	location, err := time.LoadLocation("Europe/Berlin")
+	c.Assert(err, IsNil)
+	suite.location = location
+}
+
+func (suite *FreezeCalendarSuite) load(c *C, content string) *FreezeCalendar {
+	file := filepath.Join(c.MkDir(), "freezes.yaml")
+	c.Assert(os.WriteFile(file, []byte(content), 0600), IsNil)
+	freezeCalendar, err := LoadFreezeCalendarFile(file)
+	c.Assert(err, IsNil)
+	return freezeCalendar
+}
+
+func (suite *FreezeCalendarSuite) at(year int, month time.Month, day int, hour int) time.Time {
+	return time.Date(year, month, day, hour, 0, 0, 0, suite.location)
+}
+
+func (suite *FreezeCalendarSuite) TestAllowed(c *C) {
+	// 2024-03-06 is a Wednesday
+	c.Assert(suite.freezeCalendar.Check("stable", []string{"default"}, suite.at(2024, 3, 6, 12)), IsNil)
+}
+
+func (suite *FreezeCalendarSuite) TestRecurringFreezeQueues(c *C) {
+	freeze := suite.freezeCalendar.Check("stable", []string{"default"}, suite.at(2024, 3, 9, 12))
+	c.Assert(freeze, NotNil)
+	c.Assert(freeze.Action, Equals, FreezeActionQueue)
+	c.Assert(freeze.Reason, Equals, "Updates are frozen by weekend freeze")
+	c.Assert(freeze.Until.Equal(suite.at(2024, 3, 11, 8)), Equals, true)
+}
+
+func (suite *FreezeCalendarSuite) TestRecurringFreezeDoesNotApplyToOtherClassifiers(c *C) {
+	c.Assert(suite.freezeCalendar.Check("develop", []string{"default"}, suite.at(2024, 3, 9, 12)), IsNil)
+}
+
+func (suite *FreezeCalendarSuite) TestAbsoluteFreezeRejectsMatchingNamespaces(c *C) {
+	freeze := suite.freezeCalendar.Check("develop", []string{"default", "shop-api"}, suite.at(2024, 12, 24, 12))
+	c.Assert(freeze, NotNil)
+	c.Assert(freeze.Action, Equals, FreezeActionReject)
+	c.Assert(freeze.Until.Equal(suite.at(2025, 1, 2, 0)), Equals, true)
+	c.Assert(suite.freezeCalendar.Check("develop", []string{"default"}, suite.at(2024, 12, 24, 12)), IsNil)
+}
+
+func (suite *FreezeCalendarSuite) TestOverlappingFreezesAreSkipped(c *C) {
+	// The holidays end on a Thursday, so the update is queued until the end of the holidays.
+	freeze := suite.freezeCalendar.Check("stable", []string{"shop-api"}, suite.at(2024, 12, 28, 12))
+	c.Assert(freeze, NotNil)
+	c.Assert(freeze.Action, Equals, FreezeActionReject)
+	c.Assert(freeze.Reason, Equals, "Updates are frozen by weekend freeze, holidays")
+	c.Assert(freeze.Until.Equal(suite.at(2025, 1, 2, 0)), Equals, true)
+}
+
+func (suite *FreezeCalendarSuite) TestMaintenanceWindow(c *C) {
+	c.Assert(suite.freezeCalendar.Check("production", nil, suite.at(2024, 3, 6, 10)), IsNil)
+	freeze := suite.freezeCalendar.Check("production", nil, suite.at(2024, 3, 6, 18))
+	c.Assert(freeze, NotNil)
+	c.Assert(freeze.Action, Equals, FreezeActionQueue)
+	c.Assert(freeze.Until.Equal(suite.at(2024, 3, 7, 9)), Equals, true)
+}
+
+func (suite *FreezeCalendarSuite) TestNoAllowedWindowRejects(c *C) {
+	freezeCalendar := suite.load(c, `
+rules:
+  - name: forever
+    action: queue
+    maintenanceWindows:
+      - from: 2020-01-01T00:00:00Z
+        until: 2020-01-02T00:00:00Z
+`)
+	freeze := freezeCalendar.Check("stable", nil, time.Date(2024, 3, 6, 12, 0, 0, 0, time.UTC))
+	c.Assert(freeze, NotNil)
+	c.Assert(freeze.Action, Equals, FreezeActionReject)
+	c.Assert(freeze.Until.IsZero(), Equals, true)
+}
+
+func (suite *FreezeCalendarSuite) TestLoadInvalidWindows(c *C) {
+	for _, content := range []string{
+		"rules:\n  - freezes:\n      - schedule: \"0 18 * * 5\"\n",
+		"rules:\n  - freezes:\n      - schedule: \"0 18 * *\"\n        duration: 1h\n",
+		"rules:\n  - freezes:\n      - from: 2024-01-02T00:00:00Z\n        until: 2024-01-01T00:00:00Z\n",
+		"rules:\n  - action: wait\n",
+		"timezone: Mars/Olympus\n",
+	} {
+		file := filepath.Join(c.MkDir(), "freezes.yaml")
+		c.Assert(os.WriteFile(file, []byte(content), 0600), IsNil)
+		_, err := LoadFreezeCalendarFile(file)
+		c.Assert(err, NotNil, Commentf("calendar %q", content))
+	}
+}
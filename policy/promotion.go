@@ -0,0 +1,75 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// LoadPromotionPolicyFile reads the promotion policy from the passed YAML or JSON file.
+func LoadPromotionPolicyFile(file string) (*PromotionPolicy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	promotionPolicy := &PromotionPolicy{}
+	err = yaml.UnmarshalStrict(data, promotionPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return promotionPolicy, promotionPolicy.validate()
+}
+
+// PromotionRule configures the update classifier the image of a successful update is promoted to.
+type PromotionRule struct {
+	// To is the update classifier the image is promoted to.
+	To string `json:"to"`
+	// After is the duration, for example 30m, the update soaks after it succeeded before the promoted update starts.
+	After *metaV1.Duration `json:"after,omitempty"`
+}
+
+// PromotionPolicy schedules updates of further update classifiers with the image of successful updates.
+type PromotionPolicy struct {
+	// Classifiers maps the update classifiers to the promotion of their successful updates.
+	Classifiers map[string]PromotionRule `json:"classifiers,omitempty"`
+}
+
+// Rule returns the promotion rule for updates with the update classifier or nil, if they are not promoted.
+func (promotionPolicy *PromotionPolicy) Rule(updateClassifier string) *PromotionRule {
+	rule, ok := promotionPolicy.Classifiers[updateClassifier]
+	if !ok {
+		return nil
+	}
+	return &rule
+}
+
+// Delay returns the time between the success of an update and the start of the promoted update.
+func (rule *PromotionRule) Delay() time.Duration {
+	if rule.After == nil {
+		return 0
+	}
+	return rule.After.Duration
+}
+
+func (promotionPolicy *PromotionPolicy) validate() error {
+	for updateClassifier, rule := range promotionPolicy.Classifiers {
+		if len(rule.To) == 0 {
+			return fmt.Errorf("The promotion of update classifier %q needs the update classifier to promote to", updateClassifier)
+		}
+		if rule.Delay() < 0 {
+			return fmt.Errorf("The promotion delay of update classifier %q must not be negative", updateClassifier)
+		}
+		// Following the promotions must not lead back to an update classifier, as updates would be promoted forever.
+		visited := map[string]bool{updateClassifier: true}
+		for next := &rule; next != nil; next = promotionPolicy.Rule(next.To) {
+			if visited[next.To] {
+				return fmt.Errorf("The promotions of update classifier %q form a cycle", updateClassifier)
+			}
+			visited[next.To] = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+const promotionPolicyYAML = `
+classifiers:
+  develop:
+    to: staging
+  staging:
+    to: stable
+    after: 30m
+`
+
+type PromotionPolicySuite struct {
+	promotionPolicy *PromotionPolicy
+}
+
+var _ = Suite(&PromotionPolicySuite{})
+
+func (suite *PromotionPolicySuite) load(c *C, content string) (*PromotionPolicy, error) {
+	file := filepath.Join(c.MkDir(), "promotions.yaml")
+	c.Assert(os.WriteFile(file, []byte(content), 0600), IsNil)
+	return LoadPromotionPolicyFile(file)
+}
+
+func (suite *PromotionPolicySuite) SetUpTest(c *C) {
+	promotionPolicy, err := suite.load(c, promotionPolicyYAML)
+	c.Assert(err, IsNil)
+	suite.promotionPolicy = promotionPolicy
+}
+
+func (suite *PromotionPolicySuite) TestRule(c *C) {
+	rule := suite.promotionPolicy.Rule("staging")
+	c.Assert(rule, NotNil)
+	c.Assert(rule.To, Equals, "stable")
+	c.Assert(rule.Delay(), Equals, 30*time.Minute)
+	c.Assert(suite.promotionPolicy.Rule("develop").Delay(), Equals, time.Duration(0))
+	c.Assert(suite.promotionPolicy.Rule("stable"), IsNil)
+}
+
+func (suite *PromotionPolicySuite) TestLoadWithoutTarget(c *C) {
+	_, err := suite.load(c, "classifiers: {staging: {after: 30m}}")
+	c.Assert(err, ErrorMatches, `The promotion of update classifier "staging" needs the update classifier to promote to`)
+}
+
+func (suite *PromotionPolicySuite) TestLoadNegativeDelay(c *C) {
+	_, err := suite.load(c, "classifiers: {staging: {to: stable, after: -1m}}")
+	c.Assert(err, ErrorMatches, ".*must not be negative")
+}
+
+func (suite *PromotionPolicySuite) TestLoadCycle(c *C) {
+	_, err := suite.load(c, "classifiers: {staging: {to: staging}}")
+	c.Assert(err, ErrorMatches, ".*form a cycle")
+	_, err = suite.load(c, "classifiers: {develop: {to: staging}, staging: {to: stable}, stable: {to: develop}}")
+	c.Assert(err, ErrorMatches, ".*form a cycle")
+}
+
+func (suite *PromotionPolicySuite) TestLoadUnknownField(c *C) {
+	_, err := suite.load(c, "classifiers: {staging: {target: stable}}")
+	c.Assert(err, NotNil)
+}
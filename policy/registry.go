@@ -0,0 +1,146 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"kubernetes-update-manager/updater"
+
+	"sigs.k8s.io/yaml"
+)
+
+// LoadRegistryPolicyFile reads the registry policy from the passed YAML or JSON file.
+func LoadRegistryPolicyFile(file string) (*RegistryPolicy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	registryPolicy := &RegistryPolicy{}
+	err = yaml.UnmarshalStrict(data, registryPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return registryPolicy, registryPolicy.validate()
+}
+
+// ImageRules lists the registries and repositories images may be pulled from. An empty list does not restrict the images.
+type ImageRules struct {
+	// Registries are the registry hosts, for example eu.gcr.io, images may be pulled from.
+	Registries []string `json:"registries,omitempty"`
+	// Repositories are patterns of repositories including their registry, for example docker.io/xcnt/*. The patterns
+	// follow the shell file name pattern syntax in which * doesn't match a /. A pattern ending with /** matches all
+	// repositories below the prefix.
+	Repositories []string `json:"repositories,omitempty"`
+}
+
+// RegistryPolicy restricts which images can be rolled out by the update manager.
+type RegistryPolicy struct {
+	ImageRules
+	// Classifiers holds additional restrictions for updates with specific update classifiers. Images must satisfy
+	// both, the global and the classifier rules.
+	Classifiers map[string]ImageRules `json:"classifiers,omitempty"`
+}
+
+// Check returns an error describing the violation if the image is not allowed for an update with the passed classifier.
+func (registryPolicy *RegistryPolicy) Check(image *updater.Image, updateClassifier string) error {
+	err := registryPolicy.ImageRules.check(image)
+	if err != nil {
+		return err
+	}
+	classifierRules, ok := registryPolicy.Classifiers[updateClassifier]
+	if !ok {
+		return nil
+	}
+	err = classifierRules.check(image)
+	if err != nil {
+		return fmt.Errorf("%s for update classifier %q", err.Error(), updateClassifier)
+	}
+	return nil
+}
+
+func (registryPolicy *RegistryPolicy) validate() error {
+	err := registryPolicy.ImageRules.validate()
+	if err != nil {
+		return err
+	}
+	for _, classifierRules := range registryPolicy.Classifiers {
+		err = classifierRules.validate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rules ImageRules) check(image *updater.Image) error {
+	registry := image.GetRegistry()
+	if len(rules.Registries) > 0 && !containsString(rules.Registries, registry) {
+		return fmt.Errorf("Registry %s of image %s is not allowed", registry, image.String())
+	}
+	if len(rules.Repositories) > 0 && !MatchesRepository(rules.Repositories, image) {
+		return fmt.Errorf("Repository %s of image %s is not allowed", FullRepository(image), image.String())
+	}
+	return nil
+}
+
+func (rules ImageRules) validate() error {
+	return ValidateRepositoryPatterns(rules.Repositories)
+}
+
+// ValidateRepositoryPatterns returns an error if one of the passed repository patterns is malformed.
+func ValidateRepositoryPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		_, err := path.Match(strings.TrimSuffix(pattern, "/**"), "")
+		if err != nil {
+			return fmt.Errorf("Invalid repository pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// FullRepository returns the repository of the image including its registry, for example docker.io/library/nginx.
+func FullRepository(image *updater.Image) string {
+	return fmt.Sprintf("%s/%s", image.GetRegistry(), image.GetRepository())
+}
+
+// MatchesRepository returns if the repository of the image including its registry matches one of the patterns.
+// The patterns follow the shell file name pattern syntax in which * doesn't match a /. A pattern ending with /**
+// matches all repositories below the prefix.
+func MatchesRepository(patterns []string, image *updater.Image) bool {
+	return matchesAnyRepository(patterns, FullRepository(image))
+}
+
+func matchesAnyRepository(patterns []string, repository string) bool {
+	for _, pattern := range patterns {
+		if matchesRepository(pattern, repository) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesRepository(pattern string, repository string) bool {
+	if strings.HasSuffix(pattern, "/**") {
+		prefixPattern := strings.TrimSuffix(pattern, "/**")
+		splits := strings.Split(repository, "/")
+		for index := 1; index < len(splits); index++ {
+			if matched, _ := path.Match(prefixPattern, strings.Join(splits[:index], "/")); matched {
+				return true
+			}
+		}
+		return false
+	}
+	matched, _ := path.Match(pattern, repository)
+	return matched
+}
+
+func containsString(values []string, value string) bool {
+	for _, item := range values {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
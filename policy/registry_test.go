@@ -0,0 +1,86 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+
+	"kubernetes-update-manager/updater"
+
+	. "gopkg.in/check.v1"
+)
+
+const registryPolicyYAML = `
+registries:
+  - docker.io
+  - eu.gcr.io
+repositories:
+  - docker.io/xcnt/*
+  - eu.gcr.io/xcnt-infrastructure/**
+classifiers:
+  stable:
+    registries:
+      - eu.gcr.io
+`
+
+type RegistryPolicySuite struct {
+	registryPolicy *RegistryPolicy
+}
+
+var _ = Suite(&RegistryPolicySuite{})
+
+func (suite *RegistryPolicySuite) SetUpTest(c *C) {
+	file := filepath.Join(c.MkDir(), "policy.yaml")
+	c.Assert(os.WriteFile(file, []byte(registryPolicyYAML), 0600), IsNil)
+	registryPolicy, err := LoadRegistryPolicyFile(file)
+	c.Assert(err, IsNil)
+	suite.registryPolicy = registryPolicy
+}
+
+func (suite *RegistryPolicySuite) check(image string, updateClassifier string) error {
+	return suite.registryPolicy.Check(updater.NewImage(image), updateClassifier)
+}
+
+func (suite *RegistryPolicySuite) TestAllowed(c *C) {
+	c.Assert(suite.check("xcnt/test:1.0.0", "develop"), IsNil)
+}
+
+func (suite *RegistryPolicySuite) TestAllowedNestedRepository(c *C) {
+	c.Assert(suite.check("eu.gcr.io/xcnt-infrastructure/team/test:1.0.0", "stable"), IsNil)
+}
+
+func (suite *RegistryPolicySuite) TestRegistryNotAllowed(c *C) {
+	err := suite.check("quay.io/xcnt/test:1.0.0", "develop")
+	c.Assert(err, ErrorMatches, "Registry quay.io of image quay.io/xcnt/test:1.0.0 is not allowed")
+}
+
+func (suite *RegistryPolicySuite) TestRepositoryNotAllowed(c *C) {
+	err := suite.check("nginx:1.21", "develop")
+	c.Assert(err, ErrorMatches, "Repository docker.io/library/nginx of image nginx:1.21 is not allowed")
+}
+
+func (suite *RegistryPolicySuite) TestStarDoesNotMatchNestedRepository(c *C) {
+	c.Assert(suite.check("xcnt/team/test:1.0.0", "develop"), NotNil)
+}
+
+func (suite *RegistryPolicySuite) TestClassifierRestriction(c *C) {
+	err := suite.check("xcnt/test:1.0.0", "stable")
+	c.Assert(err, ErrorMatches, "Registry docker.io of image xcnt/test:1.0.0 is not allowed for update classifier \"stable\"")
+}
+
+func (suite *RegistryPolicySuite) TestEmptyPolicyAllowsEverything(c *C) {
+	c.Assert((&RegistryPolicy{}).Check(updater.NewImage("quay.io/any/image"), "stable"), IsNil)
+}
+
+func (suite *RegistryPolicySuite) TestLoadInvalidPattern(c *C) {
+	file := filepath.Join(c.MkDir(), "policy.yaml")
+	c.Assert(os.WriteFile(file, []byte("repositories: [\"docker.io/[\"]"), 0600), IsNil)
+	_, err := LoadRegistryPolicyFile(file)
+	c.Assert(err, NotNil)
+}
+
+func (suite *RegistryPolicySuite) TestLoadUnknownField(c *C) {
+	file := filepath.Join(c.MkDir(), "policy.yaml")
+	c.Assert(os.WriteFile(file, []byte("registry: [docker.io]"), 0600), IsNil)
+	_, err := LoadRegistryPolicyFile(file)
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,124 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultTimeout is the time a query may take before it is cancelled.
+	DefaultTimeout = 30 * time.Second
+
+	maxResponseSize = 16 * 1024 * 1024
+)
+
+var (
+	// ErrUnsupportedResult is returned if a query returns a range vector or strings instead of numbers
+	ErrUnsupportedResult = errors.New("The query must return an instant vector or a scalar")
+)
+
+// response is the envelope of the responses of the Prometheus HTTP API.
+type response struct {
+	Status    string          `json:"status"`
+	ErrorType string          `json:"errorType"`
+	Error     string          `json:"error"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type queryData struct {
+	ResultType string          `json:"resultType"`
+	Result     json.RawMessage `json:"result"`
+}
+
+type sample struct {
+	Value []interface{} `json:"value"`
+}
+
+// Client evaluates instant queries against the HTTP API of a Prometheus server.
+type Client struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewClient returns a client for the Prometheus server reachable at the base url, for example
+// http://prometheus.monitoring:9090. If no http client is passed, one with the default timeout is used.
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: DefaultTimeout}
+	}
+	return &Client{
+		url:        strings.TrimSuffix(baseURL, "/") + "/api/v1/query",
+		httpClient: httpClient,
+	}
+}
+
+// Query evaluates the PromQL query at the current time and returns the values of all series of the result.
+func (client *Client) Query(query string) ([]float64, error) {
+	httpResponse, err := client.httpClient.PostForm(client.url, url.Values{"query": {query}})
+	if err != nil {
+		return nil, err
+	}
+	defer httpResponse.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(httpResponse.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	result := &response{}
+	if err = json.Unmarshal(body, result); err != nil {
+		return nil, fmt.Errorf("Prometheus responded with status %d: %w", httpResponse.StatusCode, err)
+	}
+	if result.Status != "success" {
+		return nil, fmt.Errorf("Prometheus responded with %s: %s", result.ErrorType, result.Error)
+	}
+	data := &queryData{}
+	if err = json.Unmarshal(result.Data, data); err != nil {
+		return nil, err
+	}
+	switch data.ResultType {
+	case "vector":
+		samples := make([]sample, 0)
+		if err = json.Unmarshal(data.Result, &samples); err != nil {
+			return nil, err
+		}
+		values := make([]float64, 0, len(samples))
+		for _, vectorSample := range samples {
+			value, err := parseValue(vectorSample.Value)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	case "scalar":
+		var scalar []interface{}
+		if err = json.Unmarshal(data.Result, &scalar); err != nil {
+			return nil, err
+		}
+		value, err := parseValue(scalar)
+		if err != nil {
+			return nil, err
+		}
+		return []float64{value}, nil
+	default:
+		return nil, ErrUnsupportedResult
+	}
+}
+
+// parseValue reads the value of a [timestamp, "value"] pair of the Prometheus API.
+func parseValue(pair []interface{}) (float64, error) {
+	if len(pair) != 2 {
+		return 0, fmt.Errorf("Invalid sample %v", pair)
+	}
+	value, ok := pair[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("Invalid sample value %v", pair[1])
+	}
+	return strconv.ParseFloat(value, 64)
+}
@@ -0,0 +1,73 @@
+package prometheus
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "gopkg.in/check.v1"
+)
+
+type ClientSuite struct {
+	server   *httptest.Server
+	query    string
+	response string
+}
+
+var _ = Suite(&ClientSuite{})
+
+func (suite *ClientSuite) SetUpTest(c *C) {
+	suite.query = ""
+	suite.server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		c.Assert(request.URL.Path, Equals, "/api/v1/query")
+		suite.query = request.FormValue("query")
+		writer.Header().Set("Content-Type", "application/json")
+		_, _ = writer.Write([]byte(suite.response))
+	}))
+}
+
+func (suite *ClientSuite) TearDownTest(c *C) {
+	suite.server.Close()
+}
+
+func (suite *ClientSuite) TestVector(c *C) {
+	suite.response = `{"status":"success","data":{"resultType":"vector","result":[` +
+		`{"metric":{"pod":"a"},"value":[1714694400.1,"0.5"]},{"metric":{"pod":"b"},"value":[1714694400.1,"NaN"]}]}}`
+	values, err := NewClient(suite.server.URL+"/", nil).Query(`sum(rate(http_errors_total[1m]))`)
+	c.Assert(err, IsNil)
+	c.Assert(suite.query, Equals, `sum(rate(http_errors_total[1m]))`)
+	c.Assert(values, HasLen, 2)
+	c.Assert(values[0], Equals, 0.5)
+	c.Assert(values[1] != values[1], Equals, true)
+}
+
+func (suite *ClientSuite) TestEmptyVector(c *C) {
+	suite.response = `{"status":"success","data":{"resultType":"vector","result":[]}}`
+	values, err := NewClient(suite.server.URL, nil).Query("up")
+	c.Assert(err, IsNil)
+	c.Assert(values, HasLen, 0)
+}
+
+func (suite *ClientSuite) TestScalar(c *C) {
+	suite.response = `{"status":"success","data":{"resultType":"scalar","result":[1714694400.1,"42"]}}`
+	values, err := NewClient(suite.server.URL, nil).Query("scalar(up)")
+	c.Assert(err, IsNil)
+	c.Assert(values, DeepEquals, []float64{42})
+}
+
+func (suite *ClientSuite) TestMatrix(c *C) {
+	suite.response = `{"status":"success","data":{"resultType":"matrix","result":[]}}`
+	_, err := NewClient(suite.server.URL, nil).Query("up[5m]")
+	c.Assert(err, Equals, ErrUnsupportedResult)
+}
+
+func (suite *ClientSuite) TestError(c *C) {
+	suite.response = `{"status":"error","errorType":"bad_data","error":"parse error"}`
+	_, err := NewClient(suite.server.URL, nil).Query("sum(")
+	c.Assert(err, ErrorMatches, "Prometheus responded with bad_data: parse error")
+}
+
+func (suite *ClientSuite) TestInvalidResponse(c *C) {
+	suite.response = `not json`
+	_, err := NewClient(suite.server.URL, nil).Query("up")
+	c.Assert(err, ErrorMatches, "Prometheus responded with status 200: .*")
+}
@@ -0,0 +1,106 @@
+package signature
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrNoPublicKeys is returned if the verifier has been configured without any public key to check against.
+	ErrNoPublicKeys = errors.New("No public keys configured for the signature verification")
+)
+
+// LoadPublicKeys reads the PEM encoded public keys from the passed paths. A path may either point to a single file
+// or to a directory, for example a mounted kubernetes secret, in which case all non hidden files inside of it are read.
+func LoadPublicKeys(paths []string) ([]crypto.PublicKey, error) {
+	publicKeys := make([]crypto.PublicKey, 0)
+	for _, keyPath := range paths {
+		files, err := keyFilesIn(keyPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			keys, err := loadPublicKeyFile(file)
+			if err != nil {
+				return nil, err
+			}
+			publicKeys = append(publicKeys, keys...)
+		}
+	}
+	if len(publicKeys) == 0 {
+		return nil, ErrNoPublicKeys
+	}
+	return publicKeys, nil
+}
+
+func keyFilesIn(keyPath string) ([]string, error) {
+	info, err := os.Stat(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{keyPath}, nil
+	}
+
+	entries, err := os.ReadDir(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0)
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		file := filepath.Join(keyPath, entry.Name())
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+func loadPublicKeyFile(file string) ([]crypto.PublicKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	publicKeys, err := ParsePublicKeys(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return publicKeys, nil
+}
+
+// ParsePublicKeys returns all public keys found in the passed PEM data.
+func ParsePublicKeys(data []byte) ([]crypto.PublicKey, error) {
+	publicKeys := make([]crypto.PublicKey, 0)
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "PUBLIC KEY" {
+			continue
+		}
+		publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		publicKeys = append(publicKeys, publicKey)
+	}
+	if len(publicKeys) == 0 {
+		return nil, ErrNoPublicKeys
+	}
+	return publicKeys, nil
+}
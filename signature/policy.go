@@ -0,0 +1,111 @@
+package signature
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mode specifies how strictly image signatures are checked before an update is scheduled.
+type Mode string
+
+const (
+	// ModeOff disables the signature verification.
+	ModeOff Mode = "off"
+	// ModeWarn verifies the signature but only logs a warning if the image isn't signed.
+	ModeWarn Mode = "warn"
+	// ModeEnforce rejects updates with images which do not carry a valid signature.
+	ModeEnforce Mode = "enforce"
+)
+
+var modeStrictness = map[Mode]int{
+	ModeOff:     0,
+	ModeWarn:    1,
+	ModeEnforce: 2,
+}
+
+// ParseMode converts the passed string into a verification mode.
+func ParseMode(value string) (Mode, error) {
+	mode := Mode(strings.ToLower(strings.TrimSpace(value)))
+	if _, ok := modeStrictness[mode]; !ok {
+		return ModeOff, fmt.Errorf("Unknown signature verification mode %q", value)
+	}
+	return mode, nil
+}
+
+// ParseModeAssignments parses a list of "name=mode" entries as they are passed via the command line
+// and returns the modes by name.
+func ParseModeAssignments(assignments []string) (map[string]Mode, error) {
+	modes := map[string]Mode{}
+	for _, assignment := range assignments {
+		splits := strings.SplitN(assignment, "=", 2)
+		if len(splits) != 2 || len(strings.TrimSpace(splits[0])) == 0 {
+			return nil, fmt.Errorf("Invalid signature verification assignment %q, expected name=mode", assignment)
+		}
+		mode, err := ParseMode(splits[1])
+		if err != nil {
+			return nil, err
+		}
+		modes[strings.TrimSpace(splits[0])] = mode
+	}
+	return modes, nil
+}
+
+// NewPolicy returns a policy which applies the passed mode to all updates.
+func NewPolicy(defaultMode Mode) *Policy {
+	return &Policy{
+		Default:     defaultMode,
+		Namespaces:  map[string]Mode{},
+		Classifiers: map[string]Mode{},
+	}
+}
+
+// Policy describes for which updates image signatures are verified.
+type Policy struct {
+	// Default is the mode used if neither the classifier nor any of the touched namespaces have an explicit mode.
+	Default Mode
+	// Namespaces holds the modes configured for specific namespaces.
+	Namespaces map[string]Mode
+	// Classifiers holds the modes configured for specific update classifiers.
+	Classifiers map[string]Mode
+}
+
+// ModeFor returns the mode which applies to an update with the passed classifier touching resources in the passed
+// namespaces. If any explicit configuration for the classifier or namespaces exists, the strictest one of these
+// is returned. The default mode is only used if nothing has been configured explicitly.
+func (policy *Policy) ModeFor(updateClassifier string, namespaces []string) Mode {
+	modes := make([]Mode, 0)
+	if mode, ok := policy.Classifiers[updateClassifier]; ok {
+		modes = append(modes, mode)
+	}
+	for _, namespace := range namespaces {
+		if mode, ok := policy.Namespaces[namespace]; ok {
+			modes = append(modes, mode)
+		}
+	}
+	if len(modes) == 0 {
+		return policy.Default
+	}
+
+	strictest := ModeOff
+	for _, mode := range modes {
+		if modeStrictness[mode] > modeStrictness[strictest] {
+			strictest = mode
+		}
+	}
+	return strictest
+}
+
+// IsActive returns if any update could be subject to a signature verification with this policy.
+func (policy *Policy) IsActive() bool {
+	if policy.Default != ModeOff {
+		return true
+	}
+	for _, modes := range []map[string]Mode{policy.Namespaces, policy.Classifiers} {
+		for _, mode := range modes {
+			if mode != ModeOff {
+				return true
+			}
+		}
+	}
+	return false
+}
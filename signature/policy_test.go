@@ -0,0 +1,63 @@
+package signature
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type PolicySuite struct {
+	policy *Policy
+}
+
+var _ = Suite(&PolicySuite{})
+
+func (suite *PolicySuite) SetUpTest(c *C) {
+	suite.policy = NewPolicy(ModeWarn)
+	suite.policy.Namespaces["production"] = ModeEnforce
+	suite.policy.Namespaces["sandbox"] = ModeOff
+	suite.policy.Classifiers["develop"] = ModeOff
+}
+
+func (suite *PolicySuite) TestParseMode(c *C) {
+	mode, err := ParseMode(" Enforce ")
+	c.Assert(err, IsNil)
+	c.Assert(mode, Equals, ModeEnforce)
+}
+
+func (suite *PolicySuite) TestParseModeInvalid(c *C) {
+	_, err := ParseMode("strict")
+	c.Assert(err, NotNil)
+}
+
+func (suite *PolicySuite) TestParseModeAssignments(c *C) {
+	modes, err := ParseModeAssignments([]string{"production=enforce", "develop=off"})
+	c.Assert(err, IsNil)
+	c.Assert(modes["production"], Equals, ModeEnforce)
+	c.Assert(modes["develop"], Equals, ModeOff)
+}
+
+func (suite *PolicySuite) TestParseModeAssignmentsInvalid(c *C) {
+	_, err := ParseModeAssignments([]string{"production"})
+	c.Assert(err, NotNil)
+}
+
+func (suite *PolicySuite) TestModeForDefault(c *C) {
+	c.Assert(suite.policy.ModeFor("stable", []string{"default"}), Equals, ModeWarn)
+}
+
+func (suite *PolicySuite) TestModeForNamespace(c *C) {
+	c.Assert(suite.policy.ModeFor("stable", []string{"default", "production"}), Equals, ModeEnforce)
+}
+
+func (suite *PolicySuite) TestModeForClassifier(c *C) {
+	c.Assert(suite.policy.ModeFor("develop", []string{"default"}), Equals, ModeOff)
+}
+
+func (suite *PolicySuite) TestModeForStrictestWins(c *C) {
+	c.Assert(suite.policy.ModeFor("develop", []string{"production"}), Equals, ModeEnforce)
+	c.Assert(suite.policy.ModeFor("stable", []string{"sandbox"}), Equals, ModeOff)
+}
+
+func (suite *PolicySuite) TestIsActive(c *C) {
+	c.Assert(suite.policy.IsActive(), Equals, true)
+	c.Assert(NewPolicy(ModeOff).IsActive(), Equals, false)
+}
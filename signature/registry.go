@@ -0,0 +1,237 @@
+package signature
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"kubernetes-update-manager/updater"
+)
+
+const (
+	dockerHubRegistryHost = "registry-1.docker.io"
+	maxManifestSize       = 4 * 1024 * 1024
+)
+
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// manifest is the subset of an OCI image manifest which is needed to read cosign signatures.
+type manifest struct {
+	Layers []descriptor `json:"layers"`
+}
+
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// registryClient implements the parts of the OCI distribution API used for the signature verification.
+// Only anonymous pulls, optionally via the bearer token flow of the registry, are supported.
+type registryClient struct {
+	httpClient *http.Client
+	tokens     map[string]string
+	lock       sync.Mutex
+}
+
+func newRegistryClient(httpClient *http.Client) *registryClient {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &registryClient{
+		httpClient: httpClient,
+		tokens:     map[string]string{},
+	}
+}
+
+// resolveDigest returns the manifest digest the reference of the image currently points to.
+func (client *registryClient) resolveDigest(image *updater.Image) (string, error) {
+	reference := image.GetReference()
+	if strings.HasPrefix(reference, "sha256:") {
+		return reference, nil
+	}
+	response, err := client.get(image, "manifests/"+reference, manifestMediaTypes...)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if digest := response.Header.Get("Docker-Content-Digest"); len(digest) > 0 {
+		return digest, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxManifestSize))
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(hash[:]), nil
+}
+
+// getManifest returns the manifest with the passed tag or digest. It returns nil if the manifest does not exist.
+func (client *registryClient) getManifest(image *updater.Image, reference string) (*manifest, error) {
+	response, err := client.get(image, "manifests/"+reference, manifestMediaTypes...)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	result := &manifest{}
+	err = json.NewDecoder(io.LimitReader(response.Body, maxManifestSize)).Decode(result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// getBlob returns the content of the blob with the passed digest and verifies that it matches the digest.
+func (client *registryClient) getBlob(image *updater.Image, digest string) ([]byte, error) {
+	response, err := client.get(image, "blobs/"+digest)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unexpected status code %d while fetching blob %s", response.StatusCode, digest)
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxManifestSize))
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(body)
+	if "sha256:"+hex.EncodeToString(hash[:]) != digest {
+		return nil, fmt.Errorf("The content of blob %s doesn't match its digest", digest)
+	}
+	return body, nil
+}
+
+func (client *registryClient) get(image *updater.Image, path string, accept ...string) (*http.Response, error) {
+	requestURL := fmt.Sprintf("https://%s/v2/%s/%s", registryHost(image), image.GetRepository(), path)
+	response, err := client.do(image, requestURL, accept)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode == http.StatusUnauthorized {
+		challenge := response.Header.Get("WWW-Authenticate")
+		response.Body.Close()
+		err = client.authenticate(image, challenge)
+		if err != nil {
+			return nil, err
+		}
+		response, err = client.do(image, requestURL, accept)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusNotFound {
+		response.Body.Close()
+		return nil, fmt.Errorf("Unexpected status code %d from registry %s", response.StatusCode, image.GetRegistry())
+	}
+	return response, nil
+}
+
+func (client *registryClient) do(image *updater.Image, requestURL string, accept []string) (*http.Response, error) {
+	request, err := http.NewRequest(http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(accept) > 0 {
+		request.Header.Set("Accept", strings.Join(accept, ", "))
+	}
+	if token := client.token(image); len(token) > 0 {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	return client.httpClient.Do(request)
+}
+
+func (client *registryClient) token(image *updater.Image) string {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	return client.tokens[tokenKey(image)]
+}
+
+// authenticate requests an anonymous pull token following the bearer challenge of the registry.
+func (client *registryClient) authenticate(image *updater.Image, challenge string) error {
+	parameters := parseBearerChallenge(challenge)
+	realm, ok := parameters["realm"]
+	if !ok {
+		return fmt.Errorf("Registry %s requires an unsupported authentication scheme", image.GetRegistry())
+	}
+	tokenURL, err := url.Parse(realm)
+	if err != nil {
+		return err
+	}
+	query := tokenURL.Query()
+	if service, ok := parameters["service"]; ok {
+		query.Set("service", service)
+	}
+	query.Set("scope", fmt.Sprintf("repository:%s:pull", image.GetRepository()))
+	tokenURL.RawQuery = query.Encode()
+
+	response, err := client.httpClient.Get(tokenURL.String())
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("Unexpected status code %d while requesting a registry token", response.StatusCode)
+	}
+	tokenResponse := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	err = json.NewDecoder(response.Body).Decode(&tokenResponse)
+	if err != nil {
+		return err
+	}
+	token := tokenResponse.Token
+	if len(token) == 0 {
+		token = tokenResponse.AccessToken
+	}
+
+	client.lock.Lock()
+	defer client.lock.Unlock()
+	client.tokens[tokenKey(image)] = token
+	return nil
+}
+
+func parseBearerChallenge(challenge string) map[string]string {
+	parameters := map[string]string{}
+	splits := strings.SplitN(strings.TrimSpace(challenge), " ", 2)
+	if len(splits) != 2 || !strings.EqualFold(splits[0], "Bearer") {
+		return parameters
+	}
+	for _, parameter := range strings.Split(splits[1], ",") {
+		keyValue := strings.SplitN(strings.TrimSpace(parameter), "=", 2)
+		if len(keyValue) == 2 {
+			parameters[keyValue[0]] = strings.Trim(keyValue[1], "\"")
+		}
+	}
+	return parameters
+}
+
+func tokenKey(image *updater.Image) string {
+	return image.GetRegistry() + "/" + image.GetRepository()
+}
+
+func registryHost(image *updater.Image) string {
+	registry := image.GetRegistry()
+	if registry == updater.DefaultRegistry {
+		return dockerHubRegistryHost
+	}
+	return registry
+}
@@ -0,0 +1,133 @@
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"kubernetes-update-manager/updater"
+)
+
+const (
+	// CosignSignatureAnnotation is the layer annotation in which cosign stores the base64 encoded signature.
+	CosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+	cosignSignatureSuffix     = ".sig"
+)
+
+var (
+	// ErrNoSignature is returned if no cosign signature has been published for the image.
+	ErrNoSignature = errors.New("The image has not been signed")
+	// ErrInvalidSignature is returned if signatures exist, but none of them could be verified with the configured keys.
+	ErrInvalidSignature = errors.New("The image signature could not be verified with any of the configured public keys")
+)
+
+// simpleSigningPayload is the payload format cosign signs.
+type simpleSigningPayload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// NewVerifier returns a verifier which checks cosign signatures of images against the passed public keys.
+func NewVerifier(publicKeys []crypto.PublicKey) *Verifier {
+	return &Verifier{
+		publicKeys: publicKeys,
+		registry:   newRegistryClient(nil),
+	}
+}
+
+// Verifier checks if images have been signed with cosign by one of the configured public keys. The signatures are
+// read from the registry the image resides in.
+type Verifier struct {
+	publicKeys []crypto.PublicKey
+	registry   *registryClient
+}
+
+// SetHTTPClient configures the client which is used to talk to the registries.
+func (verifier *Verifier) SetHTTPClient(httpClient *http.Client) {
+	verifier.registry = newRegistryClient(httpClient)
+}
+
+// Verify returns the digest of the manifest of the passed image if at least one of its signatures can be verified with
+// one of the configured public keys. The image should be deployed with the digest, as its tag may be moved after the
+// verification. It returns ErrNoSignature if the image isn't signed at all and ErrInvalidSignature if no signature
+// could be verified.
+func (verifier *Verifier) Verify(image *updater.Image) (string, error) {
+	if len(verifier.publicKeys) == 0 {
+		return "", ErrNoPublicKeys
+	}
+	digest, err := verifier.registry.resolveDigest(image)
+	if err != nil {
+		return "", err
+	}
+	signatureManifest, err := verifier.registry.getManifest(image, signatureTag(digest))
+	if err != nil {
+		return "", err
+	}
+	if signatureManifest == nil || len(signatureManifest.Layers) == 0 {
+		return "", ErrNoSignature
+	}
+
+	for _, layer := range signatureManifest.Layers {
+		encodedSignature, ok := layer.Annotations[CosignSignatureAnnotation]
+		if !ok {
+			continue
+		}
+		signature, err := base64.StdEncoding.DecodeString(encodedSignature)
+		if err != nil {
+			continue
+		}
+		payload, err := verifier.registry.getBlob(image, layer.Digest)
+		if err != nil {
+			return "", err
+		}
+		if verifier.verifyPayload(payload, signature, digest) {
+			return digest, nil
+		}
+	}
+	return "", ErrInvalidSignature
+}
+
+func (verifier *Verifier) verifyPayload(payload []byte, signature []byte, digest string) bool {
+	simpleSigning := &simpleSigningPayload{}
+	err := json.Unmarshal(payload, simpleSigning)
+	if err != nil || simpleSigning.Critical.Image.DockerManifestDigest != digest {
+		return false
+	}
+	for _, publicKey := range verifier.publicKeys {
+		if verifySignature(publicKey, payload, signature) {
+			return true
+		}
+	}
+	return false
+}
+
+func verifySignature(publicKey crypto.PublicKey, payload []byte, signature []byte) bool {
+	hash := sha256.Sum256(payload)
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(key, hash[:], signature)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature) == nil
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, payload, signature)
+	default:
+		return false
+	}
+}
+
+// signatureTag returns the tag cosign uses to store the signatures of the manifest with the passed digest.
+func signatureTag(digest string) string {
+	return fmt.Sprintf("%s%s", strings.Replace(digest, ":", "-", 1), cosignSignatureSuffix)
+}
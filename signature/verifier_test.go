@@ -0,0 +1,189 @@
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"kubernetes-update-manager/updater"
+
+	. "gopkg.in/check.v1"
+)
+
+type fakeRegistry struct {
+	server    *httptest.Server
+	manifests map[string][]byte
+	blobs     map[string][]byte
+}
+
+func newFakeRegistry() *fakeRegistry {
+	registry := &fakeRegistry{
+		manifests: map[string][]byte{},
+		blobs:     map[string][]byte{},
+	}
+	registry.server = httptest.NewTLSServer(http.HandlerFunc(registry.serve))
+	return registry
+}
+
+func (registry *fakeRegistry) serve(writer http.ResponseWriter, request *http.Request) {
+	path := strings.TrimPrefix(request.URL.Path, "/v2/xcnt/test/")
+	if strings.HasPrefix(path, "manifests/") {
+		data, ok := registry.manifests[strings.TrimPrefix(path, "manifests/")]
+		if !ok {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writer.Header().Set("Docker-Content-Digest", digestOf(data))
+		writer.Write(data)
+		return
+	}
+	data, ok := registry.blobs[strings.TrimPrefix(path, "blobs/")]
+	if !ok {
+		writer.WriteHeader(http.StatusNotFound)
+		return
+	}
+	writer.Write(data)
+}
+
+func (registry *fakeRegistry) image(tag string) *updater.Image {
+	host := strings.TrimPrefix(registry.server.URL, "https://")
+	return updater.NewImage(fmt.Sprintf("%s/xcnt/test:%s", host, tag))
+}
+
+func (registry *fakeRegistry) push(tag string) string {
+	data := []byte(fmt.Sprintf(`{"schemaVersion":2,"tag":"%s"}`, tag))
+	registry.manifests[tag] = data
+	return digestOf(data)
+}
+
+func (registry *fakeRegistry) sign(digest string, key *ecdsa.PrivateKey) {
+	payload := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"xcnt/test"},"image":{"docker-manifest-digest":"%s"},"type":"cosign container image signature"},"optional":null}`, digest))
+	hash := sha256.Sum256(payload)
+	signature, _ := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	payloadDigest := digestOf(payload)
+	registry.blobs[payloadDigest] = payload
+	signatureManifest := manifest{
+		Layers: []descriptor{
+			{
+				MediaType: "application/vnd.dev.cosign.simplesigning.v1+json",
+				Digest:    payloadDigest,
+				Size:      int64(len(payload)),
+				Annotations: map[string]string{
+					CosignSignatureAnnotation: base64.StdEncoding.EncodeToString(signature),
+				},
+			},
+		},
+	}
+	data, _ := json.Marshal(signatureManifest)
+	registry.manifests[signatureTag(digest)] = data
+}
+
+func digestOf(data []byte) string {
+	hash := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(hash[:])
+}
+
+type VerifierSuite struct {
+	registry   *fakeRegistry
+	privateKey *ecdsa.PrivateKey
+	verifier   *Verifier
+}
+
+var _ = Suite(&VerifierSuite{})
+
+func (suite *VerifierSuite) SetUpTest(c *C) {
+	suite.registry = newFakeRegistry()
+	suite.privateKey, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	suite.verifier = NewVerifier([]crypto.PublicKey{&suite.privateKey.PublicKey})
+	suite.verifier.SetHTTPClient(suite.registry.server.Client())
+}
+
+func (suite *VerifierSuite) TearDownTest(c *C) {
+	suite.registry.server.Close()
+}
+
+func (suite *VerifierSuite) TestVerifySigned(c *C) {
+	digest := suite.registry.push("1.0.0")
+	suite.registry.sign(digest, suite.privateKey)
+	verifiedDigest, err := suite.verifier.Verify(suite.registry.image("1.0.0"))
+	c.Assert(err, IsNil)
+	c.Assert(verifiedDigest, Equals, digest)
+}
+
+func (suite *VerifierSuite) TestVerifyPinnedImage(c *C) {
+	digest := suite.registry.push("1.0.0")
+	suite.registry.sign(digest, suite.privateKey)
+	suite.registry.push("1.0.0-moved")
+	suite.registry.manifests["1.0.0"] = suite.registry.manifests["1.0.0-moved"]
+	verifiedDigest, err := suite.verifier.Verify(suite.registry.image("1.0.0").WithDigest(digest))
+	c.Assert(err, IsNil)
+	c.Assert(verifiedDigest, Equals, digest)
+	_, err = suite.verifier.Verify(suite.registry.image("1.0.0"))
+	c.Assert(err, Equals, ErrNoSignature)
+}
+
+func (suite *VerifierSuite) TestVerifyUnsigned(c *C) {
+	suite.registry.push("1.0.0")
+	_, err := suite.verifier.Verify(suite.registry.image("1.0.0"))
+	c.Assert(err, Equals, ErrNoSignature)
+}
+
+func (suite *VerifierSuite) TestVerifySignedWithOtherKey(c *C) {
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	digest := suite.registry.push("1.0.0")
+	suite.registry.sign(digest, otherKey)
+	_, err := suite.verifier.Verify(suite.registry.image("1.0.0"))
+	c.Assert(err, Equals, ErrInvalidSignature)
+}
+
+func (suite *VerifierSuite) TestVerifySignatureOfOtherImage(c *C) {
+	signedDigest := suite.registry.push("1.0.0")
+	suite.registry.sign(signedDigest, suite.privateKey)
+	otherDigest := suite.registry.push("1.0.1")
+	suite.registry.manifests[signatureTag(otherDigest)] = suite.registry.manifests[signatureTag(signedDigest)]
+	_, err := suite.verifier.Verify(suite.registry.image("1.0.1"))
+	c.Assert(err, Equals, ErrInvalidSignature)
+}
+
+func (suite *VerifierSuite) TestVerifyMissingImage(c *C) {
+	_, err := suite.verifier.Verify(suite.registry.image("1.0.0"))
+	c.Assert(err, NotNil)
+}
+
+func (suite *VerifierSuite) TestVerifyWithoutKeys(c *C) {
+	verifier := NewVerifier(nil)
+	_, err := verifier.Verify(suite.registry.image("1.0.0"))
+	c.Assert(err, Equals, ErrNoPublicKeys)
+}
+
+func (suite *VerifierSuite) TestLoadPublicKeysFromDirectory(c *C) {
+	directory := c.MkDir()
+	encoded, err := x509.MarshalPKIXPublicKey(&suite.privateKey.PublicKey)
+	c.Assert(err, IsNil)
+	data := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: encoded})
+	c.Assert(os.WriteFile(filepath.Join(directory, "cosign.pub"), data, 0600), IsNil)
+	c.Assert(os.WriteFile(filepath.Join(directory, ".hidden"), []byte("ignored"), 0600), IsNil)
+
+	publicKeys, err := LoadPublicKeys([]string{directory})
+	c.Assert(err, IsNil)
+	c.Assert(publicKeys, HasLen, 1)
+}
+
+func (suite *VerifierSuite) TestLoadPublicKeysWithoutKeys(c *C) {
+	directory := c.MkDir()
+	_, err := LoadPublicKeys([]string{directory})
+	c.Assert(err, Equals, ErrNoPublicKeys)
+}
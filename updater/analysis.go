@@ -0,0 +1,276 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/apps/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// AnalysisAnnotation lists, as a YAML or JSON list, additional queries the metrics of a deployment are analyzed
+	// with once it is ready. Every entry has a query and a min and/or max threshold, for example
+	// [{"name": "errors", "query": "sum(rate(http_errors_total{namespace=\"$namespace\"}[1m]))", "max": 1}].
+	AnalysisAnnotation = "xcnt.io/analysis"
+
+	// DefaultAnalysisWindow is the time the metrics of a ready deployment are analyzed for if not configured otherwise.
+	DefaultAnalysisWindow = 5 * time.Minute
+	// DefaultAnalysisInterval is the time between two evaluations of the queries if not configured otherwise.
+	DefaultAnalysisInterval = 30 * time.Second
+)
+
+var (
+	// ErrNoAnalysisQuery is returned if an analysis query does not have a query
+	ErrNoAnalysisQuery = errors.New("The analysis query must not be empty")
+	// ErrNoAnalysisThreshold is returned if an analysis query has neither a min nor a max threshold
+	ErrNoAnalysisThreshold = errors.New("The analysis query needs a min or max threshold")
+)
+
+// MetricQuerier evaluates queries against a metrics backend, for example Prometheus.
+type MetricQuerier interface {
+	// Query evaluates the query at the current time and returns the values of all returned series.
+	Query(query string) ([]float64, error)
+}
+
+// AnalysisQuery is a query whose values have to stay within the thresholds while a deployment is analyzed. The
+// placeholders $namespace and $deployment are replaced with the namespace and the name of the analyzed deployment.
+type AnalysisQuery struct {
+	// Name describes the query in errors. The query itself is used if empty.
+	Name string `json:"name,omitempty"`
+	// Query is the query evaluated against the metrics backend.
+	Query string `json:"query"`
+	// Min is the lowest value the query may return.
+	Min *float64 `json:"min,omitempty"`
+	// Max is the highest value the query may return.
+	Max *float64 `json:"max,omitempty"`
+	// FailOnNoData fails the analysis if the query does not return any value. It defaults to true.
+	FailOnNoData *bool `json:"failOnNoData,omitempty"`
+}
+
+// Validate returns an error if the query can not be evaluated.
+func (query *AnalysisQuery) Validate() error {
+	if len(strings.TrimSpace(query.Query)) == 0 {
+		return ErrNoAnalysisQuery
+	}
+	if query.Min == nil && query.Max == nil {
+		return ErrNoAnalysisThreshold
+	}
+	return nil
+}
+
+// failsOnNoData returns whether a query without any value fails the analysis.
+func (query *AnalysisQuery) failsOnNoData() bool {
+	return query.FailOnNoData == nil || *query.FailOnNoData
+}
+
+func (query *AnalysisQuery) describe() string {
+	if len(query.Name) > 0 {
+		return query.Name
+	}
+	return query.Query
+}
+
+// violation returns why the value violates the thresholds of the query or an empty string if it does not. Values
+// which are not a number are ignored.
+func (query *AnalysisQuery) violation(value float64) string {
+	if math.IsNaN(value) {
+		return ""
+	}
+	if query.Max != nil && value > *query.Max {
+		return fmt.Sprintf("The value %s is above the maximum %s", formatValue(value), formatValue(*query.Max))
+	}
+	if query.Min != nil && value < *query.Min {
+		return fmt.Sprintf("The value %s is below the minimum %s", formatValue(value), formatValue(*query.Min))
+	}
+	return ""
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// Analysis configures the metric analysis of the deployments of an update. Once the deployments of a wave are ready,
+// their queries are evaluated every interval for the window. The update fails and is rolled back as soon as a value
+// violates the thresholds of its query.
+type Analysis struct {
+	// Querier evaluates the queries.
+	Querier MetricQuerier
+	// Queries are evaluated for every deployment in addition to the ones of its analysis annotation.
+	Queries []AnalysisQuery
+	// Window is the time the metrics of a ready deployment are analyzed for.
+	Window time.Duration
+	// Interval is the time between two evaluations of the queries.
+	Interval time.Duration
+}
+
+// AnalysisError is returned if the analysis annotation of a deployment is invalid or its metrics violated the
+// thresholds of a query.
+type AnalysisError struct {
+	// Namespace is the namespace of the deployment.
+	Namespace string
+	// Name is the name of the deployment.
+	Name string
+	// Query describes the violated query. It is empty if the analysis annotation is invalid.
+	Query string
+	// Reason describes the problem with the analysis.
+	Reason string
+}
+
+// Error returns the description of the failed analysis.
+func (analysisError *AnalysisError) Error() string {
+	if len(analysisError.Query) == 0 {
+		return fmt.Sprintf("Deployment %s/%s: %s", analysisError.Namespace, analysisError.Name, analysisError.Reason)
+	}
+	return fmt.Sprintf("Analysis of deployment %s/%s, query %s: %s", analysisError.Namespace, analysisError.Name, analysisError.Query, analysisError.Reason)
+}
+
+// PlanConflict marks invalid analysis annotations and failed analyses as conflicts with the workloads.
+func (analysisError *AnalysisError) PlanConflict() {}
+
+// CheckAnalysisAnnotations verifies that the analysis annotations of the passed deployments are valid.
+func CheckAnalysisAnnotations(deployments []v1.Deployment) error {
+	for _, deployment := range deployments {
+		_, err := analysisQueriesOf(&deployment)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func analysisQueriesOf(deployment *v1.Deployment) ([]AnalysisQuery, error) {
+	value, ok := deployment.Annotations[AnalysisAnnotation]
+	if !ok || len(strings.TrimSpace(value)) == 0 {
+		return nil, nil
+	}
+	newError := func(err error) error {
+		return &AnalysisError{
+			Namespace: deployment.Namespace,
+			Name:      deployment.Name,
+			Reason:    "The analysis annotation is invalid: " + err.Error(),
+		}
+	}
+	var queries []AnalysisQuery
+	err := yaml.UnmarshalStrict([]byte(value), &queries)
+	if err != nil {
+		return nil, newError(err)
+	}
+	for _, query := range queries {
+		err = query.Validate()
+		if err != nil {
+			return nil, newError(err)
+		}
+	}
+	return queries, nil
+}
+
+// queriesFor returns the queries the deployment is analyzed with, with the placeholders replaced.
+func (analysis *Analysis) queriesFor(deployment *v1.Deployment) []AnalysisQuery {
+	// The annotations have been checked while planning the update.
+	annotationQueries, _ := analysisQueriesOf(deployment)
+	queries := make([]AnalysisQuery, 0, len(analysis.Queries)+len(annotationQueries))
+	replacer := strings.NewReplacer("$namespace", deployment.Namespace, "$deployment", deployment.Name)
+	for _, query := range append(append([]AnalysisQuery{}, analysis.Queries...), annotationQueries...) {
+		query.Query = replacer.Replace(query.Query)
+		queries = append(queries, query)
+	}
+	return queries
+}
+
+// analyzeWave waits for the deployments of the wave to become ready and analyzes their metrics for the window of
+// the analysis. It returns an AnalysisError if a threshold has been violated. The analysis is stopped early if the
+// update is aborted.
+func (up *updater) analyzeWave(wave Wave) error {
+	analysis := up.updatePlan.GetAnalysis()
+	if analysis == nil {
+		return nil
+	}
+	deployments := make([]*v1.Deployment, 0, len(wave.Deployments))
+	for _, index := range wave.Deployments {
+		if len(analysis.queriesFor(up.updateProgress.deployments[index])) > 0 {
+			deployments = append(deployments, up.updateProgress.deployments[index])
+		}
+	}
+	if len(deployments) == 0 {
+		return nil
+	}
+	err := up.waitForDeployments()
+	if err != nil || up.updateProgress.Failed() {
+		return err
+	}
+	log.WithField("window", analysis.Window.String()).Debug("Analyzing the metrics of the wave")
+	analysisEnd := time.Now().Add(analysis.Window)
+	interval := analysis.Interval
+	if interval <= 0 {
+		interval = DefaultAnalysisInterval
+	}
+	for {
+		wait := interval
+		if remaining := time.Until(analysisEnd); remaining < wait {
+			wait = remaining
+		}
+		if !up.sleepUnlessAborted(wait) {
+			return nil
+		}
+		for _, deployment := range deployments {
+			err = up.evaluate(analysis, deployment)
+			if err != nil {
+				return err
+			}
+		}
+		if !time.Now().Before(analysisEnd) {
+			return nil
+		}
+	}
+}
+
+// evaluate runs the queries of the deployment and returns an AnalysisError for the first violated threshold. A
+// failing query, or one without any value unless configured otherwise, is treated as a violation, as the health of
+// the deployment can not be verified.
+func (up *updater) evaluate(analysis *Analysis, deployment *v1.Deployment) error {
+	for _, query := range analysis.queriesFor(deployment) {
+		newError := func(reason string) error {
+			return &AnalysisError{
+				Namespace: deployment.Namespace,
+				Name:      deployment.Name,
+				Query:     query.describe(),
+				Reason:    reason,
+			}
+		}
+		values, err := analysis.Querier.Query(query.Query)
+		if err != nil {
+			return newError("The query failed: " + err.Error())
+		}
+		if len(values) == 0 && query.failsOnNoData() {
+			return newError("The query did not return any value")
+		}
+		for _, value := range values {
+			if violation := query.violation(value); len(violation) > 0 {
+				return newError(violation)
+			}
+		}
+	}
+	return nil
+}
+
+// sleepUnlessAborted waits for the duration and returns false as soon as the update is aborted.
+func (up *updater) sleepUnlessAborted(duration time.Duration) bool {
+	end := time.Now().Add(duration)
+	for time.Now().Before(end) {
+		if up.updateProgress.Failed() {
+			return false
+		}
+		step := time.Until(end)
+		if step > 100*time.Millisecond {
+			step = 100 * time.Millisecond
+		}
+		time.Sleep(step)
+	}
+	return !up.updateProgress.Failed()
+}
@@ -0,0 +1,193 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type fakeQuerier struct {
+	mutex   sync.Mutex
+	values  []float64
+	err     error
+	queries []string
+}
+
+func (querier *fakeQuerier) Query(query string) ([]float64, error) {
+	querier.mutex.Lock()
+	defer querier.mutex.Unlock()
+	querier.queries = append(querier.queries, query)
+	return querier.values, querier.err
+}
+
+func (querier *fakeQuerier) executedQueries() []string {
+	querier.mutex.Lock()
+	defer querier.mutex.Unlock()
+	return append([]string{}, querier.queries...)
+}
+
+type AnalysisSuite struct {
+	kubernetesAPI KubernetesAPI
+	config        *Config
+	querier       *fakeQuerier
+	updatePlan    *updatePlan
+}
+
+var _ = Suite(&AnalysisSuite{})
+
+func floatPointer(value float64) *float64 {
+	return &value
+}
+
+func (suite *AnalysisSuite) SetUpTest(c *C) {
+	suite.kubernetesAPI = NewFakeKubernetesAPI()
+	suite.kubernetesAPI.NewNamespace("default")
+	suite.config = NewConfig(suite.kubernetesAPI.Client, NewImage("xcnt/test:1.1.0"), "stable")
+	deployment := deploymentNamed("default", "api", map[string]string{UpdateClassifier: "stable"})
+	deployment.Status.ReadyReplicas = 1
+	c.Assert(suite.kubernetesAPI.NewDeploymentIn("default", deployment), IsNil)
+	updated := *deployment.DeepCopy()
+	updated.Annotations["xcnt.io/updated"] = "true"
+	updated.Spec.Template.Spec.Containers[0].Image = "xcnt/test:1.1.0"
+	suite.querier = &fakeQuerier{values: []float64{0.5}}
+	suite.updatePlan = &updatePlan{
+		deployments: []v1.Deployment{updated},
+		jobs:        []batchv1.Job{},
+		analysis: &Analysis{
+			Querier: suite.querier,
+			Queries: []AnalysisQuery{{
+				Name:  "errors",
+				Query: `sum(rate(http_errors_total{namespace="$namespace",deployment="$deployment"}[1m]))`,
+				Max:   floatPointer(1),
+			}},
+			Window:   300 * time.Millisecond,
+			Interval: 100 * time.Millisecond,
+		},
+	}
+}
+
+func (suite *AnalysisSuite) getDeployment(c *C) *v1.Deployment {
+	deployment, err := suite.config.GetDeploymentAPIFor("default").Get(context.TODO(), "api", metaV1.GetOptions{})
+	c.Assert(err, IsNil)
+	return deployment
+}
+
+func (suite *AnalysisSuite) waitForFinish(progress UpdateProgress) {
+	for i := 0; i < 40 && !progress.Finished(); i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func (suite *AnalysisSuite) TestValidate(c *C) {
+	c.Assert((&AnalysisQuery{Query: "up", Min: floatPointer(1)}).Validate(), IsNil)
+	c.Assert((&AnalysisQuery{Query: " ", Min: floatPointer(1)}).Validate(), Equals, ErrNoAnalysisQuery)
+	c.Assert((&AnalysisQuery{Query: "up"}).Validate(), Equals, ErrNoAnalysisThreshold)
+}
+
+func (suite *AnalysisSuite) TestViolation(c *C) {
+	query := &AnalysisQuery{Query: "up", Min: floatPointer(1), Max: floatPointer(2.5)}
+	c.Assert(query.violation(1.5), Equals, "")
+	c.Assert(query.violation(3), Equals, "The value 3 is above the maximum 2.5")
+	c.Assert(query.violation(0.5), Equals, "The value 0.5 is below the minimum 1")
+	c.Assert(query.violation(zero/zero), Equals, "")
+}
+
+var zero = 0.0
+
+func (suite *AnalysisSuite) TestCheckAnalysisAnnotations(c *C) {
+	valid := deploymentNamed("default", "api", map[string]string{AnalysisAnnotation: "- query: up\n  min: 1\n"})
+	c.Assert(CheckAnalysisAnnotations([]v1.Deployment{valid}), IsNil)
+
+	err := CheckAnalysisAnnotations([]v1.Deployment{deploymentNamed("default", "api", map[string]string{AnalysisAnnotation: "query: up"})})
+	c.Assert(err, FitsTypeOf, &AnalysisError{})
+	c.Assert(err, ErrorMatches, "Deployment default/api: The analysis annotation is invalid: .*")
+	err = CheckAnalysisAnnotations([]v1.Deployment{deploymentNamed("default", "api", map[string]string{AnalysisAnnotation: `[{"query": "up", "threshold": 1}]`})})
+	c.Assert(err, ErrorMatches, "Deployment default/api: The analysis annotation is invalid: .*threshold.*")
+}
+
+func (suite *AnalysisSuite) TestQueriesFor(c *C) {
+	deployment := deploymentNamed("prod", "api", map[string]string{AnalysisAnnotation: `[{"query": "up{deployment=\"$deployment\"}", "min": 1}]`})
+	queries := suite.updatePlan.analysis.queriesFor(&deployment)
+	c.Assert(queries, HasLen, 2)
+	c.Assert(queries[0].Query, Equals, `sum(rate(http_errors_total{namespace="prod",deployment="api"}[1m]))`)
+	c.Assert(queries[1].Query, Equals, `up{deployment="api"}`)
+	c.Assert(suite.updatePlan.analysis.Queries[0].Query, Matches, `.*\$namespace.*`)
+}
+
+func (suite *AnalysisSuite) TestPassingAnalysis(c *C) {
+	progress := Update(suite.updatePlan, suite.config)
+	time.Sleep(150 * time.Millisecond)
+	c.Assert(progress.Finished(), Equals, false)
+	suite.waitForFinish(progress)
+	c.Assert(progress.Successful(), Equals, true)
+	queries := suite.querier.executedQueries()
+	c.Assert(len(queries) >= 3, Equals, true)
+	c.Assert(queries[0], Equals, `sum(rate(http_errors_total{namespace="default",deployment="api"}[1m]))`)
+	c.Assert(suite.getDeployment(c).Annotations["xcnt.io/updated"], Equals, "true")
+}
+
+func (suite *AnalysisSuite) TestRollBackViolatedThreshold(c *C) {
+	current := suite.getDeployment(c)
+	suite.kubernetesAPI.NewReplicaSetIn("default", GetReplicaSetFor(current))
+	lastRS := GetReplicaSetFor(&suite.updatePlan.deployments[0])
+	revision, err := strconv.Atoi(lastRS.Annotations[ReplicaSetRevisionAnnotation])
+	c.Assert(err, IsNil)
+	suite.kubernetesAPI.NewReplicaSetIn("default", lastRS)
+	suite.updatePlan.deployments[0].Generation = int64(revision)
+	suite.updatePlan.deployments[0].Status.ObservedGeneration = int64(revision)
+
+	suite.querier.values = []float64{0.5, 2}
+	progress := Update(suite.updatePlan, suite.config)
+	suite.waitForFinish(progress)
+	c.Assert(progress.Failed(), Equals, true)
+	c.Assert(suite.getDeployment(c).Spec.Template.Spec.Containers[0].Image, Equals, "xcnt/test:1.0.0")
+}
+
+func (suite *AnalysisSuite) TestRollBackFailingQuery(c *C) {
+	suite.querier.err = errors.New("connection refused")
+	progress := Update(suite.updatePlan, suite.config)
+	suite.waitForFinish(progress)
+	c.Assert(progress.Failed(), Equals, true)
+	c.Assert(suite.querier.executedQueries(), HasLen, 1)
+}
+
+func (suite *AnalysisSuite) TestRollBackQueryWithoutData(c *C) {
+	suite.querier.values = nil
+	progress := Update(suite.updatePlan, suite.config)
+	suite.waitForFinish(progress)
+	c.Assert(progress.Failed(), Equals, true)
+	c.Assert(suite.querier.executedQueries(), HasLen, 1)
+}
+
+func (suite *AnalysisSuite) TestQueryWithoutDataMayPass(c *C) {
+	failOnNoData := false
+	suite.updatePlan.analysis.Queries[0].FailOnNoData = &failOnNoData
+	suite.querier.values = nil
+	progress := Update(suite.updatePlan, suite.config)
+	suite.waitForFinish(progress)
+	c.Assert(progress.Successful(), Equals, true)
+}
+
+func (suite *AnalysisSuite) TestEvaluateQueryWithoutData(c *C) {
+	deployment := suite.updatePlan.deployments[0]
+	up := &updater{}
+	err := up.evaluate(&Analysis{Querier: &fakeQuerier{}, Queries: suite.updatePlan.analysis.Queries}, &deployment)
+	c.Assert(err, FitsTypeOf, &AnalysisError{})
+	c.Assert(err, ErrorMatches, "Analysis of deployment default/api, query errors: The query did not return any value")
+}
+
+func (suite *AnalysisSuite) TestWithoutQueries(c *C) {
+	suite.updatePlan.analysis.Queries = nil
+	suite.updatePlan.analysis.Window = time.Hour
+	progress := Update(suite.updatePlan, suite.config)
+	suite.waitForFinish(progress)
+	c.Assert(progress.Successful(), Equals, true)
+	c.Assert(suite.querier.executedQueries(), HasLen, 0)
+}
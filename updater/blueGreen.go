@@ -0,0 +1,372 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/getsentry/raven-go"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/apps/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// UpdateStrategyAnnotation selects how a deployment is updated. Deployments are updated in place by the rolling
+	// update of kubernetes unless the strategy is blue-green.
+	UpdateStrategyAnnotation = "xcnt.io/update-strategy"
+	// BlueGreenServiceAnnotation lists the services, separated by commas, whose selector is switched to the new color
+	// of a blue-green deployment once it is ready.
+	BlueGreenServiceAnnotation = "xcnt.io/blue-green-service"
+	// BlueGreenTeardownDelayAnnotation is the duration, for example 30m, the previous color of a blue-green deployment
+	// is kept after the services have been switched. It defaults to one hour.
+	BlueGreenTeardownDelayAnnotation = "xcnt.io/blue-green-teardown-delay"
+	// BlueGreenPreviousAnnotation is set on the new color of a blue-green deployment to the name of the deployment of
+	// the previous color.
+	BlueGreenPreviousAnnotation = "xcnt.io/blue-green-previous"
+	// BlueGreenStandbyAnnotation is set on the previous color of a blue-green deployment to the time the services have
+	// been switched away from it. Deployments on standby are not updated anymore and torn down after the delay.
+	BlueGreenStandbyAnnotation = "xcnt.io/blue-green-standby"
+	// ColorLabel is the label of the pods, the selector of a blue-green deployment and its services which tells the
+	// colors apart.
+	ColorLabel = "xcnt.io/color"
+
+	// StrategyRolling updates the deployment in place.
+	StrategyRolling = "rolling"
+	// StrategyBlueGreen brings up a parallel deployment of the other color and switches the services to it.
+	StrategyBlueGreen = "blue-green"
+
+	colorBlue                     = "blue"
+	colorGreen                    = "green"
+	defaultBlueGreenTeardownDelay = time.Hour
+	revisionAnnotation            = "deployment.kubernetes.io/revision"
+)
+
+// StrategyError is returned when planning an update of a deployment whose update strategy annotations are invalid.
+type StrategyError struct {
+	// Namespace is the namespace of the deployment.
+	Namespace string
+	// Name is the name of the deployment.
+	Name string
+	// Reason describes the problem with the update strategy.
+	Reason string
+}
+
+// Error returns the description of the invalid update strategy.
+func (strategyError *StrategyError) Error() string {
+	return fmt.Sprintf("Deployment %s/%s: %s", strategyError.Namespace, strategyError.Name, strategyError.Reason)
+}
+
+// PlanConflict marks invalid update strategies as conflicts with the workloads.
+func (strategyError *StrategyError) PlanConflict() {}
+
+// CheckUpdateStrategies verifies that the update strategy annotations of the passed deployments are valid.
+func CheckUpdateStrategies(deployments []v1.Deployment) error {
+	for _, deployment := range deployments {
+		newError := func(reason string) error {
+			return &StrategyError{Namespace: deployment.Namespace, Name: deployment.Name, Reason: reason}
+		}
+		strategy, ok := deployment.Annotations[UpdateStrategyAnnotation]
+		if !ok || strategy == StrategyRolling {
+			continue
+		}
+		if strategy != StrategyBlueGreen {
+			return newError(fmt.Sprintf("The update strategy %q is neither %s nor %s", strategy, StrategyRolling, StrategyBlueGreen))
+		}
+		if len(blueGreenServicesOf(&deployment)) == 0 {
+			return newError("A blue-green deployment needs the services to switch in the " + BlueGreenServiceAnnotation + " annotation")
+		}
+		if _, err := blueGreenTeardownDelayOf(&deployment); err != nil {
+			return newError(fmt.Sprintf("The teardown delay %q is not a duration", deployment.Annotations[BlueGreenTeardownDelayAnnotation]))
+		}
+	}
+	return nil
+}
+
+func isBlueGreen(deployment *v1.Deployment) bool {
+	return deployment.Annotations[UpdateStrategyAnnotation] == StrategyBlueGreen
+}
+
+func blueGreenServicesOf(deployment *v1.Deployment) []string {
+	services := make([]string, 0)
+	for _, service := range strings.Split(deployment.Annotations[BlueGreenServiceAnnotation], ",") {
+		service = strings.TrimSpace(service)
+		if len(service) > 0 {
+			services = append(services, service)
+		}
+	}
+	return services
+}
+
+func blueGreenTeardownDelayOf(deployment *v1.Deployment) (time.Duration, error) {
+	value, ok := deployment.Annotations[BlueGreenTeardownDelayAnnotation]
+	if !ok || len(strings.TrimSpace(value)) == 0 {
+		return defaultBlueGreenTeardownDelay, nil
+	}
+	return time.ParseDuration(strings.TrimSpace(value))
+}
+
+// colorOf returns the color of the pods of the deployment or an empty string if they do not have a color yet.
+func colorOf(deployment *v1.Deployment) string {
+	if deployment.Spec.Template.Labels[ColorLabel] == colorBlue {
+		return colorBlue
+	}
+	if deployment.Spec.Template.Labels[ColorLabel] == colorGreen {
+		return colorGreen
+	}
+	return ""
+}
+
+// nextColor returns the color the deployment is updated to. Deployments without a color are followed by green.
+func nextColor(deployment *v1.Deployment) string {
+	if colorOf(deployment) == colorGreen {
+		return colorBlue
+	}
+	return colorGreen
+}
+
+// blueGreenDeploymentOf returns the deployment of the next color for the passed deployment of the current color and
+// its updated version. The new deployment is named after the current one with the color as suffix and takes over the
+// update classifier.
+func blueGreenDeploymentOf(current v1.Deployment, updated v1.Deployment) v1.Deployment {
+	color := nextColor(&current)
+	baseName := strings.TrimSuffix(strings.TrimSuffix(current.Name, "-"+colorBlue), "-"+colorGreen)
+	annotations := make(map[string]string, len(updated.Annotations)+1)
+	for key, value := range updated.Annotations {
+		annotations[key] = value
+	}
+	delete(annotations, revisionAnnotation)
+	delete(annotations, BlueGreenStandbyAnnotation)
+	annotations[BlueGreenPreviousAnnotation] = current.Name
+	spec := *updated.Spec.DeepCopy()
+	if spec.Selector == nil {
+		spec.Selector = &metaV1.LabelSelector{}
+	}
+	spec.Selector.MatchLabels = withLabel(spec.Selector.MatchLabels, ColorLabel, color)
+	spec.Template.Labels = withLabel(spec.Template.Labels, ColorLabel, color)
+	return v1.Deployment{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:        truncateName(baseName, maxNameSize-len(color)-1) + "-" + color,
+			Namespace:   current.Namespace,
+			Labels:      withLabel(updated.Labels, ColorLabel, color),
+			Annotations: annotations,
+		},
+		Spec: spec,
+	}
+}
+
+// applyBlueGreen creates the deployment of the new color. A deployment of the color which is still kept on standby
+// from a previous update is replaced.
+func (up *updater) applyBlueGreen(deployment *v1.Deployment) (*v1.Deployment, error) {
+	deploymentAPI := up.kubernetesWrapper.GetDeploymentAPIFor(deployment.Namespace)
+	created, err := deploymentAPI.Create(context.TODO(), deployment, metaV1.CreateOptions{})
+	if !apiErrors.IsAlreadyExists(err) {
+		return created, err
+	}
+	existing, err := deploymentAPI.Get(context.TODO(), deployment.Name, metaV1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	existing.Labels = deployment.Labels
+	existing.Annotations = deployment.Annotations
+	existing.Spec = deployment.Spec
+	return deploymentAPI.Update(context.TODO(), existing, metaV1.UpdateOptions{})
+}
+
+// switchBlueGreen waits for the new colors of the blue-green deployments of the wave to become ready and switches
+// their services to them. The previous colors are put on standby and torn down after the delay.
+func (up *updater) switchBlueGreen(wave Wave) error {
+	indices := make([]int, 0)
+	for _, index := range wave.Deployments {
+		if isBlueGreen(up.updateProgress.deployments[index]) {
+			indices = append(indices, index)
+		}
+	}
+	if len(indices) == 0 {
+		return nil
+	}
+	for !up.blueGreenReady(indices) {
+		if up.updateProgress.Failed() {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+		err := up.monitorChanges()
+		if err != nil {
+			return err
+		}
+	}
+	for _, index := range indices {
+		deployment := up.updateProgress.deployments[index]
+		err := up.selectColor(deployment, colorOf(deployment))
+		if err != nil {
+			return err
+		}
+		up.standby(deployment)
+	}
+	return nil
+}
+
+// blueGreenReady returns if the new colors of the blue-green deployments run all their replicas.
+func (up *updater) blueGreenReady(indices []int) bool {
+	for _, index := range indices {
+		deployment := up.updateProgress.deployments[index]
+		if !isDeploymentFinished(deployment) {
+			return false
+		}
+		if deployment.Spec.Replicas != nil && deployment.Status.ReadyReplicas < *deployment.Spec.Replicas {
+			return false
+		}
+	}
+	return true
+}
+
+// selectColor switches the services of the blue-green deployment to the pods of the color. Without a color, the
+// services select the pods of all colors.
+func (up *updater) selectColor(deployment *v1.Deployment, color string) error {
+	serviceAPI := up.kubernetesWrapper.GetServiceAPIFor(deployment.Namespace)
+	for _, name := range blueGreenServicesOf(deployment) {
+		service, err := serviceAPI.Get(context.TODO(), name, metaV1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if len(color) > 0 {
+			service.Spec.Selector = withLabel(service.Spec.Selector, ColorLabel, color)
+		} else {
+			delete(service.Spec.Selector, ColorLabel)
+		}
+		log.WithFields(log.Fields{
+			"service":   name,
+			"namespace": deployment.Namespace,
+			"color":     color,
+		}).Debug("Switching service")
+		_, err = serviceAPI.Update(context.TODO(), service, metaV1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// standby takes the update classifier from the previous color of the blue-green deployment, so it is not updated
+// anymore, and schedules its teardown. The previous color is kept until then for a quick revert. The services and
+// the teardown delay of the new color are recorded on the previous color together with the standby time, so
+// SweepStandbyDeployments tears it down even if the update manager is restarted before the delay passed.
+func (up *updater) standby(deployment *v1.Deployment) {
+	previousName := deployment.Annotations[BlueGreenPreviousAnnotation]
+	deploymentLogger := log.WithFields(log.Fields{"name": previousName, "namespace": deployment.Namespace})
+	deploymentAPI := up.kubernetesWrapper.GetDeploymentAPIFor(deployment.Namespace)
+	previous, err := deploymentAPI.Get(context.TODO(), previousName, metaV1.GetOptions{})
+	if err != nil {
+		deploymentLogger.WithError(err).Warn("Could not put the previous color on standby")
+		return
+	}
+	if previous.Annotations == nil {
+		previous.Annotations = map[string]string{}
+	}
+	delay, _ := blueGreenTeardownDelayOf(deployment)
+	delete(previous.Annotations, UpdateClassifier)
+	previous.Annotations[BlueGreenStandbyAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	previous.Annotations[BlueGreenServiceAnnotation] = deployment.Annotations[BlueGreenServiceAnnotation]
+	previous.Annotations[BlueGreenTeardownDelayAnnotation] = delay.String()
+	_, err = deploymentAPI.Update(context.TODO(), previous, metaV1.UpdateOptions{})
+	if err != nil {
+		deploymentLogger.WithError(err).Warn("Could not put the previous color on standby")
+		return
+	}
+	deploymentLogger.WithField("delay", delay.String()).Debug("Previous color is on standby")
+	time.AfterFunc(delay, func() {
+		previous, err := deploymentAPI.Get(context.TODO(), previousName, metaV1.GetOptions{})
+		if err == nil {
+			teardown(up.kubernetesWrapper, previous)
+		}
+	})
+}
+
+// SweepStandbyDeployments tears down the previous colors of the blue-green deployments in the passed namespaces whose
+// teardown delay passed since they have been put on standby. The standby time is read from the deployments, so
+// previous colors whose teardown has been scheduled by an earlier run of the update manager are torn down as well.
+func SweepStandbyDeployments(kubernetesWrapper KubernetesWrapper, namespaces []string) error {
+	for _, namespace := range namespaces {
+		deployments, err := kubernetesWrapper.GetDeploymentAPIFor(namespace).List(context.TODO(), metaV1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		for index := range deployments.Items {
+			deployment := &deployments.Items[index]
+			standby, ok := deployment.Annotations[BlueGreenStandbyAnnotation]
+			if !ok {
+				continue
+			}
+			standbyTime, err := time.Parse(time.RFC3339, standby)
+			if err != nil {
+				log.WithFields(log.Fields{"name": deployment.Name, "namespace": namespace}).WithError(err).Warn("Could not parse the standby time of the previous color")
+				continue
+			}
+			delay, err := blueGreenTeardownDelayOf(deployment)
+			if err != nil {
+				delay = defaultBlueGreenTeardownDelay
+			}
+			if time.Now().Before(standbyTime.Add(delay)) {
+				continue
+			}
+			teardown(kubernetesWrapper, deployment)
+		}
+	}
+	return nil
+}
+
+// teardown deletes the previous color of a blue-green deployment unless it has been taken into use again in the
+// meantime, either by a new update or by switching a service back to it.
+func teardown(kubernetesWrapper KubernetesWrapper, previous *v1.Deployment) {
+	deploymentLogger := log.WithFields(log.Fields{"name": previous.Name, "namespace": previous.Namespace})
+	if _, ok := previous.Annotations[BlueGreenStandbyAnnotation]; !ok {
+		return
+	}
+	color := colorOf(previous)
+	for _, serviceName := range blueGreenServicesOf(previous) {
+		service, err := kubernetesWrapper.GetServiceAPIFor(previous.Namespace).Get(context.TODO(), serviceName, metaV1.GetOptions{})
+		if err == nil && service.Spec.Selector[ColorLabel] == color {
+			deploymentLogger.Debug("Keeping the previous color as a service has been switched back to it")
+			return
+		}
+	}
+	deploymentLogger.Debug("Tearing down the previous color")
+	err := kubernetesWrapper.GetDeploymentAPIFor(previous.Namespace).Delete(context.TODO(), previous.Name, backgroundDeleteOptions())
+	if err != nil && !apiErrors.IsNotFound(err) {
+		deploymentLogger.WithError(err).Error("Could not tear down the previous color")
+		raven.CaptureError(err, nil)
+	}
+}
+
+// rollbackBlueGreen switches the services back to the previous color of the blue-green deployment, restores its
+// update classifier and removes the new color.
+func (up *updater) rollbackBlueGreen(deployment *v1.Deployment) error {
+	log.WithFields(log.Fields{
+		"namespace": deployment.Namespace,
+		"name":      deployment.Name,
+	}).Debug("Rolling back blue-green deployment")
+	deploymentAPI := up.kubernetesWrapper.GetDeploymentAPIFor(deployment.Namespace)
+	previous, err := deploymentAPI.Get(context.TODO(), deployment.Annotations[BlueGreenPreviousAnnotation], metaV1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	err = up.selectColor(deployment, colorOf(previous))
+	if err != nil {
+		return err
+	}
+	if _, ok := previous.Annotations[BlueGreenStandbyAnnotation]; ok {
+		delete(previous.Annotations, BlueGreenStandbyAnnotation)
+		previous.Annotations[UpdateClassifier] = deployment.Annotations[UpdateClassifier]
+		_, err = deploymentAPI.Update(context.TODO(), previous, metaV1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+	}
+	err = deploymentAPI.Delete(context.TODO(), deployment.Name, backgroundDeleteOptions())
+	if apiErrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
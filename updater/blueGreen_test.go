@@ -0,0 +1,242 @@
+package updater
+
+import (
+	"context"
+	"time"
+
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type BlueGreenSuite struct {
+	kubernetesAPI KubernetesAPI
+	config        *Config
+}
+
+var _ = Suite(&BlueGreenSuite{})
+
+func blueGreenDeployment(name string, teardownDelay string) v1.Deployment {
+	deployment := deploymentNamed("default", name, map[string]string{
+		UpdateClassifier:                 "stable",
+		UpdateStrategyAnnotation:         StrategyBlueGreen,
+		BlueGreenServiceAnnotation:       "api",
+		BlueGreenTeardownDelayAnnotation: teardownDelay,
+	})
+	deployment.Spec.Selector = &metaV1.LabelSelector{MatchLabels: map[string]string{"app": "api"}}
+	deployment.Spec.Template.Labels = map[string]string{"app": "api"}
+	return deployment
+}
+
+func (suite *BlueGreenSuite) SetUpTest(c *C) {
+	suite.kubernetesAPI = NewFakeKubernetesAPI()
+	suite.kubernetesAPI.NewNamespace("default")
+	suite.config = NewConfig(suite.kubernetesAPI.Client, NewImage("xcnt/test:1.1.0"), "stable")
+	suite.config.SetNamespaces([]string{"default"})
+	service := &apiv1.Service{
+		ObjectMeta: metaV1.ObjectMeta{Name: "api", Namespace: "default"},
+		Spec:       apiv1.ServiceSpec{Selector: map[string]string{"app": "api"}},
+	}
+	_, err := suite.kubernetesAPI.Client.CoreV1().Services("default").Create(context.TODO(), service, metaV1.CreateOptions{})
+	c.Assert(err, IsNil)
+}
+
+func (suite *BlueGreenSuite) getDeployment(name string) (*v1.Deployment, error) {
+	return suite.config.GetDeploymentAPIFor("default").Get(context.TODO(), name, metaV1.GetOptions{})
+}
+
+func (suite *BlueGreenSuite) serviceColor(c *C) string {
+	service, err := suite.config.GetServiceAPIFor("default").Get(context.TODO(), "api", metaV1.GetOptions{})
+	c.Assert(err, IsNil)
+	return service.Spec.Selector[ColorLabel]
+}
+
+// switchTo runs the update of the deployment and makes the new color ready. It returns once the service has been
+// switched to the new color.
+func (suite *BlueGreenSuite) switchTo(c *C, deployment v1.Deployment, color string) UpdateProgress {
+	c.Assert(suite.kubernetesAPI.NewDeploymentIn("default", deployment), IsNil)
+	updatePlan, err := Plan(suite.config)
+	c.Assert(err, IsNil)
+	progress := Update(updatePlan, suite.config)
+	var created *v1.Deployment
+	for i := 0; i < 20 && created == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+		created, _ = suite.getDeployment("api-" + color)
+	}
+	c.Assert(created, NotNil)
+	time.Sleep(150 * time.Millisecond)
+	c.Assert(suite.serviceColor(c), Equals, "")
+	c.Assert(progress.Finished(), Equals, false)
+
+	created.Status.Replicas = 1
+	created.Status.ReadyReplicas = 1
+	c.Assert(suite.kubernetesAPI.UpdateDeploymentIn("default", created), IsNil)
+	for i := 0; i < 20 && suite.serviceColor(c) != color; i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	c.Assert(suite.serviceColor(c), Equals, color)
+	return progress
+}
+
+func (suite *BlueGreenSuite) TestCheckUpdateStrategies(c *C) {
+	c.Assert(CheckUpdateStrategies([]v1.Deployment{blueGreenDeployment("api", "")}), IsNil)
+	c.Assert(CheckUpdateStrategies([]v1.Deployment{deploymentNamed("default", "api", map[string]string{UpdateStrategyAnnotation: StrategyRolling})}), IsNil)
+
+	err := CheckUpdateStrategies([]v1.Deployment{deploymentNamed("default", "api", map[string]string{UpdateStrategyAnnotation: "recreate"})})
+	c.Assert(err, FitsTypeOf, &StrategyError{})
+	c.Assert(err, ErrorMatches, `Deployment default/api: The update strategy "recreate" is neither rolling nor blue-green`)
+	err = CheckUpdateStrategies([]v1.Deployment{deploymentNamed("default", "api", map[string]string{UpdateStrategyAnnotation: StrategyBlueGreen})})
+	c.Assert(err, ErrorMatches, "Deployment default/api: A blue-green deployment needs the services to switch in the xcnt.io/blue-green-service annotation")
+	err = CheckUpdateStrategies([]v1.Deployment{blueGreenDeployment("api", "later")})
+	c.Assert(err, ErrorMatches, `Deployment default/api: The teardown delay "later" is not a duration`)
+}
+
+func (suite *BlueGreenSuite) TestBlueGreenDeployment(c *C) {
+	current := blueGreenDeployment("api", "")
+	current.Annotations[revisionAnnotation] = "3"
+	next := blueGreenDeploymentOf(current, current)
+	c.Assert(next.Name, Equals, "api-green")
+	c.Assert(next.Annotations[BlueGreenPreviousAnnotation], Equals, "api")
+	c.Assert(next.Annotations[UpdateClassifier], Equals, "stable")
+	_, ok := next.Annotations[revisionAnnotation]
+	c.Assert(ok, Equals, false)
+	c.Assert(next.Spec.Selector.MatchLabels, DeepEquals, map[string]string{"app": "api", ColorLabel: "green"})
+	c.Assert(next.Spec.Template.Labels, DeepEquals, map[string]string{"app": "api", ColorLabel: "green"})
+	c.Assert(current.Spec.Selector.MatchLabels, DeepEquals, map[string]string{"app": "api"})
+
+	following := blueGreenDeploymentOf(next, next)
+	c.Assert(following.Name, Equals, "api-blue")
+	c.Assert(following.Annotations[BlueGreenPreviousAnnotation], Equals, "api-green")
+	c.Assert(following.Spec.Template.Labels, DeepEquals, map[string]string{"app": "api", ColorLabel: "blue"})
+}
+
+func (suite *BlueGreenSuite) TestWavesReferToCurrentNames(c *C) {
+	updatePlaner := &UpdatePlaner{
+		JobLister: func() []batchv1.Job { return []batchv1.Job{} },
+		DeploymentLister: func() []v1.Deployment {
+			return []v1.Deployment{
+				deploymentNamed("default", "frontend", map[string]string{UpdateAfterAnnotation: "api"}),
+				blueGreenDeployment("api", ""),
+			}
+		},
+	}
+	updatePlan := updatePlaner.Plan(suite.config)
+	c.Assert(updatePlan.GetToApplyDeployments()[1].Name, Equals, "api-green")
+	c.Assert(updatePlan.GetWaves(), DeepEquals, []Wave{{Deployments: []int{1}}, {Deployments: []int{0}}})
+}
+
+func (suite *BlueGreenSuite) TestSwitchAndTearDown(c *C) {
+	progress := suite.switchTo(c, blueGreenDeployment("api", "200ms"), "green")
+	previous, err := suite.getDeployment("api")
+	c.Assert(err, IsNil)
+	c.Assert(previous.Annotations[UpdateClassifier], Equals, "")
+	c.Assert(previous.Annotations[BlueGreenStandbyAnnotation], Not(Equals), "")
+	c.Assert(previous.Annotations[BlueGreenTeardownDelayAnnotation], Equals, "200ms")
+	for i := 0; i < 20 && !progress.Finished(); i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	c.Assert(progress.Successful(), Equals, true)
+
+	time.Sleep(400 * time.Millisecond)
+	_, err = suite.getDeployment("api")
+	c.Assert(err, NotNil)
+	_, err = suite.getDeployment("api-green")
+	c.Assert(err, IsNil)
+}
+
+func (suite *BlueGreenSuite) TestKeepPreviousColorSwitchedBackTo(c *C) {
+	deployment := blueGreenDeployment("api-blue", "200ms")
+	deployment.Spec.Template.Labels[ColorLabel] = "blue"
+	suite.switchTo(c, deployment, "green")
+	service, err := suite.config.GetServiceAPIFor("default").Get(context.TODO(), "api", metaV1.GetOptions{})
+	c.Assert(err, IsNil)
+	service.Spec.Selector[ColorLabel] = "blue"
+	_, err = suite.config.GetServiceAPIFor("default").Update(context.TODO(), service, metaV1.UpdateOptions{})
+	c.Assert(err, IsNil)
+
+	time.Sleep(400 * time.Millisecond)
+	_, err = suite.getDeployment("api-blue")
+	c.Assert(err, IsNil)
+}
+
+func (suite *BlueGreenSuite) TestRollback(c *C) {
+	suite.switchTo(c, blueGreenDeployment("api", "1h"), "green")
+	current, err := suite.getDeployment("api-green")
+	c.Assert(err, IsNil)
+	up := &updater{kubernetesWrapper: suite.config}
+	c.Assert(up.rollbackDeployment(current), IsNil)
+
+	c.Assert(suite.serviceColor(c), Equals, "")
+	previous, err := suite.getDeployment("api")
+	c.Assert(err, IsNil)
+	c.Assert(previous.Annotations[UpdateClassifier], Equals, "stable")
+	_, ok := previous.Annotations[BlueGreenStandbyAnnotation]
+	c.Assert(ok, Equals, false)
+	_, err = suite.getDeployment("api-green")
+	c.Assert(err, NotNil)
+}
+
+func (suite *BlueGreenSuite) TestRollbackFinishedUpdate(c *C) {
+	progress := suite.switchTo(c, blueGreenDeployment("api", "1h"), "green")
+	for i := 0; i < 20 && !progress.Finished(); i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	c.Assert(progress.Successful(), Equals, true)
+	rollbackPlan := progress.GetRollbackPlan()
+	c.Assert(rollbackPlan.Targets, HasLen, 1)
+	c.Assert(rollbackPlan.Targets[0].Deployment.Name, Equals, "api-green")
+	c.Assert(rollbackPlan.Targets[0].PreviousTemplate, IsNil)
+
+	rollback := Rollback(rollbackPlan, suite.config)
+	for i := 0; i < 20 && !rollback.Finished(); i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	c.Assert(rollback.Failed(), Equals, false)
+	c.Assert(rollback.GetDeployments()[0].Name, Equals, "api")
+	c.Assert(suite.serviceColor(c), Equals, "")
+	previous, err := suite.getDeployment("api")
+	c.Assert(err, IsNil)
+	c.Assert(previous.Annotations[UpdateClassifier], Equals, "stable")
+	_, err = suite.getDeployment("api-green")
+	c.Assert(err, NotNil)
+}
+
+// standbyDeployment creates the previous color of a blue-green deployment which has been put on standby the passed
+// duration ago.
+func (suite *BlueGreenSuite) standbyDeployment(c *C, name string, color string, since time.Duration) {
+	deployment := blueGreenDeployment(name, "1h")
+	delete(deployment.Annotations, UpdateClassifier)
+	deployment.Annotations[BlueGreenStandbyAnnotation] = time.Now().Add(-since).UTC().Format(time.RFC3339)
+	deployment.Spec.Template.Labels[ColorLabel] = color
+	c.Assert(suite.kubernetesAPI.NewDeploymentIn("default", deployment), IsNil)
+}
+
+func (suite *BlueGreenSuite) TestSweepStandbyDeployments(c *C) {
+	suite.standbyDeployment(c, "api-blue", "blue", 2*time.Hour)
+	suite.standbyDeployment(c, "api-green", "green", 10*time.Minute)
+	active := blueGreenDeployment("api", "1h")
+	c.Assert(suite.kubernetesAPI.NewDeploymentIn("default", active), IsNil)
+
+	c.Assert(SweepStandbyDeployments(suite.config, []string{"default"}), IsNil)
+	_, err := suite.getDeployment("api-blue")
+	c.Assert(err, NotNil)
+	_, err = suite.getDeployment("api-green")
+	c.Assert(err, IsNil)
+	_, err = suite.getDeployment("api")
+	c.Assert(err, IsNil)
+}
+
+func (suite *BlueGreenSuite) TestSweepKeepsPreviousColorSwitchedBackTo(c *C) {
+	suite.standbyDeployment(c, "api-blue", "blue", 2*time.Hour)
+	service, err := suite.config.GetServiceAPIFor("default").Get(context.TODO(), "api", metaV1.GetOptions{})
+	c.Assert(err, IsNil)
+	service.Spec.Selector[ColorLabel] = "blue"
+	_, err = suite.config.GetServiceAPIFor("default").Update(context.TODO(), service, metaV1.UpdateOptions{})
+	c.Assert(err, IsNil)
+
+	c.Assert(SweepStandbyDeployments(suite.config, []string{"default"}), IsNil)
+	_, err = suite.getDeployment("api-blue")
+	c.Assert(err, IsNil)
+}
@@ -0,0 +1,250 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/apps/v1"
+	apiErrors "k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// CanaryLabel marks the deployment and the pods of a canary. Its value is the name of the deployment the canary
+	// has been created for.
+	CanaryLabel = "xcnt.io/canary"
+
+	canarySuffix      = "-canary"
+	maxLabelValueSize = 63
+	maxNameSize       = 253
+)
+
+var (
+	// ErrInvalidCanaryWeight is returned if the canary weight is not a percentage between 1 and 99
+	ErrInvalidCanaryWeight = errors.New("The canary weight must be between 1 and 99 percent")
+	// ErrInvalidCanarySoakTime is returned if the soak time of a canary is negative
+	ErrInvalidCanarySoakTime = errors.New("The canary soak time must not be negative")
+	// ErrInvalidCanaryMaxRestarts is returned if the tolerated number of restarts of a canary is negative
+	ErrInvalidCanaryMaxRestarts = errors.New("The canary max restarts must not be negative")
+)
+
+// CanaryError is returned if the canary of a deployment failed its checks during the soak time.
+type CanaryError struct {
+	// Namespace is the namespace of the deployment.
+	Namespace string
+	// Name is the name of the deployment.
+	Name string
+	// Reason describes why the canary failed.
+	Reason string
+}
+
+// Error returns the description of the failed canary.
+func (canaryError *CanaryError) Error() string {
+	return fmt.Sprintf("Canary of deployment %s/%s: %s", canaryError.Namespace, canaryError.Name, canaryError.Reason)
+}
+
+// Canary configures an update which first runs the new image in a canary copy of each deployment next to the
+// previous version. The deployments are only updated if the canaries stayed healthy for the soak time, otherwise the
+// canaries are removed and the update fails.
+type Canary struct {
+	// Weight is the percentage of the pods of a deployment, and with that of its traffic, which run the new image while
+	// the canary soaks.
+	Weight int
+	// SoakTime is the time the canaries have to run before the deployments are updated. The canaries have to be ready
+	// at the end of the soak time.
+	SoakTime time.Duration
+	// MaxRestarts is the number of container restarts of the pods of a canary which are tolerated during the soak time.
+	MaxRestarts int32
+}
+
+// Validate returns an error if the canary configuration can not be applied.
+func (canary *Canary) Validate() error {
+	if canary.Weight < 1 || canary.Weight > 99 {
+		return ErrInvalidCanaryWeight
+	}
+	if canary.SoakTime < 0 {
+		return ErrInvalidCanarySoakTime
+	}
+	if canary.MaxRestarts < 0 {
+		return ErrInvalidCanaryMaxRestarts
+	}
+	return nil
+}
+
+// Replicas returns the number of canary replicas which make up the weight of the pods next to the passed number of
+// replicas running the previous version. At least one canary replica is started.
+func (canary *Canary) Replicas(replicas int32) int32 {
+	remaining := int64(100 - canary.Weight)
+	canaryReplicas := (int64(replicas)*int64(canary.Weight) + remaining - 1) / remaining
+	if canaryReplicas < 1 {
+		return 1
+	}
+	return int32(canaryReplicas)
+}
+
+// canaryDeploymentOf returns the canary of the passed updated deployment. The pods of the canary carry the labels of
+// the deployment's pods, so services route the weight of the traffic to them, and the canary label which keeps them
+// apart from the pods of the deployment.
+func canaryDeploymentOf(deployment v1.Deployment, canary *Canary) *v1.Deployment {
+	labelValue := truncateName(deployment.Name, maxLabelValueSize)
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	canaryReplicas := canary.Replicas(replicas)
+	spec := *deployment.Spec.DeepCopy()
+	spec.Replicas = &canaryReplicas
+	if spec.Selector == nil {
+		spec.Selector = &metaV1.LabelSelector{}
+	}
+	spec.Selector.MatchLabels = withLabel(spec.Selector.MatchLabels, CanaryLabel, labelValue)
+	spec.Template.Labels = withLabel(spec.Template.Labels, CanaryLabel, labelValue)
+	return &v1.Deployment{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      truncateName(deployment.Name, maxNameSize-len(canarySuffix)) + canarySuffix,
+			Namespace: deployment.Namespace,
+			Labels:    withLabel(deployment.Labels, CanaryLabel, labelValue),
+		},
+		Spec: spec,
+	}
+}
+
+func withLabel(labels map[string]string, key string, value string) map[string]string {
+	copied := make(map[string]string, len(labels)+1)
+	for labelKey, labelValue := range labels {
+		copied[labelKey] = labelValue
+	}
+	copied[key] = value
+	return copied
+}
+
+func truncateName(name string, size int) string {
+	if len(name) <= size {
+		return name
+	}
+	return strings.TrimRight(name[:size], "-.")
+}
+
+// runCanaries creates the canaries of the deployments of the wave and soaks them. Blue-green deployments are not
+// soaked in canaries, as their new color is only switched to once it is ready. The canaries of a successful soak are
+// returned and have to be removed once the deployments are updated. Otherwise the canaries are removed right away.
+func (up *updater) runCanaries(deployments []v1.Deployment, wave Wave, canary *Canary) ([]*v1.Deployment, error) {
+	soaked := make([]v1.Deployment, 0, len(wave.Deployments))
+	canaries := make([]*v1.Deployment, 0, len(wave.Deployments))
+	for _, index := range wave.Deployments {
+		if isBlueGreen(&deployments[index]) {
+			continue
+		}
+		canaryDeployment, err := up.createCanary(canaryDeploymentOf(deployments[index], canary))
+		if err != nil {
+			up.deleteCanaries(canaries)
+			return nil, err
+		}
+		soaked = append(soaked, deployments[index])
+		canaries = append(canaries, canaryDeployment)
+	}
+	err := up.soakCanaries(soaked, canaries, canary)
+	if err != nil || up.updateProgress.Failed() {
+		up.deleteCanaries(canaries)
+		return nil, err
+	}
+	return canaries, nil
+}
+
+// createCanary creates the canary deployment. A canary left over from a previous update is replaced.
+func (up *updater) createCanary(canaryDeployment *v1.Deployment) (*v1.Deployment, error) {
+	log.WithFields(log.Fields{
+		"name":      canaryDeployment.Name,
+		"namespace": canaryDeployment.Namespace,
+		"replicas":  *canaryDeployment.Spec.Replicas,
+	}).Debug("Creating canary")
+	deploymentAPI := up.kubernetesWrapper.GetDeploymentAPIFor(canaryDeployment.Namespace)
+	err := deploymentAPI.Delete(context.TODO(), canaryDeployment.Name, backgroundDeleteOptions())
+	if err != nil && !apiErrors.IsNotFound(err) {
+		return nil, err
+	}
+	return deploymentAPI.Create(context.TODO(), canaryDeployment, metaV1.CreateOptions{})
+}
+
+// soakCanaries waits for the soak time and fails as soon as the pods of a canary restarted too often. At the end of
+// the soak time all canaries have to be ready. The soak is stopped early if the update is aborted.
+func (up *updater) soakCanaries(deployments []v1.Deployment, canaries []*v1.Deployment, canary *Canary) error {
+	soakEnd := time.Now().Add(canary.SoakTime)
+	for {
+		if up.updateProgress.Failed() {
+			return nil
+		}
+		soaked := !time.Now().Before(soakEnd)
+		for position, canaryDeployment := range canaries {
+			err := up.checkCanary(deployments[position], canaryDeployment, canary, soaked)
+			if err != nil {
+				return err
+			}
+		}
+		if soaked {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// checkCanary verifies the restarts of the canary pods and, once the soak time is over, that the canary is ready.
+func (up *updater) checkCanary(deployment v1.Deployment, canaryDeployment *v1.Deployment, canary *Canary, soaked bool) error {
+	pods, err := up.kubernetesWrapper.GetPodAPIFor(canaryDeployment.Namespace).List(context.TODO(), metaV1.ListOptions{
+		LabelSelector: CanaryLabel + "=" + canaryDeployment.Labels[CanaryLabel],
+	})
+	if err != nil {
+		return err
+	}
+	restarts := int32(0)
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			restarts += status.RestartCount
+		}
+	}
+	if restarts > canary.MaxRestarts {
+		return &CanaryError{
+			Namespace: deployment.Namespace,
+			Name:      deployment.Name,
+			Reason:    fmt.Sprintf("The canary pods restarted %d times", restarts),
+		}
+	}
+	if !soaked {
+		return nil
+	}
+	current, err := up.kubernetesWrapper.GetDeploymentAPIFor(canaryDeployment.Namespace).Get(context.TODO(), canaryDeployment.Name, metaV1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if !isDeploymentFinished(current) || current.Status.ReadyReplicas < *canaryDeployment.Spec.Replicas {
+		return &CanaryError{
+			Namespace: deployment.Namespace,
+			Name:      deployment.Name,
+			Reason:    "The canary did not become ready within the soak time",
+		}
+	}
+	return nil
+}
+
+// deleteCanaries removes the passed canaries. Errors are logged, as a remaining canary does not affect the update.
+func (up *updater) deleteCanaries(canaries []*v1.Deployment) {
+	for _, canaryDeployment := range canaries {
+		err := up.kubernetesWrapper.GetDeploymentAPIFor(canaryDeployment.Namespace).Delete(context.TODO(), canaryDeployment.Name, backgroundDeleteOptions())
+		if err != nil && !apiErrors.IsNotFound(err) {
+			log.WithFields(log.Fields{
+				"name":      canaryDeployment.Name,
+				"namespace": canaryDeployment.Namespace,
+			}).WithError(err).Warn("Could not delete canary")
+		}
+	}
+}
+
+// backgroundDeleteOptions deletes a deployment together with its replica sets and pods in the background.
+func backgroundDeleteOptions() metaV1.DeleteOptions {
+	propagation := metaV1.DeletePropagationBackground
+	return metaV1.DeleteOptions{PropagationPolicy: &propagation}
+}
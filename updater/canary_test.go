@@ -0,0 +1,139 @@
+package updater
+
+import (
+	"context"
+	"time"
+
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type CanarySuite struct {
+	kubernetesAPI KubernetesAPI
+	config        *Config
+	deployment    v1.Deployment
+	updatePlan    *updatePlan
+}
+
+var _ = Suite(&CanarySuite{})
+
+func (suite *CanarySuite) SetUpTest(c *C) {
+	suite.kubernetesAPI = NewFakeKubernetesAPI()
+	suite.kubernetesAPI.NewNamespace("default")
+	suite.config = NewConfig(suite.kubernetesAPI.Client, NewImage("xcnt/test:1.0.0"), "stable")
+	suite.deployment = deploymentNamed("default", "api", map[string]string{UpdateClassifier: "stable"})
+	suite.deployment.Spec.Template.Labels = map[string]string{"app": "api"}
+	suite.deployment.Spec.Selector = &metaV1.LabelSelector{MatchLabels: map[string]string{"app": "api"}}
+	c.Assert(suite.kubernetesAPI.NewDeploymentIn("default", suite.deployment), IsNil)
+	updated := *suite.deployment.DeepCopy()
+	updated.Annotations["xcnt.io/updated"] = "true"
+	updated.Spec.Template.Spec.Containers[0].Image = "xcnt/test:1.1.0"
+	suite.updatePlan = &updatePlan{
+		deployments: []v1.Deployment{updated},
+		jobs:        []batchv1.Job{},
+		canary:      &Canary{Weight: 10, SoakTime: 300 * time.Millisecond},
+	}
+}
+
+func (suite *CanarySuite) getDeployment(name string) (*v1.Deployment, error) {
+	return suite.config.GetDeploymentAPIFor("default").Get(context.TODO(), name, metaV1.GetOptions{})
+}
+
+func (suite *CanarySuite) waitForCanary(c *C) *v1.Deployment {
+	for i := 0; i < 20; i++ {
+		canaryDeployment, err := suite.getDeployment("api-canary")
+		if err == nil {
+			return canaryDeployment
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Fatal("The canary has not been created")
+	return nil
+}
+
+func (suite *CanarySuite) TestValidate(c *C) {
+	c.Assert((&Canary{Weight: 10, SoakTime: time.Minute}).Validate(), IsNil)
+	c.Assert((&Canary{Weight: 0}).Validate(), Equals, ErrInvalidCanaryWeight)
+	c.Assert((&Canary{Weight: 100}).Validate(), Equals, ErrInvalidCanaryWeight)
+	c.Assert((&Canary{Weight: 10, SoakTime: -time.Second}).Validate(), Equals, ErrInvalidCanarySoakTime)
+	c.Assert((&Canary{Weight: 10, MaxRestarts: -1}).Validate(), Equals, ErrInvalidCanaryMaxRestarts)
+}
+
+func (suite *CanarySuite) TestReplicas(c *C) {
+	c.Assert((&Canary{Weight: 10}).Replicas(9), Equals, int32(1))
+	c.Assert((&Canary{Weight: 10}).Replicas(1), Equals, int32(1))
+	c.Assert((&Canary{Weight: 50}).Replicas(4), Equals, int32(4))
+	c.Assert((&Canary{Weight: 25}).Replicas(10), Equals, int32(4))
+	c.Assert((&Canary{Weight: 10}).Replicas(0), Equals, int32(1))
+}
+
+func (suite *CanarySuite) TestCanaryDeployment(c *C) {
+	canaryDeployment := canaryDeploymentOf(suite.updatePlan.deployments[0], &Canary{Weight: 50})
+	c.Assert(canaryDeployment.Name, Equals, "api-canary")
+	c.Assert(canaryDeployment.Annotations[UpdateClassifier], Equals, "")
+	c.Assert(*canaryDeployment.Spec.Replicas, Equals, int32(1))
+	c.Assert(canaryDeployment.Spec.Selector.MatchLabels, DeepEquals, map[string]string{"app": "api", CanaryLabel: "api"})
+	c.Assert(canaryDeployment.Spec.Template.Labels, DeepEquals, map[string]string{"app": "api", CanaryLabel: "api"})
+	c.Assert(canaryDeployment.Spec.Template.Spec.Containers[0].Image, Equals, "xcnt/test:1.1.0")
+	c.Assert(suite.updatePlan.deployments[0].Spec.Selector.MatchLabels, DeepEquals, map[string]string{"app": "api"})
+}
+
+func (suite *CanarySuite) TestPromoteHealthyCanary(c *C) {
+	progress := Update(suite.updatePlan, suite.config)
+	canaryDeployment := suite.waitForCanary(c)
+	current, err := suite.getDeployment("api")
+	c.Assert(err, IsNil)
+	c.Assert(current.Annotations["xcnt.io/updated"], Equals, "")
+
+	canaryDeployment.Status.Replicas = 1
+	canaryDeployment.Status.ReadyReplicas = 1
+	c.Assert(suite.kubernetesAPI.UpdateDeploymentIn("default", canaryDeployment), IsNil)
+	for i := 0; i < 20 && current.Annotations["xcnt.io/updated"] == ""; i++ {
+		time.Sleep(50 * time.Millisecond)
+		current, _ = suite.getDeployment("api")
+	}
+	c.Assert(current.Annotations["xcnt.io/updated"], Equals, "true")
+	c.Assert(progress.Failed(), Equals, false)
+	_, err = suite.getDeployment("api-canary")
+	c.Assert(err, NotNil)
+}
+
+func (suite *CanarySuite) TestRollBackRestartingCanary(c *C) {
+	progress := Update(suite.updatePlan, suite.config)
+	suite.waitForCanary(c)
+	pod := &apiv1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "api-canary-1",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "api", CanaryLabel: "api"},
+		},
+		Status: apiv1.PodStatus{ContainerStatuses: []apiv1.ContainerStatus{{Name: "app", RestartCount: 2}}},
+	}
+	_, err := suite.kubernetesAPI.Client.CoreV1().Pods("default").Create(context.TODO(), pod, metaV1.CreateOptions{})
+	c.Assert(err, IsNil)
+	for i := 0; i < 20 && !progress.Finished(); i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	c.Assert(progress.Failed(), Equals, true)
+	time.Sleep(50 * time.Millisecond)
+	_, err = suite.getDeployment("api-canary")
+	c.Assert(err, NotNil)
+	current, err := suite.getDeployment("api")
+	c.Assert(err, IsNil)
+	c.Assert(current.Annotations["xcnt.io/updated"], Equals, "")
+}
+
+func (suite *CanarySuite) TestRollBackCanaryNotReady(c *C) {
+	progress := Update(suite.updatePlan, suite.config)
+	suite.waitForCanary(c)
+	for i := 0; i < 20 && !progress.Finished(); i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+	c.Assert(progress.Failed(), Equals, true)
+	current, err := suite.getDeployment("api")
+	c.Assert(err, IsNil)
+	c.Assert(current.Annotations["xcnt.io/updated"], Equals, "")
+}
@@ -1,6 +1,8 @@
 package updater
 
 import (
+	"time"
+
 	"k8s.io/client-go/kubernetes"
 	appsV1 "k8s.io/client-go/kubernetes/typed/apps/v1"
 	batchV1Interface "k8s.io/client-go/kubernetes/typed/batch/v1"
@@ -57,6 +59,16 @@ func (config *ClientsetWrapper) GetReplicaSetAPIFor(namespace string) appsV1.Rep
 	return appsV1.ReplicaSets(namespace)
 }
 
+// GetPodAPIFor returns the API to interact with pods for the passed namespace
+func (config *ClientsetWrapper) GetPodAPIFor(namespace string) corev1.PodInterface {
+	return config.getCoreV1().Pods(namespace)
+}
+
+// GetServiceAPIFor returns the API to interact with services for the passed namespace
+func (config *ClientsetWrapper) GetServiceAPIFor(namespace string) corev1.ServiceInterface {
+	return config.getCoreV1().Services(namespace)
+}
+
 func (config *ClientsetWrapper) getCoreV1() corev1.CoreV1Interface {
 	return config.GetClientset().CoreV1()
 }
@@ -65,8 +77,19 @@ func (config *ClientsetWrapper) getCoreV1() corev1.CoreV1Interface {
 type Config struct {
 	ClientsetWrapper
 	image            *Image
+	imageDigest      string
 	updateClassifier string
 	namespaces       []string
+	labelSelector    string
+	requester        string
+	updateUUID       string
+	revision         string
+	changeCause      string
+	notBefore        time.Time
+	force            bool
+	canary           *Canary
+	analysis         *Analysis
+	rollbackPolicy   RollbackPolicy
 }
 
 // GetNamespaces returns an array of all namespaces which should be used.
@@ -82,12 +105,150 @@ func (config *Config) SetNamespaces(namespaces []string) {
 	config.namespaces = namespaces
 }
 
+// GetLabelSelector returns the label selector deployments and jobs need to match to be considered for the update.
+func (config *Config) GetLabelSelector() string {
+	return config.labelSelector
+}
+
+// SetLabelSelector restricts the deployments and jobs considered for the update to the ones matching the label selector.
+func (config *Config) SetLabelSelector(labelSelector string) {
+	config.labelSelector = labelSelector
+}
+
+// GetRequester returns the name of the principal which requested the update.
+func (config *Config) GetRequester() string {
+	return config.requester
+}
+
+// SetRequester attaches the name of the principal which requested the update to the configuration.
+func (config *Config) SetRequester(requester string) {
+	config.requester = requester
+}
+
+// GetUpdateUUID returns the uuid of the update stamped on the updated deployments.
+func (config *Config) GetUpdateUUID() string {
+	return config.updateUUID
+}
+
+// SetUpdateUUID sets the uuid of the update stamped on the updated deployments.
+func (config *Config) SetUpdateUUID(updateUUID string) {
+	config.updateUUID = updateUUID
+}
+
+// GetRevision returns the revision, for example the commit SHA, the update has been built from.
+func (config *Config) GetRevision() string {
+	return config.revision
+}
+
+// SetRevision sets the revision, for example the commit SHA, the update has been built from.
+func (config *Config) SetRevision(revision string) {
+	config.revision = revision
+}
+
+// GetChangeCause returns the free-form description of the update shown in the rollout history of the deployments.
+func (config *Config) GetChangeCause() string {
+	return config.changeCause
+}
+
+// SetChangeCause sets the free-form description of the update shown in the rollout history of the deployments.
+func (config *Config) SetChangeCause(changeCause string) {
+	config.changeCause = changeCause
+}
+
+// GetNotBefore returns the time the update must not be started before. It is the zero time if the update may be
+// started immediately.
+func (config *Config) GetNotBefore() time.Time {
+	return config.notBefore
+}
+
+// SetNotBefore sets the time the update must not be started before.
+func (config *Config) SetNotBefore(notBefore time.Time) {
+	config.notBefore = notBefore
+}
+
 // GetImage returns the image which should be updated.
 func (config *Config) GetImage() *Image {
 	return config.image
 }
 
+// GetImageDigest returns the digest the image has been pinned to or an empty string if it is deployed by its name.
+func (config *Config) GetImageDigest() string {
+	return config.imageDigest
+}
+
+// SetImageDigest pins the image to the passed digest, for example once its signature has been verified, so the
+// verified image is deployed even if its tag is moved.
+func (config *Config) SetImageDigest(digest string) {
+	config.imageDigest = digest
+}
+
+// GetDeployedImage returns the image the containers are updated to. It is pinned to the digest of the image if one has
+// been set.
+func (config *Config) GetDeployedImage() *Image {
+	if len(config.imageDigest) == 0 {
+		return config.image
+	}
+	return config.image.WithDigest(config.imageDigest)
+}
+
 // GetUpdateClassifier returns the update classifier passed to this update configuration.
 func (config *Config) GetUpdateClassifier() string {
 	return config.updateClassifier
 }
+
+// SetForce toggles if the version guards of the workloads should be ignored for the update.
+func (config *Config) SetForce(force bool) {
+	config.force = force
+}
+
+// IsForced returns if the version guards of the workloads are ignored for the update.
+func (config *Config) IsForced() bool {
+	return config.force
+}
+
+// SetCanary configures the update to soak the new image in canaries of the deployments before updating them. Passing
+// nil updates the deployments directly.
+func (config *Config) SetCanary(canary *Canary) {
+	config.canary = canary
+}
+
+// GetCanary returns the canary configuration of the update or nil if the deployments are updated directly.
+func (config *Config) GetCanary() *Canary {
+	return config.canary
+}
+
+// SetAnalysis configures the metric analysis of the deployments once they are ready. Passing nil skips the analysis.
+func (config *Config) SetAnalysis(analysis *Analysis) {
+	config.analysis = analysis
+}
+
+// GetAnalysis returns the metric analysis of the deployments or nil if they are not analyzed.
+func (config *Config) GetAnalysis() *Analysis {
+	return config.analysis
+}
+
+// SetRollbackPolicy sets the rollback policy of the deployments without a rollback policy annotation.
+func (config *Config) SetRollbackPolicy(rollbackPolicy RollbackPolicy) {
+	config.rollbackPolicy = rollbackPolicy
+}
+
+// GetRollbackPolicy returns the rollback policy of the deployments without a rollback policy annotation. It defaults
+// to rollback-all.
+func (config *Config) GetRollbackPolicy() RollbackPolicy {
+	if len(config.rollbackPolicy) == 0 {
+		return RollbackAll
+	}
+	return config.rollbackPolicy
+}
+
+// WithUpdateClassifier returns a copy of the configuration for an update of the same image with another update
+// classifier. The uuid, the not before time and the metric analysis are specific to an update and not copied.
+func (config *Config) WithUpdateClassifier(updateClassifier string) *Config {
+	copied := *config
+	copied.updateClassifier = updateClassifier
+	copied.namespaces = append([]string{}, config.GetNamespaces()...)
+	copied.updateUUID = ""
+	copied.notBefore = time.Time{}
+	copied.analysis = nil
+	return &copied
+}
@@ -0,0 +1,70 @@
+package updater
+
+import (
+	"strings"
+)
+
+const (
+	// UpdateContainersAnnotation lists the comma separated names of the containers the update manager may update.
+	// If it is not set, all containers with a matching image are updated.
+	UpdateContainersAnnotation = "xcnt.io/update-containers"
+	// ExcludeContainersAnnotation lists the comma separated names of the containers the update manager must not update.
+	ExcludeContainersAnnotation = "xcnt.io/update-exclude-containers"
+)
+
+// NewContainerFilter returns the container filter configured by the annotations of a workload.
+func NewContainerFilter(annotations map[string]string) *ContainerFilter {
+	filter := &ContainerFilter{
+		exclude: splitNames(annotations[ExcludeContainersAnnotation]),
+	}
+	if include, ok := annotations[UpdateContainersAnnotation]; ok {
+		filter.include = splitNames(include)
+	}
+	return filter
+}
+
+// ContainerFilter decides which containers of a workload may be touched by an update.
+type ContainerFilter struct {
+	include map[string]bool
+	exclude map[string]bool
+}
+
+// Allows returns if the container with the passed name may be updated.
+func (filter *ContainerFilter) Allows(name string) bool {
+	if filter.exclude[name] {
+		return false
+	}
+	return filter.include == nil || filter.include[name]
+}
+
+// ContainerChange describes a container of a planned workload which runs the updated image.
+type ContainerChange struct {
+	// Kind is the kind of the workload, for example Deployment or Job.
+	Kind string
+	// Namespace is the namespace of the workload.
+	Namespace string
+	// Name is the name of the workload.
+	Name string
+	// Container is the name of the container.
+	Container string
+	// ContainerType is either container or initContainer.
+	ContainerType string
+	// PreviousImage is the image the container is currently running.
+	PreviousImage string
+	// Image is the image the container runs after the update.
+	Image string
+	// Excluded is set if the container runs the image but has been excluded from the update by the annotations
+	// of the workload.
+	Excluded bool
+}
+
+func splitNames(value string) map[string]bool {
+	names := map[string]bool{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if len(name) > 0 {
+			names[name] = true
+		}
+	}
+	return names
+}
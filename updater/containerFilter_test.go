@@ -0,0 +1,161 @@
+package updater
+
+import (
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	apiv1 "k8s.io/api/core/v1"
+)
+
+type ContainerFilterSuite struct {
+	config *Config
+}
+
+var _ = Suite(&ContainerFilterSuite{})
+
+func (suite *ContainerFilterSuite) SetUpTest(c *C) {
+	fakeAPI := NewFakeKubernetesAPI()
+	suite.config = NewConfig(fakeAPI.Client, NewImage("xcnt/test:1.0.0"), "stable")
+}
+
+func (suite *ContainerFilterSuite) plan(deployments []v1.Deployment, jobs []batchv1.Job) UpdatePlan {
+	updatePlaner := &UpdatePlaner{
+		JobLister:        func() []batchv1.Job { return jobs },
+		DeploymentLister: func() []v1.Deployment { return deployments },
+	}
+	return updatePlaner.Plan(suite.config)
+}
+
+func (suite *ContainerFilterSuite) deploymentWith(annotations map[string]string) v1.Deployment {
+	deployment := GetDeploymentWith(annotations, "xcnt/test:0.9.9", "xcnt/test:0.9.9")
+	deployment.Spec.Template.Spec.Containers[0].Name = "app"
+	deployment.Spec.Template.Spec.Containers[1].Name = "worker"
+	return deployment
+}
+
+func (suite *ContainerFilterSuite) TestAllowsWithoutAnnotations(c *C) {
+	filter := NewContainerFilter(nil)
+	c.Assert(filter.Allows("app"), Equals, true)
+}
+
+func (suite *ContainerFilterSuite) TestAllowsIncluded(c *C) {
+	filter := NewContainerFilter(map[string]string{UpdateContainersAnnotation: "app, worker"})
+	c.Assert(filter.Allows("app"), Equals, true)
+	c.Assert(filter.Allows("worker"), Equals, true)
+	c.Assert(filter.Allows("sidecar"), Equals, false)
+}
+
+func (suite *ContainerFilterSuite) TestAllowsEmptyInclude(c *C) {
+	filter := NewContainerFilter(map[string]string{UpdateContainersAnnotation: ""})
+	c.Assert(filter.Allows("app"), Equals, false)
+}
+
+func (suite *ContainerFilterSuite) TestExcludeWins(c *C) {
+	filter := NewContainerFilter(map[string]string{
+		UpdateContainersAnnotation:  "app,worker",
+		ExcludeContainersAnnotation: "worker",
+	})
+	c.Assert(filter.Allows("app"), Equals, true)
+	c.Assert(filter.Allows("worker"), Equals, false)
+}
+
+func (suite *ContainerFilterSuite) TestPlanIncludedContainers(c *C) {
+	deployment := suite.deploymentWith(map[string]string{UpdateContainersAnnotation: "worker"})
+	plan := suite.plan([]v1.Deployment{deployment}, nil)
+	containers := plan.GetToApplyDeployments()[0].Spec.Template.Spec.Containers
+	c.Assert(containers[0].Image, Equals, "xcnt/test:0.9.9")
+	c.Assert(containers[1].Image, Equals, "xcnt/test:1.0.0")
+}
+
+func (suite *ContainerFilterSuite) TestPlanExcludedContainers(c *C) {
+	deployment := suite.deploymentWith(map[string]string{ExcludeContainersAnnotation: "worker"})
+	plan := suite.plan([]v1.Deployment{deployment}, nil)
+	containers := plan.GetToApplyDeployments()[0].Spec.Template.Spec.Containers
+	c.Assert(containers[0].Image, Equals, "xcnt/test:1.0.0")
+	c.Assert(containers[1].Image, Equals, "xcnt/test:0.9.9")
+}
+
+func (suite *ContainerFilterSuite) TestPlanDoesNotChangeListedDeployment(c *C) {
+	deployment := suite.deploymentWith(nil)
+	suite.plan([]v1.Deployment{deployment}, nil)
+	c.Assert(deployment.Spec.Template.Spec.Containers[0].Image, Equals, "xcnt/test:0.9.9")
+}
+
+func (suite *ContainerFilterSuite) TestPlanInitAndEphemeralContainers(c *C) {
+	deployment := suite.deploymentWith(map[string]string{ExcludeContainersAnnotation: "migrate"})
+	initContainer := GetContainerWith("xcnt/test:0.9.9")
+	initContainer.Name = "migrate"
+	deployment.Spec.Template.Spec.InitContainers = []apiv1.Container{initContainer}
+	deployment.Spec.Template.Spec.EphemeralContainers = []apiv1.EphemeralContainer{
+		{EphemeralContainerCommon: apiv1.EphemeralContainerCommon{Name: "debug", Image: "xcnt/test:0.9.9"}},
+	}
+	plan := suite.plan([]v1.Deployment{deployment}, nil)
+	podSpec := plan.GetToApplyDeployments()[0].Spec.Template.Spec
+	c.Assert(podSpec.InitContainers[0].Image, Equals, "xcnt/test:0.9.9")
+	c.Assert(podSpec.EphemeralContainers[0].Image, Equals, "xcnt/test:0.9.9")
+	for _, change := range plan.GetContainerChanges() {
+		c.Assert(change.Container, Not(Equals), "debug")
+	}
+}
+
+func (suite *ContainerFilterSuite) TestPlanContainerChanges(c *C) {
+	deployment := suite.deploymentWith(map[string]string{ExcludeContainersAnnotation: "worker"})
+	job := GetJobWith(map[string]string{UpdateContainersAnnotation: "migrate"}, "xcnt/test:0.9.9", "xcnt/other:1.0.0")
+	job.Spec.Template.Spec.Containers[0].Name = "migrate"
+	plan := suite.plan([]v1.Deployment{deployment}, []batchv1.Job{job})
+	changes := plan.GetContainerChanges()
+	c.Assert(changes, DeepEquals, []ContainerChange{
+		{
+			Kind:          "Deployment",
+			Namespace:     "default",
+			Name:          deployment.Name,
+			Container:     "app",
+			ContainerType: ContainerTypeContainer,
+			PreviousImage: "xcnt/test:0.9.9",
+			Image:         "xcnt/test:1.0.0",
+		},
+		{
+			Kind:          "Deployment",
+			Namespace:     "default",
+			Name:          deployment.Name,
+			Container:     "worker",
+			ContainerType: ContainerTypeContainer,
+			PreviousImage: "xcnt/test:0.9.9",
+			Image:         "xcnt/test:0.9.9",
+			Excluded:      true,
+		},
+		{
+			Kind:          "Job",
+			Namespace:     "default",
+			Name:          plan.GetToCreateJobs()[0].Name,
+			Container:     "migrate",
+			ContainerType: ContainerTypeContainer,
+			PreviousImage: "xcnt/test:0.9.9",
+			Image:         "xcnt/test:1.0.0",
+		},
+	})
+}
+
+func (suite *ContainerFilterSuite) TestMatchesDeploymentOnlyExcludedContainers(c *C) {
+	deployment := suite.deploymentWith(map[string]string{
+		UpdateClassifier:            "stable",
+		ExcludeContainersAnnotation: "app,worker",
+	})
+	c.Assert(MatchesDeployment(suite.config, deployment), Equals, false)
+}
+
+func (suite *ContainerFilterSuite) TestGetImagesOfIgnoresEphemeralContainers(c *C) {
+	deployment := GetDeploymentWith(map[string]string{UpdateClassifier: "stable"}, "xcnt/test:1.0.0")
+	deployment.Spec.Template.Spec.EphemeralContainers = []apiv1.EphemeralContainer{
+		{EphemeralContainerCommon: apiv1.EphemeralContainerCommon{Name: "debug", Image: "busybox:1.36"}},
+	}
+	c.Assert(GetImagesOf(deployment.Spec.Template.Spec), DeepEquals, []string{"xcnt/test:1.0.0"})
+}
+
+func (suite *ContainerFilterSuite) TestMatchesDeploymentIgnoresEphemeralContainer(c *C) {
+	deployment := GetDeploymentWith(map[string]string{UpdateClassifier: "stable"}, "xcnt/other:1.0.0")
+	deployment.Spec.Template.Spec.EphemeralContainers = []apiv1.EphemeralContainer{
+		{EphemeralContainerCommon: apiv1.EphemeralContainerCommon{Name: "debug", Image: "xcnt/test:0.9.9"}},
+	}
+	c.Assert(MatchesDeployment(suite.config, deployment), Equals, false)
+}
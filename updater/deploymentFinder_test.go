@@ -87,3 +87,17 @@ func (suite *DeploymentFinderSuite) TestDeploymentListFor(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(len(deployments), Equals, 0)
 }
+
+func (suite *DeploymentFinderSuite) TestDeploymentListWithLabelSelector(c *C) {
+	deployment := GetDeploymentWith(map[string]string{UpdateClassifier: suite.updateClassifier}, suite.imageName)
+	deployment.Labels = map[string]string{"team": "payments"}
+	deployment2 := GetDeploymentWith(map[string]string{UpdateClassifier: suite.updateClassifier}, suite.imageName)
+	deployment2.Labels = map[string]string{"team": "search"}
+	suite.kubernetesAPI.NewDeploymentIn("default", deployment)
+	suite.kubernetesAPI.NewDeploymentIn("default", deployment2)
+	suite.config.SetLabelSelector("team=payments")
+	deployments, err := suite.deploymentFinder.List()
+	c.Assert(err, IsNil)
+	c.Assert(len(deployments), Equals, 1)
+	c.Assert(deployments[0].Name, Equals, deployment.Name)
+}
@@ -4,6 +4,13 @@ import (
 	"strings"
 )
 
+const (
+	// DefaultRegistry is the registry images are pulled from if their name doesn't include one.
+	DefaultRegistry = "docker.io"
+	// DefaultTag is the tag which is used if an image name doesn't specify a tag or digest.
+	DefaultTag = "latest"
+)
+
 // NewImage returns the image configuration for the specified name
 func NewImage(name string) *Image {
 	name = strings.TrimSpace(name)
@@ -62,6 +69,65 @@ func (image *Image) EqualsImage(name string) bool {
 	return image.GetImage() == other.GetImage()
 }
 
+// WithDigest returns the image pinned to the passed digest. The tag is kept for readability, the digest takes
+// precedence when the image is pulled.
+func (image *Image) WithDigest(digest string) *Image {
+	name, _ := image.splitDigest()
+	return NewImage(name + "@" + digest)
+}
+
 func (image *Image) getSplitConfig() []string {
-	return strings.SplitN(image.GetName(), ":", 2)
+	name, _ := image.splitDigest()
+	return strings.SplitN(name, ":", 2)
+}
+
+// GetRegistry returns the registry host the image is pulled from. Images without an explicit registry
+// are resolved against the docker hub.
+func (image *Image) GetRegistry() string {
+	registry, _ := image.splitRegistry()
+	return registry
+}
+
+// GetRepository returns the repository path of the image inside of its registry without any tag or digest.
+func (image *Image) GetRepository() string {
+	_, repository := image.splitRegistry()
+	return repository
+}
+
+// GetReference returns the digest of the image if it has been pinned to one, the tag if one has been given
+// and "latest" otherwise.
+func (image *Image) GetReference() string {
+	name, digest := image.splitDigest()
+	if len(digest) > 0 {
+		return digest
+	}
+	lastSlash := strings.LastIndex(name, "/")
+	if colon := strings.LastIndex(name, ":"); colon > lastSlash {
+		return name[colon+1:]
+	}
+	return DefaultTag
+}
+
+func (image *Image) splitDigest() (string, string) {
+	splits := strings.SplitN(image.GetName(), "@", 2)
+	if len(splits) == 1 {
+		return splits[0], ""
+	}
+	return splits[0], splits[1]
+}
+
+func (image *Image) splitRegistry() (string, string) {
+	name, _ := image.splitDigest()
+	lastSlash := strings.LastIndex(name, "/")
+	if colon := strings.LastIndex(name, ":"); colon > lastSlash {
+		name = name[:colon]
+	}
+	splits := strings.SplitN(name, "/", 2)
+	if len(splits) == 2 && (strings.ContainsAny(splits[0], ".:") || splits[0] == "localhost") {
+		return splits[0], splits[1]
+	}
+	if len(splits) == 1 {
+		return DefaultRegistry, "library/" + name
+	}
+	return DefaultRegistry, name
 }
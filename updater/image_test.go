@@ -70,3 +70,49 @@ func (s *ImageSuite) TestEqualsImageOtherImageSameTag(c *C) {
 	name := strings.Join([]string{"eu.gcr.io/xcnt-infrastructure/jenkins2", s.image.GetTag()}, ":")
 	c.Assert(s.image.EqualsImage(name), Equals, false)
 }
+
+func (s *ImageSuite) TestGetRegistry(c *C) {
+	c.Assert(s.image.GetRegistry(), Equals, "eu.gcr.io")
+}
+
+func (s *ImageSuite) TestGetRegistryDockerHub(c *C) {
+	c.Assert(NewImage("xcnt/test:1.0.0").GetRegistry(), Equals, DefaultRegistry)
+}
+
+func (s *ImageSuite) TestGetRegistryWithPort(c *C) {
+	image := NewImage("localhost:5000/xcnt/test:1.0.0")
+	c.Assert(image.GetRegistry(), Equals, "localhost:5000")
+	c.Assert(image.GetRepository(), Equals, "xcnt/test")
+}
+
+func (s *ImageSuite) TestGetRepository(c *C) {
+	c.Assert(s.image.GetRepository(), Equals, "xcnt-infrastructure/jenkins")
+}
+
+func (s *ImageSuite) TestGetRepositoryOfficialImage(c *C) {
+	c.Assert(NewImage("nginx:1.21").GetRepository(), Equals, "library/nginx")
+}
+
+func (s *ImageSuite) TestGetReference(c *C) {
+	c.Assert(s.image.GetReference(), Equals, "2018-02-18-v1")
+}
+
+func (s *ImageSuite) TestGetReferenceWithoutTag(c *C) {
+	c.Assert(NewImage("localhost:5000/xcnt/test").GetReference(), Equals, DefaultTag)
+}
+
+func (s *ImageSuite) TestGetReferenceWithDigest(c *C) {
+	image := NewImage("xcnt/test@sha256:abcdef")
+	c.Assert(image.GetReference(), Equals, "sha256:abcdef")
+	c.Assert(image.GetRepository(), Equals, "xcnt/test")
+}
+
+func (s *ImageSuite) TestWithDigest(c *C) {
+	image := NewImage("xcnt/test:1.0.0").WithDigest("sha256:abcdef")
+	c.Assert(image.String(), Equals, "xcnt/test:1.0.0@sha256:abcdef")
+	c.Assert(image.GetImage(), Equals, "xcnt/test")
+	c.Assert(image.GetTag(), Equals, "1.0.0")
+	c.Assert(image.GetReference(), Equals, "sha256:abcdef")
+	c.Assert(image.EqualsImage("xcnt/test:0.9.0"), Equals, true)
+	c.Assert(image.WithDigest("sha256:012345").String(), Equals, "xcnt/test:1.0.0@sha256:012345")
+}
@@ -7,8 +7,18 @@ import (
 	batchv1 "k8s.io/api/batch/v1"
 	appsV1 "k8s.io/client-go/kubernetes/typed/apps/v1"
 	batchV1Interface "k8s.io/client-go/kubernetes/typed/batch/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 )
 
+// PlanConflict is implemented by the errors which reject an update because it conflicts with the workloads it
+// would change, for example an invalid annotation or a violated version guard, rather than because of an internal
+// problem.
+type PlanConflict interface {
+	error
+	// PlanConflict marks the error as a conflict.
+	PlanConflict()
+}
+
 // MatchConfig interface includes functions needed to be provided to
 // match the cofigurations
 type MatchConfig interface {
@@ -26,6 +36,18 @@ type UpdatePlan interface {
 	// GetToApplyDeployments returns a slice of deployments which are the deployment configurations needed to be applied to the cluster for the update
 	// to run through
 	GetToApplyDeployments() []v1.Deployment
+	// GetContainerChanges returns the containers of all workloads in the plan which run the updated image.
+	GetContainerChanges() []ContainerChange
+	// GetWaves returns the groups of deployments in the order they are updated in
+	GetWaves() []Wave
+	// GetCanary returns the canary configuration the deployments are updated with or nil if they are updated directly
+	GetCanary() *Canary
+	// GetAnalysis returns the metric analysis of the deployments once they are ready or nil if they are not analyzed
+	GetAnalysis() *Analysis
+	// GetRollbackJobs returns the jobs, running the previous image, which are created when the update is rolled back
+	GetRollbackJobs() []batchv1.Job
+	// GetRollbackPolicy returns the rollback policy of the deployments without a rollback policy annotation
+	GetRollbackPolicy() RollbackPolicy
 }
 
 // KubernetesWrapper includes functionality which needs to be implemented for returning the job interface.
@@ -36,6 +58,10 @@ type KubernetesWrapper interface {
 	GetDeploymentAPIFor(namespace string) appsV1.DeploymentInterface
 	// GetReplicaSetAPIFor returns the clientset's specified replicaset api for the configuration
 	GetReplicaSetAPIFor(namespace string) appsV1.ReplicaSetInterface
+	// GetPodAPIFor returns the clientset's specified pod api for the configuration
+	GetPodAPIFor(namespace string) corev1.PodInterface
+	// GetServiceAPIFor returns the clientset's specified service api for the configuration
+	GetServiceAPIFor(namespace string) corev1.ServiceInterface
 }
 
 // UpdateProgress interface can be used to query status of current upgrade processes.
@@ -58,4 +84,9 @@ type UpdateProgress interface {
 	Successful() bool
 	// Abort cancels the run of this specific udpater.
 	Abort()
+	// GetRollbackPlan returns the deployments applied by the update with the pod templates they ran before and the jobs
+	// run when the update is rolled back
+	GetRollbackPlan() *RollbackPlan
+	// GetRollbackJobs returns the jobs which have been created while rolling back the update
+	GetRollbackJobs() []*batchv1.Job
 }
@@ -61,3 +61,17 @@ func (suite *JobFinderSuite) TestJobConfigurationWithMultipleNamespaces(c *C) {
 	c.Assert(len(jobList), Equals, 1)
 	c.Assert(jobList[0].Name, Equals, job1.Name)
 }
+
+func (suite *JobFinderSuite) TestJobConfigurationWithLabelSelector(c *C) {
+	job1 := GetJobWith(map[string]string{UpdateClassifier: suite.updateClassifier}, suite.imageName)
+	job1.Labels = map[string]string{"team": "payments"}
+	job2 := GetJobWith(map[string]string{UpdateClassifier: suite.updateClassifier}, suite.imageName)
+	suite.kubernetesAPI.NewJobIn("default", job1)
+	suite.kubernetesAPI.NewJobIn("default", job2)
+	suite.config.SetLabelSelector("team in (payments)")
+
+	jobList, err := suite.jobFinder.List()
+	c.Assert(err, IsNil)
+	c.Assert(len(jobList), Equals, 1)
+	c.Assert(jobList[0].Name, Equals, job1.Name)
+}
@@ -32,6 +32,19 @@ func (k KubernetesAPI) NewNamespace(namespace string) error {
 	return err
 }
 
+// NewNamespaceWithLabels creates a new namespace with the passed labels
+func (k KubernetesAPI) NewNamespaceWithLabels(namespace string, labels map[string]string) error {
+	ns := &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   namespace,
+			Labels: labels,
+		},
+	}
+
+	_, err := k.Client.CoreV1().Namespaces().Create(context.TODO(), ns, metav1.CreateOptions{})
+	return err
+}
+
 // NewDeploymentIn creates the specified deployment configuration
 func (k KubernetesAPI) NewDeploymentIn(namespace string, deployment appsv1.Deployment) error {
 	_, err := k.Client.AppsV1().Deployments(namespace).Create(context.TODO(), &deployment, metav1.CreateOptions{})
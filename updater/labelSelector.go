@@ -0,0 +1,33 @@
+package updater
+
+import (
+	"strings"
+
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ValidateLabelSelector returns an error if the passed string is not a valid kubernetes label selector.
+func ValidateLabelSelector(labelSelector string) error {
+	_, err := labels.Parse(labelSelector)
+	return err
+}
+
+// CombineLabelSelectors joins the passed label selectors to one selector which requires all of them to match.
+// Empty selectors are ignored.
+func CombineLabelSelectors(labelSelectors ...string) string {
+	requirements := make([]string, 0, len(labelSelectors))
+	for _, labelSelector := range labelSelectors {
+		labelSelector = strings.TrimSpace(labelSelector)
+		if len(labelSelector) > 0 {
+			requirements = append(requirements, labelSelector)
+		}
+	}
+	return strings.Join(requirements, ",")
+}
+
+// listOptionsFor returns the options used to list the update candidates of the configuration. The label selector is
+// evaluated by the kubernetes API server.
+func listOptionsFor(config *Config) metaV1.ListOptions {
+	return metaV1.ListOptions{LabelSelector: config.GetLabelSelector()}
+}
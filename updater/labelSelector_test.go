@@ -0,0 +1,23 @@
+package updater
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type LabelSelectorSuite struct{}
+
+var _ = Suite(&LabelSelectorSuite{})
+
+func (suite *LabelSelectorSuite) TestCombineLabelSelectors(c *C) {
+	c.Assert(CombineLabelSelectors("team=payments", "", " tier in (web) "), Equals, "team=payments,tier in (web)")
+}
+
+func (suite *LabelSelectorSuite) TestCombineLabelSelectorsEmpty(c *C) {
+	c.Assert(CombineLabelSelectors("", " "), Equals, "")
+}
+
+func (suite *LabelSelectorSuite) TestValidateLabelSelector(c *C) {
+	c.Assert(ValidateLabelSelector("team=payments,!legacy"), IsNil)
+	c.Assert(ValidateLabelSelector(""), IsNil)
+	c.Assert(ValidateLabelSelector("team in payments"), NotNil)
+}
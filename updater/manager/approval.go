@@ -0,0 +1,123 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// State describes the phase of an update.
+type State string
+
+const (
+	// StatePendingApproval is the state of an update which waits for the approval of other principals.
+	StatePendingApproval State = "pending_approval"
+	// StateScheduled is the state of an update which waits for its start time.
+	StateScheduled State = "scheduled"
+	// StateQueued is the state of an update which waits for conflicting updates to finish.
+	StateQueued State = "queued"
+	// StateRejected is the state of an update which has been rejected instead of being approved.
+	StateRejected State = "rejected"
+	// StateExpired is the state of an update which has not been approved in time.
+	StateExpired State = "expired"
+	// StateAborted is the state of an update which has been aborted before it started.
+	StateAborted State = "aborted"
+	// StateCancelled is the state of an update which has been cancelled before it started.
+	StateCancelled State = "cancelled"
+	// StateRunning is the state of an update which is rolled out.
+	StateRunning State = "running"
+	// StateSucceeded is the state of an update which has been rolled out successfully.
+	StateSucceeded State = "succeeded"
+	// StateFailed is the state of an update which could not be rolled out.
+	StateFailed State = "failed"
+
+	// stateStarted marks a hold which has been released. The state of the update is then the one of its progress.
+	stateStarted State = "started"
+)
+
+var (
+	// ErrNotPendingApproval is returned if an update is approved or rejected which does not wait for approval.
+	ErrNotPendingApproval = errors.New("The update is not waiting for approval")
+	// ErrAlreadyApproved is returned if a principal approves an update a second time.
+	ErrAlreadyApproved = errors.New("The update has already been approved by the principal")
+	// ErrNotCancellable is returned if an update is cancelled which does not wait to be started.
+	ErrNotCancellable = errors.New("The update is not waiting to be started")
+)
+
+// Approval describes the approvals of an update which has been held for approval.
+type Approval struct {
+	// RequiredApprovers is the number of approvals the update needs before it is started.
+	RequiredApprovers int
+	// Approvers are the names of the principals which approved the update.
+	Approvers []string
+	// Rejecter is the name of the principal which rejected the update.
+	Rejecter string
+	// Reason describes why the update has been rejected.
+	Reason string
+	// ExpiryTime is the time the update expires at if it has not been approved.
+	ExpiryTime time.Time
+}
+
+// approve records the approval of the principal and dispatches the update if it has been approved often enough.
+func (updaterProgress *UpdateProgressImpl) approve(approver string) error {
+	released, err := updaterProgress.recordApproval(approver)
+	if released {
+		updaterProgress.hold.dispatch()
+	}
+	return err
+}
+
+// recordApproval records the approval of the principal and returns if the update has been released by it.
+func (updaterProgress *UpdateProgressImpl) recordApproval(approver string) (bool, error) {
+	updaterProgress.mutex.Lock()
+	defer updaterProgress.mutex.Unlock()
+	hold, err := updaterProgress.pendingHoldFor(approver, "approve")
+	if err != nil {
+		return false, err
+	}
+	for _, existingApprover := range hold.approval.Approvers {
+		if existingApprover == approver {
+			return false, ErrAlreadyApproved
+		}
+	}
+	hold.approval.Approvers = append(hold.approval.Approvers, approver)
+	if len(hold.approval.Approvers) >= hold.approval.RequiredApprovers {
+		return updaterProgress.release(), nil
+	}
+	return false, nil
+}
+
+// reject stops the update from being started.
+func (updaterProgress *UpdateProgressImpl) reject(rejecter string, reason string) error {
+	updaterProgress.mutex.Lock()
+	defer updaterProgress.mutex.Unlock()
+	hold, err := updaterProgress.pendingHoldFor(rejecter, "reject")
+	if err != nil {
+		return err
+	}
+	hold.state = StateRejected
+	hold.approval.Rejecter = rejecter
+	hold.approval.Reason = reason
+	hold.held.finish()
+	return nil
+}
+
+// pendingHoldFor returns the hold of the update if it is waiting for approval and the principal may approve or reject
+// it. The lock of the update progress must be held.
+func (updaterProgress *UpdateProgressImpl) pendingHoldFor(principal string, action string) (*updateHold, error) {
+	updaterProgress.expireIfDue()
+	hold := updaterProgress.hold
+	if hold == nil || hold.approval == nil {
+		return nil, ErrNotPendingApproval
+	}
+	if hold.state != StatePendingApproval || hold.released {
+		return nil, fmt.Errorf("%w, it is %s", ErrNotPendingApproval, updaterProgress.stateLocked())
+	}
+	if principal == updaterProgress.requester {
+		return nil, Reject("%s requested the update and may not %s it", principal, action)
+	}
+	if !hold.rule.Allows(principal) {
+		return nil, Reject("%s is not allowed to %s updates with update classifier %s", principal, action, updaterProgress.updateClassifier)
+	}
+	return hold, nil
+}
@@ -0,0 +1,188 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"kubernetes-update-manager/policy"
+	"kubernetes-update-manager/updater"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+type ApprovalSuite struct {
+	controller   *gomock.Controller
+	manager      *Manager
+	rule         *policy.ApprovalRule
+	updateCalled int
+}
+
+var _ = Suite(&ApprovalSuite{})
+
+func (suite *ApprovalSuite) SetUpTest(c *C) {
+	suite.controller = gomock.NewController(c)
+	suite.updateCalled = 0
+	suite.rule = &policy.ApprovalRule{RequiredApprovers: 2, Approvers: []string{"release-*"}}
+	suite.manager = NewManager(testclient.NewSimpleClientset())
+	suite.manager.ApprovalPolicy = &policy.ApprovalPolicy{Classifiers: map[string]policy.ApprovalRule{"production": *suite.rule}}
+	suite.manager.Plan = func(config *updater.Config) (updater.UpdatePlan, error) {
+		updatePlan := NewMockUpdatePlan(suite.controller)
+		updatePlan.EXPECT().GetToApplyDeployments().Return([]v1.Deployment{{ObjectMeta: metaV1.ObjectMeta{Name: "api"}}}).AnyTimes()
+		updatePlan.EXPECT().GetToCreateJobs().Return([]batchv1.Job{}).AnyTimes()
+		return updatePlan, nil
+	}
+	suite.manager.Update = func(updatePlan updater.UpdatePlan, wrapper updater.KubernetesWrapper) updater.UpdateProgress {
+		suite.updateCalled++
+		progress := NewMockUpdateProgress(suite.controller)
+		progress.EXPECT().Successful().Return(false).AnyTimes()
+		progress.EXPECT().Failed().Return(false).AnyTimes()
+		progress.EXPECT().Finished().Return(false).AnyTimes()
+		return progress
+	}
+}
+
+func (suite *ApprovalSuite) TearDownTest(c *C) {
+	suite.controller.Finish()
+}
+
+func (suite *ApprovalSuite) create(c *C, updateClassifier string) UpdateProgress {
+	config := updater.NewConfig(testclient.NewSimpleClientset(), updater.NewImage("xcnt/test:1.0.0"), updateClassifier)
+	config.SetRequester("ci")
+	updateProgress, err := suite.manager.Create(config)
+	c.Assert(err, IsNil)
+	return updateProgress
+}
+
+func (suite *ApprovalSuite) TestUnprotectedClassifierStarts(c *C) {
+	updateProgress := suite.create(c, "stable")
+	c.Assert(updateProgress.State(), Equals, StateRunning)
+	c.Assert(updateProgress.Approval(), IsNil)
+	c.Assert(suite.updateCalled, Equals, 1)
+}
+
+func (suite *ApprovalSuite) TestProtectedClassifierIsHeld(c *C) {
+	updateProgress := suite.create(c, "production")
+	c.Assert(updateProgress.State(), Equals, StatePendingApproval)
+	c.Assert(suite.updateCalled, Equals, 0)
+	c.Assert(updateProgress.Finished(), Equals, false)
+	c.Assert(len(updateProgress.GetDeployments()), Equals, 1)
+	c.Assert(updateProgress.Approval().RequiredApprovers, Equals, 2)
+}
+
+func (suite *ApprovalSuite) TestApprovalStartsUpdate(c *C) {
+	updateProgress := suite.create(c, "production")
+	_, err := suite.manager.Approve(updateProgress.UUID(), "release-alice")
+	c.Assert(err, IsNil)
+	c.Assert(updateProgress.State(), Equals, StatePendingApproval)
+	_, err = suite.manager.Approve(updateProgress.UUID(), "release-bob")
+	c.Assert(err, IsNil)
+	c.Assert(updateProgress.State(), Equals, StateRunning)
+	c.Assert(suite.updateCalled, Equals, 1)
+	c.Assert(updateProgress.Approval().Approvers, DeepEquals, []string{"release-alice", "release-bob"})
+}
+
+func (suite *ApprovalSuite) TestApprovedUpdateAppliesCurrentDeployments(c *C) {
+	replicas := int32(2)
+	clientset := testclient.NewSimpleClientset(&v1.Deployment{
+		ObjectMeta: metaV1.ObjectMeta{Namespace: "default", Name: "api"},
+		Spec:       v1.DeploymentSpec{Replicas: &replicas},
+	})
+	suite.manager.Plan = func(config *updater.Config) (updater.UpdatePlan, error) {
+		deployment, err := clientset.AppsV1().Deployments("default").Get(context.TODO(), "api", metaV1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		updatePlan := NewMockUpdatePlan(suite.controller)
+		updatePlan.EXPECT().GetToApplyDeployments().Return([]v1.Deployment{*deployment}).AnyTimes()
+		updatePlan.EXPECT().GetToCreateJobs().Return([]batchv1.Job{}).AnyTimes()
+		return updatePlan, nil
+	}
+	var applied []v1.Deployment
+	update := suite.manager.Update
+	suite.manager.Update = func(updatePlan updater.UpdatePlan, wrapper updater.KubernetesWrapper) updater.UpdateProgress {
+		applied = updatePlan.GetToApplyDeployments()
+		return update(updatePlan, wrapper)
+	}
+	suite.manager.ApprovalPolicy.Classifiers["production"] = policy.ApprovalRule{RequiredApprovers: 1}
+	updateProgress := suite.create(c, "production")
+
+	deployment, err := clientset.AppsV1().Deployments("default").Get(context.TODO(), "api", metaV1.GetOptions{})
+	c.Assert(err, IsNil)
+	replicas = 5
+	deployment.Spec.Replicas = &replicas
+	_, err = clientset.AppsV1().Deployments("default").Update(context.TODO(), deployment, metaV1.UpdateOptions{})
+	c.Assert(err, IsNil)
+
+	_, err = suite.manager.Approve(updateProgress.UUID(), "release-alice")
+	c.Assert(err, IsNil)
+	c.Assert(updateProgress.State(), Equals, StateRunning)
+	c.Assert(applied, HasLen, 1)
+	c.Assert(*applied[0].Spec.Replicas, Equals, int32(5))
+}
+
+func (suite *ApprovalSuite) TestRequesterMayNotApprove(c *C) {
+	suite.manager.ApprovalPolicy.Classifiers["production"] = policy.ApprovalRule{RequiredApprovers: 1}
+	updateProgress := suite.create(c, "production")
+	_, err := suite.manager.Approve(updateProgress.UUID(), "ci")
+	var rejection *RejectionError
+	c.Assert(errors.As(err, &rejection), Equals, true)
+	c.Assert(err, ErrorMatches, "ci requested the update and may not approve it")
+}
+
+func (suite *ApprovalSuite) TestApproverNotAllowed(c *C) {
+	updateProgress := suite.create(c, "production")
+	_, err := suite.manager.Approve(updateProgress.UUID(), "developer")
+	c.Assert(err, ErrorMatches, "developer is not allowed to approve updates with update classifier production")
+}
+
+func (suite *ApprovalSuite) TestApproveTwice(c *C) {
+	updateProgress := suite.create(c, "production")
+	_, err := suite.manager.Approve(updateProgress.UUID(), "release-alice")
+	c.Assert(err, IsNil)
+	_, err = suite.manager.Approve(updateProgress.UUID(), "release-alice")
+	c.Assert(err, Equals, ErrAlreadyApproved)
+}
+
+func (suite *ApprovalSuite) TestReject(c *C) {
+	updateProgress := suite.create(c, "production")
+	_, err := suite.manager.Reject(updateProgress.UUID(), "release-alice", "Not during the sale")
+	c.Assert(err, IsNil)
+	c.Assert(updateProgress.State(), Equals, StateRejected)
+	c.Assert(updateProgress.Finished(), Equals, true)
+	c.Assert(updateProgress.Failed(), Equals, true)
+	c.Assert(updateProgress.Approval().Reason, Equals, "Not during the sale")
+	_, err = suite.manager.Approve(updateProgress.UUID(), "release-bob")
+	c.Assert(errors.Is(err, ErrNotPendingApproval), Equals, true)
+	c.Assert(suite.updateCalled, Equals, 0)
+}
+
+func (suite *ApprovalSuite) TestExpiry(c *C) {
+	suite.manager.ApprovalPolicy.Classifiers["production"] = policy.ApprovalRule{
+		RequiredApprovers: 1,
+		Expiry:            &metaV1.Duration{Duration: time.Millisecond},
+	}
+	updateProgress := suite.create(c, "production")
+	time.Sleep(5 * time.Millisecond)
+	c.Assert(updateProgress.State(), Equals, StateExpired)
+	c.Assert(updateProgress.Finished(), Equals, true)
+	_, err := suite.manager.Approve(updateProgress.UUID(), "release-alice")
+	c.Assert(err, ErrorMatches, "The update is not waiting for approval, it is expired")
+}
+
+func (suite *ApprovalSuite) TestApproveRunningUpdate(c *C) {
+	updateProgress := suite.create(c, "stable")
+	_, err := suite.manager.Approve(updateProgress.UUID(), "release-alice")
+	c.Assert(err, Equals, ErrNotPendingApproval)
+}
+
+func (suite *ApprovalSuite) TestAbortHeldUpdate(c *C) {
+	updateProgress := suite.create(c, "production")
+	updateProgress.Abort()
+	c.Assert(updateProgress.State(), Equals, StateAborted)
+	c.Assert(updateProgress.Finished(), Equals, true)
+}
@@ -0,0 +1,315 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"kubernetes-update-manager/policy"
+	"kubernetes-update-manager/updater"
+	"time"
+
+	"github.com/google/uuid"
+	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+)
+
+// DeferralError is returned by a plan verifier if the update must not be started before the start time, for example
+// because of a freeze window. The manager then schedules the update instead of rejecting it.
+type DeferralError struct {
+	// StartTime is the earliest time the update may be started at.
+	StartTime time.Time
+	// Reason describes why the update has been deferred.
+	Reason string
+}
+
+// Error returns the reason of the deferral.
+func (deferral *DeferralError) Error() string {
+	return fmt.Sprintf("%s, the update may start at %s", deferral.Reason, deferral.StartTime.Format(time.RFC3339))
+}
+
+// Defer returns a deferral error with the start time and the formatted reason.
+func Defer(startTime time.Time, format string, args ...interface{}) error {
+	return &DeferralError{StartTime: startTime, Reason: fmt.Sprintf(format, args...)}
+}
+
+// Schedule describes when an update held by the manager will be started.
+type Schedule struct {
+	// StartTime is the time the update will be started at.
+	StartTime time.Time
+	// Reason describes why the start has been deferred.
+	Reason string
+	// Error describes why the update could not be started at the start time.
+	Error string
+}
+
+// updateHold keeps an update from being started until it has been approved, its start time has been reached and the
+// updates it conflicts with have finished.
+type updateHold struct {
+	state    State
+	approval *Approval
+	rule     *policy.ApprovalRule
+	schedule *Schedule
+	queue    *Queue
+	timer    *time.Timer
+	failure  string
+	held     *heldProgress
+	released bool
+	dispatch func()
+}
+
+// holdUpdate replaces the progress of the update with a held progress of the plan. If an approval rule is passed,
+// the update waits for the approvals first. The dispatch function is called once the update has been approved and the
+// start time of the schedule, if one is passed, has been reached. If neither is awaited, the update is released right
+// away, which is reported by returning true, and the caller has to dispatch it.
+func (updaterProgress *UpdateProgressImpl) holdUpdate(updatePlan updater.UpdatePlan, rule *policy.ApprovalRule, schedule *Schedule, dispatch func()) bool {
+	updaterProgress.mutex.Lock()
+	defer updaterProgress.mutex.Unlock()
+	held := newHeldProgress(updatePlan)
+	updaterProgress.progress = held
+	updaterProgress.hold = &updateHold{
+		state:    StateQueued,
+		rule:     rule,
+		schedule: schedule,
+		held:     held,
+		dispatch: dispatch,
+	}
+	if rule == nil {
+		return updaterProgress.release()
+	}
+	updaterProgress.hold.state = StatePendingApproval
+	updaterProgress.hold.approval = &Approval{
+		RequiredApprovers: rule.RequiredApprovers,
+		Approvers:         make([]string, 0),
+		ExpiryTime:        time.Now().Add(rule.ExpiryDuration()),
+	}
+	return false
+}
+
+// release marks the held update as ready to be dispatched and returns true or, if its start time has not been reached
+// yet, arms a timer dispatching it then. The lock of the update progress must be held.
+func (updaterProgress *UpdateProgressImpl) release() bool {
+	hold := updaterProgress.hold
+	if hold.schedule != nil && time.Now().Before(hold.schedule.StartTime) {
+		hold.state = StateScheduled
+		hold.timer = time.AfterFunc(time.Until(hold.schedule.StartTime), updaterProgress.startScheduled)
+		return false
+	}
+	hold.released = true
+	return true
+}
+
+// startScheduled dispatches the update once the start time of its schedule has been reached, unless it has been
+// aborted.
+func (updaterProgress *UpdateProgressImpl) startScheduled() {
+	updaterProgress.mutex.Lock()
+	hold := updaterProgress.hold
+	ready := hold.state == StateScheduled
+	hold.released = ready
+	updaterProgress.mutex.Unlock()
+	if ready {
+		hold.dispatch()
+	}
+}
+
+// startReleased replaces the held progress with the progress of the update returned by the start function, unless the
+// update has been stopped since it has been released.
+func (updaterProgress *UpdateProgressImpl) startReleased(start func() updater.UpdateProgress) {
+	updaterProgress.mutex.Lock()
+	defer updaterProgress.mutex.Unlock()
+	hold := updaterProgress.hold
+	if !hold.waiting() {
+		return
+	}
+	progress := start()
+	hold.state = stateStarted
+	if hold.queue != nil {
+		hold.queue.Position = 0
+		hold.queue.UpdateUUIDs = nil
+	}
+	updaterProgress.progress = progress
+}
+
+// enqueue marks the released update as waiting for the updates with the passed uuids, unless it has been stopped
+// since it has been released.
+func (updaterProgress *UpdateProgressImpl) enqueue(updateUUIDs []uuid.UUID) {
+	updaterProgress.mutex.Lock()
+	defer updaterProgress.mutex.Unlock()
+	hold := updaterProgress.hold
+	if !hold.waiting() {
+		return
+	}
+	hold.state = StateQueued
+	hold.queue = &Queue{Position: len(updateUUIDs), UpdateUUIDs: updateUUIDs}
+}
+
+// postpone schedules the released update again if the error is a deferral by a verifier and marks it as failed
+// otherwise, unless it has been stopped since it has been released.
+func (updaterProgress *UpdateProgressImpl) postpone(err error) {
+	updaterProgress.mutex.Lock()
+	defer updaterProgress.mutex.Unlock()
+	hold := updaterProgress.hold
+	if !hold.waiting() {
+		return
+	}
+	var deferral *DeferralError
+	if !errors.As(err, &deferral) || !deferral.StartTime.After(time.Now()) {
+		updaterProgress.failHold(err)
+		return
+	}
+	hold.schedule = &Schedule{StartTime: deferral.StartTime, Reason: deferral.Reason}
+	hold.released = false
+	if hold.queue != nil {
+		hold.queue.Position = 0
+		hold.queue.UpdateUUIDs = nil
+	}
+	updaterProgress.release()
+}
+
+// failReleased marks the released update as failed because it conflicts with other updates.
+func (updaterProgress *UpdateProgressImpl) failReleased(conflict *ConflictError) {
+	updaterProgress.mutex.Lock()
+	defer updaterProgress.mutex.Unlock()
+	hold := updaterProgress.hold
+	if !hold.waiting() {
+		return
+	}
+	hold.queue = &Queue{UpdateUUIDs: conflict.UpdateUUIDs}
+	updaterProgress.failHold(conflict)
+}
+
+// failHold marks the held update as failed with the error. The lock of the update progress must be held.
+func (updaterProgress *UpdateProgressImpl) failHold(err error) {
+	hold := updaterProgress.hold
+	hold.state = StateFailed
+	hold.failure = err.Error()
+	hold.held.finish()
+}
+
+// stopHold prevents a held update from being started and moves it to the passed state. It returns false if the update
+// is not waiting for approval, its start time or conflicting updates anymore. The lock of the update progress must be
+// held.
+func (updaterProgress *UpdateProgressImpl) stopHold(state State) bool {
+	updaterProgress.expireIfDue()
+	hold := updaterProgress.hold
+	if hold == nil || !hold.waiting() {
+		return false
+	}
+	if hold.timer != nil {
+		hold.timer.Stop()
+	}
+	hold.state = state
+	hold.held.finish()
+	return true
+}
+
+// cancel prevents an update waiting for approval, its start time or conflicting updates from being started. Only the
+// requester and the principals allowed to approve the update may cancel it.
+func (updaterProgress *UpdateProgressImpl) cancel(canceller string) error {
+	updaterProgress.mutex.Lock()
+	defer updaterProgress.mutex.Unlock()
+	hold := updaterProgress.hold
+	if hold != nil && hold.waiting() && canceller != updaterProgress.requester && (hold.rule == nil || !hold.rule.Allows(canceller)) {
+		return Reject("%s did not request the update and is not allowed to approve it, so it may not cancel it", canceller)
+	}
+	if !updaterProgress.stopHold(StateCancelled) {
+		return fmt.Errorf("%w, it is %s", ErrNotCancellable, updaterProgress.stateLocked())
+	}
+	return nil
+}
+
+// expireIfDue marks an update waiting for approval as expired once its expiry time passed. The lock of the update
+// progress must be held.
+func (updaterProgress *UpdateProgressImpl) expireIfDue() {
+	hold := updaterProgress.hold
+	if hold != nil && hold.state == StatePendingApproval && !hold.released && time.Now().After(hold.approval.ExpiryTime) {
+		hold.state = StateExpired
+		hold.held.finish()
+	}
+}
+
+// waiting returns if the held update has not been started or stopped yet.
+func (hold *updateHold) waiting() bool {
+	return hold.state == StatePendingApproval || hold.state == StateScheduled || hold.state == StateQueued
+}
+
+func newHeldProgress(updatePlan updater.UpdatePlan) *heldProgress {
+	held := &heldProgress{}
+	for _, job := range updatePlan.GetToCreateJobs() {
+		job := job
+		held.jobs = append(held.jobs, &job)
+	}
+	for _, deployment := range updatePlan.GetToApplyDeployments() {
+		deployment := deployment
+		held.deployments = append(held.deployments, &deployment)
+	}
+	return held
+}
+
+// heldProgress stands in for the progress of an update which has not been started. It finishes unsuccessfully if
+// the update is rejected, expires, is aborted or can not be started.
+type heldProgress struct {
+	jobs        []*batchv1.Job
+	deployments []*v1.Deployment
+	finishTime  *time.Time
+}
+
+func (held *heldProgress) finish() {
+	if held.finishTime == nil {
+		finishTime := time.Now()
+		held.finishTime = &finishTime
+	}
+}
+
+// GetJobs returns the jobs which will be created once the update is started.
+func (held *heldProgress) GetJobs() []*batchv1.Job {
+	return held.jobs
+}
+
+// GetDeployments returns the deployments which will be updated once the update is started.
+func (held *heldProgress) GetDeployments() []*v1.Deployment {
+	return held.deployments
+}
+
+// FinishedJobsCount returns 0 as no job has been started.
+func (held *heldProgress) FinishedJobsCount() int {
+	return 0
+}
+
+// UpdatedDeploymentsCount returns 0 as no deployment has been updated.
+func (held *heldProgress) UpdatedDeploymentsCount() int {
+	return 0
+}
+
+// FinishTime returns when the update has been rejected, expired or aborted.
+func (held *heldProgress) FinishTime() *time.Time {
+	return held.finishTime
+}
+
+// Finished returns if the update will not be started anymore.
+func (held *heldProgress) Finished() bool {
+	return held.finishTime != nil
+}
+
+// Failed returns if the update will not be started anymore.
+func (held *heldProgress) Failed() bool {
+	return held.Finished()
+}
+
+// Successful returns false as the update has not been rolled out.
+func (held *heldProgress) Successful() bool {
+	return false
+}
+
+// Abort prevents the update from being started.
+func (held *heldProgress) Abort() {
+	held.finish()
+}
+
+// GetRollbackPlan returns nil as no deployment has been updated.
+func (held *heldProgress) GetRollbackPlan() *updater.RollbackPlan {
+	return nil
+}
+
+// GetRollbackJobs returns nil as the update has not been rolled back.
+func (held *heldProgress) GetRollbackJobs() []*batchv1.Job {
+	return nil
+}
@@ -0,0 +1,249 @@
+package manager
+
+import (
+	"errors"
+	"kubernetes-update-manager/policy"
+	"kubernetes-update-manager/updater"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+type HoldSuite struct {
+	controller   *gomock.Controller
+	manager      *Manager
+	planErr      error
+	planCalled   int
+	updateCalled int
+}
+
+var _ = Suite(&HoldSuite{})
+
+func (suite *HoldSuite) SetUpTest(c *C) {
+	suite.controller = gomock.NewController(c)
+	suite.planErr = nil
+	suite.planCalled = 0
+	suite.updateCalled = 0
+	suite.manager = NewManager(testclient.NewSimpleClientset())
+	suite.manager.ApprovalPolicy = &policy.ApprovalPolicy{Classifiers: map[string]policy.ApprovalRule{
+		"production": {RequiredApprovers: 1},
+	}}
+	suite.manager.Plan = func(config *updater.Config) (updater.UpdatePlan, error) {
+		suite.planCalled++
+		if suite.planCalled > 1 && suite.planErr != nil {
+			return nil, suite.planErr
+		}
+		updatePlan := NewMockUpdatePlan(suite.controller)
+		updatePlan.EXPECT().GetToApplyDeployments().Return([]v1.Deployment{}).AnyTimes()
+		updatePlan.EXPECT().GetToCreateJobs().Return([]batchv1.Job{}).AnyTimes()
+		return updatePlan, nil
+	}
+	suite.manager.Update = func(updatePlan updater.UpdatePlan, wrapper updater.KubernetesWrapper) updater.UpdateProgress {
+		suite.updateCalled++
+		progress := NewMockUpdateProgress(suite.controller)
+		progress.EXPECT().Successful().Return(false).AnyTimes()
+		progress.EXPECT().Failed().Return(false).AnyTimes()
+		progress.EXPECT().Finished().Return(false).AnyTimes()
+		return progress
+	}
+}
+
+func (suite *HoldSuite) TearDownTest(c *C) {
+	suite.controller.Finish()
+}
+
+func (suite *HoldSuite) create(c *C, updateClassifier string, verifiers ...PlanVerifier) UpdateProgress {
+	config := updater.NewConfig(testclient.NewSimpleClientset(), updater.NewImage("xcnt/test:1.0.0"), updateClassifier)
+	config.SetRequester("ci")
+	updateProgress, err := suite.manager.Create(config, verifiers...)
+	c.Assert(err, IsNil)
+	return updateProgress
+}
+
+// deferUntil returns a verifier deferring updates until the start time, like a freeze window queueing updates.
+func deferUntil(startTime time.Time) PlanVerifier {
+	return func(config *updater.Config, updatePlan updater.UpdatePlan) error {
+		if time.Now().Before(startTime) {
+			return Defer(startTime, "Updates are frozen")
+		}
+		return nil
+	}
+}
+
+// waitForState polls the state of the update until it left the passed state or a second passed.
+func waitForState(updateProgress UpdateProgress, state State) State {
+	deadline := time.Now().Add(time.Second)
+	for updateProgress.State() == state && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	return updateProgress.State()
+}
+
+func (suite *HoldSuite) TestDeferredUpdateStartsAtStartTime(c *C) {
+	startTime := time.Now().Add(20 * time.Millisecond)
+	updateProgress := suite.create(c, "stable", deferUntil(startTime))
+	c.Assert(updateProgress.State(), Equals, StateScheduled)
+	c.Assert(updateProgress.Finished(), Equals, false)
+	c.Assert(updateProgress.Schedule().StartTime, Equals, startTime)
+	c.Assert(updateProgress.Schedule().Reason, Equals, "Updates are frozen")
+	c.Assert(suite.updateCalled, Equals, 0)
+
+	c.Assert(waitForState(updateProgress, StateScheduled), Equals, StateRunning)
+	c.Assert(suite.updateCalled, Equals, 1)
+	c.Assert(suite.planCalled, Equals, 2)
+}
+
+func (suite *HoldSuite) TestLatestDeferralWins(c *C) {
+	startTime := time.Now().Add(time.Hour)
+	updateProgress := suite.create(c, "stable", deferUntil(startTime), deferUntil(time.Now().Add(time.Minute)))
+	c.Assert(updateProgress.Schedule().StartTime, Equals, startTime)
+	updateProgress.Abort()
+}
+
+func (suite *HoldSuite) TestRejectionWinsOverDeferral(c *C) {
+	config := updater.NewConfig(testclient.NewSimpleClientset(), updater.NewImage("xcnt/test:1.0.0"), "stable")
+	_, err := suite.manager.Create(config, deferUntil(time.Now().Add(time.Hour)), func(config *updater.Config, updatePlan updater.UpdatePlan) error {
+		return Reject("Not allowed")
+	})
+	var rejection *RejectionError
+	c.Assert(errors.As(err, &rejection), Equals, true)
+}
+
+func (suite *HoldSuite) TestPreviewIgnoresDeferral(c *C) {
+	config := updater.NewConfig(testclient.NewSimpleClientset(), updater.NewImage("xcnt/test:1.0.0"), "stable")
+	updatePlan, err := suite.manager.Preview(config, deferUntil(time.Now().Add(time.Hour)))
+	c.Assert(err, IsNil)
+	c.Assert(updatePlan, NotNil)
+}
+
+func (suite *HoldSuite) TestAbortScheduledUpdate(c *C) {
+	updateProgress := suite.create(c, "stable", deferUntil(time.Now().Add(10*time.Millisecond)))
+	updateProgress.Abort()
+	c.Assert(updateProgress.State(), Equals, StateAborted)
+	c.Assert(updateProgress.Finished(), Equals, true)
+	time.Sleep(30 * time.Millisecond)
+	c.Assert(updateProgress.State(), Equals, StateAborted)
+	c.Assert(suite.updateCalled, Equals, 0)
+}
+
+func (suite *HoldSuite) TestApprovedUpdateWaitsForStartTime(c *C) {
+	updateProgress := suite.create(c, "production", deferUntil(time.Now().Add(time.Hour)))
+	c.Assert(updateProgress.State(), Equals, StatePendingApproval)
+	_, err := suite.manager.Approve(updateProgress.UUID(), "release-alice")
+	c.Assert(err, IsNil)
+	c.Assert(updateProgress.State(), Equals, StateScheduled)
+	c.Assert(suite.updateCalled, Equals, 0)
+	updateProgress.Abort()
+	c.Assert(updateProgress.State(), Equals, StateAborted)
+}
+
+func (suite *HoldSuite) TestNotBefore(c *C) {
+	notBefore := time.Now().Add(time.Hour)
+	config := updater.NewConfig(testclient.NewSimpleClientset(), updater.NewImage("xcnt/test:1.0.0"), "stable")
+	config.SetNotBefore(notBefore)
+	updateProgress, err := suite.manager.Create(config, deferUntil(time.Now().Add(time.Minute)))
+	c.Assert(err, IsNil)
+	c.Assert(updateProgress.State(), Equals, StateScheduled)
+	c.Assert(updateProgress.Schedule().StartTime, Equals, notBefore)
+	c.Assert(updateProgress.Schedule().Reason, Equals, "Scheduled by the requester")
+	updateProgress.Abort()
+}
+
+func (suite *HoldSuite) TestNotBeforeInThePastStartsImmediately(c *C) {
+	config := updater.NewConfig(testclient.NewSimpleClientset(), updater.NewImage("xcnt/test:1.0.0"), "stable")
+	config.SetNotBefore(time.Now().Add(-time.Hour))
+	updateProgress, err := suite.manager.Create(config)
+	c.Assert(err, IsNil)
+	c.Assert(updateProgress.State(), Equals, StateRunning)
+	c.Assert(updateProgress.Schedule(), IsNil)
+}
+
+func (suite *HoldSuite) TestCancel(c *C) {
+	updateProgress := suite.create(c, "stable", deferUntil(time.Now().Add(10*time.Millisecond)))
+	_, err := suite.manager.Cancel(updateProgress.UUID(), "ci")
+	c.Assert(err, IsNil)
+	c.Assert(updateProgress.State(), Equals, StateCancelled)
+	c.Assert(updateProgress.Finished(), Equals, true)
+	time.Sleep(30 * time.Millisecond)
+	c.Assert(suite.updateCalled, Equals, 0)
+
+	_, err = suite.manager.Cancel(updateProgress.UUID(), "ci")
+	c.Assert(err, ErrorMatches, "The update is not waiting to be started, it is cancelled")
+}
+
+func (suite *HoldSuite) TestCancelPendingApproval(c *C) {
+	updateProgress := suite.create(c, "production")
+	_, err := suite.manager.Cancel(updateProgress.UUID(), "ci")
+	c.Assert(err, IsNil)
+	c.Assert(updateProgress.State(), Equals, StateCancelled)
+}
+
+func (suite *HoldSuite) TestCancelByOtherPrincipal(c *C) {
+	suite.manager.ApprovalPolicy.Classifiers["production"] = policy.ApprovalRule{RequiredApprovers: 1, Approvers: []string{"release-*"}}
+	updateProgress := suite.create(c, "production")
+	_, err := suite.manager.Cancel(updateProgress.UUID(), "developer")
+	var rejection *RejectionError
+	c.Assert(errors.As(err, &rejection), Equals, true)
+	c.Assert(updateProgress.State(), Equals, StatePendingApproval)
+	_, err = suite.manager.Cancel(updateProgress.UUID(), "release-alice")
+	c.Assert(err, IsNil)
+	c.Assert(updateProgress.State(), Equals, StateCancelled)
+
+	scheduled := suite.create(c, "stable", deferUntil(time.Now().Add(time.Hour)))
+	_, err = suite.manager.Cancel(scheduled.UUID(), "release-alice")
+	c.Assert(errors.As(err, &rejection), Equals, true)
+	c.Assert(scheduled.State(), Equals, StateScheduled)
+}
+
+func (suite *HoldSuite) TestCancelRunningUpdate(c *C) {
+	updateProgress := suite.create(c, "stable")
+	_, err := suite.manager.Cancel(updateProgress.UUID(), "ci")
+	c.Assert(errors.Is(err, ErrNotCancellable), Equals, true)
+	c.Assert(updateProgress.State(), Equals, StateRunning)
+}
+
+func (suite *HoldSuite) TestFailedStart(c *C) {
+	suite.planErr = errors.New("The cluster is not reachable")
+	updateProgress := suite.create(c, "stable", deferUntil(time.Now().Add(10*time.Millisecond)))
+	c.Assert(waitForState(updateProgress, StateScheduled), Equals, StateFailed)
+	c.Assert(updateProgress.Finished(), Equals, true)
+	c.Assert(updateProgress.Failed(), Equals, true)
+	c.Assert(updateProgress.Schedule().Error, Equals, "The cluster is not reachable")
+	c.Assert(suite.updateCalled, Equals, 0)
+}
+
+func (suite *HoldSuite) TestApprovedUpdateIsVerifiedAgain(c *C) {
+	frozen := false
+	updateProgress := suite.create(c, "production", func(config *updater.Config, updatePlan updater.UpdatePlan) error {
+		if frozen {
+			return Reject("Updates are frozen")
+		}
+		return nil
+	})
+	frozen = true
+	_, err := suite.manager.Approve(updateProgress.UUID(), "release-alice")
+	c.Assert(err, IsNil)
+	c.Assert(updateProgress.State(), Equals, StateFailed)
+	c.Assert(updateProgress.Finished(), Equals, true)
+	c.Assert(suite.updateCalled, Equals, 0)
+}
+
+func (suite *HoldSuite) TestApprovedUpdateIsDeferredAgain(c *C) {
+	var startTime time.Time
+	updateProgress := suite.create(c, "production", func(config *updater.Config, updatePlan updater.UpdatePlan) error {
+		return deferUntil(startTime)(config, updatePlan)
+	})
+	startTime = time.Now().Add(20 * time.Millisecond)
+	_, err := suite.manager.Approve(updateProgress.UUID(), "release-alice")
+	c.Assert(err, IsNil)
+	c.Assert(updateProgress.State(), Equals, StateScheduled)
+	c.Assert(updateProgress.Schedule().StartTime, Equals, startTime)
+	c.Assert(suite.updateCalled, Equals, 0)
+
+	c.Assert(waitForState(updateProgress, StateScheduled), Equals, StateRunning)
+	c.Assert(suite.updateCalled, Equals, 1)
+}
@@ -10,5 +10,29 @@ import (
 type UpdateProgress interface {
 	// UUID returns the unique identifier for the specified update progress
 	UUID() uuid.UUID
+	// Requester returns the name of the principal which requested the update
+	Requester() string
+	// Image returns the image the update rolls out
+	Image() string
+	// UpdateClassifier returns the update classifier the update has been requested for
+	UpdateClassifier() string
+	// State returns the phase of the update
+	State() State
+	// Approval returns the approvals of the update or nil, if the update did not need to be approved
+	Approval() *Approval
+	// Schedule returns when the update will be started or nil, if its start has not been deferred
+	Schedule() *Schedule
+	// Queue returns the updates the update waits for or nil, if it never conflicted with another update
+	Queue() *Queue
+	// Promotion returns the update the update is promoted to once it succeeded or nil, if it is not promoted
+	Promotion() *Promotion
+	// PromotedFrom returns the uuid of the update promoted to the update or the nil uuid, if it has been requested directly
+	PromotedFrom() uuid.UUID
+	// RollbackOf returns the uuid of the update rolled back by the update or the nil uuid, if it is not a rollback
+	RollbackOf() uuid.UUID
 	updater.UpdateProgress
 }
+
+// PlanVerifier checks an update plan before it is scheduled. If the update must not be run, an error,
+// usually a RejectionError, is returned.
+type PlanVerifier func(config *updater.Config, updatePlan updater.UpdatePlan) error
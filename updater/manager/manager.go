@@ -1,39 +1,85 @@
 package manager
 
 import (
+	"errors"
+	"kubernetes-update-manager/policy"
 	"kubernetes-update-manager/updater"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"k8s.io/client-go/kubernetes"
 )
 
+// ErrImageDigestChanged is returned if the verifiers pin the image of an update to another digest once the update has
+// been planned with the digest they pinned before.
+var ErrImageDigestChanged = errors.New("The verified image digest changed while the update was verified")
+
 // NewManager returns a manager initialized with the provided configuration.
 func NewManager(clientset kubernetes.Interface) *Manager {
 	return &Manager{
-		Update:        updater.Update,
-		Plan:          updater.Plan,
-		clientset:     clientset,
-		updates:       map[uuid.UUID]UpdateProgress{},
-		thresholdTime: 10 * time.Minute,
+		Update:            updater.Update,
+		Plan:              updater.Plan,
+		Restore:           updater.Rollback,
+		ConflictMode:      ConflictModeQueue,
+		clientset:         clientset,
+		RollbackRetention: DefaultRollbackRetention,
+		updates:           map[uuid.UUID]UpdateProgress{},
+		retained:          map[uuid.UUID]UpdateProgress{},
+		thresholdTime:     10 * time.Minute,
+		queueInterval:     defaultQueueInterval,
 	}
 }
 
 // Manager is the main entry point for providing status updates for updates as well as storing them for retrieval.
 type Manager struct {
-	Update        func(updater.UpdatePlan, updater.KubernetesWrapper) updater.UpdateProgress
-	Plan          func(*updater.Config) (updater.UpdatePlan, error)
-	clientset     kubernetes.Interface
-	updates       map[uuid.UUID]UpdateProgress
-	thresholdTime time.Duration
+	Update         func(updater.UpdatePlan, updater.KubernetesWrapper) updater.UpdateProgress
+	Plan           func(*updater.Config) (updater.UpdatePlan, error)
+	Verifiers      []PlanVerifier
+	ApprovalPolicy *policy.ApprovalPolicy
+	// ConflictMode specifies if updates touching the update classifier or deployments of a queued or running update are
+	// queued or rejected.
+	ConflictMode ConflictMode
+	// PromotionPolicy promotes the images of successful updates to further update classifiers. If nil, updates are not
+	// promoted.
+	PromotionPolicy *policy.PromotionPolicy
+	// Promote creates the update promoting a successful update. It defaults to Create and may be replaced to apply the
+	// verifiers and settings of the update classifier the update is promoted to.
+	Promote func(config *updater.Config) (UpdateProgress, error)
+	// Restore starts the rollback of the deployments touched by an update. It defaults to updater.Rollback.
+	Restore func(*updater.RollbackPlan, updater.KubernetesWrapper) updater.UpdateProgress
+	// RollbackRetention is the time successful updates can be rolled back for after they finished, even if they have
+	// been cleaned up already. It defaults to DefaultRollbackRetention.
+	RollbackRetention time.Duration
+	clientset         kubernetes.Interface
+	updatesMutex      sync.RWMutex
+	updates           map[uuid.UUID]UpdateProgress
+	retained          map[uuid.UUID]UpdateProgress
+	thresholdTime     time.Duration
+	queueMutex        sync.Mutex
+	claims            []*claim
+	queueInterval     time.Duration
+	draining          bool
 }
 
-// Cleanup removes updates which are finished and passed a specific time threshold after completion
+// Cleanup removes updates which are finished and passed a specific time threshold after completion. Successful updates
+// are retained for rollbacks until the rollback retention passed.
 func (manager *Manager) Cleanup() {
+	manager.updatesMutex.Lock()
+	defer manager.updatesMutex.Unlock()
+	now := time.Now()
 	for updateProgressKey, updateProgress := range manager.updates {
-		if updateProgress.Finished() && updateProgress.FinishTime().Add(manager.thresholdTime).Before(time.Now()) {
+		if updateProgress.Finished() && updateProgress.FinishTime().Add(manager.thresholdTime).Before(now) {
 			delete(manager.updates, updateProgressKey)
+			if updateProgress.RollbackOf() == uuid.Nil && updateProgress.Successful() && updateProgress.FinishTime().Add(manager.RollbackRetention).After(now) {
+				manager.retained[updateProgressKey] = updateProgress
+			}
+		}
+	}
+	for updateProgressKey, updateProgress := range manager.retained {
+		if !updateProgress.FinishTime().Add(manager.RollbackRetention).After(now) {
+			delete(manager.retained, updateProgressKey)
 		}
 	}
 }
@@ -51,6 +97,8 @@ func (manager *Manager) GetByString(uuidString string) (UpdateProgress, error) {
 
 // Get returns the status of the process with the provided uuid. Returns os.ErrNotExist if no update could be found with the provied uuid.
 func (manager *Manager) Get(toGetUUID uuid.UUID) (UpdateProgress, error) {
+	manager.updatesMutex.RLock()
+	defer manager.updatesMutex.RUnlock()
 	update, ok := manager.updates[toGetUUID]
 	if !ok {
 		return nil, os.ErrNotExist
@@ -58,20 +106,217 @@ func (manager *Manager) Get(toGetUUID uuid.UUID) (UpdateProgress, error) {
 	return update, nil
 }
 
-// Schedule takes the specified update plan, starts it and stores the result in the manager.
+// Schedule takes the specified update plan, starts it and stores the result in the manager. The update is started
+// right away, even if it conflicts with updates created by the manager.
 func (manager *Manager) Schedule(updatePlan updater.UpdatePlan, config *updater.Config) (UpdateProgress, error) {
-	updateProgress := WrapUpdateProgress(manager.Update(updatePlan, config))
+	updateProgress := manager.register(manager.Update(updatePlan, config), config)
+	return updateProgress, nil
+}
+
+// register wraps the progress with the identity of the update in the configuration and stores it in the manager.
+func (manager *Manager) register(progress updater.UpdateProgress, config *updater.Config) *UpdateProgressImpl {
+	updateProgress := wrapUpdate(progress, config)
+	manager.store(updateProgress)
+	return updateProgress
+}
+
+// wrapUpdate wraps the progress with the identity of the update in the configuration.
+func wrapUpdate(progress updater.UpdateProgress, config *updater.Config) *UpdateProgressImpl {
+	updateProgress := WrapUpdateProgress(progress)
+	if updateUUID, err := uuid.Parse(config.GetUpdateUUID()); err == nil {
+		updateProgress.uuid = updateUUID
+	}
+	updateProgress.requester = config.GetRequester()
+	updateProgress.image = config.GetImage().String()
+	updateProgress.updateClassifier = config.GetUpdateClassifier()
+	return updateProgress
+}
+
+// store makes the update retrievable from the manager. The progress of the update must be set, as it is accessed
+// concurrently as soon as it is stored.
+func (manager *Manager) store(updateProgress *UpdateProgressImpl) {
+	manager.updatesMutex.Lock()
 	manager.updates[updateProgress.UUID()] = updateProgress
+	manager.updatesMutex.Unlock()
+}
+
+// Create creates and schedules an update plan adn returns the update progress. The plan is checked by the configured
+// verifiers of the manager and the additionally passed ones before it is scheduled. The uuid of the update is assigned
+// before planning, so it can be stamped on the updated deployments. Updates whose update classifier requires approval
+// or which have been deferred by a verifier or the not before time of the configuration are held instead of being
+// started. Updates which touch the update classifier or deployments of a queued or running update are queued until it
+// finished or, depending on the conflict mode, rejected with a ConflictError. Held updates are verified again once
+// they are released and planned again, as the deployments may have changed while they waited. They fail if a verifier
+// rejects them then and are scheduled again if a verifier defers them.
+func (manager *Manager) Create(config *updater.Config, verifiers ...PlanVerifier) (UpdateProgress, error) {
+	config.SetUpdateUUID(uuid.New().String())
+	updatePlan, err := manager.Plan(config)
+	if err != nil {
+		return nil, err
+	}
+	updatePlan, deferral, err := manager.verifyPlan(config, updatePlan, verifiers)
+	if err != nil {
+		return nil, err
+	}
+	var rule *policy.ApprovalRule
+	if manager.ApprovalPolicy != nil {
+		rule = manager.ApprovalPolicy.Rule(config.GetUpdateClassifier())
+	}
+	var schedule *Schedule
+	if deferral != nil {
+		schedule = &Schedule{StartTime: deferral.StartTime, Reason: deferral.Reason}
+	}
+	if notBefore := config.GetNotBefore(); notBefore.After(time.Now()) && (schedule == nil || notBefore.After(schedule.StartTime)) {
+		schedule = &Schedule{StartTime: notBefore, Reason: "Scheduled by the requester"}
+	}
+	prepare := func() (*preparedUpdate, error) {
+		currentPlan, err := manager.Plan(config)
+		if err != nil {
+			return nil, err
+		}
+		currentPlan, deferral, err := manager.verifyPlan(config, currentPlan, verifiers)
+		if err != nil {
+			return nil, err
+		}
+		if deferral != nil {
+			return nil, deferral
+		}
+		return manager.prepared(currentPlan, config), nil
+	}
+	// The update is only stored once it holds the progress of the plan, so it is never retrieved without one.
+	updateProgress := wrapUpdate(nil, config)
+	released := updateProgress.holdUpdate(updatePlan, rule, schedule, func() {
+		// Held updates are planned again, so the deployments are updated in their then current state, and verified
+		// again, as the verifiers may reject them by now.
+		_ = manager.launch(updateProgress, prepare)
+	})
+	manager.store(updateProgress)
+	if !released {
+		manager.watchPromotion(updateProgress, config)
+		return updateProgress, nil
+	}
+	err = manager.dispatch(updateProgress, prepare, manager.prepared(updatePlan, config))
+	if err != nil {
+		manager.Delete(updateProgress.UUID())
+		return nil, err
+	}
+	manager.watchPromotion(updateProgress, config)
+	return updateProgress, nil
+}
+
+// Approve records the approval of the principal for the update waiting for approval. The update is started once it
+// has been approved by the required number of principals. Returns os.ErrNotExist if the update does not exist,
+// ErrNotPendingApproval if it does not wait for approval and a RejectionError if the principal must not approve it.
+// An existing update is returned even if the approval failed, so it can be described to the principal.
+func (manager *Manager) Approve(updateUUID uuid.UUID, approver string) (UpdateProgress, error) {
+	updateProgress, err := manager.heldUpdate(updateUUID)
+	if err != nil {
+		return nil, err
+	}
+	return updateProgress, updateProgress.approve(approver)
+}
+
+// Reject prevents the update waiting for approval from being started. It returns the same errors as Approve.
+func (manager *Manager) Reject(updateUUID uuid.UUID, rejecter string, reason string) (UpdateProgress, error) {
+	updateProgress, err := manager.heldUpdate(updateUUID)
+	if err != nil {
+		return nil, err
+	}
+	return updateProgress, updateProgress.reject(rejecter, reason)
+}
+
+// Cancel prevents the update waiting for approval, its start time or conflicting updates from being started. Only the
+// requester of the update and the principals allowed to approve it may cancel it. Returns os.ErrNotExist if the
+// update does not exist, ErrNotCancellable if it does not wait anymore and a RejectionError if the principal must not
+// cancel it.
+func (manager *Manager) Cancel(updateUUID uuid.UUID, canceller string) (UpdateProgress, error) {
+	updateProgress, err := manager.heldUpdate(updateUUID)
+	if err != nil {
+		return nil, err
+	}
+	return updateProgress, updateProgress.cancel(canceller)
+}
+
+func (manager *Manager) heldUpdate(updateUUID uuid.UUID) (*UpdateProgressImpl, error) {
+	update, err := manager.Get(updateUUID)
+	if err != nil {
+		return nil, err
+	}
+	updateProgress, ok := update.(*UpdateProgressImpl)
+	if !ok {
+		return nil, ErrNotPendingApproval
+	}
 	return updateProgress, nil
 }
 
-// Create creates and schedules an update plan adn returns the update progress
-func (manager *Manager) Create(config *updater.Config) (UpdateProgress, error) {
-	updateProgress, err := manager.Plan(config)
+// Preview creates and verifies the update plan for the configuration the same way Create does without scheduling it.
+// A deferral of the update by a verifier is not reported as the plan itself is valid.
+func (manager *Manager) Preview(config *updater.Config, verifiers ...PlanVerifier) (updater.UpdatePlan, error) {
+	updatePlan, err := manager.Plan(config)
+	if err != nil {
+		return nil, err
+	}
+	updatePlan, _, err = manager.verifyPlan(config, updatePlan, verifiers)
 	if err != nil {
 		return nil, err
 	}
-	return manager.Schedule(updateProgress, config)
+	return updatePlan, nil
+}
+
+// prepared returns the verified update of the plan.
+func (manager *Manager) prepared(updatePlan updater.UpdatePlan, config *updater.Config) *preparedUpdate {
+	return &preparedUpdate{
+		resources: resourcesOf(config.GetUpdateClassifier(), updatePlan),
+		start:     func() updater.UpdateProgress { return manager.Update(updatePlan, config) },
+	}
+}
+
+// verifyPlan runs all verifiers against the plan and returns the plan to apply. Verifiers may pin the image of the
+// configuration to the digest they verified, the update is planned again with the pinned image then and the new plan
+// is verified again, so the verifiers have seen the plan which is applied. Returns ErrImageDigestChanged if the
+// verifiers pin another digest then.
+func (manager *Manager) verifyPlan(config *updater.Config, updatePlan updater.UpdatePlan, verifiers []PlanVerifier) (updater.UpdatePlan, *DeferralError, error) {
+	digest := config.GetImageDigest()
+	deferral, err := manager.verify(config, updatePlan, verifiers)
+	if err != nil {
+		return nil, nil, err
+	}
+	if config.GetImageDigest() == digest {
+		return updatePlan, deferral, nil
+	}
+	digest = config.GetImageDigest()
+	updatePlan, err = manager.Plan(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	deferral, err = manager.verify(config, updatePlan, verifiers)
+	if err != nil {
+		return nil, nil, err
+	}
+	if config.GetImageDigest() != digest {
+		return nil, nil, ErrImageDigestChanged
+	}
+	return updatePlan, deferral, nil
+}
+
+// verify runs all verifiers against the plan. The first error which is not a deferral is returned. Deferrals do not
+// stop the verification, the one with the latest start time is returned once all verifiers passed.
+func (manager *Manager) verify(config *updater.Config, updatePlan updater.UpdatePlan, verifiers []PlanVerifier) (*DeferralError, error) {
+	var latestDeferral *DeferralError
+	for _, verifier := range append(manager.Verifiers, verifiers...) {
+		err := verifier(config, updatePlan)
+		var deferral *DeferralError
+		if errors.As(err, &deferral) {
+			if latestDeferral == nil || deferral.StartTime.After(latestDeferral.StartTime) {
+				latestDeferral = deferral
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return latestDeferral, nil
 }
 
 // DeleteByString deletes the specific uuid string representation from the update manager. Does nothing
@@ -85,5 +330,7 @@ func (manager *Manager) DeleteByString(uuidStringToDelete string) {
 
 // Delete removes the specified uuid from the update manager if it is present
 func (manager *Manager) Delete(uuidToDelete uuid.UUID) {
+	manager.updatesMutex.Lock()
+	defer manager.updatesMutex.Unlock()
 	delete(manager.updates, uuidToDelete)
 }
@@ -10,6 +10,8 @@ import (
 	gomock "github.com/golang/mock/gomock"
 	"github.com/google/uuid"
 	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	testclient "k8s.io/client-go/kubernetes/fake"
 )
 
@@ -39,18 +41,26 @@ func (managerSuite *ManagerSuite) SetUpTest(c *C) {
 	managerSuite.config = updater.NewConfig(managerSuite.clientset, managerSuite.image, managerSuite.updateClassifier)
 	manager.Plan = func(config *updater.Config) (updater.UpdatePlan, error) {
 		managerSuite.planCalled = true
-		return NewMockUpdatePlan(managerSuite.controller), nil
+		return managerSuite.newPlan(), nil
 	}
 	manager.Update = func(updatePlan updater.UpdatePlan, wrapper updater.KubernetesWrapper) updater.UpdateProgress {
 		managerSuite.updateCalled = true
 		mockUpdateProgress := NewMockUpdateProgress(managerSuite.controller)
 		mockUpdateProgress.EXPECT().Finished().DoAndReturn(func() bool { return managerSuite.finishTime != nil }).MinTimes(0)
 		mockUpdateProgress.EXPECT().FinishTime().DoAndReturn(func() *time.Time { return managerSuite.finishTime }).MinTimes(0)
+		mockUpdateProgress.EXPECT().Successful().Return(true).MinTimes(0)
 
 		return mockUpdateProgress
 	}
 }
 
+func (managerSuite *ManagerSuite) newPlan() *MockUpdatePlan {
+	updatePlan := NewMockUpdatePlan(managerSuite.controller)
+	updatePlan.EXPECT().GetToApplyDeployments().Return([]v1.Deployment{}).AnyTimes()
+	updatePlan.EXPECT().GetToCreateJobs().Return([]batchv1.Job{}).AnyTimes()
+	return updatePlan
+}
+
 func (managerSuite *ManagerSuite) TearDownTest(c *C) {
 	managerSuite.controller.Finish()
 }
@@ -66,6 +76,22 @@ func (managerSuite *ManagerSuite) TestManagerCreate(c *C) {
 	c.Assert(managerSuite.updateCalled, IsTrue)
 }
 
+func (managerSuite *ManagerSuite) TestManagerCreatePlansWithUpdateUUID(c *C) {
+	manager := managerSuite.manager
+	var plannedUUID string
+	manager.Plan = func(config *updater.Config) (updater.UpdatePlan, error) {
+		plannedUUID = config.GetUpdateUUID()
+		return managerSuite.newPlan(), nil
+	}
+	config := updater.NewConfig(managerSuite.clientset, managerSuite.image, managerSuite.updateClassifier)
+	updateProgress, err := manager.Create(config)
+	c.Assert(err, IsNil)
+	c.Assert(updateProgress.UUID().String(), Equals, plannedUUID)
+	secondProgress, err := manager.Create(config)
+	c.Assert(err, IsNil)
+	c.Assert(secondProgress.UUID(), Not(Equals), updateProgress.UUID())
+}
+
 func (managerSuite *ManagerSuite) TestManagerCreateWithErrorInPlan(c *C) {
 	manager := managerSuite.manager
 	expectedErr := errors.New("test")
@@ -146,3 +172,104 @@ func (managerSuite *ManagerSuite) TestDeleteNotExisting(c *C) {
 	manager := managerSuite.manager
 	manager.Delete(uuid.New())
 }
+
+func (managerSuite *ManagerSuite) TestManagerCreateWithRejectingVerifier(c *C) {
+	manager := managerSuite.manager
+	manager.Verifiers = []PlanVerifier{
+		func(config *updater.Config, updatePlan updater.UpdatePlan) error {
+			return Reject("rejected %s", config.GetUpdateClassifier())
+		},
+	}
+	_, err := manager.Create(managerSuite.config)
+	c.Assert(err, NotNil)
+	c.Assert(err.Error(), Equals, "rejected stable")
+	c.Assert(managerSuite.planCalled, IsTrue)
+	c.Assert(managerSuite.updateCalled, IsFalse)
+}
+
+func (managerSuite *ManagerSuite) TestManagerCreateWithPassedVerifier(c *C) {
+	manager := managerSuite.manager
+	verifierCalled := false
+	_, err := manager.Create(managerSuite.config, func(config *updater.Config, updatePlan updater.UpdatePlan) error {
+		verifierCalled = true
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(verifierCalled, IsTrue)
+	c.Assert(managerSuite.updateCalled, IsTrue)
+}
+
+func (managerSuite *ManagerSuite) TestManagerPreview(c *C) {
+	manager := managerSuite.manager
+	verifierCalled := false
+	updatePlan, err := manager.Preview(managerSuite.config, func(config *updater.Config, updatePlan updater.UpdatePlan) error {
+		verifierCalled = true
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(updatePlan, NotNil)
+	c.Assert(verifierCalled, IsTrue)
+	c.Assert(managerSuite.planCalled, IsTrue)
+	c.Assert(managerSuite.updateCalled, IsFalse)
+}
+
+func (managerSuite *ManagerSuite) TestManagerCreateWithRequester(c *C) {
+	managerSuite.config.SetRequester("payments-ci")
+	updateProgress, err := managerSuite.manager.Create(managerSuite.config)
+	c.Assert(err, IsNil)
+	c.Assert(updateProgress.Requester(), Equals, "payments-ci")
+	c.Assert(updateProgress.Image(), Equals, managerSuite.config.GetImage().String())
+	c.Assert(updateProgress.UpdateClassifier(), Equals, managerSuite.config.GetUpdateClassifier())
+}
+
+func (managerSuite *ManagerSuite) TestManagerCreatePlansPinnedImageAgain(c *C) {
+	manager := managerSuite.manager
+	plannedDigests := make([]string, 0)
+	manager.Plan = func(config *updater.Config) (updater.UpdatePlan, error) {
+		plannedDigests = append(plannedDigests, config.GetImageDigest())
+		return managerSuite.newPlan(), nil
+	}
+	var startedPlan updater.UpdatePlan
+	update := manager.Update
+	manager.Update = func(updatePlan updater.UpdatePlan, wrapper updater.KubernetesWrapper) updater.UpdateProgress {
+		startedPlan = updatePlan
+		return update(updatePlan, wrapper)
+	}
+	verifiedPlans := make([]updater.UpdatePlan, 0)
+	_, err := manager.Create(managerSuite.config, func(config *updater.Config, updatePlan updater.UpdatePlan) error {
+		verifiedPlans = append(verifiedPlans, updatePlan)
+		config.SetImageDigest("sha256:abcdef")
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(plannedDigests, DeepEquals, []string{"", "sha256:abcdef"})
+	c.Assert(verifiedPlans, HasLen, 2)
+	c.Assert(startedPlan, NotNil)
+	c.Assert(startedPlan, Not(Equals), verifiedPlans[0])
+	c.Assert(startedPlan, Equals, verifiedPlans[1])
+}
+
+func (managerSuite *ManagerSuite) TestManagerCreateRejectsPinnedPlan(c *C) {
+	manager := managerSuite.manager
+	_, err := manager.Create(managerSuite.config, func(config *updater.Config, updatePlan updater.UpdatePlan) error {
+		if len(config.GetImageDigest()) > 0 {
+			return &RejectionError{Reason: "The pinned image is frozen"}
+		}
+		config.SetImageDigest("sha256:abcdef")
+		return nil
+	})
+	c.Assert(err, ErrorMatches, "The pinned image is frozen")
+	c.Assert(managerSuite.updateCalled, IsFalse)
+}
+
+func (managerSuite *ManagerSuite) TestManagerCreateRejectsChangingDigest(c *C) {
+	manager := managerSuite.manager
+	digests := []string{"sha256:abcdef", "sha256:123456"}
+	_, err := manager.Create(managerSuite.config, func(config *updater.Config, updatePlan updater.UpdatePlan) error {
+		config.SetImageDigest(digests[0])
+		digests = digests[1:]
+		return nil
+	})
+	c.Assert(err, Equals, ErrImageDigestChanged)
+	c.Assert(managerSuite.updateCalled, IsFalse)
+}
@@ -0,0 +1,81 @@
+package manager
+
+import (
+	"kubernetes-update-manager/policy"
+	"kubernetes-update-manager/updater"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// Promotion describes the update a successful update is promoted to.
+type Promotion struct {
+	// UpdateClassifier is the update classifier the image is promoted to.
+	UpdateClassifier string
+	// Delay is the time between the success of the update and the start of the promoted update.
+	Delay time.Duration
+	// UpdateUUID identifies the promoted update. It is the nil uuid until the update succeeded.
+	UpdateUUID uuid.UUID
+	// Error describes why the update has not been promoted.
+	Error string
+}
+
+// watchPromotion promotes the update once it succeeded, if the promotion policy of the manager promotes updates with
+// its update classifier.
+func (manager *Manager) watchPromotion(updateProgress *UpdateProgressImpl, config *updater.Config) {
+	if manager.PromotionPolicy == nil {
+		return
+	}
+	rule := manager.PromotionPolicy.Rule(config.GetUpdateClassifier())
+	if rule == nil {
+		return
+	}
+	updateProgress.mutex.Lock()
+	updateProgress.promotion = &Promotion{UpdateClassifier: rule.To, Delay: rule.Delay()}
+	updateProgress.mutex.Unlock()
+	go manager.promoteOnSuccess(updateProgress, config, rule)
+}
+
+// promoteOnSuccess waits for the update to finish and creates the update of the same image with the update classifier
+// of the rule, scheduled for the end of the delay of the rule. Failed updates are not promoted.
+func (manager *Manager) promoteOnSuccess(updateProgress *UpdateProgressImpl, config *updater.Config, rule *policy.PromotionRule) {
+	for !updateProgress.Finished() {
+		time.Sleep(manager.queueInterval)
+	}
+	if !updateProgress.Successful() {
+		updateProgress.promoted(uuid.Nil, "The update did not succeed")
+		return
+	}
+	promotedConfig := config.WithUpdateClassifier(rule.To)
+	promotedConfig.SetNotBefore(updateProgress.FinishTime().Add(rule.Delay()))
+	promote := manager.Promote
+	if promote == nil {
+		promote = func(config *updater.Config) (UpdateProgress, error) { return manager.Create(config) }
+	}
+	promotedProgress, err := promote(promotedConfig)
+	logger := log.WithFields(log.Fields{
+		"uuid":             updateProgress.UUID().String(),
+		"updateClassifier": rule.To,
+	})
+	if err != nil {
+		logger.WithError(err).Error("Could not promote the update")
+		updateProgress.promoted(uuid.Nil, err.Error())
+		return
+	}
+	if promotedImpl, ok := promotedProgress.(*UpdateProgressImpl); ok {
+		promotedImpl.mutex.Lock()
+		promotedImpl.promotedFrom = updateProgress.UUID()
+		promotedImpl.mutex.Unlock()
+	}
+	updateProgress.promoted(promotedProgress.UUID(), "")
+	logger.WithField("promotedUUID", promotedProgress.UUID().String()).Info("Update promoted")
+}
+
+// promoted records the promoted update or why the update has not been promoted.
+func (updaterProgress *UpdateProgressImpl) promoted(updateUUID uuid.UUID, failure string) {
+	updaterProgress.mutex.Lock()
+	defer updaterProgress.mutex.Unlock()
+	updaterProgress.promotion.UpdateUUID = updateUUID
+	updaterProgress.promotion.Error = failure
+}
@@ -0,0 +1,151 @@
+package manager
+
+import (
+	"errors"
+	"kubernetes-update-manager/policy"
+	"kubernetes-update-manager/updater"
+	"sync"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+type PromotionSuite struct {
+	controller *gomock.Controller
+	manager    *Manager
+	mutex      sync.Mutex
+	finished   bool
+	successful bool
+	finishTime time.Time
+}
+
+var _ = Suite(&PromotionSuite{})
+
+func (suite *PromotionSuite) SetUpTest(c *C) {
+	suite.controller = gomock.NewController(c)
+	suite.finished = false
+	suite.successful = false
+	suite.finishTime = time.Now()
+	suite.manager = NewManager(testclient.NewSimpleClientset())
+	suite.manager.queueInterval = 5 * time.Millisecond
+	suite.manager.PromotionPolicy = &policy.PromotionPolicy{Classifiers: map[string]policy.PromotionRule{
+		"staging": {To: "stable", After: &metaV1.Duration{Duration: 30 * time.Minute}},
+	}}
+	suite.manager.Plan = func(config *updater.Config) (updater.UpdatePlan, error) {
+		updatePlan := NewMockUpdatePlan(suite.controller)
+		deployment := v1.Deployment{ObjectMeta: metaV1.ObjectMeta{Namespace: "default", Name: config.GetUpdateClassifier()}}
+		updatePlan.EXPECT().GetToApplyDeployments().Return([]v1.Deployment{deployment}).AnyTimes()
+		updatePlan.EXPECT().GetToCreateJobs().Return([]batchv1.Job{}).AnyTimes()
+		return updatePlan, nil
+	}
+	suite.manager.Update = func(updatePlan updater.UpdatePlan, wrapper updater.KubernetesWrapper) updater.UpdateProgress {
+		progress := NewMockUpdateProgress(suite.controller)
+		progress.EXPECT().Finished().DoAndReturn(func() bool { return suite.state(false) }).AnyTimes()
+		progress.EXPECT().Successful().DoAndReturn(func() bool { return suite.state(true) }).AnyTimes()
+		progress.EXPECT().Failed().DoAndReturn(func() bool { return suite.state(false) && !suite.state(true) }).AnyTimes()
+		progress.EXPECT().FinishTime().DoAndReturn(func() *time.Time { return &suite.finishTime }).AnyTimes()
+		return progress
+	}
+}
+
+func (suite *PromotionSuite) TearDownTest(c *C) {
+	suite.finish(false)
+	time.Sleep(4 * suite.manager.queueInterval)
+	suite.controller.Finish()
+}
+
+// state returns if the update finished or, if successful is passed, if it succeeded.
+func (suite *PromotionSuite) state(successful bool) bool {
+	suite.mutex.Lock()
+	defer suite.mutex.Unlock()
+	if successful {
+		return suite.successful
+	}
+	return suite.finished
+}
+
+func (suite *PromotionSuite) finish(successful bool) {
+	suite.mutex.Lock()
+	defer suite.mutex.Unlock()
+	if !suite.finished {
+		suite.finished = true
+		suite.successful = successful
+	}
+}
+
+func (suite *PromotionSuite) create(c *C, updateClassifier string) UpdateProgress {
+	config := updater.NewConfig(testclient.NewSimpleClientset(), updater.NewImage("xcnt/test:1.0.0"), updateClassifier)
+	config.SetRequester("ci")
+	updateProgress, err := suite.manager.Create(config)
+	c.Assert(err, IsNil)
+	return updateProgress
+}
+
+func waitForPromotion(updateProgress UpdateProgress) *Promotion {
+	for i := 0; i < 40; i++ {
+		promotion := updateProgress.Promotion()
+		if promotion.UpdateUUID != uuid.Nil || len(promotion.Error) > 0 {
+			return promotion
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return updateProgress.Promotion()
+}
+
+func (suite *PromotionSuite) TestPromoteSuccessfulUpdate(c *C) {
+	staging := suite.create(c, "staging")
+	c.Assert(staging.Promotion(), DeepEquals, &Promotion{UpdateClassifier: "stable", Delay: 30 * time.Minute})
+	c.Assert(staging.PromotedFrom(), Equals, uuid.Nil)
+
+	suite.finish(true)
+	promotion := waitForPromotion(staging)
+	c.Assert(promotion.Error, Equals, "")
+	c.Assert(promotion.UpdateUUID, Not(Equals), uuid.Nil)
+	stable, err := suite.manager.Get(promotion.UpdateUUID)
+	c.Assert(err, IsNil)
+	c.Assert(stable.UpdateClassifier(), Equals, "stable")
+	c.Assert(stable.Image(), Equals, "xcnt/test:1.0.0")
+	c.Assert(stable.Requester(), Equals, "ci")
+	c.Assert(stable.PromotedFrom(), Equals, staging.UUID())
+	c.Assert(stable.Promotion(), IsNil)
+	c.Assert(stable.State(), Equals, StateScheduled)
+	c.Assert(stable.Schedule().StartTime.Equal(suite.finishTime.Add(30*time.Minute)), Equals, true)
+
+	_, err = suite.manager.Cancel(stable.UUID(), "ci")
+	c.Assert(err, IsNil)
+	c.Assert(stable.State(), Equals, StateCancelled)
+}
+
+func (suite *PromotionSuite) TestDoNotPromoteFailedUpdate(c *C) {
+	staging := suite.create(c, "staging")
+	suite.finish(false)
+	promotion := waitForPromotion(staging)
+	c.Assert(promotion.UpdateUUID, Equals, uuid.Nil)
+	c.Assert(promotion.Error, Equals, "The update did not succeed")
+	c.Assert(suite.manager.updates, HasLen, 1)
+}
+
+func (suite *PromotionSuite) TestDoNotPromoteOtherClassifiers(c *C) {
+	stable := suite.create(c, "stable")
+	c.Assert(stable.Promotion(), IsNil)
+}
+
+func (suite *PromotionSuite) TestPromoteWithHook(c *C) {
+	var promotedClassifier string
+	suite.manager.Promote = func(config *updater.Config) (UpdateProgress, error) {
+		promotedClassifier = config.GetUpdateClassifier()
+		return nil, errors.New("The freeze window is active")
+	}
+	staging := suite.create(c, "staging")
+	suite.finish(true)
+	promotion := waitForPromotion(staging)
+	c.Assert(promotedClassifier, Equals, "stable")
+	c.Assert(promotion.UpdateUUID, Equals, uuid.Nil)
+	c.Assert(promotion.Error, Equals, "The freeze window is active")
+}
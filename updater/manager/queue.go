@@ -0,0 +1,262 @@
+package manager
+
+import (
+	"fmt"
+	"kubernetes-update-manager/updater"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ConflictMode describes how updates are handled which touch resources of an update which is queued or running.
+type ConflictMode string
+
+const (
+	// ConflictModeQueue starts conflicting updates one after another in the order they have been released.
+	ConflictModeQueue ConflictMode = "queue"
+	// ConflictModeReject refuses updates which conflict with a queued or running update.
+	ConflictModeReject ConflictMode = "reject"
+
+	defaultQueueInterval = time.Second
+)
+
+// ConflictError is returned if an update conflicts with queued or running updates and the conflict mode of the
+// manager rejects conflicting updates.
+type ConflictError struct {
+	// UpdateUUIDs identify the updates the update conflicts with.
+	UpdateUUIDs []uuid.UUID
+}
+
+// Error returns the description of the conflict.
+func (conflict *ConflictError) Error() string {
+	return fmt.Sprintf("The update conflicts with the queued or running updates %s", joinUUIDs(conflict.UpdateUUIDs))
+}
+
+// PlanConflict marks conflicts with queued or running updates like conflicts with the workloads.
+func (conflict *ConflictError) PlanConflict() {}
+
+// Queue describes the updates a queued update waits for.
+type Queue struct {
+	// Position is the number of unfinished updates the update waits for. It is 0 once the update has been started.
+	Position int
+	// UpdateUUIDs identify the updates the update waits for.
+	UpdateUUIDs []uuid.UUID
+	// Error describes why the update could not be started once it left the queue.
+	Error string
+}
+
+// claim reserves the resources touched by an update which is queued or running. Blockers are the claims of the updates
+// a queued update waits for, they are nil once the update is launched. A launching update is planned and verified
+// again before it is started.
+type claim struct {
+	updateProgress *UpdateProgressImpl
+	resources      map[string]bool
+	prepare        preparer
+	blockers       []*claim
+	launching      bool
+}
+
+// preparer plans the update again and verifies it before it is started. A *DeferralError is returned if a verifier
+// deferred the update.
+type preparer func() (*preparedUpdate, error)
+
+// preparedUpdate is an update which has been verified and may be started.
+type preparedUpdate struct {
+	// resources are the keys of the resources the update touches.
+	resources map[string]bool
+	// start starts the update. It must not block, as it is called while the lock of the update progress is held.
+	start func() updater.UpdateProgress
+}
+
+// resourcesOf returns the keys of the resources an update of the plan touches. Updates conflict if they share the
+// update classifier or any deployment.
+func resourcesOf(updateClassifier string, updatePlan updater.UpdatePlan) map[string]bool {
+	resources := map[string]bool{"classifier/" + updateClassifier: true}
+	for _, deployment := range updatePlan.GetToApplyDeployments() {
+		resources["deployment/"+deployment.Namespace+"/"+deployment.Name] = true
+	}
+	return resources
+}
+
+// launch plans and verifies the released update again and dispatches it. An update deferred by a verifier is
+// scheduled again and an update which can not be prepared fails, in both cases the error is returned.
+func (manager *Manager) launch(updateProgress *UpdateProgressImpl, prepare preparer) error {
+	prepared, err := prepare()
+	if err != nil {
+		updateProgress.postpone(err)
+		return err
+	}
+	return manager.dispatch(updateProgress, prepare, prepared)
+}
+
+// dispatch starts the released and prepared update unless it conflicts with a queued or running update. A conflicting
+// update is queued until the updates it conflicts with have finished or, if the manager rejects conflicting updates,
+// fails with a ConflictError which is returned as well. Updates leaving the queue are planned and verified again.
+func (manager *Manager) dispatch(updateProgress *UpdateProgressImpl, prepare preparer, prepared *preparedUpdate) error {
+	manager.queueMutex.Lock()
+	blockers := manager.conflicting(prepared.resources, nil)
+	if len(blockers) > 0 && manager.ConflictMode == ConflictModeReject {
+		manager.queueMutex.Unlock()
+		err := &ConflictError{UpdateUUIDs: uuidsOf(blockers)}
+		updateProgress.failReleased(err)
+		return err
+	}
+	manager.claims = append(manager.claims, &claim{
+		updateProgress: updateProgress,
+		resources:      prepared.resources,
+		prepare:        prepare,
+		blockers:       blockers,
+	})
+	if len(blockers) > 0 {
+		updateProgress.enqueue(uuidsOf(blockers))
+		manager.drain()
+		manager.queueMutex.Unlock()
+		return nil
+	}
+	manager.queueMutex.Unlock()
+	updateProgress.startReleased(prepared.start)
+	return nil
+}
+
+// drain starts draining the queue unless it is already drained. The queue mutex must be held.
+func (manager *Manager) drain() {
+	if !manager.draining {
+		manager.draining = true
+		go manager.drainQueue()
+	}
+}
+
+// conflicting returns the claims of unfinished updates sharing a resource with the passed ones. If the resources are
+// the ones of a claim leaving the queue, only the claims queued before it and the ones of started updates are
+// considered. The claims of finished updates are released. The queue mutex must be held.
+func (manager *Manager) conflicting(resources map[string]bool, leaving *claim) []*claim {
+	var conflicting []*claim
+	active := make([]*claim, 0, len(manager.claims))
+	preceding := true
+	for _, existing := range manager.claims {
+		if existing.updateProgress.Finished() {
+			continue
+		}
+		active = append(active, existing)
+		if existing == leaving {
+			preceding = false
+			continue
+		}
+		if !preceding && (existing.blockers != nil || existing.launching) {
+			continue
+		}
+		for resource := range resources {
+			if existing.resources[resource] {
+				conflicting = append(conflicting, existing)
+				break
+			}
+		}
+	}
+	manager.claims = active
+	return conflicting
+}
+
+// unclaim releases the claim of the update. The queue mutex must be held.
+func (manager *Manager) unclaim(entry *claim) {
+	for index, existing := range manager.claims {
+		if existing == entry {
+			manager.claims = append(manager.claims[:index:index], manager.claims[index+1:]...)
+			return
+		}
+	}
+}
+
+// drainQueue periodically starts the queued updates whose conflicting updates have finished until the queue is empty.
+func (manager *Manager) drainQueue() {
+	for {
+		time.Sleep(manager.queueInterval)
+		if !manager.advanceQueue() {
+			return
+		}
+	}
+}
+
+// advanceQueue launches the queued updates, in the order they have been queued, which do not wait for unfinished
+// updates anymore. It returns if updates are still queued.
+func (manager *Manager) advanceQueue() bool {
+	manager.queueMutex.Lock()
+	queued := false
+	var leaving []*claim
+	for _, entry := range manager.claims {
+		if entry.blockers == nil {
+			continue
+		}
+		if entry.updateProgress.Finished() {
+			entry.blockers = nil
+			continue
+		}
+		entry.blockers = unfinished(entry.blockers)
+		if len(entry.blockers) > 0 {
+			entry.updateProgress.enqueue(uuidsOf(entry.blockers))
+			queued = true
+			continue
+		}
+		entry.blockers = nil
+		entry.launching = true
+		leaving = append(leaving, entry)
+	}
+	manager.draining = queued
+	manager.queueMutex.Unlock()
+	for _, entry := range leaving {
+		manager.relaunch(entry)
+	}
+	return queued
+}
+
+// relaunch plans and verifies the update of the claim leaving the queue again and starts it. The queue is not locked
+// meanwhile, the claim keeps the resources of the update reserved. If the update touches resources of other updates
+// now, it is queued again. Updates deferred by a verifier release their claim until they are scheduled again.
+func (manager *Manager) relaunch(entry *claim) {
+	prepared, err := entry.prepare()
+	manager.queueMutex.Lock()
+	entry.launching = false
+	if err != nil {
+		manager.unclaim(entry)
+		manager.queueMutex.Unlock()
+		entry.updateProgress.postpone(err)
+		return
+	}
+	entry.resources = prepared.resources
+	blockers := manager.conflicting(entry.resources, entry)
+	if len(blockers) > 0 {
+		entry.blockers = blockers
+		entry.updateProgress.enqueue(uuidsOf(blockers))
+		manager.drain()
+		manager.queueMutex.Unlock()
+		return
+	}
+	manager.queueMutex.Unlock()
+	entry.updateProgress.startReleased(prepared.start)
+}
+
+func unfinished(claims []*claim) []*claim {
+	var remaining []*claim
+	for _, existing := range claims {
+		if !existing.updateProgress.Finished() {
+			remaining = append(remaining, existing)
+		}
+	}
+	return remaining
+}
+
+func uuidsOf(claims []*claim) []uuid.UUID {
+	updateUUIDs := make([]uuid.UUID, 0, len(claims))
+	for _, existing := range claims {
+		updateUUIDs = append(updateUUIDs, existing.updateProgress.UUID())
+	}
+	return updateUUIDs
+}
+
+func joinUUIDs(updateUUIDs []uuid.UUID) string {
+	names := make([]string, 0, len(updateUUIDs))
+	for _, updateUUID := range updateUUIDs {
+		names = append(names, updateUUID.String())
+	}
+	return strings.Join(names, ", ")
+}
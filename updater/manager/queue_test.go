@@ -0,0 +1,232 @@
+package manager
+
+import (
+	"errors"
+	"kubernetes-update-manager/policy"
+	"kubernetes-update-manager/updater"
+	"os"
+	"sync"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+type QueueSuite struct {
+	controller   *gomock.Controller
+	manager      *Manager
+	mutex        sync.Mutex
+	deployments  map[string][]string
+	finished     []bool
+	planCalled   int
+	updateCalled int
+}
+
+var _ = Suite(&QueueSuite{})
+
+func (suite *QueueSuite) SetUpTest(c *C) {
+	suite.controller = gomock.NewController(c)
+	suite.deployments = map[string][]string{}
+	suite.finished = nil
+	suite.planCalled = 0
+	suite.updateCalled = 0
+	suite.manager = NewManager(testclient.NewSimpleClientset())
+	suite.manager.queueInterval = 5 * time.Millisecond
+	suite.manager.ApprovalPolicy = &policy.ApprovalPolicy{Classifiers: map[string]policy.ApprovalRule{
+		"production": {RequiredApprovers: 1},
+	}}
+	suite.manager.Plan = func(config *updater.Config) (updater.UpdatePlan, error) {
+		suite.mutex.Lock()
+		defer suite.mutex.Unlock()
+		suite.planCalled++
+		deployments := make([]v1.Deployment, 0)
+		for _, name := range suite.deployments[config.GetUpdateClassifier()] {
+			deployments = append(deployments, v1.Deployment{ObjectMeta: metaV1.ObjectMeta{Namespace: "default", Name: name}})
+		}
+		updatePlan := NewMockUpdatePlan(suite.controller)
+		updatePlan.EXPECT().GetToApplyDeployments().Return(deployments).AnyTimes()
+		updatePlan.EXPECT().GetToCreateJobs().Return([]batchv1.Job{}).AnyTimes()
+		return updatePlan, nil
+	}
+	suite.manager.Update = func(updatePlan updater.UpdatePlan, wrapper updater.KubernetesWrapper) updater.UpdateProgress {
+		suite.mutex.Lock()
+		defer suite.mutex.Unlock()
+		index := suite.updateCalled
+		suite.updateCalled++
+		suite.finished = append(suite.finished, false)
+		progress := NewMockUpdateProgress(suite.controller)
+		progress.EXPECT().Successful().DoAndReturn(func() bool { return suite.isFinished(index) }).AnyTimes()
+		progress.EXPECT().Failed().Return(false).AnyTimes()
+		progress.EXPECT().Finished().DoAndReturn(func() bool { return suite.isFinished(index) }).AnyTimes()
+		return progress
+	}
+}
+
+func (suite *QueueSuite) TearDownTest(c *C) {
+	for updateUUID := range suite.manager.updates {
+		_, _ = suite.manager.Cancel(updateUUID, "ci")
+	}
+	for index := range suite.finished {
+		suite.finish(index)
+	}
+	time.Sleep(4 * suite.manager.queueInterval)
+	suite.controller.Finish()
+}
+
+func (suite *QueueSuite) isFinished(index int) bool {
+	suite.mutex.Lock()
+	defer suite.mutex.Unlock()
+	return suite.finished[index]
+}
+
+func (suite *QueueSuite) finish(index int) {
+	suite.mutex.Lock()
+	defer suite.mutex.Unlock()
+	suite.finished[index] = true
+}
+
+func (suite *QueueSuite) calls() (int, int) {
+	suite.mutex.Lock()
+	defer suite.mutex.Unlock()
+	return suite.planCalled, suite.updateCalled
+}
+
+func (suite *QueueSuite) create(c *C, updateClassifier string) UpdateProgress {
+	config := updater.NewConfig(testclient.NewSimpleClientset(), updater.NewImage("xcnt/test:1.0.0"), updateClassifier)
+	config.SetRequester("ci")
+	updateProgress, err := suite.manager.Create(config)
+	c.Assert(err, IsNil)
+	return updateProgress
+}
+
+func (suite *QueueSuite) TestConflictingUpdateIsQueued(c *C) {
+	first := suite.create(c, "stable")
+	second := suite.create(c, "stable")
+	c.Assert(first.State(), Equals, StateRunning)
+	c.Assert(second.State(), Equals, StateQueued)
+	c.Assert(second.Finished(), Equals, false)
+	c.Assert(second.Queue().Position, Equals, 1)
+	c.Assert(second.Queue().UpdateUUIDs, DeepEquals, []uuid.UUID{first.UUID()})
+	c.Assert(first.Queue(), IsNil)
+
+	time.Sleep(4 * suite.manager.queueInterval)
+	c.Assert(second.State(), Equals, StateQueued)
+	suite.finish(0)
+	c.Assert(waitForState(second, StateQueued), Equals, StateRunning)
+	c.Assert(second.Queue().Position, Equals, 0)
+	planCalled, updateCalled := suite.calls()
+	c.Assert(planCalled, Equals, 3)
+	c.Assert(updateCalled, Equals, 2)
+}
+
+func (suite *QueueSuite) TestQueuedUpdatesStartInOrder(c *C) {
+	first := suite.create(c, "stable")
+	second := suite.create(c, "stable")
+	third := suite.create(c, "stable")
+	c.Assert(third.Queue().Position, Equals, 2)
+	c.Assert(third.Queue().UpdateUUIDs, DeepEquals, []uuid.UUID{first.UUID(), second.UUID()})
+
+	suite.finish(0)
+	c.Assert(waitForState(second, StateQueued), Equals, StateRunning)
+	time.Sleep(4 * suite.manager.queueInterval)
+	c.Assert(third.State(), Equals, StateQueued)
+	c.Assert(third.Queue().Position, Equals, 1)
+	c.Assert(third.Queue().UpdateUUIDs, DeepEquals, []uuid.UUID{second.UUID()})
+}
+
+func (suite *QueueSuite) TestSharedDeploymentConflicts(c *C) {
+	suite.deployments["stable"] = []string{"api", "worker"}
+	suite.deployments["hotfix"] = []string{"worker"}
+	suite.deployments["canary"] = []string{"frontend"}
+	suite.create(c, "stable")
+	c.Assert(suite.create(c, "hotfix").State(), Equals, StateQueued)
+	c.Assert(suite.create(c, "canary").State(), Equals, StateRunning)
+}
+
+func (suite *QueueSuite) TestCancelQueuedUpdate(c *C) {
+	suite.create(c, "stable")
+	second := suite.create(c, "stable")
+	_, err := suite.manager.Cancel(second.UUID(), "ci")
+	c.Assert(err, IsNil)
+	c.Assert(second.State(), Equals, StateCancelled)
+
+	suite.finish(0)
+	time.Sleep(4 * suite.manager.queueInterval)
+	c.Assert(second.State(), Equals, StateCancelled)
+	_, updateCalled := suite.calls()
+	c.Assert(updateCalled, Equals, 1)
+}
+
+func (suite *QueueSuite) TestRejectConflictingUpdate(c *C) {
+	suite.manager.ConflictMode = ConflictModeReject
+	first := suite.create(c, "stable")
+	config := updater.NewConfig(testclient.NewSimpleClientset(), updater.NewImage("xcnt/test:1.0.0"), "stable")
+	updateProgress, err := suite.manager.Create(config)
+	c.Assert(updateProgress, IsNil)
+	var conflict *ConflictError
+	c.Assert(errors.As(err, &conflict), Equals, true)
+	c.Assert(conflict.UpdateUUIDs, DeepEquals, []uuid.UUID{first.UUID()})
+	_, err = suite.manager.GetByString(config.GetUpdateUUID())
+	c.Assert(err, Equals, os.ErrNotExist)
+}
+
+func (suite *QueueSuite) TestRejectConflictingApprovedUpdate(c *C) {
+	suite.manager.ConflictMode = ConflictModeReject
+	first := suite.create(c, "production")
+	held := suite.create(c, "production")
+	c.Assert(held.State(), Equals, StatePendingApproval)
+	_, err := suite.manager.Approve(first.UUID(), "alice")
+	c.Assert(err, IsNil)
+	c.Assert(first.State(), Equals, StateRunning)
+	_, err = suite.manager.Approve(held.UUID(), "alice")
+	c.Assert(err, IsNil)
+	c.Assert(held.State(), Equals, StateFailed)
+	c.Assert(held.Finished(), Equals, true)
+	c.Assert(held.Queue().Error, Matches, "The update conflicts with the queued or running updates .*")
+}
+
+func (suite *QueueSuite) TestQueuedUpdateIsVerifiedAgain(c *C) {
+	frozen := false
+	suite.manager.Verifiers = []PlanVerifier{func(config *updater.Config, updatePlan updater.UpdatePlan) error {
+		suite.mutex.Lock()
+		defer suite.mutex.Unlock()
+		if frozen {
+			return Reject("Updates are frozen")
+		}
+		return nil
+	}}
+	suite.create(c, "stable")
+	second := suite.create(c, "stable")
+	suite.mutex.Lock()
+	frozen = true
+	suite.mutex.Unlock()
+	suite.finish(0)
+	c.Assert(waitForState(second, StateQueued), Equals, StateFailed)
+	c.Assert(second.Queue().Error, Equals, "Updates are frozen")
+	_, updateCalled := suite.calls()
+	c.Assert(updateCalled, Equals, 1)
+}
+
+func (suite *QueueSuite) TestQueuedUpdateClaimsReplannedDeployments(c *C) {
+	suite.deployments["stable"] = []string{"api"}
+	suite.deployments["canary"] = []string{"worker"}
+	suite.create(c, "stable")
+	second := suite.create(c, "stable")
+	canary := suite.create(c, "canary")
+	c.Assert(canary.State(), Equals, StateRunning)
+	suite.mutex.Lock()
+	suite.deployments["stable"] = []string{"api", "worker"}
+	suite.mutex.Unlock()
+
+	suite.finish(0)
+	time.Sleep(4 * suite.manager.queueInterval)
+	c.Assert(second.State(), Equals, StateQueued)
+	c.Assert(second.Queue().UpdateUUIDs, DeepEquals, []uuid.UUID{canary.UUID()})
+	suite.finish(1)
+	c.Assert(waitForState(second, StateQueued), Equals, StateRunning)
+}
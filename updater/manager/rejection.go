@@ -0,0 +1,20 @@
+package manager
+
+import "fmt"
+
+// RejectionError is returned if an update has been refused by one of the verifiers checking an update plan
+// before it is scheduled.
+type RejectionError struct {
+	// Reason describes why the update has been rejected.
+	Reason string
+}
+
+// Error returns the reason of the rejection.
+func (rejection *RejectionError) Error() string {
+	return rejection.Reason
+}
+
+// Reject returns a rejection error with the formatted reason.
+func Reject(format string, args ...interface{}) error {
+	return &RejectionError{Reason: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,136 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kubernetes-update-manager/updater"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultRollbackRetention is the time successful updates can be rolled back for after they finished if not
+// configured otherwise.
+const DefaultRollbackRetention = 24 * time.Hour
+
+// ErrNotRollbackable is returned if an update is rolled back which did not succeed or did not update any deployment.
+var ErrNotRollbackable = errors.New("The update can not be rolled back")
+
+// DriftError is returned if a deployment of the rolled back update has been changed since the update applied it, so
+// restoring its previous pod template would discard the change.
+type DriftError struct {
+	// Namespace is the namespace of the deployment.
+	Namespace string
+	// Name is the name of the deployment.
+	Name string
+}
+
+// Error returns the description of the changed deployment.
+func (drift *DriftError) Error() string {
+	return fmt.Sprintf("Deployment %s/%s has been changed since the update, force the rollback to discard the change", drift.Namespace, drift.Name)
+}
+
+// Rollback restores the deployments updated by the successful update to the pod templates they ran before, runs its
+// rollback jobs and returns the progress of the rollback. The rollback is tracked as an update of its own with the image and update classifier
+// of the rolled back update. It is queued behind or, depending on the conflict mode, rejected by queued or running
+// updates touching the same deployments. An update is only rolled back once. Unless the rollback is forced, it is
+// rejected with a DriftError if a deployment has been changed since the update applied it. Updates which have been
+// cleaned up can be rolled back within the rollback retention. Returns os.ErrNotExist if the update does not exist
+// and ErrNotRollbackable if it can not be rolled back.
+func (manager *Manager) Rollback(updateUUID uuid.UUID, requester string, force bool) (UpdateProgress, error) {
+	update, err := manager.rollbackable(updateUUID)
+	if err != nil {
+		return nil, err
+	}
+	if update.RollbackOf() != uuid.Nil {
+		return nil, fmt.Errorf("%w, it is a rollback itself", ErrNotRollbackable)
+	}
+	if !update.Successful() {
+		return nil, fmt.Errorf("%w, it is %s", ErrNotRollbackable, update.State())
+	}
+	rollbackPlan := update.GetRollbackPlan()
+	if rollbackPlan == nil || len(rollbackPlan.Targets) == 0 {
+		return nil, fmt.Errorf("%w, it did not update any deployment", ErrNotRollbackable)
+	}
+	if !force {
+		err = manager.checkDrift(rollbackPlan)
+		if err != nil {
+			return nil, err
+		}
+	}
+	rollbackProgress := WrapUpdateProgress(nil)
+	rollbackProgress.requester = requester
+	rollbackProgress.image = update.Image()
+	rollbackProgress.updateClassifier = update.UpdateClassifier()
+	rollbackProgress.rollbackOf = update.UUID()
+	held := &heldProgress{}
+	resources := map[string]bool{"classifier/" + update.UpdateClassifier(): true}
+	for _, target := range rollbackPlan.Targets {
+		held.deployments = append(held.deployments, target.Deployment.DeepCopy())
+		resources["deployment/"+target.Deployment.Namespace+"/"+target.Deployment.Name] = true
+	}
+	rollbackProgress.progress = held
+	rollbackProgress.hold = &updateHold{state: StateQueued, held: held, released: true}
+	rolledBack, _ := update.(*UpdateProgressImpl)
+	if rolledBack != nil {
+		err = rolledBack.markRolledBack(rollbackProgress.UUID())
+		if err != nil {
+			return nil, err
+		}
+	}
+	manager.store(rollbackProgress)
+
+	restore := manager.Restore
+	if restore == nil {
+		restore = updater.Rollback
+	}
+	kubernetesWrapper := updater.NewClientsetWrapper(manager.clientset)
+	prepared := &preparedUpdate{
+		resources: resources,
+		start:     func() updater.UpdateProgress { return restore(rollbackPlan, kubernetesWrapper) },
+	}
+	err = manager.dispatch(rollbackProgress, func() (*preparedUpdate, error) { return prepared, nil }, prepared)
+	if err != nil {
+		manager.Delete(rollbackProgress.UUID())
+		if rolledBack != nil {
+			rolledBack.unmarkRolledBack()
+		}
+		return nil, err
+	}
+	return rollbackProgress, nil
+}
+
+// rollbackable returns the update with the uuid, including the ones retained for rollbacks after they have been
+// cleaned up. Returns os.ErrNotExist if the update is unknown.
+func (manager *Manager) rollbackable(updateUUID uuid.UUID) (UpdateProgress, error) {
+	update, err := manager.Get(updateUUID)
+	if err == nil {
+		return update, nil
+	}
+	manager.updatesMutex.RLock()
+	defer manager.updatesMutex.RUnlock()
+	update, ok := manager.retained[updateUUID]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return update, nil
+}
+
+// checkDrift returns a DriftError if the pod template of a deployment of the rollback plan differs from the one the
+// update applied.
+func (manager *Manager) checkDrift(rollbackPlan *updater.RollbackPlan) error {
+	for _, target := range rollbackPlan.Targets {
+		current, err := manager.clientset.AppsV1().Deployments(target.Deployment.Namespace).Get(context.TODO(), target.Deployment.Name, metaV1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(current.Spec.Template, target.Deployment.Spec.Template) {
+			return &DriftError{Namespace: target.Deployment.Namespace, Name: target.Deployment.Name}
+		}
+	}
+	return nil
+}
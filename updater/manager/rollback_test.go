@@ -0,0 +1,195 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"kubernetes-update-manager/updater"
+	"os"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+)
+
+type RollbackSuite struct {
+	controller *gomock.Controller
+	manager    *Manager
+	successful bool
+	finishTime time.Time
+	targets    []updater.RollbackTarget
+	restored   *updater.RollbackPlan
+}
+
+var _ = Suite(&RollbackSuite{})
+
+func (suite *RollbackSuite) SetUpTest(c *C) {
+	suite.controller = gomock.NewController(c)
+	suite.successful = true
+	suite.finishTime = time.Now()
+	deployment := &v1.Deployment{ObjectMeta: metaV1.ObjectMeta{Namespace: "default", Name: "api"}}
+	suite.targets = []updater.RollbackTarget{{Deployment: deployment, PreviousTemplate: &apiv1.PodTemplateSpec{}}}
+	suite.restored = nil
+	suite.manager = NewManager(testclient.NewSimpleClientset(deployment.DeepCopy()))
+	suite.manager.Plan = func(config *updater.Config) (updater.UpdatePlan, error) {
+		updatePlan := NewMockUpdatePlan(suite.controller)
+		updatePlan.EXPECT().GetToApplyDeployments().Return([]v1.Deployment{*deployment}).AnyTimes()
+		updatePlan.EXPECT().GetToCreateJobs().Return([]batchv1.Job{}).AnyTimes()
+		return updatePlan, nil
+	}
+	suite.manager.Update = func(updatePlan updater.UpdatePlan, wrapper updater.KubernetesWrapper) updater.UpdateProgress {
+		progress := NewMockUpdateProgress(suite.controller)
+		progress.EXPECT().Finished().Return(true).AnyTimes()
+		progress.EXPECT().FinishTime().DoAndReturn(func() *time.Time { return &suite.finishTime }).AnyTimes()
+		progress.EXPECT().Successful().DoAndReturn(func() bool { return suite.successful }).AnyTimes()
+		progress.EXPECT().Failed().DoAndReturn(func() bool { return !suite.successful }).AnyTimes()
+		progress.EXPECT().GetRollbackPlan().DoAndReturn(func() *updater.RollbackPlan {
+			return &updater.RollbackPlan{Targets: suite.targets}
+		}).AnyTimes()
+		return progress
+	}
+	suite.manager.Restore = func(rollbackPlan *updater.RollbackPlan, wrapper updater.KubernetesWrapper) updater.UpdateProgress {
+		suite.restored = rollbackPlan
+		progress := NewMockUpdateProgress(suite.controller)
+		progress.EXPECT().Finished().Return(false).AnyTimes()
+		progress.EXPECT().Successful().Return(false).AnyTimes()
+		progress.EXPECT().Failed().Return(false).AnyTimes()
+		return progress
+	}
+}
+
+func (suite *RollbackSuite) TearDownTest(c *C) {
+	suite.controller.Finish()
+}
+
+func (suite *RollbackSuite) create(c *C) UpdateProgress {
+	updateProgress, err := suite.manager.Create(updater.NewConfig(nil, updater.NewImage("xcnt/test:1.1.0"), "stable"))
+	c.Assert(err, IsNil)
+	return updateProgress
+}
+
+func (suite *RollbackSuite) TestRollback(c *C) {
+	updateProgress := suite.create(c)
+	rollback, err := suite.manager.Rollback(updateProgress.UUID(), "ops", false)
+	c.Assert(err, IsNil)
+	c.Assert(rollback.UUID(), Not(Equals), updateProgress.UUID())
+	c.Assert(rollback.RollbackOf(), Equals, updateProgress.UUID())
+	c.Assert(rollback.Requester(), Equals, "ops")
+	c.Assert(rollback.Image(), Equals, "xcnt/test:1.1.0")
+	c.Assert(rollback.UpdateClassifier(), Equals, "stable")
+	c.Assert(rollback.State(), Equals, StateRunning)
+	c.Assert(suite.restored.Targets, DeepEquals, suite.targets)
+	stored, err := suite.manager.Get(rollback.UUID())
+	c.Assert(err, IsNil)
+	c.Assert(stored, Equals, rollback)
+	c.Assert(updateProgress.RollbackOf(), Equals, uuid.Nil)
+}
+
+func (suite *RollbackSuite) TestRollbackQueuedBehindConflictingUpdate(c *C) {
+	updateProgress := suite.create(c)
+	suite.manager.queueInterval = time.Hour
+	suite.manager.claims = append(suite.manager.claims, &claim{
+		updateProgress: WrapUpdateProgress(&heldProgress{}),
+		resources:      map[string]bool{"deployment/default/api": true},
+	})
+	rollback, err := suite.manager.Rollback(updateProgress.UUID(), "ops", false)
+	c.Assert(err, IsNil)
+	c.Assert(rollback.State(), Equals, StateQueued)
+	c.Assert(suite.restored, IsNil)
+}
+
+func (suite *RollbackSuite) TestRollbackUnsuccessfulUpdate(c *C) {
+	suite.successful = false
+	updateProgress := suite.create(c)
+	_, err := suite.manager.Rollback(updateProgress.UUID(), "ops", false)
+	c.Assert(errors.Is(err, ErrNotRollbackable), Equals, true)
+	c.Assert(err, ErrorMatches, "The update can not be rolled back, it is failed")
+}
+
+func (suite *RollbackSuite) TestRollbackWithoutDeployments(c *C) {
+	suite.targets = nil
+	updateProgress := suite.create(c)
+	_, err := suite.manager.Rollback(updateProgress.UUID(), "ops", false)
+	c.Assert(err, ErrorMatches, "The update can not be rolled back, it did not update any deployment")
+}
+
+func (suite *RollbackSuite) TestRollbackOfRollback(c *C) {
+	updateProgress := suite.create(c)
+	rollback, err := suite.manager.Rollback(updateProgress.UUID(), "ops", false)
+	c.Assert(err, IsNil)
+	_, err = suite.manager.Rollback(rollback.UUID(), "ops", false)
+	c.Assert(err, ErrorMatches, "The update can not be rolled back, it is a rollback itself")
+}
+
+func (suite *RollbackSuite) TestRollbackAfterCleanup(c *C) {
+	updateProgress := suite.create(c)
+	suite.finishTime = time.Now().Add(-time.Hour)
+	suite.manager.Cleanup()
+	_, err := suite.manager.Get(updateProgress.UUID())
+	c.Assert(err, Equals, os.ErrNotExist)
+	rollback, err := suite.manager.Rollback(updateProgress.UUID(), "ops", false)
+	c.Assert(err, IsNil)
+	c.Assert(rollback.RollbackOf(), Equals, updateProgress.UUID())
+	c.Assert(suite.restored.Targets, DeepEquals, suite.targets)
+	_, err = suite.manager.Rollback(updateProgress.UUID(), "ops", false)
+	c.Assert(errors.Is(err, ErrNotRollbackable), Equals, true)
+}
+
+func (suite *RollbackSuite) TestRollbackAfterRetention(c *C) {
+	updateProgress := suite.create(c)
+	suite.manager.RollbackRetention = 30 * time.Minute
+	suite.finishTime = time.Now().Add(-time.Hour)
+	suite.manager.Cleanup()
+	_, err := suite.manager.Rollback(updateProgress.UUID(), "ops", false)
+	c.Assert(err, Equals, os.ErrNotExist)
+}
+
+func (suite *RollbackSuite) TestRollbackNotFound(c *C) {
+	_, err := suite.manager.Rollback(uuid.New(), "ops", false)
+	c.Assert(err, Equals, os.ErrNotExist)
+}
+
+func (suite *RollbackSuite) TestRollbackTwice(c *C) {
+	updateProgress := suite.create(c)
+	rollback, err := suite.manager.Rollback(updateProgress.UUID(), "ops", false)
+	c.Assert(err, IsNil)
+	_, err = suite.manager.Rollback(updateProgress.UUID(), "ops", true)
+	c.Assert(errors.Is(err, ErrNotRollbackable), Equals, true)
+	c.Assert(err, ErrorMatches, "The update can not be rolled back, it has already been rolled back by update "+rollback.UUID().String())
+}
+
+func (suite *RollbackSuite) TestRejectedRollbackMayBeRepeated(c *C) {
+	updateProgress := suite.create(c)
+	suite.manager.ConflictMode = ConflictModeReject
+	suite.manager.claims = append(suite.manager.claims, &claim{
+		updateProgress: WrapUpdateProgress(&heldProgress{}),
+		resources:      map[string]bool{"deployment/default/api": true},
+	})
+	_, err := suite.manager.Rollback(updateProgress.UUID(), "ops", false)
+	c.Assert(err, FitsTypeOf, &ConflictError{})
+	suite.manager.claims = nil
+	_, err = suite.manager.Rollback(updateProgress.UUID(), "ops", false)
+	c.Assert(err, IsNil)
+}
+
+func (suite *RollbackSuite) TestRollbackOfChangedDeployment(c *C) {
+	updateProgress := suite.create(c)
+	changed := suite.targets[0].Deployment.DeepCopy()
+	changed.Spec.Template.Spec.Containers = []apiv1.Container{{Name: "api", Image: "xcnt/test:1.2.0"}}
+	_, err := suite.manager.clientset.AppsV1().Deployments("default").Update(context.TODO(), changed, metaV1.UpdateOptions{})
+	c.Assert(err, IsNil)
+
+	_, err = suite.manager.Rollback(updateProgress.UUID(), "ops", false)
+	c.Assert(err, DeepEquals, &DriftError{Namespace: "default", Name: "api"})
+	c.Assert(suite.restored, IsNil)
+
+	rollback, err := suite.manager.Rollback(updateProgress.UUID(), "ops", true)
+	c.Assert(err, IsNil)
+	c.Assert(rollback.State(), Equals, StateRunning)
+	c.Assert(suite.restored.Targets, DeepEquals, suite.targets)
+}
@@ -1,7 +1,9 @@
 package manager
 
 import (
+	"fmt"
 	"kubernetes-update-manager/updater"
+	"sync"
 	"time"
 
 	uuidGenerator "github.com/google/uuid"
@@ -19,8 +21,17 @@ func WrapUpdateProgress(toWrapProgress updater.UpdateProgress) *UpdateProgressIm
 
 // UpdateProgressImpl is the implementation of the UpdateProgress interface
 type UpdateProgressImpl struct {
-	uuid     uuidGenerator.UUID
-	progress updater.UpdateProgress
+	mutex            sync.Mutex
+	uuid             uuidGenerator.UUID
+	progress         updater.UpdateProgress
+	requester        string
+	image            string
+	updateClassifier string
+	hold             *updateHold
+	promotion        *Promotion
+	promotedFrom     uuidGenerator.UUID
+	rollbackOf       uuidGenerator.UUID
+	rolledBackBy     uuidGenerator.UUID
 }
 
 // UUID returns the unique identifier for the specified update progress.
@@ -28,47 +39,193 @@ func (updaterProgress *UpdateProgressImpl) UUID() uuidGenerator.UUID {
 	return updaterProgress.uuid
 }
 
+// Requester returns the name of the principal which requested the update.
+func (updaterProgress *UpdateProgressImpl) Requester() string {
+	return updaterProgress.requester
+}
+
+// Image returns the image the update rolls out.
+func (updaterProgress *UpdateProgressImpl) Image() string {
+	return updaterProgress.image
+}
+
+// UpdateClassifier returns the update classifier the update has been requested for.
+func (updaterProgress *UpdateProgressImpl) UpdateClassifier() string {
+	return updaterProgress.updateClassifier
+}
+
+// State returns the phase of the update.
+func (updaterProgress *UpdateProgressImpl) State() State {
+	updaterProgress.mutex.Lock()
+	defer updaterProgress.mutex.Unlock()
+	updaterProgress.expireIfDue()
+	return updaterProgress.stateLocked()
+}
+
+// stateLocked returns the phase of the update. The lock of the update progress must be held.
+func (updaterProgress *UpdateProgressImpl) stateLocked() State {
+	if updaterProgress.hold != nil && updaterProgress.hold.state != stateStarted {
+		return updaterProgress.hold.state
+	}
+	progress := updaterProgress.progress
+	switch {
+	case progress.Successful():
+		return StateSucceeded
+	case progress.Failed() || progress.Finished():
+		return StateFailed
+	}
+	return StateRunning
+}
+
+// Approval returns the approvals of the update or nil, if the update did not need to be approved.
+func (updaterProgress *UpdateProgressImpl) Approval() *Approval {
+	updaterProgress.mutex.Lock()
+	defer updaterProgress.mutex.Unlock()
+	updaterProgress.expireIfDue()
+	if updaterProgress.hold == nil || updaterProgress.hold.approval == nil {
+		return nil
+	}
+	approval := *updaterProgress.hold.approval
+	approval.Approvers = append([]string{}, approval.Approvers...)
+	return &approval
+}
+
+// Schedule returns when the update will be started or nil, if its start has not been deferred.
+func (updaterProgress *UpdateProgressImpl) Schedule() *Schedule {
+	updaterProgress.mutex.Lock()
+	defer updaterProgress.mutex.Unlock()
+	if updaterProgress.hold == nil || updaterProgress.hold.schedule == nil {
+		return nil
+	}
+	schedule := *updaterProgress.hold.schedule
+	schedule.Error = updaterProgress.hold.failure
+	return &schedule
+}
+
+// Queue returns the updates the update waits for or nil, if it never conflicted with another update.
+func (updaterProgress *UpdateProgressImpl) Queue() *Queue {
+	updaterProgress.mutex.Lock()
+	defer updaterProgress.mutex.Unlock()
+	if updaterProgress.hold == nil || updaterProgress.hold.queue == nil {
+		return nil
+	}
+	queue := *updaterProgress.hold.queue
+	queue.UpdateUUIDs = append([]uuidGenerator.UUID{}, queue.UpdateUUIDs...)
+	queue.Error = updaterProgress.hold.failure
+	return &queue
+}
+
+// Promotion returns the update the update is promoted to once it succeeded or nil, if it is not promoted.
+func (updaterProgress *UpdateProgressImpl) Promotion() *Promotion {
+	updaterProgress.mutex.Lock()
+	defer updaterProgress.mutex.Unlock()
+	if updaterProgress.promotion == nil {
+		return nil
+	}
+	promotion := *updaterProgress.promotion
+	return &promotion
+}
+
+// PromotedFrom returns the uuid of the update which has been promoted to the update or the nil uuid, if the update
+// has been requested directly.
+func (updaterProgress *UpdateProgressImpl) PromotedFrom() uuidGenerator.UUID {
+	updaterProgress.mutex.Lock()
+	defer updaterProgress.mutex.Unlock()
+	return updaterProgress.promotedFrom
+}
+
+// RollbackOf returns the uuid of the update which is rolled back by the update or the nil uuid, if the update is not a
+// rollback.
+func (updaterProgress *UpdateProgressImpl) RollbackOf() uuidGenerator.UUID {
+	updaterProgress.mutex.Lock()
+	defer updaterProgress.mutex.Unlock()
+	return updaterProgress.rollbackOf
+}
+
+// markRolledBack records the rollback of the update. It returns ErrNotRollbackable if the update has already been
+// rolled back.
+func (updaterProgress *UpdateProgressImpl) markRolledBack(rollbackUUID uuidGenerator.UUID) error {
+	updaterProgress.mutex.Lock()
+	defer updaterProgress.mutex.Unlock()
+	if updaterProgress.rolledBackBy != uuidGenerator.Nil {
+		return fmt.Errorf("%w, it has already been rolled back by update %s", ErrNotRollbackable, updaterProgress.rolledBackBy)
+	}
+	updaterProgress.rolledBackBy = rollbackUUID
+	return nil
+}
+
+// unmarkRolledBack forgets the rollback of the update, if the rollback could not be started.
+func (updaterProgress *UpdateProgressImpl) unmarkRolledBack() {
+	updaterProgress.mutex.Lock()
+	defer updaterProgress.mutex.Unlock()
+	updaterProgress.rolledBackBy = uuidGenerator.Nil
+}
+
+// current returns the wrapped progress. It is replaced when an update held for approval is started.
+func (updaterProgress *UpdateProgressImpl) current() updater.UpdateProgress {
+	updaterProgress.mutex.Lock()
+	defer updaterProgress.mutex.Unlock()
+	updaterProgress.expireIfDue()
+	return updaterProgress.progress
+}
+
 // GetJobs returns a list of jobs which are included in the update progress.
 func (updaterProgress *UpdateProgressImpl) GetJobs() []*batchv1.Job {
-	return updaterProgress.progress.GetJobs()
+	return updaterProgress.current().GetJobs()
 }
 
 // GetDeployments returns the list of deployments which needs to be updated.
 func (updaterProgress *UpdateProgressImpl) GetDeployments() []*v1.Deployment {
-	return updaterProgress.progress.GetDeployments()
+	return updaterProgress.current().GetDeployments()
 }
 
 // FinishedJobsCount returns how many jobs have been finished.
 func (updaterProgress *UpdateProgressImpl) FinishedJobsCount() int {
-	return updaterProgress.progress.FinishedJobsCount()
+	return updaterProgress.current().FinishedJobsCount()
 }
 
 // UpdatedDeploymentsCount returns the amount of deployments which update has been finished.
 func (updaterProgress *UpdateProgressImpl) UpdatedDeploymentsCount() int {
-	return updaterProgress.progress.UpdatedDeploymentsCount()
+	return updaterProgress.current().UpdatedDeploymentsCount()
 }
 
 // FinishTime returns when the progress was finished. If the update hasn't finished yet, this will return nil.
 func (updaterProgress *UpdateProgressImpl) FinishTime() *time.Time {
-	return updaterProgress.progress.FinishTime()
+	return updaterProgress.current().FinishTime()
 }
 
 // Finished returns if the update progress has run through succesfully or unsuccessfully
 func (updaterProgress *UpdateProgressImpl) Finished() bool {
-	return updaterProgress.progress.Finished()
+	return updaterProgress.current().Finished()
 }
 
 // Failed returns if the update is marked as failed
 func (updaterProgress *UpdateProgressImpl) Failed() bool {
-	return updaterProgress.progress.Failed()
+	return updaterProgress.current().Failed()
 }
 
 // Successful returns true if the complete update progress has run through
 func (updaterProgress *UpdateProgressImpl) Successful() bool {
-	return updaterProgress.progress.Successful()
+	return updaterProgress.current().Successful()
+}
+
+// GetRollbackPlan returns the deployments applied by the update with the pod templates they ran before and the jobs
+// run when the update is rolled back.
+func (updaterProgress *UpdateProgressImpl) GetRollbackPlan() *updater.RollbackPlan {
+	return updaterProgress.current().GetRollbackPlan()
+}
+
+// GetRollbackJobs returns the jobs which have been created while rolling back the update.
+func (updaterProgress *UpdateProgressImpl) GetRollbackJobs() []*batchv1.Job {
+	return updaterProgress.current().GetRollbackJobs()
 }
 
-// Abort cancels the run of this specific udpater.
+// Abort cancels the run of this specific udpater. An update which is still waiting to be started is not started
+// anymore.
 func (updaterProgress *UpdateProgressImpl) Abort() {
-	updaterProgress.progress.Abort()
+	updaterProgress.mutex.Lock()
+	updaterProgress.stopHold(StateAborted)
+	progress := updaterProgress.progress
+	updaterProgress.mutex.Unlock()
+	progress.Abort()
 }
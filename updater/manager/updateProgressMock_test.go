@@ -11,6 +11,7 @@ import (
 	v10 "k8s.io/api/batch/v1"
 	v11 "k8s.io/client-go/kubernetes/typed/apps/v1"
 	v12 "k8s.io/client-go/kubernetes/typed/batch/v1"
+	v13 "k8s.io/client-go/kubernetes/typed/core/v1"
 	reflect "reflect"
 	time "time"
 )
@@ -117,6 +118,90 @@ func (mr *MockUpdatePlanMockRecorder) GetToApplyDeployments() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetToApplyDeployments", reflect.TypeOf((*MockUpdatePlan)(nil).GetToApplyDeployments))
 }
 
+// GetContainerChanges mocks base method
+func (m *MockUpdatePlan) GetContainerChanges() []x.ContainerChange {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetContainerChanges")
+	ret0, _ := ret[0].([]x.ContainerChange)
+	return ret0
+}
+
+// GetContainerChanges indicates an expected call of GetContainerChanges
+func (mr *MockUpdatePlanMockRecorder) GetContainerChanges() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetContainerChanges", reflect.TypeOf((*MockUpdatePlan)(nil).GetContainerChanges))
+}
+
+// GetWaves mocks base method
+func (m *MockUpdatePlan) GetWaves() []x.Wave {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWaves")
+	ret0, _ := ret[0].([]x.Wave)
+	return ret0
+}
+
+// GetWaves indicates an expected call of GetWaves
+func (mr *MockUpdatePlanMockRecorder) GetWaves() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWaves", reflect.TypeOf((*MockUpdatePlan)(nil).GetWaves))
+}
+
+// GetCanary mocks base method
+func (m *MockUpdatePlan) GetCanary() *x.Canary {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCanary")
+	ret0, _ := ret[0].(*x.Canary)
+	return ret0
+}
+
+// GetCanary indicates an expected call of GetCanary
+func (mr *MockUpdatePlanMockRecorder) GetCanary() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCanary", reflect.TypeOf((*MockUpdatePlan)(nil).GetCanary))
+}
+
+// GetAnalysis mocks base method
+func (m *MockUpdatePlan) GetAnalysis() *x.Analysis {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAnalysis")
+	ret0, _ := ret[0].(*x.Analysis)
+	return ret0
+}
+
+// GetAnalysis indicates an expected call of GetAnalysis
+func (mr *MockUpdatePlanMockRecorder) GetAnalysis() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAnalysis", reflect.TypeOf((*MockUpdatePlan)(nil).GetAnalysis))
+}
+
+// GetRollbackJobs mocks base method
+func (m *MockUpdatePlan) GetRollbackJobs() []v10.Job {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRollbackJobs")
+	ret0, _ := ret[0].([]v10.Job)
+	return ret0
+}
+
+// GetRollbackJobs indicates an expected call of GetRollbackJobs
+func (mr *MockUpdatePlanMockRecorder) GetRollbackJobs() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRollbackJobs", reflect.TypeOf((*MockUpdatePlan)(nil).GetRollbackJobs))
+}
+
+// GetRollbackPolicy mocks base method
+func (m *MockUpdatePlan) GetRollbackPolicy() x.RollbackPolicy {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRollbackPolicy")
+	ret0, _ := ret[0].(x.RollbackPolicy)
+	return ret0
+}
+
+// GetRollbackPolicy indicates an expected call of GetRollbackPolicy
+func (mr *MockUpdatePlanMockRecorder) GetRollbackPolicy() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRollbackPolicy", reflect.TypeOf((*MockUpdatePlan)(nil).GetRollbackPolicy))
+}
+
 // MockKubernetesWrapper is a mock of KubernetesWrapper interface
 type MockKubernetesWrapper struct {
 	ctrl     *gomock.Controller
@@ -182,6 +267,34 @@ func (mr *MockKubernetesWrapperMockRecorder) GetReplicaSetAPIFor(namespace inter
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReplicaSetAPIFor", reflect.TypeOf((*MockKubernetesWrapper)(nil).GetReplicaSetAPIFor), namespace)
 }
 
+// GetPodAPIFor mocks base method
+func (m *MockKubernetesWrapper) GetPodAPIFor(namespace string) v13.PodInterface {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPodAPIFor", namespace)
+	ret0, _ := ret[0].(v13.PodInterface)
+	return ret0
+}
+
+// GetPodAPIFor indicates an expected call of GetPodAPIFor
+func (mr *MockKubernetesWrapperMockRecorder) GetPodAPIFor(namespace interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPodAPIFor", reflect.TypeOf((*MockKubernetesWrapper)(nil).GetPodAPIFor), namespace)
+}
+
+// GetServiceAPIFor mocks base method
+func (m *MockKubernetesWrapper) GetServiceAPIFor(namespace string) v13.ServiceInterface {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetServiceAPIFor", namespace)
+	ret0, _ := ret[0].(v13.ServiceInterface)
+	return ret0
+}
+
+// GetServiceAPIFor indicates an expected call of GetServiceAPIFor
+func (mr *MockKubernetesWrapperMockRecorder) GetServiceAPIFor(namespace interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetServiceAPIFor", reflect.TypeOf((*MockKubernetesWrapper)(nil).GetServiceAPIFor), namespace)
+}
+
 // MockUpdateProgress is a mock of UpdateProgress interface
 type MockUpdateProgress struct {
 	ctrl     *gomock.Controller
@@ -328,3 +441,31 @@ func (mr *MockUpdateProgressMockRecorder) Abort() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Abort", reflect.TypeOf((*MockUpdateProgress)(nil).Abort))
 }
+
+// GetRollbackPlan mocks base method
+func (m *MockUpdateProgress) GetRollbackPlan() *x.RollbackPlan {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRollbackPlan")
+	ret0, _ := ret[0].(*x.RollbackPlan)
+	return ret0
+}
+
+// GetRollbackPlan indicates an expected call of GetRollbackPlan
+func (mr *MockUpdateProgressMockRecorder) GetRollbackPlan() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRollbackPlan", reflect.TypeOf((*MockUpdateProgress)(nil).GetRollbackPlan))
+}
+
+// GetRollbackJobs mocks base method
+func (m *MockUpdateProgress) GetRollbackJobs() []*v10.Job {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRollbackJobs")
+	ret0, _ := ret[0].([]*v10.Job)
+	return ret0
+}
+
+// GetRollbackJobs indicates an expected call of GetRollbackJobs
+func (mr *MockUpdateProgressMockRecorder) GetRollbackJobs() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRollbackJobs", reflect.TypeOf((*MockUpdateProgress)(nil).GetRollbackJobs))
+}
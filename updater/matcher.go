@@ -1,6 +1,9 @@
 package updater
 
 import (
+	"path"
+	"strings"
+
 	v1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	apiv1 "k8s.io/api/core/v1"
@@ -13,58 +16,78 @@ const (
 
 // MatchesDeployment returns if the specified deployment includes the matching configuration.
 func MatchesDeployment(matchConfig MatchConfig, deployment v1.Deployment) bool {
-	if !matchesDeploymentSpec(matchConfig, deployment.Spec) {
+	meta := deployment.GetObjectMeta()
+	if !matchesDeploymentSpec(matchConfig, deployment.Spec, NewContainerFilter(meta.GetAnnotations())) {
 		return false
 	}
-	meta := deployment.GetObjectMeta()
 	return MatchesAnnotation(matchConfig, meta.GetAnnotations())
 }
 
 // matchesDeploymentSpec returns if the specified deployment specification includes the image
-// which should be updated.
-func matchesDeploymentSpec(matchConfig MatchConfig, deployment v1.DeploymentSpec) bool {
-	return MatchesPodSpec(matchConfig, deployment.Template.Spec)
+// which should be updated in one of the containers allowed by the filter.
+func matchesDeploymentSpec(matchConfig MatchConfig, deployment v1.DeploymentSpec, filter *ContainerFilter) bool {
+	return MatchesPodSpecFiltered(matchConfig, deployment.Template.Spec, filter)
 }
 
 // MatchesJob checks if the specified job fits to the match configuration.
 func MatchesJob(matchConfig MatchConfig, job batchv1.Job) bool {
-	if !matchesJobSpec(matchConfig, job.Spec) {
+	meta := job.GetObjectMeta()
+	if !matchesJobSpec(matchConfig, job.Spec, NewContainerFilter(meta.GetAnnotations())) {
 		return false
 	}
-	meta := job.GetObjectMeta()
 	return MatchesAnnotation(matchConfig, meta.GetAnnotations())
 }
 
-func matchesJobSpec(matchConfig MatchConfig, jobSpec batchv1.JobSpec) bool {
-	return MatchesPodSpec(matchConfig, jobSpec.Template.Spec)
+func matchesJobSpec(matchConfig MatchConfig, jobSpec batchv1.JobSpec, filter *ContainerFilter) bool {
+	return MatchesPodSpecFiltered(matchConfig, jobSpec.Template.Spec, filter)
 }
 
 // MatchesPodSpec checks if the pod specification includes the specified image
 // which returns data.
 func MatchesPodSpec(matchConfig MatchConfig, podSpec apiv1.PodSpec) bool {
-	return matchesContainers(matchConfig, podSpec.Containers) || matchesContainers(matchConfig, podSpec.InitContainers)
+	return MatchesPodSpecFiltered(matchConfig, podSpec, NewContainerFilter(nil))
 }
 
-func matchesContainers(matchConfig MatchConfig, containers []apiv1.Container) bool {
-	for _, container := range containers {
-		if matchesContainer(matchConfig, container) {
+// MatchesPodSpecFiltered checks if any container or init container of the pod
+// specification which is allowed by the filter includes the specified image.
+func MatchesPodSpecFiltered(matchConfig MatchConfig, podSpec apiv1.PodSpec, filter *ContainerFilter) bool {
+	for _, container := range getPodContainers(&podSpec) {
+		if filter.Allows(container.Name) && matchesContainerImage(matchConfig, *container.Image) {
 			return true
 		}
 	}
 	return false
 }
 
-func matchesContainer(matchConfig MatchConfig, container apiv1.Container) bool {
-	return matchConfig.GetImage().EqualsImage(container.Image)
+func matchesContainerImage(matchConfig MatchConfig, image string) bool {
+	return matchConfig.GetImage().EqualsImage(image)
 }
 
 // MatchesAnnotation returns if the annotation includes the
-// specified update classifier
+// specified update classifier. The annotation may contain a comma
+// separated list of classifiers. Each entry is matched as a shell
+// file name pattern, so "release-*" matches "release-1" and "*"
+// matches every classifier.
 func MatchesAnnotation(matchConfig MatchConfig, annotations map[string]string) bool {
 	item, ok := annotations[UpdateClassifier]
 	if !ok {
 		return ok
 	}
 
-	return item == matchConfig.GetUpdateClassifier()
+	return MatchesClassifier(item, matchConfig.GetUpdateClassifier())
+}
+
+// MatchesClassifier returns if the update classifier is included in the
+// comma separated list of classifier patterns.
+func MatchesClassifier(patterns string, updateClassifier string) bool {
+	for _, pattern := range strings.Split(patterns, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if len(pattern) == 0 {
+			continue
+		}
+		if matched, err := path.Match(pattern, updateClassifier); err == nil && matched {
+			return true
+		}
+	}
+	return false
 }
@@ -116,6 +116,20 @@ func (mr *MockUpdatePlanMockRecorder) GetToApplyDeployments() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetToApplyDeployments", reflect.TypeOf((*MockUpdatePlan)(nil).GetToApplyDeployments))
 }
 
+// GetContainerChanges mocks base method
+func (m *MockUpdatePlan) GetContainerChanges() []ContainerChange {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetContainerChanges")
+	ret0, _ := ret[0].([]ContainerChange)
+	return ret0
+}
+
+// GetContainerChanges indicates an expected call of GetContainerChanges
+func (mr *MockUpdatePlanMockRecorder) GetContainerChanges() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetContainerChanges", reflect.TypeOf((*MockUpdatePlan)(nil).GetContainerChanges))
+}
+
 // MockKubernetesWrapper is a mock of KubernetesWrapper interface
 type MockKubernetesWrapper struct {
 	ctrl     *gomock.Controller
@@ -327,3 +341,31 @@ func (mr *MockUpdateProgressMockRecorder) Abort() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Abort", reflect.TypeOf((*MockUpdateProgress)(nil).Abort))
 }
+
+// GetRollbackPlan mocks base method
+func (m *MockUpdateProgress) GetRollbackPlan() *RollbackPlan {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRollbackPlan")
+	ret0, _ := ret[0].(*RollbackPlan)
+	return ret0
+}
+
+// GetRollbackPlan indicates an expected call of GetRollbackPlan
+func (mr *MockUpdateProgressMockRecorder) GetRollbackPlan() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRollbackPlan", reflect.TypeOf((*MockUpdateProgress)(nil).GetRollbackPlan))
+}
+
+// GetRollbackJobs mocks base method
+func (m *MockUpdateProgress) GetRollbackJobs() []*v10.Job {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRollbackJobs")
+	ret0, _ := ret[0].([]*v10.Job)
+	return ret0
+}
+
+// GetRollbackJobs indicates an expected call of GetRollbackJobs
+func (mr *MockUpdateProgressMockRecorder) GetRollbackJobs() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRollbackJobs", reflect.TypeOf((*MockUpdateProgress)(nil).GetRollbackJobs))
+}
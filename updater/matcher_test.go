@@ -63,6 +63,62 @@ func (suite *MatcherSuite) TestMachesAnnotationNotSet(c *C) {
 	c.Assert(MatchesAnnotation(suite.matcherConfig, annotations), Equals, false)
 }
 
+func (suite *MatcherSuite) TestMatchesAnnotationList(c *C) {
+	suite.MockUpdateClassifier("hotfix")
+	annotations := map[string]string{
+		UpdateClassifier: "staging, hotfix",
+	}
+	c.Assert(MatchesAnnotation(suite.matcherConfig, annotations), Equals, true)
+}
+
+func (suite *MatcherSuite) TestMatchesAnnotationListNotMatched(c *C) {
+	suite.MockUpdateClassifier("stable")
+	annotations := map[string]string{
+		UpdateClassifier: "staging,hotfix",
+	}
+	c.Assert(MatchesAnnotation(suite.matcherConfig, annotations), Equals, false)
+}
+
+func (suite *MatcherSuite) TestMatchesAnnotationGlob(c *C) {
+	suite.MockUpdateClassifier("release-1.4")
+	annotations := map[string]string{
+		UpdateClassifier: "staging,release-*",
+	}
+	c.Assert(MatchesAnnotation(suite.matcherConfig, annotations), Equals, true)
+}
+
+func (suite *MatcherSuite) TestMatchesAnnotationGlobNotMatched(c *C) {
+	suite.MockUpdateClassifier("prerelease-1.4")
+	annotations := map[string]string{
+		UpdateClassifier: "release-*",
+	}
+	c.Assert(MatchesAnnotation(suite.matcherConfig, annotations), Equals, false)
+}
+
+func (suite *MatcherSuite) TestMatchesAnnotationWildcard(c *C) {
+	suite.MockUpdateClassifier("anything")
+	annotations := map[string]string{
+		UpdateClassifier: "*",
+	}
+	c.Assert(MatchesAnnotation(suite.matcherConfig, annotations), Equals, true)
+}
+
+func (suite *MatcherSuite) TestMatchesAnnotationInvalidPattern(c *C) {
+	suite.MockUpdateClassifier("stable")
+	annotations := map[string]string{
+		UpdateClassifier: "[stable, stable",
+	}
+	c.Assert(MatchesAnnotation(suite.matcherConfig, annotations), Equals, true)
+}
+
+func (suite *MatcherSuite) TestMatchesAnnotationEmptyEntries(c *C) {
+	suite.MockUpdateClassifier("")
+	annotations := map[string]string{
+		UpdateClassifier: ",,",
+	}
+	c.Assert(MatchesAnnotation(suite.matcherConfig, annotations), Equals, false)
+}
+
 func (suite *MatcherSuite) TestMatchesPodSpec(c *C) {
 	suite.MockImage("xcnt/test:1.0.0")
 	check := MatchesPodSpec(suite.matcherConfig, GetPodSpecWith("xcnt/test:0.9.9"))
@@ -76,30 +132,47 @@ func (suite *MatcherSuite) TestMatchesWithNonSpecifiedPodSpec(c *C) {
 }
 
 func (suite *MatcherSuite) TestMatchesWithMultipleContainers(c *C) {
+	suite.MockImage("xcnt/test:1.0.0")
 	podSpec := GetPodSpecWith("xcnt/test2:1.0.0", "xcnt/test:0.1.5", "xcnt/test3:0.1.5")
 	check := MatchesPodSpec(suite.matcherConfig, podSpec)
 	c.Assert(check, Equals, true)
 }
 
 func (suite *MatcherSuite) TestMatchesDeployment(c *C) {
+	suite.MockImage("xcnt/test:1.0.0")
+	suite.MockUpdateClassifier("stable")
 	deployment := GetDeploymentWith(map[string]string{UpdateClassifier: "stable"}, "xcnt/test:0.1.5")
 	check := MatchesDeployment(suite.matcherConfig, deployment)
 	c.Assert(check, Equals, true)
 }
 
 func (suite *MatcherSuite) TestMatchesDeploymentWithWrongClassifier(c *C) {
+	suite.MockImage("xcnt/test:1.0.0")
+	suite.MockUpdateClassifier("stable")
 	deployment := GetDeploymentWith(map[string]string{UpdateClassifier: "latest"}, "xcnt/test:0.1.5")
 	check := MatchesDeployment(suite.matcherConfig, deployment)
 	c.Assert(check, Equals, false)
 }
 
 func (suite *MatcherSuite) TestMatchesDeploymentWithWrongImage(c *C) {
+	suite.MockImage("xcnt/test:1.0.0")
+	suite.MockUpdateClassifier("stable")
 	deployment := GetDeploymentWith(map[string]string{UpdateClassifier: "stable"}, "xcnt/test1:0.1.5")
 	check := MatchesDeployment(suite.matcherConfig, deployment)
 	c.Assert(check, Equals, false)
 }
 
+func (suite *MatcherSuite) TestMatchesDeploymentWithClassifierList(c *C) {
+	suite.MockImage("xcnt/test:1.0.0")
+	suite.MockUpdateClassifier("stable")
+	deployment := GetDeploymentWith(map[string]string{UpdateClassifier: "staging,stable"}, "xcnt/test:0.1.5")
+	check := MatchesDeployment(suite.matcherConfig, deployment)
+	c.Assert(check, Equals, true)
+}
+
 func (suite *MatcherSuite) TestMatchesDeploymentWithInitContainer(c *C) {
+	suite.MockImage("xcnt/test:1.0.0")
+	suite.MockUpdateClassifier("stable")
 	deployment := GetDeploymentWith(map[string]string{UpdateClassifier: "stable"}, "xcnt/test1:0.1.5")
 	deployment.Spec.Template.Spec.InitContainers = []apiv1.Container{GetContainerWith("xcnt/test:0.9.9")}
 	check := MatchesDeployment(suite.matcherConfig, deployment)
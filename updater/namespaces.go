@@ -15,8 +15,14 @@ type NamespaceAPIGetter interface {
 
 // ListNamespaces returns a list of all namespaces which are in the cluster
 func ListNamespaces(config NamespaceAPIGetter) ([]string, error) {
+	return ListNamespacesMatching(config, "")
+}
+
+// ListNamespacesMatching returns a list of all namespaces in the cluster whose labels match the label selector.
+// An empty selector matches all namespaces.
+func ListNamespacesMatching(config NamespaceAPIGetter, labelSelector string) ([]string, error) {
 	namespacesAPI := config.GetNamespacesAPI()
-	namespaces, err := namespacesAPI.List(context.TODO(), metaV1.ListOptions{})
+	namespaces, err := namespacesAPI.List(context.TODO(), metaV1.ListOptions{LabelSelector: labelSelector})
 	if err != nil {
 		return make([]string, 0), err
 	}
@@ -25,3 +25,14 @@ func (suite *NamespacesSuite) TestList(c *C) {
 	c.Assert(ns[0], Equals, "default")
 	c.Assert(ns[1], Equals, "other")
 }
+
+func (suite *NamespacesSuite) TestListMatching(c *C) {
+	kubernetesAPI := NewFakeKubernetesAPI()
+	config := NewConfig(kubernetesAPI.Client, NewImage(""), "")
+	kubernetesAPI.NewNamespaceWithLabels("production", map[string]string{"xcnt.io/updates": "enabled"})
+	kubernetesAPI.NewNamespaceWithLabels("staging", map[string]string{"xcnt.io/updates": "disabled"})
+	kubernetesAPI.NewNamespace("kube-system")
+	ns, err := ListNamespacesMatching(config, "xcnt.io/updates=enabled")
+	c.Assert(err, IsNil)
+	c.Assert(ns, DeepEquals, []string{"production"})
+}
@@ -0,0 +1,110 @@
+package updater
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// UpdateUUIDAnnotation is stamped on updated deployments and their pod templates with the uuid of the update.
+	UpdateUUIDAnnotation = "xcnt.io/update-uuid"
+	// UpdateTimeAnnotation is stamped on updated deployments and their pod templates with the RFC 3339 time the
+	// update has been planned at.
+	UpdateTimeAnnotation = "xcnt.io/update-time"
+	// UpdateRequesterAnnotation is stamped on updated deployments and their pod templates with the name of the
+	// principal which requested the update.
+	UpdateRequesterAnnotation = "xcnt.io/update-requester"
+	// PreviousImageAnnotation is stamped on updated deployments and their pod templates with the images the updated
+	// containers ran before the update, separated by commas.
+	PreviousImageAnnotation = "xcnt.io/update-previous-image"
+	// RevisionAnnotation is stamped on updated deployments and their pod templates with the revision, for example the
+	// commit SHA, passed with the update request.
+	RevisionAnnotation = "xcnt.io/update-revision"
+	// ChangeCauseAnnotation is the annotation kubectl rollout history shows as the cause of a revision. It is set on
+	// updated deployments and copied by kubernetes to the replica set of the revision.
+	ChangeCauseAnnotation = "kubernetes.io/change-cause"
+)
+
+// releaseMetadata holds the information about an update which is stamped on the updated deployments.
+type releaseMetadata struct {
+	updateUUID  string
+	time        time.Time
+	requester   string
+	revision    string
+	changeCause string
+	image       string
+}
+
+func newReleaseMetadata(config *Config) *releaseMetadata {
+	return &releaseMetadata{
+		updateUUID:  config.GetUpdateUUID(),
+		time:        time.Now().UTC(),
+		requester:   config.GetRequester(),
+		revision:    config.GetRevision(),
+		changeCause: config.GetChangeCause(),
+		image:       config.GetImage().String(),
+	}
+}
+
+// annotations returns the annotations describing the update of a workload which ran the previous images.
+func (metadata *releaseMetadata) annotations(previousImages []string) map[string]string {
+	annotations := map[string]string{
+		UpdateTimeAnnotation:    metadata.time.Format(time.RFC3339),
+		PreviousImageAnnotation: strings.Join(previousImages, ","),
+	}
+	for key, value := range map[string]string{
+		UpdateUUIDAnnotation:      metadata.updateUUID,
+		UpdateRequesterAnnotation: metadata.requester,
+		RevisionAnnotation:        metadata.revision,
+	} {
+		if len(value) > 0 {
+			annotations[key] = value
+		}
+	}
+	return annotations
+}
+
+// defaultChangeCause describes the update if no change cause has been passed with the request.
+func (metadata *releaseMetadata) defaultChangeCause() string {
+	changeCause := "Update to " + metadata.image
+	if len(metadata.revision) > 0 {
+		changeCause += fmt.Sprintf(" at revision %s", metadata.revision)
+	}
+	if len(metadata.requester) > 0 {
+		changeCause += fmt.Sprintf(" requested by %s", metadata.requester)
+	}
+	if len(metadata.updateUUID) > 0 {
+		changeCause += fmt.Sprintf(" (update %s)", metadata.updateUUID)
+	}
+	return changeCause
+}
+
+// stamp sets the release metadata on the deployment meta and its pod template meta. The change cause is only set on
+// the deployment, as kubernetes copies it from there to the replica set of the revision.
+func (metadata *releaseMetadata) stamp(deploymentMeta *metaV1.ObjectMeta, templateMeta *metaV1.ObjectMeta, previousImages []string) {
+	annotations := metadata.annotations(previousImages)
+	setAnnotations(templateMeta, annotations)
+	changeCause := metadata.changeCause
+	if len(changeCause) == 0 {
+		changeCause = metadata.defaultChangeCause()
+	}
+	annotations[ChangeCauseAnnotation] = changeCause
+	setAnnotations(deploymentMeta, annotations)
+}
+
+func setAnnotations(meta *metaV1.ObjectMeta, annotations map[string]string) {
+	merged := make(map[string]string, len(meta.Annotations)+len(annotations))
+	for key, value := range meta.Annotations {
+		merged[key] = value
+	}
+	for _, key := range []string{UpdateUUIDAnnotation, UpdateRequesterAnnotation, RevisionAnnotation} {
+		delete(merged, key)
+	}
+	for key, value := range annotations {
+		merged[key] = value
+	}
+	meta.Annotations = merged
+}
@@ -0,0 +1,304 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/getsentry/raven-go"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// UpdatePhaseAnnotation selects when a job matching the update is run. Jobs are run as migrations before the
+	// deployments are updated unless the phase is rollback.
+	UpdatePhaseAnnotation = "xcnt.io/update-phase"
+
+	// PhaseUpdate runs the job with the new image before the deployments are updated.
+	PhaseUpdate = "update"
+	// PhaseRollback runs the job with the previous image once the deployments have been rolled back, for example to
+	// revert a database migration.
+	PhaseRollback = "rollback"
+)
+
+// DefaultRollbackJobTimeout is the time a rollback job without an active deadline may run before the rollback fails.
+const DefaultRollbackJobTimeout = 30 * time.Minute
+
+var (
+	// ErrRollbackJobFailed is returned if a job run while rolling back an update failed.
+	ErrRollbackJobFailed = errors.New("The rollback job failed")
+	// ErrRollbackJobTimeout is returned if a job run while rolling back an update did not finish in time.
+	ErrRollbackJobTimeout = errors.New("The rollback job did not finish in time")
+	// ErrRollbackJobsAborted is returned if the update has been aborted while its rollback jobs ran.
+	ErrRollbackJobsAborted = errors.New("The rollback jobs have been aborted")
+)
+
+// PhaseError is returned when planning an update with a job whose update phase annotation is invalid.
+type PhaseError struct {
+	// Namespace is the namespace of the job.
+	Namespace string
+	// Name is the name of the job.
+	Name string
+	// Phase is the invalid update phase.
+	Phase string
+}
+
+// Error returns the description of the invalid update phase.
+func (phaseError *PhaseError) Error() string {
+	return fmt.Sprintf("Job %s/%s: The update phase %q is neither %s nor %s", phaseError.Namespace, phaseError.Name, phaseError.Phase, PhaseUpdate, PhaseRollback)
+}
+
+// PlanConflict marks invalid update phases as conflicts with the workloads.
+func (phaseError *PhaseError) PlanConflict() {}
+
+// CheckUpdatePhases verifies that the update phase annotations of the passed jobs are valid.
+func CheckUpdatePhases(jobs []batchv1.Job) error {
+	for _, job := range jobs {
+		phase, ok := job.Annotations[UpdatePhaseAnnotation]
+		if ok && phase != PhaseUpdate && phase != PhaseRollback {
+			return &PhaseError{Namespace: job.Namespace, Name: job.Name, Phase: phase}
+		}
+	}
+	return nil
+}
+
+// RollbackImageError is returned when planning an update with rollback jobs in a namespace whose updated deployments
+// ran different images before the update, so the image the rollback jobs should run is ambiguous.
+type RollbackImageError struct {
+	// Namespace is the namespace of the rollback jobs.
+	Namespace string
+	// Images are the distinct images the updated deployments of the namespace ran before the update.
+	Images []string
+}
+
+// Error returns the description of the ambiguous previous images.
+func (imageError *RollbackImageError) Error() string {
+	return fmt.Sprintf("Namespace %s: The rollback jobs can not be run as the updated deployments ran different images before the update: %s", imageError.Namespace, strings.Join(imageError.Images, ", "))
+}
+
+// PlanConflict marks ambiguous previous images as conflicts with the workloads.
+func (imageError *RollbackImageError) PlanConflict() {}
+
+// CheckRollbackImages verifies that the updated deployments of every namespace with rollback jobs ran the same image
+// before the update, which the rollback jobs of the namespace are run with.
+func CheckRollbackImages(changes []ContainerChange, rollbackJobs []batchv1.Job) error {
+	previousImages := previousImagesByNamespace(changes)
+	for _, job := range rollbackJobs {
+		if images := previousImages[job.Namespace]; len(images) > 1 {
+			return &RollbackImageError{Namespace: job.Namespace, Images: images}
+		}
+	}
+	return nil
+}
+
+// previousImagesByNamespace returns the distinct images the changed containers of the deployments of every namespace
+// ran before the update.
+func previousImagesByNamespace(changes []ContainerChange) map[string][]string {
+	deploymentChanges := map[string][]ContainerChange{}
+	for _, change := range changes {
+		if change.Kind == "Deployment" {
+			deploymentChanges[change.Namespace] = append(deploymentChanges[change.Namespace], change)
+		}
+	}
+	previousImages := map[string][]string{}
+	for namespace, namespaceChanges := range deploymentChanges {
+		if images := updatedPreviousImages(namespaceChanges); len(images) > 0 {
+			previousImages[namespace] = images
+		}
+	}
+	return previousImages
+}
+
+// splitRollbackJobs separates the jobs run when an update is rolled back from the migration jobs.
+func splitRollbackJobs(jobs []batchv1.Job) ([]batchv1.Job, []batchv1.Job) {
+	migrationJobs := make([]batchv1.Job, 0, len(jobs))
+	rollbackJobs := make([]batchv1.Job, 0)
+	for _, job := range jobs {
+		if job.Annotations[UpdatePhaseAnnotation] == PhaseRollback {
+			rollbackJobs = append(rollbackJobs, job)
+		} else {
+			migrationJobs = append(migrationJobs, job)
+		}
+	}
+	return migrationJobs, rollbackJobs
+}
+
+// RollbackPlan describes how the changes of an update are reverted.
+type RollbackPlan struct {
+	// Targets are the deployments applied by the update.
+	Targets []RollbackTarget
+	// Jobs are the rollback jobs, running the previous image, which are created once the deployments have been
+	// restored.
+	Jobs []batchv1.Job
+}
+
+// RollbackTarget is a deployment touched by an update together with the pod template it ran before the update.
+type RollbackTarget struct {
+	// Deployment is the deployment as it has been applied by the update.
+	Deployment *v1.Deployment
+	// PreviousTemplate is the pod template of the deployment before the update. It is nil for the new color of a
+	// blue-green deployment, which is rolled back by switching its services to the previous color.
+	PreviousTemplate *apiv1.PodTemplateSpec
+}
+
+// Rollback restores the deployments of the rollback plan to the state before their update asynchronously and returns
+// the progress of the rollback. Deployments updated in place get their previous pod template again, the services of
+// blue-green deployments are switched back to the previous color and the new color is removed. The rollback jobs of
+// the plan are run once all deployments have been restored. The rollback succeeds once the rollback jobs succeeded and
+// all restored deployments are ready.
+func Rollback(rollbackPlan *RollbackPlan, kubernetesWrapper KubernetesWrapper) UpdateProgress {
+	deployments := make([]*v1.Deployment, len(rollbackPlan.Targets))
+	for index, target := range rollbackPlan.Targets {
+		deployments[index] = target.Deployment.DeepCopy()
+	}
+	up := &updater{
+		kubernetesWrapper: kubernetesWrapper,
+		updateProgress: &updateProgressConfiguration{
+			jobs:                 []*batchv1.Job{},
+			deployments:          deployments,
+			applied:              make([]bool, len(deployments)),
+			pending:              true,
+			failed:               false,
+			rollbackJobTemplates: rollbackPlan.Jobs,
+		},
+	}
+	go up.runRollback(rollbackPlan.Targets)
+	return up.updateProgress
+}
+
+func (up *updater) runRollback(targets []RollbackTarget) error {
+	updateProgressConfiguration := up.updateProgress
+	log.WithField("numDeployments", len(targets)).Debug("Running rollback")
+	for index, target := range targets {
+		if updateProgressConfiguration.Failed() {
+			return nil
+		}
+		deploymentLogger := log.WithFields(log.Fields{
+			"name":      target.Deployment.Name,
+			"namespace": target.Deployment.Namespace,
+		})
+		deploymentLogger.Debug("Restoring deployment")
+		restoredDeployment, err := up.restore(target)
+		if err != nil {
+			updateProgressConfiguration.fail()
+			deploymentLogger.WithError(err).Error("Error while restoring a deployment")
+			raven.CaptureError(err, nil)
+			return err
+		}
+		updateProgressConfiguration.deployments[index] = restoredDeployment
+		updateProgressConfiguration.applied[index] = true
+	}
+	err := up.runRollbackJobs()
+	if err != nil {
+		updateProgressConfiguration.fail()
+		return err
+	}
+	updateProgressConfiguration.pending = false
+
+	return up.monitorChangesLoop()
+}
+
+// restore brings back the state of the deployment of the target before its update and returns the deployment which
+// runs the previous pods.
+func (up *updater) restore(target RollbackTarget) (*v1.Deployment, error) {
+	deployment := target.Deployment.DeepCopy()
+	deploymentAPI := up.kubernetesWrapper.GetDeploymentAPIFor(deployment.Namespace)
+	if target.PreviousTemplate == nil {
+		err := up.rollbackBlueGreen(deployment)
+		if err != nil {
+			return nil, err
+		}
+		return deploymentAPI.Get(context.TODO(), deployment.Annotations[BlueGreenPreviousAnnotation], metaV1.GetOptions{})
+	}
+	current, err := deploymentAPI.Get(context.TODO(), deployment.Name, metaV1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	target.PreviousTemplate.DeepCopyInto(&current.Spec.Template)
+	return deploymentAPI.Update(context.TODO(), current, metaV1.UpdateOptions{})
+}
+
+// runRollbackJobs creates the rollback jobs of the update and waits for them to finish. The update is not finished
+// before. It returns an error wrapping ErrRollbackJobFailed as soon as one of the jobs failed and one wrapping
+// ErrRollbackJobTimeout if a job did not finish within its active deadline or, without one, the default rollback job
+// timeout. ErrRollbackJobsAborted is returned if the update is aborted while waiting.
+func (up *updater) runRollbackJobs() error {
+	updateProgressConfiguration := up.updateProgress
+	templates := updateProgressConfiguration.rollbackJobTemplates
+	if len(templates) == 0 {
+		return nil
+	}
+	updateProgressConfiguration.setRollingBack(true)
+	defer updateProgressConfiguration.setRollingBack(false)
+	deadlines := make([]time.Time, 0, len(templates))
+	for _, template := range templates {
+		job := instantiateJob(template)
+		jobLogger := log.WithFields(log.Fields{
+			"name":      job.Name,
+			"namespace": job.Namespace,
+			"images":    strings.Join(GetImagesOf(job.Spec.Template.Spec), ", "),
+		})
+		jobLogger.Debug("Creating rollback job")
+		createdJob, err := up.kubernetesWrapper.GetJobAPIFor(job.Namespace).Create(context.TODO(), &job, metaV1.CreateOptions{})
+		if err != nil {
+			jobLogger.WithError(err).Error("Error while creating rollback job")
+			raven.CaptureError(err, nil)
+			return err
+		}
+		updateProgressConfiguration.mutex.Lock()
+		updateProgressConfiguration.rollbackJobs = append(updateProgressConfiguration.rollbackJobs, createdJob)
+		updateProgressConfiguration.mutex.Unlock()
+		deadlines = append(deadlines, time.Now().Add(rollbackJobTimeout(createdJob)))
+	}
+	for {
+		if updateProgressConfiguration.isAborted() {
+			log.WithError(ErrRollbackJobsAborted).Warn("Stopped waiting for the rollback jobs")
+			return ErrRollbackJobsAborted
+		}
+		finished := true
+		for index, job := range updateProgressConfiguration.GetRollbackJobs() {
+			currentJob, err := up.kubernetesWrapper.GetJobAPIFor(job.Namespace).Get(context.TODO(), job.Name, metaV1.GetOptions{})
+			if err == nil {
+				job = currentJob
+				updateProgressConfiguration.mutex.Lock()
+				currentJob.DeepCopyInto(updateProgressConfiguration.rollbackJobs[index])
+				updateProgressConfiguration.mutex.Unlock()
+			}
+			jobLogger := log.WithFields(log.Fields{"name": job.Name, "namespace": job.Namespace})
+			if job.Status.Failed > 0 {
+				err = fmt.Errorf("%w: %s/%s", ErrRollbackJobFailed, job.Namespace, job.Name)
+				jobLogger.WithError(err).Error("Rollback job failed")
+				raven.CaptureError(err, nil)
+				return err
+			}
+			if isJobFinished(job) {
+				continue
+			}
+			if time.Now().After(deadlines[index]) {
+				err = fmt.Errorf("%w: %s/%s", ErrRollbackJobTimeout, job.Namespace, job.Name)
+				jobLogger.WithError(err).Error("Rollback job timed out")
+				raven.CaptureError(err, nil)
+				return err
+			}
+			finished = false
+		}
+		if finished {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// rollbackJobTimeout returns the time the rollback job may run for, its active deadline if it has one.
+func rollbackJobTimeout(job *batchv1.Job) time.Duration {
+	if job.Spec.ActiveDeadlineSeconds != nil && *job.Spec.ActiveDeadlineSeconds > 0 {
+		return time.Duration(*job.Spec.ActiveDeadlineSeconds) * time.Second
+	}
+	return DefaultRollbackJobTimeout
+}
@@ -0,0 +1,103 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+
+	v1 "k8s.io/api/apps/v1"
+)
+
+// RollbackPolicy specifies which deployments are rolled back if an update fails.
+type RollbackPolicy string
+
+const (
+	// RollbackPolicyAnnotation overrides the rollback policy of the update for a deployment.
+	RollbackPolicyAnnotation = "xcnt.io/rollback-policy"
+
+	// RollbackAll rolls back every updated deployment if the update fails.
+	RollbackAll RollbackPolicy = "rollback-all"
+	// RollbackFailedOnly only rolls back the deployments which are not ready or failed their canary, smoke check or
+	// metric analysis.
+	RollbackFailedOnly RollbackPolicy = "rollback-failed-only"
+	// NoRollback keeps the updated deployments for inspection if the update fails.
+	NoRollback RollbackPolicy = "no-rollback"
+)
+
+// ErrInvalidRollbackPolicy is returned if a rollback policy is neither rollback-all, rollback-failed-only nor no-rollback.
+var ErrInvalidRollbackPolicy = errors.New("The rollback policy has to be either rollback-all, rollback-failed-only or no-rollback")
+
+// ParseRollbackPolicy returns the rollback policy with the passed name. An empty name is the rollback-all policy.
+func ParseRollbackPolicy(name string) (RollbackPolicy, error) {
+	switch RollbackPolicy(name) {
+	case "", RollbackAll:
+		return RollbackAll, nil
+	case RollbackFailedOnly, NoRollback:
+		return RollbackPolicy(name), nil
+	}
+	return "", ErrInvalidRollbackPolicy
+}
+
+// RollbackPolicyError is returned when planning an update of a deployment whose rollback policy annotation is invalid.
+type RollbackPolicyError struct {
+	// Namespace is the namespace of the deployment.
+	Namespace string
+	// Name is the name of the deployment.
+	Name string
+	// Policy is the invalid rollback policy.
+	Policy string
+}
+
+// Error returns the description of the invalid rollback policy.
+func (policyError *RollbackPolicyError) Error() string {
+	return fmt.Sprintf("Deployment %s/%s: The rollback policy %q is neither %s, %s nor %s", policyError.Namespace, policyError.Name, policyError.Policy, RollbackAll, RollbackFailedOnly, NoRollback)
+}
+
+// PlanConflict marks invalid rollback policies as conflicts with the workloads.
+func (policyError *RollbackPolicyError) PlanConflict() {}
+
+// CheckRollbackPolicies verifies that the rollback policy annotations of the passed deployments are valid.
+func CheckRollbackPolicies(deployments []v1.Deployment) error {
+	for _, deployment := range deployments {
+		policy, ok := deployment.Annotations[RollbackPolicyAnnotation]
+		if !ok {
+			continue
+		}
+		if _, err := ParseRollbackPolicy(policy); err != nil || len(policy) == 0 {
+			return &RollbackPolicyError{Namespace: deployment.Namespace, Name: deployment.Name, Policy: policy}
+		}
+	}
+	return nil
+}
+
+// rollbackPolicyOf returns the rollback policy of the deployment, which falls back to the default policy of the update
+// without an annotation.
+func rollbackPolicyOf(deployment *v1.Deployment, defaultPolicy RollbackPolicy) RollbackPolicy {
+	if policy, ok := deployment.Annotations[RollbackPolicyAnnotation]; ok {
+		return RollbackPolicy(policy)
+	}
+	if len(defaultPolicy) == 0 {
+		return RollbackAll
+	}
+	return defaultPolicy
+}
+
+// isFailedDeployment returns if the deployment is considered failed by the rollback-failed-only policy. A deployment
+// failed if it is not ready or the error failing the update names it.
+func isFailedDeployment(deployment *v1.Deployment, cause error) bool {
+	if !isDeploymentFinished(deployment) {
+		return true
+	}
+	var canaryError *CanaryError
+	if errors.As(cause, &canaryError) {
+		return canaryError.Namespace == deployment.Namespace && canaryError.Name == deployment.Name
+	}
+	var smokeCheckError *SmokeCheckError
+	if errors.As(cause, &smokeCheckError) {
+		return smokeCheckError.Namespace == deployment.Namespace && smokeCheckError.Name == deployment.Name
+	}
+	var analysisError *AnalysisError
+	if errors.As(cause, &analysisError) {
+		return analysisError.Namespace == deployment.Namespace && analysisError.Name == deployment.Name
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package updater
+
+import (
+	"context"
+	"strconv"
+
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type RollbackPolicySuite struct {
+	kubernetesAPI KubernetesAPI
+	config        *Config
+}
+
+var _ = Suite(&RollbackPolicySuite{})
+
+func (suite *RollbackPolicySuite) SetUpTest(c *C) {
+	suite.kubernetesAPI = NewFakeKubernetesAPI()
+	suite.kubernetesAPI.NewNamespace("default")
+	suite.config = NewConfig(suite.kubernetesAPI.Client, NewImage("xcnt/test:1.1.0"), "stable")
+	suite.config.SetNamespaces([]string{"default"})
+}
+
+// updatedDeployment creates a deployment updated to xcnt/test:1.1.0 whose previous replica set runs xcnt/test:1.0.0,
+// so it can be rolled back.
+func (suite *RollbackPolicySuite) updatedDeployment(c *C, name string, annotations map[string]string) *v1.Deployment {
+	deployment := deploymentNamed("default", name, annotations)
+	previousReplicaSet := GetReplicaSetFor(&deployment)
+	c.Assert(suite.kubernetesAPI.NewReplicaSetIn("default", previousReplicaSet), IsNil)
+	revision, err := strconv.Atoi(previousReplicaSet.Annotations[ReplicaSetRevisionAnnotation])
+	c.Assert(err, IsNil)
+	deployment.Generation = int64(revision + 1)
+	deployment.Spec.Template.Spec.Containers[0].Image = "xcnt/test:1.1.0"
+	c.Assert(suite.kubernetesAPI.NewDeploymentIn("default", deployment), IsNil)
+	return &deployment
+}
+
+func (suite *RollbackPolicySuite) imageOf(c *C, name string) string {
+	deployment, err := suite.config.GetDeploymentAPIFor("default").Get(context.TODO(), name, metaV1.GetOptions{})
+	c.Assert(err, IsNil)
+	return deployment.Spec.Template.Spec.Containers[0].Image
+}
+
+// failedUpdate returns an updater whose update applied the passed deployments with the default rollback policy.
+func (suite *RollbackPolicySuite) failedUpdate(defaultPolicy RollbackPolicy, deployments ...*v1.Deployment) *updater {
+	applied := make([]bool, len(deployments))
+	for index := range applied {
+		applied[index] = true
+	}
+	return &updater{
+		updatePlan:        &updatePlan{rollbackPolicy: defaultPolicy},
+		kubernetesWrapper: suite.config,
+		updateProgress: &updateProgressConfiguration{
+			jobs:        []*batchv1.Job{},
+			deployments: deployments,
+			applied:     applied,
+			pending:     true,
+			failed:      true,
+		},
+	}
+}
+
+func (suite *RollbackPolicySuite) TestParseRollbackPolicy(c *C) {
+	for name, expected := range map[string]RollbackPolicy{
+		"":                     RollbackAll,
+		"rollback-all":         RollbackAll,
+		"rollback-failed-only": RollbackFailedOnly,
+		"no-rollback":          NoRollback,
+	} {
+		policy, err := ParseRollbackPolicy(name)
+		c.Assert(err, IsNil)
+		c.Assert(policy, Equals, expected)
+	}
+	_, err := ParseRollbackPolicy("rollback-some")
+	c.Assert(err, Equals, ErrInvalidRollbackPolicy)
+}
+
+func (suite *RollbackPolicySuite) TestCheckRollbackPolicies(c *C) {
+	c.Assert(CheckRollbackPolicies([]v1.Deployment{
+		deploymentNamed("default", "api", nil),
+		deploymentNamed("default", "worker", map[string]string{RollbackPolicyAnnotation: "no-rollback"}),
+	}), IsNil)
+	for _, policy := range []string{"", "never"} {
+		err := CheckRollbackPolicies([]v1.Deployment{
+			deploymentNamed("default", "worker", map[string]string{RollbackPolicyAnnotation: policy}),
+		})
+		c.Assert(err, DeepEquals, &RollbackPolicyError{Namespace: "default", Name: "worker", Policy: policy})
+	}
+}
+
+func (suite *RollbackPolicySuite) TestPlanRejectsInvalidRollbackPolicy(c *C) {
+	deployment := deploymentNamed("default", "api", map[string]string{UpdateClassifier: "stable", RollbackPolicyAnnotation: "never"})
+	c.Assert(suite.kubernetesAPI.NewDeploymentIn("default", deployment), IsNil)
+	_, err := Plan(suite.config)
+	c.Assert(err, FitsTypeOf, &RollbackPolicyError{})
+}
+
+func (suite *RollbackPolicySuite) TestPlanUsesRollbackPolicyOfConfig(c *C) {
+	deployment := deploymentNamed("default", "api", map[string]string{UpdateClassifier: "stable"})
+	c.Assert(suite.kubernetesAPI.NewDeploymentIn("default", deployment), IsNil)
+	updatePlan, err := Plan(suite.config)
+	c.Assert(err, IsNil)
+	c.Assert(updatePlan.GetRollbackPolicy(), Equals, RollbackAll)
+
+	suite.config.SetRollbackPolicy(NoRollback)
+	updatePlan, err = Plan(suite.config)
+	c.Assert(err, IsNil)
+	c.Assert(updatePlan.GetRollbackPolicy(), Equals, NoRollback)
+}
+
+func (suite *RollbackPolicySuite) TestRollbackAllContinuesPastErrors(c *C) {
+	missing := deploymentNamed("default", "missing", nil)
+	restorable := suite.updatedDeployment(c, "api", nil)
+	up := suite.failedUpdate(RollbackAll, &missing, restorable)
+	up.updateProgress.rollbackJobTemplates = []batchv1.Job{GetJobWith(map[string]string{UpdatePhaseAnnotation: PhaseRollback}, "xcnt/test:1.0.0")}
+
+	c.Assert(up.rollback(nil), Equals, ErrNoReplicaSet)
+	c.Assert(suite.imageOf(c, "api"), Equals, "xcnt/test:1.0.0")
+	c.Assert(up.updateProgress.GetRollbackJobs(), HasLen, 0)
+}
+
+func (suite *RollbackPolicySuite) TestNoRollbackKeepsDeployments(c *C) {
+	kept := suite.updatedDeployment(c, "api", nil)
+	annotated := suite.updatedDeployment(c, "worker", map[string]string{RollbackPolicyAnnotation: string(RollbackAll)})
+	up := suite.failedUpdate(NoRollback, kept, annotated)
+	up.updateProgress.rollbackJobTemplates = []batchv1.Job{GetJobWith(map[string]string{UpdatePhaseAnnotation: PhaseRollback}, "xcnt/test:1.0.0")}
+
+	c.Assert(up.rollback(nil), IsNil)
+	c.Assert(suite.imageOf(c, "api"), Equals, "xcnt/test:1.1.0")
+	c.Assert(suite.imageOf(c, "worker"), Equals, "xcnt/test:1.0.0")
+	c.Assert(up.updateProgress.GetRollbackJobs(), HasLen, 0)
+}
+
+func (suite *RollbackPolicySuite) TestRollbackFailedOnly(c *C) {
+	ready := suite.updatedDeployment(c, "api", nil)
+	ready.Status.ObservedGeneration = ready.Generation
+	ready.Status.ReadyReplicas = 1
+	notReady := suite.updatedDeployment(c, "worker", nil)
+	up := suite.failedUpdate(RollbackFailedOnly, ready, notReady)
+
+	c.Assert(up.rollback(nil), IsNil)
+	c.Assert(suite.imageOf(c, "api"), Equals, "xcnt/test:1.1.0")
+	c.Assert(suite.imageOf(c, "worker"), Equals, "xcnt/test:1.0.0")
+
+	c.Assert(up.rollback(&SmokeCheckError{Namespace: "default", Name: "api", Reason: "500 Internal Server Error"}), IsNil)
+	c.Assert(suite.imageOf(c, "api"), Equals, "xcnt/test:1.0.0")
+}
@@ -0,0 +1,206 @@
+package updater
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type RollbackSuite struct {
+	kubernetesAPI KubernetesAPI
+	config        *Config
+}
+
+var _ = Suite(&RollbackSuite{})
+
+func (suite *RollbackSuite) SetUpTest(c *C) {
+	suite.kubernetesAPI = NewFakeKubernetesAPI()
+	suite.kubernetesAPI.NewNamespace("default")
+	suite.config = NewConfig(suite.kubernetesAPI.Client, NewImage("xcnt/test:1.1.0"), "stable")
+	suite.config.SetNamespaces([]string{"default"})
+}
+
+func (suite *RollbackSuite) getDeployment(c *C, name string) *v1.Deployment {
+	deployment, err := suite.config.GetDeploymentAPIFor("default").Get(context.TODO(), name, metaV1.GetOptions{})
+	c.Assert(err, IsNil)
+	return deployment
+}
+
+func (suite *RollbackSuite) waitForFinish(progress UpdateProgress) {
+	for i := 0; i < 30 && !progress.Finished(); i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func (suite *RollbackSuite) TestRollbackRestoresPreviousTemplate(c *C) {
+	deployment := deploymentNamed("default", "api", map[string]string{UpdateClassifier: "stable"})
+	deployment.Status.ReadyReplicas = 1
+	c.Assert(suite.kubernetesAPI.NewDeploymentIn("default", deployment), IsNil)
+	updatePlan, err := Plan(suite.config)
+	c.Assert(err, IsNil)
+	progress := Update(updatePlan, suite.config)
+	suite.waitForFinish(progress)
+	c.Assert(progress.Successful(), Equals, true)
+	c.Assert(suite.getDeployment(c, "api").Spec.Template.Spec.Containers[0].Image, Equals, "xcnt/test:1.1.0")
+
+	rollbackPlan := progress.GetRollbackPlan()
+	c.Assert(rollbackPlan.Targets, HasLen, 1)
+	c.Assert(rollbackPlan.Targets[0].Deployment.Name, Equals, "api")
+	c.Assert(rollbackPlan.Targets[0].PreviousTemplate.Spec.Containers[0].Image, Equals, "xcnt/test:1.0.0")
+	c.Assert(rollbackPlan.Jobs, HasLen, 0)
+
+	rollback := Rollback(rollbackPlan, suite.config)
+	suite.waitForFinish(rollback)
+	c.Assert(rollback.Successful(), Equals, true)
+	c.Assert(rollback.GetDeployments(), HasLen, 1)
+	c.Assert(rollback.GetRollbackPlan().Targets, HasLen, 0)
+	c.Assert(suite.getDeployment(c, "api").Spec.Template.Spec.Containers[0].Image, Equals, "xcnt/test:1.0.0")
+}
+
+func (suite *RollbackSuite) TestRollbackFailsForMissingDeployment(c *C) {
+	deployment := deploymentNamed("default", "api", map[string]string{UpdateClassifier: "stable"})
+	rollback := Rollback(&RollbackPlan{Targets: []RollbackTarget{{Deployment: &deployment, PreviousTemplate: &deployment.Spec.Template}}}, suite.config)
+	suite.waitForFinish(rollback)
+	c.Assert(rollback.Finished(), Equals, true)
+	c.Assert(rollback.Failed(), Equals, true)
+}
+
+// rollbackJob waits for the rollback job instantiated from the template to be created and returns it.
+func (suite *RollbackSuite) rollbackJob(c *C, template batchv1.Job) *batchv1.Job {
+	for i := 0; i < 30; i++ {
+		jobs, err := suite.config.GetJobAPIFor("default").List(context.TODO(), metaV1.ListOptions{})
+		c.Assert(err, IsNil)
+		for _, job := range jobs.Items {
+			if job.Name != template.Name && strings.HasPrefix(job.Name, template.Name) {
+				return &job
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	c.Fatal("The rollback job has not been created")
+	return nil
+}
+
+func (suite *RollbackSuite) TestCheckUpdatePhases(c *C) {
+	jobs := []batchv1.Job{
+		GetJobWith(map[string]string{UpdatePhaseAnnotation: PhaseUpdate}, "xcnt/test:1.0.0"),
+		GetJobWith(map[string]string{UpdatePhaseAnnotation: PhaseRollback}, "xcnt/test:1.0.0"),
+		GetJobWith(nil, "xcnt/test:1.0.0"),
+	}
+	c.Assert(CheckUpdatePhases(jobs), IsNil)
+	invalid := GetJobWith(map[string]string{UpdatePhaseAnnotation: "rollbak"}, "xcnt/test:1.0.0")
+	err := CheckUpdatePhases(append(jobs, invalid))
+	c.Assert(err, FitsTypeOf, &PhaseError{})
+	c.Assert(err, ErrorMatches, `Job default/`+invalid.Name+`: The update phase "rollbak" is neither update nor rollback`)
+}
+
+func (suite *RollbackSuite) TestPlanSeparatesRollbackJobs(c *C) {
+	deployment := deploymentNamed("default", "api", map[string]string{UpdateClassifier: "stable"})
+	c.Assert(suite.kubernetesAPI.NewDeploymentIn("default", deployment), IsNil)
+	migration := GetJobDefaultAnnotation("xcnt/test:1.0.0")
+	c.Assert(suite.kubernetesAPI.NewJobIn("default", migration), IsNil)
+	rollbackJob := GetJobWith(map[string]string{UpdateClassifier: "stable", UpdatePhaseAnnotation: PhaseRollback}, "xcnt/test:1.1.0")
+	c.Assert(suite.kubernetesAPI.NewJobIn("default", rollbackJob), IsNil)
+
+	updatePlan, err := Plan(suite.config)
+	c.Assert(err, IsNil)
+	c.Assert(updatePlan.GetToCreateJobs(), HasLen, 1)
+	c.Assert(strings.HasPrefix(updatePlan.GetToCreateJobs()[0].Name, migration.Name), Equals, true)
+	c.Assert(updatePlan.GetRollbackJobs(), HasLen, 1)
+	c.Assert(updatePlan.GetRollbackJobs()[0].Spec.Template.Spec.Containers[0].Image, Equals, "xcnt/test:1.0.0")
+}
+
+func (suite *RollbackSuite) TestFailedUpdateRunsRollbackJobs(c *C) {
+	migration := GetJobDefaultAnnotation("xcnt/test:1.1.0")
+	template := GetJobWith(map[string]string{UpdatePhaseAnnotation: PhaseRollback}, "xcnt/test:1.0.0")
+	progress := Update(&updatePlan{jobs: []batchv1.Job{migration}, rollbackJobs: []batchv1.Job{template}}, suite.config)
+	time.Sleep(100 * time.Millisecond)
+	migration.Status.Failed = 1
+	c.Assert(suite.kubernetesAPI.UpdateJobIn("default", &migration), IsNil)
+
+	rollbackJob := suite.rollbackJob(c, template)
+	c.Assert(rollbackJob.Spec.Template.Spec.Containers[0].Image, Equals, "xcnt/test:1.0.0")
+	c.Assert(rollbackJob.Spec.Template.Labels[jobNameLabel], Equals, rollbackJob.Name)
+	c.Assert(progress.Failed(), Equals, true)
+	time.Sleep(150 * time.Millisecond)
+	c.Assert(progress.Finished(), Equals, false)
+	c.Assert(progress.GetRollbackJobs(), HasLen, 1)
+
+	rollbackJob.Status.Succeeded = 1
+	c.Assert(suite.kubernetesAPI.UpdateJobIn("default", rollbackJob), IsNil)
+	suite.waitForFinish(progress)
+	c.Assert(progress.Finished(), Equals, true)
+	c.Assert(progress.Successful(), Equals, false)
+	c.Assert(progress.GetRollbackJobs()[0].Status.Succeeded, Equals, int32(1))
+}
+
+func (suite *RollbackSuite) TestRollbackFailsForFailedRollbackJob(c *C) {
+	deployment := deploymentNamed("default", "api", map[string]string{UpdateClassifier: "stable"})
+	deployment.Status.ReadyReplicas = 1
+	c.Assert(suite.kubernetesAPI.NewDeploymentIn("default", deployment), IsNil)
+	template := GetJobWith(map[string]string{UpdatePhaseAnnotation: PhaseRollback}, "xcnt/test:1.0.0")
+	rollbackPlan := &RollbackPlan{
+		Targets: []RollbackTarget{{Deployment: &deployment, PreviousTemplate: &deployment.Spec.Template}},
+		Jobs:    []batchv1.Job{template},
+	}
+	rollback := Rollback(rollbackPlan, suite.config)
+	rollbackJob := suite.rollbackJob(c, template)
+	time.Sleep(150 * time.Millisecond)
+	c.Assert(rollback.Finished(), Equals, false)
+
+	rollbackJob.Status.Failed = 1
+	c.Assert(suite.kubernetesAPI.UpdateJobIn("default", rollbackJob), IsNil)
+	suite.waitForFinish(rollback)
+	c.Assert(rollback.Failed(), Equals, true)
+	c.Assert(rollback.GetRollbackJobs(), HasLen, 1)
+}
+
+func (suite *RollbackSuite) startRollbackWithJob(c *C, template batchv1.Job) (UpdateProgress, *batchv1.Job) {
+	deployment := deploymentNamed("default", "api", map[string]string{UpdateClassifier: "stable"})
+	deployment.Status.ReadyReplicas = 1
+	c.Assert(suite.kubernetesAPI.NewDeploymentIn("default", deployment), IsNil)
+	rollbackPlan := &RollbackPlan{
+		Targets: []RollbackTarget{{Deployment: &deployment, PreviousTemplate: &deployment.Spec.Template}},
+		Jobs:    []batchv1.Job{template},
+	}
+	rollback := Rollback(rollbackPlan, suite.config)
+	return rollback, suite.rollbackJob(c, template)
+}
+
+func (suite *RollbackSuite) TestRollbackFailsForTimedOutRollbackJob(c *C) {
+	template := GetJobWith(map[string]string{UpdatePhaseAnnotation: PhaseRollback}, "xcnt/test:1.0.0")
+	activeDeadlineSeconds := int64(1)
+	template.Spec.ActiveDeadlineSeconds = &activeDeadlineSeconds
+	rollback, _ := suite.startRollbackWithJob(c, template)
+	time.Sleep(500 * time.Millisecond)
+	c.Assert(rollback.Finished(), Equals, false)
+
+	suite.waitForFinish(rollback)
+	c.Assert(rollback.Finished(), Equals, true)
+	c.Assert(rollback.Failed(), Equals, true)
+}
+
+func (suite *RollbackSuite) TestAbortStopsWaitingForRollbackJobs(c *C) {
+	template := GetJobWith(map[string]string{UpdatePhaseAnnotation: PhaseRollback}, "xcnt/test:1.0.0")
+	rollback, _ := suite.startRollbackWithJob(c, template)
+	time.Sleep(150 * time.Millisecond)
+	c.Assert(rollback.Finished(), Equals, false)
+
+	rollback.Abort()
+	suite.waitForFinish(rollback)
+	c.Assert(rollback.Finished(), Equals, true)
+	c.Assert(rollback.Failed(), Equals, true)
+}
+
+func (suite *RollbackSuite) TestRollbackJobTimeout(c *C) {
+	job := GetJobWith(nil, "xcnt/test:1.0.0")
+	c.Assert(rollbackJobTimeout(&job), Equals, DefaultRollbackJobTimeout)
+	activeDeadlineSeconds := int64(90)
+	job.Spec.ActiveDeadlineSeconds = &activeDeadlineSeconds
+	c.Assert(rollbackJobTimeout(&job), Equals, 90*time.Second)
+}
@@ -0,0 +1,234 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/apps/v1"
+)
+
+const (
+	// SmokeCheckURLAnnotation is the in-cluster URL, for example http://api.default.svc:8080/health, which is requested
+	// once the deployment is ready.
+	SmokeCheckURLAnnotation = "xcnt.io/smoke-check-url"
+	// SmokeCheckServiceAnnotation is the service, port and path, for example api:8080/health, which is requested once the
+	// deployment is ready. The service is looked up in the namespace of the deployment. It is ignored if a smoke check URL
+	// is set.
+	SmokeCheckServiceAnnotation = "xcnt.io/smoke-check-service"
+	// SmokeCheckStatusAnnotation is the status code the smoke check has to respond with. It defaults to 200.
+	SmokeCheckStatusAnnotation = "xcnt.io/smoke-check-status"
+	// SmokeCheckAttemptsAnnotation is the number of times the smoke check is requested before the update fails. It
+	// defaults to 3.
+	SmokeCheckAttemptsAnnotation = "xcnt.io/smoke-check-attempts"
+	// SmokeCheckIntervalAnnotation is the duration, for example 10s, between two attempts of the smoke check. It defaults
+	// to 5s.
+	SmokeCheckIntervalAnnotation = "xcnt.io/smoke-check-interval"
+	// SmokeCheckTimeoutAnnotation is the duration, for example 2s, a single attempt of the smoke check may take. It
+	// defaults to 5s.
+	SmokeCheckTimeoutAnnotation = "xcnt.io/smoke-check-timeout"
+
+	defaultSmokeCheckStatus   = http.StatusOK
+	defaultSmokeCheckAttempts = 3
+	defaultSmokeCheckInterval = 5 * time.Second
+	defaultSmokeCheckTimeout  = 5 * time.Second
+	maxSmokeCheckBodySize     = 64 * 1024
+)
+
+// SmokeCheck is a HTTP request which has to succeed once a deployment is ready.
+type SmokeCheck struct {
+	// URL is the requested URL.
+	URL string
+	// Status is the status code the response has to have.
+	Status int
+	// Attempts is the number of requests before the smoke check fails.
+	Attempts int
+	// Interval is the time between two attempts.
+	Interval time.Duration
+	// Timeout is the time a single attempt may take.
+	Timeout time.Duration
+}
+
+// SmokeCheckError is returned if the smoke check annotations of a deployment are invalid or its smoke check failed.
+type SmokeCheckError struct {
+	// Namespace is the namespace of the deployment.
+	Namespace string
+	// Name is the name of the deployment.
+	Name string
+	// Reason describes the problem with the smoke check.
+	Reason string
+}
+
+// Error returns the description of the failed smoke check.
+func (smokeCheckError *SmokeCheckError) Error() string {
+	return fmt.Sprintf("Smoke check of deployment %s/%s: %s", smokeCheckError.Namespace, smokeCheckError.Name, smokeCheckError.Reason)
+}
+
+// PlanConflict marks invalid and failed smoke checks as conflicts with the workloads.
+func (smokeCheckError *SmokeCheckError) PlanConflict() {}
+
+// CheckSmokeChecks verifies that the smoke check annotations of the passed deployments are valid.
+func CheckSmokeChecks(deployments []v1.Deployment) error {
+	for _, deployment := range deployments {
+		_, err := smokeCheckOf(&deployment)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// smokeCheckOf returns the smoke check configured by the annotations of the deployment or nil if it has none.
+func smokeCheckOf(deployment *v1.Deployment) (*SmokeCheck, error) {
+	newError := func(reason string) error {
+		return &SmokeCheckError{Namespace: deployment.Namespace, Name: deployment.Name, Reason: reason}
+	}
+	annotations := deployment.Annotations
+	checkURL := strings.TrimSpace(annotations[SmokeCheckURLAnnotation])
+	if len(checkURL) == 0 {
+		service := strings.TrimSpace(annotations[SmokeCheckServiceAnnotation])
+		if len(service) == 0 {
+			return nil, nil
+		}
+		var err error
+		checkURL, err = serviceURL(service, deployment.Namespace)
+		if err != nil {
+			return nil, newError(err.Error())
+		}
+	}
+	parsedURL, err := url.Parse(checkURL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || len(parsedURL.Host) == 0 {
+		return nil, newError(fmt.Sprintf("The URL %q is not an absolute http or https URL", checkURL))
+	}
+	smokeCheck := &SmokeCheck{
+		URL:      checkURL,
+		Status:   defaultSmokeCheckStatus,
+		Attempts: defaultSmokeCheckAttempts,
+		Interval: defaultSmokeCheckInterval,
+		Timeout:  defaultSmokeCheckTimeout,
+	}
+	if value, ok := annotations[SmokeCheckStatusAnnotation]; ok {
+		smokeCheck.Status, err = strconv.Atoi(value)
+		if err != nil || smokeCheck.Status < 100 || smokeCheck.Status > 599 {
+			return nil, newError(fmt.Sprintf("The status %q is not a HTTP status code", value))
+		}
+	}
+	if value, ok := annotations[SmokeCheckAttemptsAnnotation]; ok {
+		smokeCheck.Attempts, err = strconv.Atoi(value)
+		if err != nil || smokeCheck.Attempts < 1 {
+			return nil, newError(fmt.Sprintf("The attempts %q are not a positive number", value))
+		}
+	}
+	if value, ok := annotations[SmokeCheckIntervalAnnotation]; ok {
+		smokeCheck.Interval, err = time.ParseDuration(value)
+		if err != nil || smokeCheck.Interval < 0 {
+			return nil, newError(fmt.Sprintf("The interval %q is not a duration", value))
+		}
+	}
+	if value, ok := annotations[SmokeCheckTimeoutAnnotation]; ok {
+		smokeCheck.Timeout, err = time.ParseDuration(value)
+		if err != nil || smokeCheck.Timeout <= 0 {
+			return nil, newError(fmt.Sprintf("The timeout %q is not a positive duration", value))
+		}
+	}
+	return smokeCheck, nil
+}
+
+// serviceURL returns the in-cluster URL of a service reference in the format service:port/path.
+func serviceURL(service string, namespace string) (string, error) {
+	path := "/"
+	if index := strings.Index(service, "/"); index >= 0 {
+		service, path = service[:index], service[index:]
+	}
+	name, port, ok := strings.Cut(service, ":")
+	if !ok || len(name) == 0 {
+		return "", fmt.Errorf("The service %q has to be in the format service:port/path", service)
+	}
+	if number, err := strconv.Atoi(port); err != nil || number < 1 || number > 65535 {
+		return "", fmt.Errorf("The port %q of the service is not a port number", port)
+	}
+	return fmt.Sprintf("http://%s.%s.svc:%s%s", name, namespace, port, path), nil
+}
+
+// smokeCheckWave runs the smoke checks of the deployments of the wave once they are ready. It returns a
+// SmokeCheckError for the first deployment whose smoke check failed.
+func (up *updater) smokeCheckWave(wave Wave) error {
+	type check struct {
+		deployment *v1.Deployment
+		smokeCheck *SmokeCheck
+	}
+	checks := make([]check, 0)
+	for _, index := range wave.Deployments {
+		deployment := up.updateProgress.deployments[index]
+		// The annotations have been checked while planning the update.
+		smokeCheck, _ := smokeCheckOf(deployment)
+		if smokeCheck != nil {
+			checks = append(checks, check{deployment: deployment, smokeCheck: smokeCheck})
+		}
+	}
+	if len(checks) == 0 {
+		return nil
+	}
+	err := up.waitForDeployments()
+	if err != nil || up.updateProgress.Failed() {
+		return err
+	}
+	for _, check := range checks {
+		err = up.runSmokeCheck(check.deployment, check.smokeCheck)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (up *updater) runSmokeCheck(deployment *v1.Deployment, smokeCheck *SmokeCheck) error {
+	var reason string
+	for attempt := 1; attempt <= smokeCheck.Attempts; attempt++ {
+		if attempt > 1 && !up.sleepUnlessAborted(smokeCheck.Interval) {
+			return nil
+		}
+		reason = requestSmokeCheck(smokeCheck)
+		if len(reason) == 0 {
+			return nil
+		}
+		log.WithFields(log.Fields{
+			"namespace": deployment.Namespace,
+			"name":      deployment.Name,
+			"attempt":   attempt,
+			"reason":    reason,
+		}).Debug("Smoke check failed")
+	}
+	return &SmokeCheckError{
+		Namespace: deployment.Namespace,
+		Name:      deployment.Name,
+		Reason:    fmt.Sprintf("%s after %d attempts", reason, smokeCheck.Attempts),
+	}
+}
+
+// requestSmokeCheck requests the URL of the smoke check once and returns why it failed or an empty string if it
+// succeeded.
+func requestSmokeCheck(smokeCheck *SmokeCheck) string {
+	ctx, cancel := context.WithTimeout(context.Background(), smokeCheck.Timeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, smokeCheck.URL, nil)
+	if err != nil {
+		return err.Error()
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err.Error()
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, maxSmokeCheckBodySize))
+	if response.StatusCode != smokeCheck.Status {
+		return fmt.Sprintf("GET %s responded with %d instead of %d", smokeCheck.URL, response.StatusCode, smokeCheck.Status)
+	}
+	return ""
+}
@@ -0,0 +1,152 @@
+package updater
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type SmokeCheckSuite struct {
+	kubernetesAPI KubernetesAPI
+	config        *Config
+	server        *httptest.Server
+	requests      int32
+	failures      int32
+	updatePlan    *updatePlan
+}
+
+var _ = Suite(&SmokeCheckSuite{})
+
+func (suite *SmokeCheckSuite) SetUpTest(c *C) {
+	suite.requests = 0
+	suite.failures = 0
+	suite.server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		c.Check(request.URL.Path, Equals, "/health")
+		if atomic.AddInt32(&suite.requests, 1) <= atomic.LoadInt32(&suite.failures) {
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writer.WriteHeader(http.StatusNoContent)
+	}))
+	suite.kubernetesAPI = NewFakeKubernetesAPI()
+	suite.kubernetesAPI.NewNamespace("default")
+	suite.config = NewConfig(suite.kubernetesAPI.Client, NewImage("xcnt/test:1.1.0"), "stable")
+	deployment := deploymentNamed("default", "api", map[string]string{
+		UpdateClassifier:             "stable",
+		SmokeCheckURLAnnotation:      suite.server.URL + "/health",
+		SmokeCheckStatusAnnotation:   "204",
+		SmokeCheckAttemptsAnnotation: "3",
+		SmokeCheckIntervalAnnotation: "10ms",
+	})
+	deployment.Status.ReadyReplicas = 1
+	c.Assert(suite.kubernetesAPI.NewDeploymentIn("default", deployment), IsNil)
+	updated := *deployment.DeepCopy()
+	updated.Spec.Template.Spec.Containers[0].Image = "xcnt/test:1.1.0"
+	suite.updatePlan = &updatePlan{
+		deployments: []v1.Deployment{updated},
+		jobs:        []batchv1.Job{},
+	}
+}
+
+func (suite *SmokeCheckSuite) TearDownTest(c *C) {
+	suite.server.Close()
+}
+
+func (suite *SmokeCheckSuite) waitForFinish(progress UpdateProgress) {
+	for i := 0; i < 40 && !progress.Finished(); i++ {
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func (suite *SmokeCheckSuite) TestSmokeCheckOf(c *C) {
+	deployment := deploymentNamed("prod", "api", nil)
+	smokeCheck, err := smokeCheckOf(&deployment)
+	c.Assert(err, IsNil)
+	c.Assert(smokeCheck, IsNil)
+
+	deployment = deploymentNamed("prod", "api", map[string]string{SmokeCheckServiceAnnotation: "api:8080/health"})
+	smokeCheck, err = smokeCheckOf(&deployment)
+	c.Assert(err, IsNil)
+	c.Assert(smokeCheck, DeepEquals, &SmokeCheck{
+		URL:      "http://api.prod.svc:8080/health",
+		Status:   http.StatusOK,
+		Attempts: 3,
+		Interval: 5 * time.Second,
+		Timeout:  5 * time.Second,
+	})
+
+	deployment = deploymentNamed("prod", "api", map[string]string{
+		SmokeCheckURLAnnotation:     "https://api.example.com/ready",
+		SmokeCheckServiceAnnotation: "api:8080",
+		SmokeCheckTimeoutAnnotation: "1s",
+	})
+	smokeCheck, err = smokeCheckOf(&deployment)
+	c.Assert(err, IsNil)
+	c.Assert(smokeCheck.URL, Equals, "https://api.example.com/ready")
+	c.Assert(smokeCheck.Timeout, Equals, time.Second)
+}
+
+func (suite *SmokeCheckSuite) TestCheckSmokeChecks(c *C) {
+	invalid := map[string]string{
+		SmokeCheckURLAnnotation:      "api/health",
+		SmokeCheckStatusAnnotation:   "ok",
+		SmokeCheckAttemptsAnnotation: "0",
+		SmokeCheckIntervalAnnotation: "soon",
+		SmokeCheckTimeoutAnnotation:  "0s",
+	}
+	for annotation, value := range invalid {
+		annotations := map[string]string{SmokeCheckURLAnnotation: "http://api:8080/health", annotation: value}
+		err := CheckSmokeChecks([]v1.Deployment{deploymentNamed("default", "api", annotations)})
+		c.Assert(err, FitsTypeOf, &SmokeCheckError{}, Commentf("%s: %s", annotation, value))
+	}
+	err := CheckSmokeChecks([]v1.Deployment{deploymentNamed("default", "api", map[string]string{SmokeCheckServiceAnnotation: "api"})})
+	c.Assert(err, ErrorMatches, `Smoke check of deployment default/api: The service "api" has to be in the format service:port/path`)
+	err = CheckSmokeChecks([]v1.Deployment{deploymentNamed("default", "api", map[string]string{SmokeCheckServiceAnnotation: "api:http/health"})})
+	c.Assert(err, ErrorMatches, `Smoke check of deployment default/api: The port "http" of the service is not a port number`)
+	err = CheckSmokeChecks([]v1.Deployment{deploymentNamed("default", "api", map[string]string{SmokeCheckServiceAnnotation: "api:8080/health"})})
+	c.Assert(err, IsNil)
+}
+
+func (suite *SmokeCheckSuite) TestPassingSmokeCheck(c *C) {
+	progress := Update(suite.updatePlan, suite.config)
+	suite.waitForFinish(progress)
+	c.Assert(progress.Successful(), Equals, true)
+	c.Assert(atomic.LoadInt32(&suite.requests), Equals, int32(1))
+}
+
+func (suite *SmokeCheckSuite) TestRetrySmokeCheck(c *C) {
+	suite.failures = 2
+	progress := Update(suite.updatePlan, suite.config)
+	suite.waitForFinish(progress)
+	c.Assert(progress.Successful(), Equals, true)
+	c.Assert(atomic.LoadInt32(&suite.requests), Equals, int32(3))
+}
+
+func (suite *SmokeCheckSuite) TestRollBackFailedSmokeCheck(c *C) {
+	current, err := suite.config.GetDeploymentAPIFor("default").Get(context.TODO(), "api", metaV1.GetOptions{})
+	c.Assert(err, IsNil)
+	suite.kubernetesAPI.NewReplicaSetIn("default", GetReplicaSetFor(current))
+	lastRS := GetReplicaSetFor(&suite.updatePlan.deployments[0])
+	revision, err := strconv.Atoi(lastRS.Annotations[ReplicaSetRevisionAnnotation])
+	c.Assert(err, IsNil)
+	suite.kubernetesAPI.NewReplicaSetIn("default", lastRS)
+	suite.updatePlan.deployments[0].Generation = int64(revision)
+	suite.updatePlan.deployments[0].Status.ObservedGeneration = int64(revision)
+
+	suite.failures = 3
+	progress := Update(suite.updatePlan, suite.config)
+	suite.waitForFinish(progress)
+	c.Assert(progress.Failed(), Equals, true)
+	c.Assert(atomic.LoadInt32(&suite.requests), Equals, int32(3))
+	current, err = suite.config.GetDeploymentAPIFor("default").Get(context.TODO(), "api", metaV1.GetOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(current.Spec.Template.Spec.Containers[0].Image, Equals, "xcnt/test:1.0.0")
+}
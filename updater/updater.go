@@ -5,12 +5,14 @@ import (
 	"errors"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/getsentry/raven-go"
 	log "github.com/sirupsen/logrus"
 	v1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
+	apiv1 "k8s.io/api/core/v1"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -25,8 +27,25 @@ var (
 type updateProgressConfiguration struct {
 	jobs        []*batchv1.Job
 	deployments []*v1.Deployment
-	failed      bool
-	finishTime  *time.Time
+	// applied marks the deployments which have been updated. Deployments of later waves are not applied until the
+	// previous waves are ready.
+	applied []bool
+	// pending is set until all waves have been applied, their blue-green deployments have been switched, their smoke
+	// checks passed and their metrics have been analyzed.
+	pending    bool
+	failed     bool
+	finishTime *time.Time
+	// rollbackTargets records the applied deployments with the pod templates they ran before the update.
+	rollbackTargets []RollbackTarget
+	// rollbackJobTemplates are the jobs created when the update is rolled back, rollbackJobs the created ones.
+	rollbackJobTemplates []batchv1.Job
+	rollbackJobs         []*batchv1.Job
+	// rollingBack is set while the rollback jobs run. The update is not finished before they finished.
+	rollingBack bool
+	// aborted is set once the update has been aborted from the outside, which also stops running rollback jobs.
+	aborted bool
+	// mutex guards the rollback jobs, rollingBack and aborted, which are changed while the progress is read.
+	mutex sync.Mutex
 }
 
 // GetJobs returns a list of jobs which are included in the update progress
@@ -46,11 +65,14 @@ func (up *updateProgressConfiguration) Failed() bool {
 
 // Successful returns true if the complete update progress has run through.
 func (up *updateProgressConfiguration) Successful() bool {
-	return len(up.GetJobs()) == up.FinishedJobsCount() && len(up.GetDeployments()) == up.UpdatedDeploymentsCount()
+	return !up.pending && len(up.GetJobs()) == up.FinishedJobsCount() && len(up.GetDeployments()) == up.UpdatedDeploymentsCount()
 }
 
 // Finished returns if the update progress has run through succesfully or unsuccessfully
 func (up *updateProgressConfiguration) Finished() bool {
+	if up.isRollingBack() {
+		return false
+	}
 	if up.Failed() || up.Successful() {
 		up.setFinishTimeIfNecessary()
 		return true
@@ -67,9 +89,35 @@ func (up *updateProgressConfiguration) setFinishTimeIfNecessary() {
 
 // Abort cancels the run of this specific udpater.
 func (up *updateProgressConfiguration) Abort() {
+	up.mutex.Lock()
+	up.aborted = true
+	up.mutex.Unlock()
+	up.fail()
+}
+
+// fail marks the update as failed. Unlike Abort, the rollback jobs of the update are still run.
+func (up *updateProgressConfiguration) fail() {
 	up.failed = true
 }
 
+func (up *updateProgressConfiguration) isAborted() bool {
+	up.mutex.Lock()
+	defer up.mutex.Unlock()
+	return up.aborted
+}
+
+func (up *updateProgressConfiguration) isRollingBack() bool {
+	up.mutex.Lock()
+	defer up.mutex.Unlock()
+	return up.rollingBack
+}
+
+func (up *updateProgressConfiguration) setRollingBack(rollingBack bool) {
+	up.mutex.Lock()
+	defer up.mutex.Unlock()
+	up.rollingBack = rollingBack
+}
+
 // FinishedJobsCount returns how many jobs have been finished
 func (up *updateProgressConfiguration) FinishedJobsCount() int {
 	count := 0
@@ -84,8 +132,8 @@ func (up *updateProgressConfiguration) FinishedJobsCount() int {
 // UpdatedDeploymentsCount returns the amount of deployments which update has been finished
 func (up *updateProgressConfiguration) UpdatedDeploymentsCount() int {
 	count := 0
-	for _, deployment := range up.GetDeployments() {
-		if isDeploymentFinished(deployment) {
+	for index, deployment := range up.GetDeployments() {
+		if up.applied[index] && isDeploymentFinished(deployment) {
 			count++
 		}
 	}
@@ -97,6 +145,26 @@ func (up *updateProgressConfiguration) FinishTime() *time.Time {
 	return up.finishTime
 }
 
+// GetRollbackPlan returns the deployments applied by the update with the pod templates they ran before and the jobs
+// run when the update is rolled back.
+func (up *updateProgressConfiguration) GetRollbackPlan() *RollbackPlan {
+	return &RollbackPlan{
+		Targets: append([]RollbackTarget{}, up.rollbackTargets...),
+		Jobs:    up.rollbackJobTemplates,
+	}
+}
+
+// GetRollbackJobs returns the jobs which have been created while rolling back the update.
+func (up *updateProgressConfiguration) GetRollbackJobs() []*batchv1.Job {
+	up.mutex.Lock()
+	defer up.mutex.Unlock()
+	rollbackJobs := make([]*batchv1.Job, 0, len(up.rollbackJobs))
+	for _, job := range up.rollbackJobs {
+		rollbackJobs = append(rollbackJobs, job.DeepCopy())
+	}
+	return rollbackJobs
+}
+
 // Update executes the passed update plan against the given kubernetes wrapper asynchronously
 func Update(updatePlan UpdatePlan, kubernetesWrapper KubernetesWrapper) UpdateProgress {
 	up := &updater{
@@ -118,18 +186,24 @@ func (up *updater) Update() UpdateProgress {
 	toCreateJobs := updatePlan.GetToCreateJobs()
 	jobs := make([]*batchv1.Job, len(toCreateJobs))
 	for index, job := range toCreateJobs {
+		job := job
 		jobs[index] = &job
 	}
 	toApplyDeployments := updatePlan.GetToApplyDeployments()
 	deployments := make([]*v1.Deployment, len(toApplyDeployments))
 	for index, deployment := range toApplyDeployments {
+		deployment := deployment
 		deployments[index] = &deployment
 	}
 
 	updateProgress := &updateProgressConfiguration{
 		jobs:        jobs,
 		deployments: deployments,
+		applied:     make([]bool, len(deployments)),
+		pending:     true,
 		failed:      false,
+		// The jobs are planned for the update, they do not have to be copied.
+		rollbackJobTemplates: updatePlan.GetRollbackJobs(),
 	}
 	up.updateProgress = updateProgress
 	go up.runUpdate()
@@ -156,33 +230,125 @@ func (up *updater) runUpdate() error {
 		createdJob, err := kubernetesWrapper.GetJobAPIFor(job.Namespace).Create(context.TODO(), &job, metaV1.CreateOptions{})
 		updateProgressConfiguration.jobs[index] = createdJob
 		if err != nil {
-			updateProgressConfiguration.Abort()
+			updateProgressConfiguration.fail()
 			jobLogger.WithError(err).Error("Error while creating job")
 			raven.CaptureError(err, nil)
 			return err
 		}
 	}
 
-	for index, deployment := range deployments {
-		deploymentLogger := log.WithFields(log.Fields{
-			"name":      deployment.Name,
-			"namespace": deployment.Namespace,
-			"images":    strings.Join(GetImagesOf(deployment.Spec.Template.Spec), ", "),
-		})
-		deploymentLogger.Debug("Updating deployment")
-		updatedDeployment, err := kubernetesWrapper.GetDeploymentAPIFor(deployment.Namespace).Update(context.TODO(), &deployment, metaV1.UpdateOptions{})
+	for waveIndex, wave := range updatePlan.GetWaves() {
+		if waveIndex > 0 {
+			err := up.waitForDeployments()
+			if err != nil || updateProgressConfiguration.Failed() {
+				return err
+			}
+			log.WithField("wave", waveIndex+1).Debug("Previous wave is ready, updating the next wave")
+		}
+		var canaries []*v1.Deployment
+		if canary := updatePlan.GetCanary(); canary != nil {
+			var err error
+			canaries, err = up.runCanaries(deployments, wave, canary)
+			if err != nil {
+				updateProgressConfiguration.fail()
+				log.WithField("wave", waveIndex+1).WithError(err).Error("Canary failed, rolling back the update")
+				raven.CaptureError(err, nil)
+				up.rollback(err)
+				return err
+			}
+			if updateProgressConfiguration.Failed() {
+				return nil
+			}
+			log.WithField("wave", waveIndex+1).Debug("Canaries are healthy, promoting the wave")
+		}
+		for _, index := range wave.Deployments {
+			deployment := deployments[index]
+			deploymentLogger := log.WithFields(log.Fields{
+				"name":      deployment.Name,
+				"namespace": deployment.Namespace,
+				"images":    strings.Join(GetImagesOf(deployment.Spec.Template.Spec), ", "),
+			})
+			deploymentLogger.Debug("Updating deployment")
+			var updatedDeployment *v1.Deployment
+			var previousTemplate *apiv1.PodTemplateSpec
+			var err error
+			if isBlueGreen(&deployment) {
+				updatedDeployment, err = up.applyBlueGreen(&deployment)
+			} else {
+				updatedDeployment, previousTemplate, err = up.applyRolling(&deployment)
+			}
+			if err != nil {
+				up.deleteCanaries(canaries)
+				up.rollback(err)
+				deploymentLogger.WithError(err).Error("Error while updating a deployment")
+				raven.CaptureError(err, nil)
+				return err
+			}
+			updateProgressConfiguration.deployments[index] = updatedDeployment
+			updateProgressConfiguration.applied[index] = true
+			updateProgressConfiguration.rollbackTargets = append(updateProgressConfiguration.rollbackTargets, RollbackTarget{
+				Deployment:       updatedDeployment,
+				PreviousTemplate: previousTemplate,
+			})
+		}
+		up.deleteCanaries(canaries)
+		err := up.switchBlueGreen(wave)
+		if err == nil && !updateProgressConfiguration.Failed() {
+			err = up.smokeCheckWave(wave)
+		}
+		if err == nil && !updateProgressConfiguration.Failed() {
+			err = up.analyzeWave(wave)
+		}
 		if err != nil {
-			up.rollback()
-			deploymentLogger.WithError(err).Error("Error while updating a deployment")
+			updateProgressConfiguration.fail()
+			log.WithField("wave", waveIndex+1).WithError(err).Error("Error while verifying the updated wave, rolling back the update")
 			raven.CaptureError(err, nil)
+			up.rollback(err)
 			return err
 		}
-		updateProgressConfiguration.deployments[index] = updatedDeployment
+		if updateProgressConfiguration.Failed() {
+			return nil
+		}
 	}
+	updateProgressConfiguration.pending = false
 
 	return up.monitorChangesLoop()
 }
 
+// applyRolling updates the deployment in place and returns it together with the pod template it ran before.
+func (up *updater) applyRolling(deployment *v1.Deployment) (*v1.Deployment, *apiv1.PodTemplateSpec, error) {
+	deploymentAPI := up.kubernetesWrapper.GetDeploymentAPIFor(deployment.Namespace)
+	current, err := deploymentAPI.Get(context.TODO(), deployment.Name, metaV1.GetOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	updatedDeployment, err := deploymentAPI.Update(context.TODO(), deployment, metaV1.UpdateOptions{})
+	return updatedDeployment, &current.Spec.Template, err
+}
+
+// waitForDeployments monitors the update until all applied deployments are ready or the update failed.
+func (up *updater) waitForDeployments() error {
+	for {
+		err := up.monitorChanges()
+		if err != nil {
+			return err
+		}
+		if up.updateProgress.Failed() || up.appliedDeploymentsReady() {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func (up *updater) appliedDeploymentsReady() bool {
+	for index, deployment := range up.updateProgress.GetDeployments() {
+		if up.updateProgress.applied[index] && !isDeploymentFinished(deployment) {
+			return false
+		}
+	}
+	return true
+}
+
 func (up *updater) monitorChangesLoop() error {
 	var err error
 	for ; ; err = up.monitorChanges() {
@@ -211,7 +377,10 @@ func (up *updater) monitorChanges() error {
 
 func (up *updater) monitorDeployments() error {
 	kubernetesAPI := up.kubernetesWrapper
-	for _, deployment := range up.updateProgress.GetDeployments() {
+	for index, deployment := range up.updateProgress.GetDeployments() {
+		if !up.updateProgress.applied[index] {
+			continue
+		}
 		currentDeployment, err := kubernetesAPI.GetDeploymentAPIFor(deployment.Namespace).Get(context.TODO(), deployment.Name, metaV1.GetOptions{})
 		if err != nil {
 			continue
@@ -230,25 +399,55 @@ func (up *updater) monitorJobs() error {
 			continue
 		}
 		if currentJob.Status.Failed > 0 {
-			status.Abort()
-			return up.rollback()
+			status.fail()
+			return up.rollback(nil)
 		}
 		currentJob.DeepCopyInto(job)
 	}
 	return nil
 }
 
-func (up *updater) rollback() error {
-	for _, deployment := range up.updateProgress.GetDeployments() {
+// rollback reverts the applied deployments of the failed update according to their rollback policy. The cause is the
+// error which failed the update, it decides which deployments failed for the rollback-failed-only policy. A deployment
+// which can not be rolled back does not stop the rollback of the others, the first error is returned. The rollback
+// jobs are only run once every applied deployment has been rolled back, as the kept ones still run the new image.
+func (up *updater) rollback(cause error) error {
+	var firstErr error
+	complete := true
+	for index, deployment := range up.updateProgress.GetDeployments() {
+		if !up.updateProgress.applied[index] {
+			continue
+		}
+		deploymentLogger := log.WithFields(log.Fields{
+			"namespace": deployment.Namespace,
+			"name":      deployment.Name,
+		})
+		policy := rollbackPolicyOf(deployment, up.updatePlan.GetRollbackPolicy())
+		if policy == NoRollback || (policy == RollbackFailedOnly && !isFailedDeployment(deployment, cause)) {
+			deploymentLogger.WithField("rollbackPolicy", string(policy)).Info("Keeping the updated deployment")
+			complete = false
+			continue
+		}
 		err := up.rollbackDeployment(deployment)
 		if err != nil {
-			return err
+			deploymentLogger.WithError(err).Error("Error while rolling back a deployment")
+			raven.CaptureError(err, nil)
+			complete = false
+			if firstErr == nil {
+				firstErr = err
+			}
 		}
 	}
-	return nil
+	if !complete {
+		return firstErr
+	}
+	return up.runRollbackJobs()
 }
 
 func (up *updater) rollbackDeployment(deployment *v1.Deployment) error {
+	if isBlueGreen(deployment) {
+		return up.rollbackBlueGreen(deployment)
+	}
 	log.WithFields(log.Fields{
 		"namespace": deployment.Namespace,
 		"type":      "deployment",
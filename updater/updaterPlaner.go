@@ -7,6 +7,7 @@ import (
 	v1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	apiv1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 const (
@@ -36,17 +37,89 @@ func Plan(config *Config) (UpdatePlan, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = CheckUpdatePhases(jobs)
+	if err != nil {
+		return nil, err
+	}
+	jobs, rollbackJobs := splitRollbackJobs(jobs)
+
+	if !config.IsForced() {
+		err = CheckVersions(config, deployments, jobs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = CheckUpdateOrder(deployments)
+	if err != nil {
+		return nil, err
+	}
+	err = CheckUpdateStrategies(deployments)
+	if err != nil {
+		return nil, err
+	}
+	err = CheckSmokeChecks(deployments)
+	if err != nil {
+		return nil, err
+	}
+	err = CheckAnalysisAnnotations(deployments)
+	if err != nil {
+		return nil, err
+	}
+	err = CheckRollbackPolicies(deployments)
+	if err != nil {
+		return nil, err
+	}
 
 	updatePlaner := &UpdatePlaner{
-		JobLister:        func() []batchv1.Job { return jobs },
-		DeploymentLister: func() []v1.Deployment { return deployments },
+		JobLister:         func() []batchv1.Job { return jobs },
+		RollbackJobLister: func() []batchv1.Job { return rollbackJobs },
+		DeploymentLister:  func() []v1.Deployment { return deployments },
 	}
-	return updatePlaner.Plan(config), nil
+	updatePlan := updatePlaner.Plan(config)
+	err = CheckRollbackImages(updatePlan.GetContainerChanges(), rollbackJobs)
+	if err != nil {
+		return nil, err
+	}
+	return updatePlan, nil
 }
 
 type updatePlan struct {
-	deployments []v1.Deployment
-	jobs        []batchv1.Job
+	deployments      []v1.Deployment
+	jobs             []batchv1.Job
+	rollbackJobs     []batchv1.Job
+	containerChanges []ContainerChange
+	waves            []Wave
+	canary           *Canary
+	analysis         *Analysis
+	rollbackPolicy   RollbackPolicy
+}
+
+// GetAnalysis returns the metric analysis of the deployments once they are ready or nil if they are not analyzed.
+func (updatePlan *updatePlan) GetAnalysis() *Analysis {
+	return updatePlan.analysis
+}
+
+// GetCanary returns the canary configuration the deployments are updated with or nil if they are updated directly.
+func (updatePlan *updatePlan) GetCanary() *Canary {
+	return updatePlan.canary
+}
+
+// GetWaves returns the groups of deployments in the order they are updated in. Without waves, all deployments are
+// updated together.
+func (updatePlan *updatePlan) GetWaves() []Wave {
+	if updatePlan.waves != nil {
+		return updatePlan.waves
+	}
+	wave := Wave{Deployments: make([]int, 0, len(updatePlan.deployments))}
+	for index := range updatePlan.deployments {
+		wave.Deployments = append(wave.Deployments, index)
+	}
+	return []Wave{wave}
+}
+
+// GetContainerChanges returns the containers of all workloads in the plan which run the updated image.
+func (updatePlan *updatePlan) GetContainerChanges() []ContainerChange {
+	return updatePlan.containerChanges
 }
 
 // GetToCreateJobs returns a slice of jobs which should be created for the deployments to run.
@@ -60,32 +133,71 @@ func (updatePlan *updatePlan) GetToApplyDeployments() []v1.Deployment {
 	return updatePlan.deployments
 }
 
+// GetRollbackJobs returns the jobs, running the previous image, which are created when the update is rolled back.
+func (updatePlan *updatePlan) GetRollbackJobs() []batchv1.Job {
+	return updatePlan.rollbackJobs
+}
+
+// GetRollbackPolicy returns the rollback policy of the deployments without a rollback policy annotation.
+func (updatePlan *updatePlan) GetRollbackPolicy() RollbackPolicy {
+	return updatePlan.rollbackPolicy
+}
+
 // UpdatePlaner provides a configuration struct to generate planed upgrades for specific deployments and jobs.
 type UpdatePlaner struct {
 	// JobLister is a function which returns all jobs which should be used for update migrations
 	JobLister func() []batchv1.Job
+	// RollbackJobLister is a function which returns all jobs which should be run when the update is rolled back. If
+	// nil, no jobs are run on rollbacks.
+	RollbackJobLister func() []batchv1.Job
 	// DeploymentLister is a function which returns all deployments which should be adjusted for the update to run through.
 	DeploymentLister func() []v1.Deployment
 	config           *Config
+	metadata         *releaseMetadata
+	containerChanges []ContainerChange
 }
 
 // Plan returns the update plan which needs to be applied for the configuration to work
 func (updatePlaner *UpdatePlaner) Plan(config *Config) UpdatePlan {
 	updatePlaner.config = config
-	deployments := updatePlaner.updatedDeployments()
+	updatePlaner.metadata = newReleaseMetadata(config)
+	updatePlaner.containerChanges = make([]ContainerChange, 0)
+	currentDeployments := updatePlaner.DeploymentLister()
+	deployments := updatePlaner.updatedDeployments(currentDeployments)
+	rollbackJobs := updatePlaner.rollbackJobs()
 	jobs := updatePlaner.migrationJobs()
+	// An invalid update order has been rejected by CheckUpdateOrder, if it is ignored all deployments are updated
+	// together. The order refers to the current names of the deployments, which differ from the planned ones for
+	// blue-green deployments.
+	waves, _ := planWaves(currentDeployments)
 	return &updatePlan{
-		deployments: deployments,
-		jobs:        jobs,
+		deployments:      deployments,
+		jobs:             jobs,
+		rollbackJobs:     rollbackJobs,
+		containerChanges: updatePlaner.containerChanges,
+		waves:            waves,
+		canary:           config.GetCanary(),
+		analysis:         config.GetAnalysis(),
+		rollbackPolicy:   config.GetRollbackPolicy(),
 	}
 }
 
-func (updatePlaner *UpdatePlaner) updatedDeployments() []v1.Deployment {
-	deployments := updatePlaner.DeploymentLister()
+func (updatePlaner *UpdatePlaner) updatedDeployments(deployments []v1.Deployment) []v1.Deployment {
 	updatedDeployments := make([]v1.Deployment, len(deployments))
 	for index, deployment := range deployments {
 		newDeployment := *deployment.DeepCopy()
-		newDeployment.Spec.Template.Spec = updatePlaner.updatePodSpec(newDeployment.Spec.Template.Spec)
+		changeCount := len(updatePlaner.containerChanges)
+		newDeployment.Spec.Template.Spec = updatePlaner.updatePodSpec(
+			newWorkloadReference("Deployment", &newDeployment.ObjectMeta),
+			newDeployment.Spec.Template.Spec,
+		)
+		previousImages := updatedPreviousImages(updatePlaner.containerChanges[changeCount:])
+		if len(previousImages) > 0 {
+			updatePlaner.metadata.stamp(&newDeployment.ObjectMeta, &newDeployment.Spec.Template.ObjectMeta, previousImages)
+		}
+		if isBlueGreen(&deployment) {
+			newDeployment = blueGreenDeploymentOf(deployment, newDeployment)
+		}
 		updatedDeployments[index] = newDeployment
 	}
 	return updatedDeployments
@@ -101,6 +213,47 @@ func (updatePlaner *UpdatePlaner) migrationJobs() []batchv1.Job {
 }
 
 func (updatePlaner *UpdatePlaner) createMigrationJob(job batchv1.Job) batchv1.Job {
+	clonedJob := instantiateJob(job)
+	clonedJob.Spec.Template.Spec = updatePlaner.updatePodSpec(
+		newWorkloadReference("Job", &clonedJob.ObjectMeta),
+		clonedJob.Spec.Template.Spec,
+	)
+	return clonedJob
+}
+
+// rollbackJobs returns the rollback jobs with the containers running the image of the update switched to the image
+// the updated deployments of their namespace ran before. The jobs keep the name of their template, they are named when
+// they are created. If no deployment of the namespace of a job is changed by the update, nothing is rolled back there
+// and the job is not planned. Ambiguous previous images are rejected by CheckRollbackImages.
+func (updatePlaner *UpdatePlaner) rollbackJobs() []batchv1.Job {
+	rollbackJobs := make([]batchv1.Job, 0)
+	if updatePlaner.RollbackJobLister == nil {
+		return rollbackJobs
+	}
+	previousImages := previousImagesByNamespace(updatePlaner.containerChanges)
+	image := updatePlaner.config.GetImage()
+	for _, job := range updatePlaner.RollbackJobLister() {
+		namespaceImages := previousImages[job.Namespace]
+		if len(namespaceImages) == 0 {
+			continue
+		}
+		rollbackJob := *job.DeepCopy()
+		podSpec := &rollbackJob.Spec.Template.Spec
+		podSpec.Containers = copyContainers(podSpec.Containers)
+		podSpec.InitContainers = copyContainers(podSpec.InitContainers)
+		filter := NewContainerFilter(rollbackJob.Annotations)
+		for _, container := range getPodContainers(podSpec) {
+			if image.EqualsImage(*container.Image) && filter.Allows(container.Name) {
+				*container.Image = namespaceImages[0]
+			}
+		}
+		rollbackJobs = append(rollbackJobs, rollbackJob)
+	}
+	return rollbackJobs
+}
+
+// instantiateJob returns a copy of the template job which can be created under a newly generated name.
+func instantiateJob(job batchv1.Job) batchv1.Job {
 	clonedJob := *job.DeepCopy()
 	clonedJob.SetUID("")
 	clonedJob.SelfLink = ""
@@ -117,27 +270,71 @@ func (updatePlaner *UpdatePlaner) createMigrationJob(job batchv1.Job) batchv1.Jo
 	labels[jobNameLabel] = clonedJob.Name
 	clonedJob.Spec.Template.ObjectMeta.SetLabels(labels)
 	clonedJob.Spec.Selector = nil
-	clonedJob.Spec.Template.Spec = updatePlaner.updatePodSpec(clonedJob.Spec.Template.Spec)
 	return clonedJob
 }
 
-func (updatePlaner *UpdatePlaner) updatePodSpec(podSpec apiv1.PodSpec) apiv1.PodSpec {
-	podSpec.Containers = updatePlaner.updateContainers(podSpec.Containers)
-	podSpec.InitContainers = updatePlaner.updateContainers(podSpec.InitContainers)
-	return podSpec
-}
+func (updatePlaner *UpdatePlaner) updatePodSpec(workload workloadReference, podSpec apiv1.PodSpec) apiv1.PodSpec {
+	podSpec.Containers = copyContainers(podSpec.Containers)
+	podSpec.InitContainers = copyContainers(podSpec.InitContainers)
 
-func (updatePlaner *UpdatePlaner) updateContainers(toUpdateContainers []apiv1.Container) []apiv1.Container {
 	image := updatePlaner.config.GetImage()
-	if toUpdateContainers == nil {
-		return make([]apiv1.Container, 0)
+	for _, container := range getPodContainers(&podSpec) {
+		if !image.EqualsImage(*container.Image) {
+			continue
+		}
+		change := ContainerChange{
+			Kind:          workload.kind,
+			Namespace:     workload.namespace,
+			Name:          workload.name,
+			Container:     container.Name,
+			ContainerType: container.Type,
+			PreviousImage: *container.Image,
+			Image:         *container.Image,
+			Excluded:      !workload.filter.Allows(container.Name),
+		}
+		if !change.Excluded {
+			*container.Image = updatePlaner.config.GetDeployedImage().String()
+			change.Image = *container.Image
+		}
+		updatePlaner.containerChanges = append(updatePlaner.containerChanges, change)
 	}
-	containers := make([]apiv1.Container, len(toUpdateContainers))
-	for index, container := range toUpdateContainers {
-		if image.EqualsImage(container.Image) {
-			container.Image = image.String()
+	return podSpec
+}
+
+// updatedPreviousImages returns the distinct images the changed containers ran before the update. Containers already
+// running the image are not considered changed, so stamping the metadata does not trigger an additional rollout.
+func updatedPreviousImages(changes []ContainerChange) []string {
+	previousImages := make([]string, 0)
+	seen := map[string]bool{}
+	for _, change := range changes {
+		if change.Excluded || change.PreviousImage == change.Image || seen[change.PreviousImage] {
+			continue
 		}
-		containers[index] = container
+		seen[change.PreviousImage] = true
+		previousImages = append(previousImages, change.PreviousImage)
 	}
+	return previousImages
+}
+
+func copyContainers(toCopyContainers []apiv1.Container) []apiv1.Container {
+	containers := make([]apiv1.Container, len(toCopyContainers))
+	copy(containers, toCopyContainers)
 	return containers
 }
+
+// workloadReference identifies the workload a pod specification belongs to while planning.
+type workloadReference struct {
+	kind      string
+	namespace string
+	name      string
+	filter    *ContainerFilter
+}
+
+func newWorkloadReference(kind string, meta *metaV1.ObjectMeta) workloadReference {
+	return workloadReference{
+		kind:      kind,
+		namespace: meta.GetNamespace(),
+		name:      meta.GetName(),
+		filter:    NewContainerFilter(meta.GetAnnotations()),
+	}
+}
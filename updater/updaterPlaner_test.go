@@ -33,7 +33,6 @@ func (suite *UpdatePlanerSuite) SetUpSuite(c *C) {
 		JobLister:        func() []batchv1.Job { return suite.jobs },
 		DeploymentLister: func() []v1.Deployment { return suite.deployments },
 	}
-	suite.deployments = append(suite.deployments)
 }
 
 func (suite *UpdatePlanerSuite) GetVerifiedDeployment(c *C) v1.Deployment {
@@ -114,3 +113,141 @@ func (suite *UpdatePlanerSuite) TestPlanFunction(c *C) {
 	c.Assert(len(plan.GetToApplyDeployments()), Equals, 0)
 	c.Assert(len(plan.GetToCreateJobs()), Equals, 0)
 }
+
+func (suite *UpdatePlanerSuite) TestPlanStampsReleaseMetadata(c *C) {
+	config := NewConfig(suite.config.GetClientset(), NewImage("xcnt/test:1.0.0"), "stable")
+	config.SetUpdateUUID("b3d0c1f4-6f5e-4a55-9a8e-2f3c1d8b9e10")
+	config.SetRequester("ci")
+	config.SetRevision("4e1c2a9")
+	updatePlan := suite.updatePlaner.Plan(config)
+	deployment := updatePlan.GetToApplyDeployments()[0]
+	for _, annotations := range []map[string]string{deployment.Annotations, deployment.Spec.Template.Annotations} {
+		c.Assert(annotations[UpdateUUIDAnnotation], Equals, "b3d0c1f4-6f5e-4a55-9a8e-2f3c1d8b9e10")
+		c.Assert(annotations[UpdateRequesterAnnotation], Equals, "ci")
+		c.Assert(annotations[RevisionAnnotation], Equals, "4e1c2a9")
+		c.Assert(annotations[PreviousImageAnnotation], Equals, "xcnt/test:0.9.9")
+		c.Assert(annotations[UpdateTimeAnnotation], Not(Equals), "")
+	}
+	c.Assert(deployment.Annotations[UpdateClassifier], Equals, "stable")
+	c.Assert(deployment.Annotations[ChangeCauseAnnotation], Equals,
+		"Update to xcnt/test:1.0.0 at revision 4e1c2a9 requested by ci (update b3d0c1f4-6f5e-4a55-9a8e-2f3c1d8b9e10)")
+	_, ok := deployment.Spec.Template.Annotations[ChangeCauseAnnotation]
+	c.Assert(ok, IsFalse)
+	c.Assert(suite.deployments[0].Annotations[UpdateUUIDAnnotation], Equals, "")
+}
+
+func (suite *UpdatePlanerSuite) TestPlanUsesPassedChangeCause(c *C) {
+	config := NewConfig(suite.config.GetClientset(), NewImage("xcnt/test:1.0.0"), "stable")
+	config.SetChangeCause("Release 1.0.0")
+	deployment := suite.updatePlaner.Plan(config).GetToApplyDeployments()[0]
+	c.Assert(deployment.Annotations[ChangeCauseAnnotation], Equals, "Release 1.0.0")
+	_, ok := deployment.Annotations[UpdateUUIDAnnotation]
+	c.Assert(ok, IsFalse)
+}
+
+func (suite *UpdatePlanerSuite) TestPlanDoesNotStampUnchangedDeployments(c *C) {
+	config := NewConfig(suite.config.GetClientset(), NewImage("xcnt/test:0.9.9"), "stable")
+	deployment := suite.updatePlaner.Plan(config).GetToApplyDeployments()[0]
+	_, ok := deployment.Annotations[UpdateTimeAnnotation]
+	c.Assert(ok, IsFalse)
+	_, ok = deployment.Annotations[ChangeCauseAnnotation]
+	c.Assert(ok, IsFalse)
+}
+
+func (suite *UpdatePlanerSuite) TestPlanRollbackJobs(c *C) {
+	rollbackJob := GetJobWith(map[string]string{UpdateClassifier: "stable", UpdatePhaseAnnotation: PhaseRollback}, "xcnt/test:1.0.0", "xcnt/tmp:1.0.0")
+	updatePlaner := &UpdatePlaner{
+		JobLister:         func() []batchv1.Job { return suite.jobs },
+		RollbackJobLister: func() []batchv1.Job { return []batchv1.Job{rollbackJob} },
+		DeploymentLister:  func() []v1.Deployment { return suite.deployments },
+	}
+	updatePlan := updatePlaner.Plan(suite.config)
+	rollbackJobs := updatePlan.GetRollbackJobs()
+	c.Assert(rollbackJobs, HasLen, 1)
+	c.Assert(rollbackJobs[0].Name, Equals, rollbackJob.Name)
+	c.Assert(rollbackJobs[0].Spec.Template.Spec.Containers[0].Image, Equals, "xcnt/test:0.9.9")
+	c.Assert(rollbackJobs[0].Spec.Template.Spec.Containers[1].Image, Equals, "xcnt/tmp:1.0.0")
+	c.Assert(rollbackJob.Spec.Template.Spec.Containers[0].Image, Equals, "xcnt/test:1.0.0")
+	c.Assert(updatePlan.GetToCreateJobs(), HasLen, 1)
+	for _, change := range updatePlan.GetContainerChanges() {
+		c.Assert(change.Name, Not(Equals), rollbackJob.Name)
+	}
+}
+
+func (suite *UpdatePlanerSuite) TestPlanNoRollbackJobsWithoutChangedDeployments(c *C) {
+	deployment := GetDeploymentDefaultAnnotation("xcnt/test:1.0.0")
+	rollbackJob := GetJobWith(map[string]string{UpdateClassifier: "stable", UpdatePhaseAnnotation: PhaseRollback}, "xcnt/test:1.0.0")
+	updatePlaner := &UpdatePlaner{
+		JobLister:         func() []batchv1.Job { return []batchv1.Job{} },
+		RollbackJobLister: func() []batchv1.Job { return []batchv1.Job{rollbackJob} },
+		DeploymentLister:  func() []v1.Deployment { return []v1.Deployment{deployment} },
+	}
+	c.Assert(updatePlaner.Plan(suite.config).GetRollbackJobs(), HasLen, 0)
+}
+
+func (suite *UpdatePlanerSuite) TestPlanRollbackJobsWithPreviousImageOfNamespace(c *C) {
+	production := GetDeploymentDefaultAnnotation("xcnt/test:0.9.8")
+	production.Namespace = "production"
+	staging := GetDeploymentDefaultAnnotation("xcnt/test:0.9.9")
+	staging.Namespace = "staging"
+	productionJob := GetJobWith(map[string]string{UpdateClassifier: "stable", UpdatePhaseAnnotation: PhaseRollback}, "xcnt/test:1.0.0")
+	productionJob.Namespace = "production"
+	stagingJob := GetJobWith(map[string]string{UpdateClassifier: "stable", UpdatePhaseAnnotation: PhaseRollback}, "xcnt/test:1.0.0")
+	stagingJob.Namespace = "staging"
+	unchangedJob := GetJobWith(map[string]string{UpdateClassifier: "stable", UpdatePhaseAnnotation: PhaseRollback}, "xcnt/test:1.0.0")
+	updatePlaner := &UpdatePlaner{
+		JobLister:         func() []batchv1.Job { return []batchv1.Job{} },
+		RollbackJobLister: func() []batchv1.Job { return []batchv1.Job{productionJob, stagingJob, unchangedJob} },
+		DeploymentLister:  func() []v1.Deployment { return []v1.Deployment{production, staging} },
+	}
+	updatePlan := updatePlaner.Plan(suite.config)
+	rollbackJobs := updatePlan.GetRollbackJobs()
+	c.Assert(rollbackJobs, HasLen, 2)
+	c.Assert(rollbackJobs[0].Namespace, Equals, "production")
+	c.Assert(rollbackJobs[0].Spec.Template.Spec.Containers[0].Image, Equals, "xcnt/test:0.9.8")
+	c.Assert(rollbackJobs[1].Namespace, Equals, "staging")
+	c.Assert(rollbackJobs[1].Spec.Template.Spec.Containers[0].Image, Equals, "xcnt/test:0.9.9")
+	c.Assert(CheckRollbackImages(updatePlan.GetContainerChanges(), []batchv1.Job{productionJob, stagingJob, unchangedJob}), IsNil)
+}
+
+func (suite *UpdatePlanerSuite) TestCheckRollbackImagesRejectsAmbiguousPreviousImages(c *C) {
+	api := GetDeploymentDefaultAnnotation("xcnt/test:0.9.8")
+	worker := GetDeploymentDefaultAnnotation("xcnt/test:0.9.9")
+	worker.Name = "worker"
+	rollbackJob := GetJobWith(map[string]string{UpdateClassifier: "stable", UpdatePhaseAnnotation: PhaseRollback}, "xcnt/test:1.0.0")
+	updatePlaner := &UpdatePlaner{
+		JobLister:         func() []batchv1.Job { return []batchv1.Job{} },
+		RollbackJobLister: func() []batchv1.Job { return []batchv1.Job{rollbackJob} },
+		DeploymentLister:  func() []v1.Deployment { return []v1.Deployment{api, worker} },
+	}
+	updatePlan := updatePlaner.Plan(suite.config)
+	err := CheckRollbackImages(updatePlan.GetContainerChanges(), []batchv1.Job{rollbackJob})
+	c.Assert(err, DeepEquals, &RollbackImageError{Namespace: "default", Images: []string{"xcnt/test:0.9.8", "xcnt/test:0.9.9"}})
+	c.Assert(err, ErrorMatches, "Namespace default: The rollback jobs can not be run as the updated deployments ran different images before the update: xcnt/test:0.9.8, xcnt/test:0.9.9")
+	c.Assert(CheckRollbackImages(updatePlan.GetContainerChanges(), []batchv1.Job{}), IsNil)
+}
+
+func (suite *UpdatePlanerSuite) TestCheckRollbackImagesIgnoresEphemeralContainers(c *C) {
+	api := GetDeploymentDefaultAnnotation("xcnt/test:0.9.8")
+	api.Spec.Template.Spec.EphemeralContainers = []apiv1.EphemeralContainer{
+		{EphemeralContainerCommon: apiv1.EphemeralContainerCommon{Name: "debug", Image: "xcnt/test:0.9.9"}},
+	}
+	rollbackJob := GetJobWith(map[string]string{UpdateClassifier: "stable", UpdatePhaseAnnotation: PhaseRollback}, "xcnt/test:1.0.0")
+	updatePlaner := &UpdatePlaner{
+		JobLister:         func() []batchv1.Job { return []batchv1.Job{} },
+		RollbackJobLister: func() []batchv1.Job { return []batchv1.Job{rollbackJob} },
+		DeploymentLister:  func() []v1.Deployment { return []v1.Deployment{api} },
+	}
+	updatePlan := updatePlaner.Plan(suite.config)
+	c.Assert(CheckRollbackImages(updatePlan.GetContainerChanges(), []batchv1.Job{rollbackJob}), IsNil)
+	c.Assert(updatePlan.GetRollbackJobs()[0].Spec.Template.Spec.Containers[0].Image, Equals, "xcnt/test:0.9.8")
+}
+
+func (suite *UpdatePlanerSuite) TestPlanPinnedImage(c *C) {
+	config := NewConfig(NewFakeKubernetesAPI().Client, NewImage("xcnt/test:1.0.0"), "stable")
+	config.SetImageDigest("sha256:abcdef")
+	updatePlan := suite.updatePlaner.Plan(config)
+	deployment := updatePlan.GetToApplyDeployments()[0]
+	c.Assert(deployment.Spec.Template.Spec.Containers[1].Image, Equals, "xcnt/test:1.0.0@sha256:abcdef")
+	c.Assert(updatePlan.GetToCreateJobs()[0].Spec.Template.Spec.Containers[1].Image, Equals, "xcnt/test:1.0.0@sha256:abcdef")
+}
@@ -2,7 +2,8 @@ package updater
 
 import apiv1 "k8s.io/api/core/v1"
 
-// GetImagesOf returns all string images of the specified podspec.
+// GetImagesOf returns all string images of the specified podspec. Ephemeral containers are left out, as they are not
+// updated.
 func GetImagesOf(podSpec apiv1.PodSpec) []string {
 	images := map[string]bool{}
 	images = fillStringMap(images, getContainerImagesOf(podSpec.Containers))
@@ -10,6 +11,36 @@ func GetImagesOf(podSpec apiv1.PodSpec) []string {
 	return stringMapToSlice(images)
 }
 
+const (
+	// ContainerTypeContainer identifies a regular container of a pod.
+	ContainerTypeContainer = "container"
+	// ContainerTypeInitContainer identifies an init container of a pod.
+	ContainerTypeInitContainer = "initContainer"
+)
+
+// podContainer references a container of any type inside of a pod specification.
+type podContainer struct {
+	Type  string
+	Name  string
+	Image *string
+}
+
+// getPodContainers returns references to all containers and init containers of the pod specification. Changing the
+// image of a returned reference changes the pod specification. Ephemeral containers are left out, they are added to
+// running pods for debugging and are not part of the templates of workloads.
+func getPodContainers(podSpec *apiv1.PodSpec) []podContainer {
+	containers := make([]podContainer, 0)
+	for index := range podSpec.Containers {
+		container := &podSpec.Containers[index]
+		containers = append(containers, podContainer{ContainerTypeContainer, container.Name, &container.Image})
+	}
+	for index := range podSpec.InitContainers {
+		container := &podSpec.InitContainers[index]
+		containers = append(containers, podContainer{ContainerTypeInitContainer, container.Name, &container.Image})
+	}
+	return containers
+}
+
 func fillStringMap(mapConfig map[string]bool, stringSlice []string) map[string]bool {
 	for _, container := range stringSlice {
 		mapConfig[container] = true
@@ -0,0 +1,198 @@
+package updater
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed semantic version as it is used in image tags, for example 1.4.2 or v2.0.0-rc.1.
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease []string
+}
+
+// ParseVersion parses a semantic version from the passed tag. A leading v is ignored, missing minor and patch
+// numbers are treated as zero and build metadata is dropped.
+func ParseVersion(tag string) (*Version, error) {
+	value := strings.TrimPrefix(strings.TrimSpace(tag), "v")
+	value = strings.SplitN(value, "+", 2)[0]
+	version := &Version{}
+	splits := strings.SplitN(value, "-", 2)
+	if len(splits) == 2 {
+		if len(splits[1]) == 0 {
+			return nil, fmt.Errorf("%q is not a semantic version", tag)
+		}
+		version.Prerelease = strings.Split(splits[1], ".")
+	}
+
+	numbers := strings.Split(splits[0], ".")
+	if len(numbers) > 3 {
+		return nil, fmt.Errorf("%q is not a semantic version", tag)
+	}
+	targets := []*int{&version.Major, &version.Minor, &version.Patch}
+	for index, number := range numbers {
+		parsed, err := strconv.Atoi(number)
+		if err != nil || parsed < 0 {
+			return nil, fmt.Errorf("%q is not a semantic version", tag)
+		}
+		*targets[index] = parsed
+	}
+	return version, nil
+}
+
+// String returns the canonical representation of the version.
+func (version *Version) String() string {
+	result := fmt.Sprintf("%d.%d.%d", version.Major, version.Minor, version.Patch)
+	if len(version.Prerelease) > 0 {
+		result = fmt.Sprintf("%s-%s", result, strings.Join(version.Prerelease, "."))
+	}
+	return result
+}
+
+// Compare returns -1 if the version is lower than the other one, 1 if it is higher and 0 if both are equal
+// following the semantic versioning precedence rules.
+func (version *Version) Compare(other *Version) int {
+	for _, pair := range [][2]int{
+		{version.Major, other.Major},
+		{version.Minor, other.Minor},
+		{version.Patch, other.Patch},
+	} {
+		if pair[0] != pair[1] {
+			return compareInt(pair[0], pair[1])
+		}
+	}
+	return comparePrerelease(version.Prerelease, other.Prerelease)
+}
+
+func comparePrerelease(left []string, right []string) int {
+	if len(left) == 0 || len(right) == 0 {
+		// A version without a prerelease has a higher precedence than one with a prerelease.
+		return compareInt(len(right), len(left))
+	}
+	for index := 0; index < len(left) && index < len(right); index++ {
+		leftNumber, leftErr := strconv.Atoi(left[index])
+		rightNumber, rightErr := strconv.Atoi(right[index])
+		switch {
+		case leftErr == nil && rightErr == nil:
+			if leftNumber != rightNumber {
+				return compareInt(leftNumber, rightNumber)
+			}
+		case leftErr == nil:
+			return -1
+		case rightErr == nil:
+			return 1
+		default:
+			if comparison := strings.Compare(left[index], right[index]); comparison != 0 {
+				return comparison
+			}
+		}
+	}
+	return compareInt(len(left), len(right))
+}
+
+func compareInt(left int, right int) int {
+	if left < right {
+		return -1
+	} else if left > right {
+		return 1
+	}
+	return 0
+}
+
+// VersionConstraint restricts the versions a workload may be updated to.
+type VersionConstraint struct {
+	raw        string
+	conditions []versionCondition
+}
+
+type versionCondition struct {
+	operator string
+	version  *Version
+}
+
+// ParseVersionConstraint parses a constraint like "~1.4", "^2", ">=1.2.0 <2.0.0" or "1.4.2". Multiple conditions
+// separated by spaces or commas must all be satisfied. The tilde allows patch updates and the caret minor and patch
+// updates of the given version.
+func ParseVersionConstraint(constraint string) (*VersionConstraint, error) {
+	fields := strings.FieldsFunc(constraint, func(r rune) bool { return r == ' ' || r == ',' })
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("Empty version constraint")
+	}
+	result := &VersionConstraint{raw: strings.TrimSpace(constraint)}
+	for _, field := range fields {
+		conditions, err := parseVersionCondition(field)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid version constraint %q: %w", constraint, err)
+		}
+		result.conditions = append(result.conditions, conditions...)
+	}
+	return result, nil
+}
+
+func parseVersionCondition(field string) ([]versionCondition, error) {
+	operator := ""
+	for _, candidate := range []string{">=", "<=", "!=", ">", "<", "=", "~", "^"} {
+		if strings.HasPrefix(field, candidate) {
+			operator = candidate
+			break
+		}
+	}
+	value := strings.TrimPrefix(field, operator)
+	version, err := ParseVersion(value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch operator {
+	case "~":
+		upper := &Version{Major: version.Major, Minor: version.Minor + 1}
+		if strings.Count(strings.TrimPrefix(value, "v"), ".") == 0 {
+			upper = &Version{Major: version.Major + 1}
+		}
+		return []versionCondition{{">=", version}, {"<", upper}}, nil
+	case "^":
+		upper := &Version{Major: version.Major + 1}
+		if version.Major == 0 {
+			upper = &Version{Minor: version.Minor + 1}
+		}
+		return []versionCondition{{">=", version}, {"<", upper}}, nil
+	case "":
+		return []versionCondition{{"=", version}}, nil
+	default:
+		return []versionCondition{{operator, version}}, nil
+	}
+}
+
+// String returns the constraint as it has been passed.
+func (constraint *VersionConstraint) String() string {
+	return constraint.raw
+}
+
+// Allows returns if the passed version satisfies all conditions of the constraint.
+func (constraint *VersionConstraint) Allows(version *Version) bool {
+	for _, condition := range constraint.conditions {
+		comparison := version.Compare(condition.version)
+		allowed := true
+		switch condition.operator {
+		case "=":
+			allowed = comparison == 0
+		case "!=":
+			allowed = comparison != 0
+		case ">":
+			allowed = comparison > 0
+		case ">=":
+			allowed = comparison >= 0
+		case "<":
+			allowed = comparison < 0
+		case "<=":
+			allowed = comparison <= 0
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
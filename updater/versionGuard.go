@@ -0,0 +1,112 @@
+package updater
+
+import (
+	"fmt"
+
+	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// SemverAnnotation opts a workload into semver aware updates. If set to "true", updates to lower versions than
+	// the currently deployed one are rejected.
+	SemverAnnotation = "xcnt.io/update-semver"
+	// VersionConstraintAnnotation restricts the versions a workload may be updated to, for example "~1.4". Setting it
+	// implies the semver aware update of the workload.
+	VersionConstraintAnnotation = "xcnt.io/update-version-constraint"
+)
+
+// VersionError is returned when planning an update which would violate the version guards of a workload.
+type VersionError struct {
+	// Kind is the kind of the workload, for example Deployment or Job.
+	Kind string
+	// Namespace is the namespace of the workload.
+	Namespace string
+	// Name is the name of the workload.
+	Name string
+	// Reason describes which guard has been violated.
+	Reason string
+}
+
+// Error returns the description of the violated guard.
+func (versionError *VersionError) Error() string {
+	return fmt.Sprintf("%s %s/%s: %s", versionError.Kind, versionError.Namespace, versionError.Name, versionError.Reason)
+}
+
+// PlanConflict marks violated version guards as conflicts with the workloads.
+func (versionError *VersionError) PlanConflict() {}
+
+// CheckVersions verifies the version guards of all passed workloads against the image of the configuration.
+func CheckVersions(config *Config, deployments []v1.Deployment, jobs []batchv1.Job) error {
+	guard := &versionGuard{image: config.GetImage()}
+	for _, deployment := range deployments {
+		err := guard.check("Deployment", deployment.GetObjectMeta(), deployment.Spec.Template.Spec)
+		if err != nil {
+			return err
+		}
+	}
+	for _, job := range jobs {
+		err := guard.check("Job", job.GetObjectMeta(), job.Spec.Template.Spec)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// versionGuard checks the requested image of an update against the version annotations of a workload.
+type versionGuard struct {
+	image *Image
+}
+
+// check returns a VersionError if the workload has opted into semver aware updates and the requested version is
+// either lower than a currently deployed one or not covered by the version constraint. Only the containers the
+// container filter of the workload allows to be updated are compared.
+func (guard *versionGuard) check(kind string, meta metaV1.Object, podSpec apiv1.PodSpec) error {
+	annotations := meta.GetAnnotations()
+	rawConstraint, hasConstraint := annotations[VersionConstraintAnnotation]
+	if annotations[SemverAnnotation] != "true" && !hasConstraint {
+		return nil
+	}
+	newError := func(format string, args ...interface{}) error {
+		return &VersionError{
+			Kind:      kind,
+			Namespace: meta.GetNamespace(),
+			Name:      meta.GetName(),
+			Reason:    fmt.Sprintf(format, args...),
+		}
+	}
+
+	requested, err := ParseVersion(guard.image.GetTag())
+	if err != nil {
+		return newError("requested tag %q is not a semantic version", guard.image.GetTag())
+	}
+	if hasConstraint {
+		constraint, err := ParseVersionConstraint(rawConstraint)
+		if err != nil {
+			return newError("%s", err.Error())
+		}
+		if !constraint.Allows(requested) {
+			return newError("requested version %s is outside of the constraint %s", requested, constraint)
+		}
+	}
+
+	filter := NewContainerFilter(annotations)
+	for _, container := range getPodContainers(&podSpec) {
+		image := *container.Image
+		if !filter.Allows(container.Name) || !guard.image.EqualsImage(image) {
+			continue
+		}
+		currentVersion, err := ParseVersion(NewImage(image).GetTag())
+		if err != nil {
+			// The currently deployed tag can not be compared, for example latest.
+			continue
+		}
+		if requested.Compare(currentVersion) < 0 {
+			return newError("requested version %s is lower than the deployed version %s", requested, currentVersion)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package updater
+
+import (
+	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	apiv1 "k8s.io/api/core/v1"
+
+	. "gopkg.in/check.v1"
+)
+
+type VersionGuardSuite struct {
+	kubernetesAPI KubernetesAPI
+}
+
+var _ = Suite(&VersionGuardSuite{})
+
+func (suite *VersionGuardSuite) SetUpTest(c *C) {
+	suite.kubernetesAPI = NewFakeKubernetesAPI()
+}
+
+func (suite *VersionGuardSuite) check(image string, annotations map[string]string, currentImage string) error {
+	config := NewConfig(suite.kubernetesAPI.Client, NewImage(image), "stable")
+	deployment := GetDeploymentWith(annotations, currentImage)
+	return CheckVersions(config, []v1.Deployment{deployment}, []batchv1.Job{})
+}
+
+func (suite *VersionGuardSuite) TestNotOptedIn(c *C) {
+	c.Assert(suite.check("xcnt/test:1.0.0", map[string]string{UpdateClassifier: "stable"}, "xcnt/test:2.0.0"), IsNil)
+}
+
+func (suite *VersionGuardSuite) TestUpgrade(c *C) {
+	c.Assert(suite.check("xcnt/test:1.1.0", map[string]string{SemverAnnotation: "true"}, "xcnt/test:1.0.0"), IsNil)
+}
+
+func (suite *VersionGuardSuite) TestDowngrade(c *C) {
+	err := suite.check("xcnt/test:1.0.0", map[string]string{SemverAnnotation: "true"}, "xcnt/test:1.1.0")
+	c.Assert(err, FitsTypeOf, &VersionError{})
+	c.Assert(err, ErrorMatches, "Deployment default/.*: requested version 1.0.0 is lower than the deployed version 1.1.0")
+}
+
+func (suite *VersionGuardSuite) TestDowngradeOfOtherImageIgnored(c *C) {
+	c.Assert(suite.check("xcnt/test:1.0.0", map[string]string{SemverAnnotation: "true"}, "xcnt/other:1.1.0"), IsNil)
+}
+
+func (suite *VersionGuardSuite) TestCurrentVersionNotSemver(c *C) {
+	c.Assert(suite.check("xcnt/test:1.0.0", map[string]string{SemverAnnotation: "true"}, "xcnt/test:latest"), IsNil)
+}
+
+func (suite *VersionGuardSuite) TestRequestedVersionNotSemver(c *C) {
+	err := suite.check("xcnt/test:latest", map[string]string{SemverAnnotation: "true"}, "xcnt/test:1.0.0")
+	c.Assert(err, ErrorMatches, ".*requested tag \"latest\" is not a semantic version")
+}
+
+func (suite *VersionGuardSuite) TestConstraint(c *C) {
+	annotations := map[string]string{VersionConstraintAnnotation: "~1.4"}
+	c.Assert(suite.check("xcnt/test:1.4.3", annotations, "xcnt/test:1.4.2"), IsNil)
+	err := suite.check("xcnt/test:1.5.0", annotations, "xcnt/test:1.4.2")
+	c.Assert(err, ErrorMatches, ".*requested version 1.5.0 is outside of the constraint ~1.4")
+}
+
+func (suite *VersionGuardSuite) TestJobDowngrade(c *C) {
+	config := NewConfig(suite.kubernetesAPI.Client, NewImage("xcnt/test:1.0.0"), "stable")
+	job := GetJobWith(map[string]string{SemverAnnotation: "true"}, "xcnt/test:1.2.0")
+	err := CheckVersions(config, []v1.Deployment{}, []batchv1.Job{job})
+	c.Assert(err, ErrorMatches, "Job default/.*: requested version 1.0.0 is lower than the deployed version 1.2.0")
+}
+
+func (suite *VersionGuardSuite) TestPlanRejectsDowngrade(c *C) {
+	suite.kubernetesAPI.NewNamespace("default")
+	deployment := GetDeploymentWith(map[string]string{UpdateClassifier: "stable", SemverAnnotation: "true"}, "xcnt/test:1.1.0")
+	suite.kubernetesAPI.NewDeploymentIn("default", deployment)
+	config := NewConfig(suite.kubernetesAPI.Client, NewImage("xcnt/test:1.0.0"), "stable")
+	config.SetNamespaces([]string{"default"})
+
+	_, err := Plan(config)
+	c.Assert(err, FitsTypeOf, &VersionError{})
+
+	config.SetForce(true)
+	updatePlan, err := Plan(config)
+	c.Assert(err, IsNil)
+	c.Assert(updatePlan.GetToApplyDeployments(), HasLen, 1)
+}
+
+func (suite *VersionGuardSuite) TestDowngradeOfExcludedContainerIgnored(c *C) {
+	config := NewConfig(suite.kubernetesAPI.Client, NewImage("xcnt/test:1.0.0"), "stable")
+	deployment := GetDeploymentWith(map[string]string{SemverAnnotation: "true", ExcludeContainersAnnotation: "sidecar"}, "xcnt/test:0.9.0", "xcnt/test:1.1.0")
+	deployment.Spec.Template.Spec.Containers[0].Name = "app"
+	deployment.Spec.Template.Spec.Containers[1].Name = "sidecar"
+	c.Assert(CheckVersions(config, []v1.Deployment{deployment}, []batchv1.Job{}), IsNil)
+
+	delete(deployment.Annotations, ExcludeContainersAnnotation)
+	deployment.Annotations[UpdateContainersAnnotation] = "sidecar"
+	err := CheckVersions(config, []v1.Deployment{deployment}, []batchv1.Job{})
+	c.Assert(err, ErrorMatches, "Deployment default/.*: requested version 1.0.0 is lower than the deployed version 1.1.0")
+}
+
+func (suite *VersionGuardSuite) TestDowngradeOfEphemeralContainerIgnored(c *C) {
+	config := NewConfig(suite.kubernetesAPI.Client, NewImage("xcnt/test:1.0.0"), "stable")
+	deployment := GetDeploymentWith(map[string]string{SemverAnnotation: "true"}, "xcnt/test:0.9.0")
+	deployment.Spec.Template.Spec.EphemeralContainers = []apiv1.EphemeralContainer{
+		{EphemeralContainerCommon: apiv1.EphemeralContainerCommon{Name: "debug", Image: "xcnt/test:1.1.0"}},
+	}
+	c.Assert(CheckVersions(config, []v1.Deployment{deployment}, []batchv1.Job{}), IsNil)
+}
@@ -0,0 +1,80 @@
+package updater
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type VersionSuite struct{}
+
+var _ = Suite(&VersionSuite{})
+
+func (suite *VersionSuite) parse(c *C, tag string) *Version {
+	version, err := ParseVersion(tag)
+	c.Assert(err, IsNil)
+	return version
+}
+
+func (suite *VersionSuite) allows(c *C, constraint string, tag string) bool {
+	parsed, err := ParseVersionConstraint(constraint)
+	c.Assert(err, IsNil)
+	return parsed.Allows(suite.parse(c, tag))
+}
+
+func (suite *VersionSuite) TestParseVersion(c *C) {
+	version := suite.parse(c, "v1.4.2-rc.1+build.5")
+	c.Assert(version.Major, Equals, 1)
+	c.Assert(version.Minor, Equals, 4)
+	c.Assert(version.Patch, Equals, 2)
+	c.Assert(version.Prerelease, DeepEquals, []string{"rc", "1"})
+	c.Assert(version.String(), Equals, "1.4.2-rc.1")
+}
+
+func (suite *VersionSuite) TestParseVersionShort(c *C) {
+	c.Assert(suite.parse(c, "2.1").String(), Equals, "2.1.0")
+}
+
+func (suite *VersionSuite) TestParseVersionInvalid(c *C) {
+	for _, tag := range []string{"latest", "", "1.2.3.4", "1.x", "1.0.0-"} {
+		_, err := ParseVersion(tag)
+		c.Assert(err, NotNil, Commentf("tag %q", tag))
+	}
+}
+
+func (suite *VersionSuite) TestCompare(c *C) {
+	ordered := []string{"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0", "1.0.1", "1.1.0", "2.0.0"}
+	for index := 1; index < len(ordered); index++ {
+		lower := suite.parse(c, ordered[index-1])
+		higher := suite.parse(c, ordered[index])
+		c.Assert(lower.Compare(higher), Equals, -1, Commentf("%s < %s", lower, higher))
+		c.Assert(higher.Compare(lower), Equals, 1, Commentf("%s > %s", higher, lower))
+	}
+	c.Assert(suite.parse(c, "v1.0").Compare(suite.parse(c, "1.0.0")), Equals, 0)
+}
+
+func (suite *VersionSuite) TestConstraintTilde(c *C) {
+	c.Assert(suite.allows(c, "~1.4", "1.4.0"), Equals, true)
+	c.Assert(suite.allows(c, "~1.4", "1.4.9"), Equals, true)
+	c.Assert(suite.allows(c, "~1.4", "1.5.0"), Equals, false)
+	c.Assert(suite.allows(c, "~1.4", "1.3.9"), Equals, false)
+	c.Assert(suite.allows(c, "~1", "1.9.0"), Equals, true)
+}
+
+func (suite *VersionSuite) TestConstraintCaret(c *C) {
+	c.Assert(suite.allows(c, "^1.4", "1.9.0"), Equals, true)
+	c.Assert(suite.allows(c, "^1.4", "2.0.0"), Equals, false)
+	c.Assert(suite.allows(c, "^0.4", "0.5.0"), Equals, false)
+}
+
+func (suite *VersionSuite) TestConstraintRange(c *C) {
+	c.Assert(suite.allows(c, ">=1.2.0, <2.0.0", "1.9.9"), Equals, true)
+	c.Assert(suite.allows(c, ">=1.2.0 <2.0.0", "2.0.0"), Equals, false)
+	c.Assert(suite.allows(c, "1.2.3", "1.2.3"), Equals, true)
+	c.Assert(suite.allows(c, "!=1.2.3", "1.2.3"), Equals, false)
+}
+
+func (suite *VersionSuite) TestConstraintInvalid(c *C) {
+	_, err := ParseVersionConstraint("~latest")
+	c.Assert(err, NotNil)
+	_, err = ParseVersionConstraint(" ")
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,132 @@
+package updater
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/apps/v1"
+)
+
+const (
+	// UpdateOrderAnnotation orders the deployments of an update. Deployments with a lower order, which defaults to 0,
+	// are updated and ready before the deployments with a higher order are updated.
+	UpdateOrderAnnotation = "xcnt.io/update-order"
+	// UpdateAfterAnnotation lists the deployments, by name or namespace/name separated by commas, which have to be
+	// updated and ready before the annotated deployment is updated. Deployments which are not part of the update are
+	// ignored.
+	UpdateAfterAnnotation = "xcnt.io/update-after"
+)
+
+// OrderError is returned when planning an update of deployments whose update order annotations are invalid or
+// contain a cycle.
+type OrderError struct {
+	// Namespace is the namespace of the deployment.
+	Namespace string
+	// Name is the name of the deployment.
+	Name string
+	// Reason describes the problem with the update order.
+	Reason string
+}
+
+// Error returns the description of the invalid update order.
+func (orderError *OrderError) Error() string {
+	return fmt.Sprintf("Deployment %s/%s: %s", orderError.Namespace, orderError.Name, orderError.Reason)
+}
+
+// PlanConflict marks invalid update orders as conflicts with the workloads.
+func (orderError *OrderError) PlanConflict() {}
+
+// Wave is a group of deployments of an update plan which are updated together. A wave is only updated once all
+// deployments of the previous waves are ready.
+type Wave struct {
+	// Deployments are the indices of the deployments of the wave in the deployments to apply of the plan.
+	Deployments []int
+}
+
+// CheckUpdateOrder verifies that the update order annotations of the passed deployments can be resolved into waves.
+func CheckUpdateOrder(deployments []v1.Deployment) error {
+	_, err := planWaves(deployments)
+	return err
+}
+
+// planWaves groups the deployments into waves. Each deployment is placed in the first wave after the waves of all
+// deployments with a lower update order and all deployments it is updated after.
+func planWaves(deployments []v1.Deployment) ([]Wave, error) {
+	predecessors, err := orderPredecessors(deployments)
+	if err != nil {
+		return nil, err
+	}
+	levels := make([]int, len(deployments))
+	for changed := true; changed; {
+		changed = false
+		for index := range deployments {
+			for _, predecessor := range predecessors[index] {
+				if levels[predecessor]+1 > levels[index] {
+					levels[index] = levels[predecessor] + 1
+					changed = true
+				}
+			}
+			if levels[index] >= len(deployments) {
+				deployment := deployments[index]
+				return nil, &OrderError{
+					Namespace: deployment.Namespace,
+					Name:      deployment.Name,
+					Reason:    "The update order contains a cycle",
+				}
+			}
+		}
+	}
+	waves := make([]Wave, 0)
+	for index, level := range levels {
+		for len(waves) <= level {
+			waves = append(waves, Wave{Deployments: make([]int, 0)})
+		}
+		waves[level].Deployments = append(waves[level].Deployments, index)
+	}
+	return waves, nil
+}
+
+// orderPredecessors returns for each deployment the indices of the deployments which have to be ready before it is
+// updated.
+func orderPredecessors(deployments []v1.Deployment) ([][]int, error) {
+	orders := make([]int, len(deployments))
+	indices := map[string]int{}
+	for index, deployment := range deployments {
+		indices[deployment.Namespace+"/"+deployment.Name] = index
+		value, ok := deployment.Annotations[UpdateOrderAnnotation]
+		if !ok {
+			continue
+		}
+		order, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return nil, &OrderError{
+				Namespace: deployment.Namespace,
+				Name:      deployment.Name,
+				Reason:    fmt.Sprintf("The update order %q is not an integer", value),
+			}
+		}
+		orders[index] = order
+	}
+	predecessors := make([][]int, len(deployments))
+	for index, deployment := range deployments {
+		for otherIndex := range deployments {
+			if orders[otherIndex] < orders[index] {
+				predecessors[index] = append(predecessors[index], otherIndex)
+			}
+		}
+		for _, name := range strings.Split(deployment.Annotations[UpdateAfterAnnotation], ",") {
+			name = strings.TrimSpace(name)
+			if len(name) == 0 {
+				continue
+			}
+			if !strings.Contains(name, "/") {
+				name = deployment.Namespace + "/" + name
+			}
+			if predecessor, ok := indices[name]; ok {
+				predecessors[index] = append(predecessors[index], predecessor)
+			}
+		}
+	}
+	return predecessors, nil
+}
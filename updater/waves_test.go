@@ -0,0 +1,122 @@
+package updater
+
+import (
+	"context"
+	"time"
+
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type WavesSuite struct{}
+
+var _ = Suite(&WavesSuite{})
+
+func deploymentNamed(namespace string, name string, annotations map[string]string) v1.Deployment {
+	deployment := GetDeploymentWith(annotations, "xcnt/test:1.0.0")
+	deployment.Namespace = namespace
+	deployment.Name = name
+	return deployment
+}
+
+func (suite *WavesSuite) TestWithoutAnnotations(c *C) {
+	waves, err := planWaves([]v1.Deployment{
+		deploymentNamed("default", "api", nil),
+		deploymentNamed("default", "frontend", nil),
+	})
+	c.Assert(err, IsNil)
+	c.Assert(waves, DeepEquals, []Wave{{Deployments: []int{0, 1}}})
+}
+
+func (suite *WavesSuite) TestUpdateOrder(c *C) {
+	waves, err := planWaves([]v1.Deployment{
+		deploymentNamed("default", "frontend", map[string]string{UpdateOrderAnnotation: "10"}),
+		deploymentNamed("default", "api", nil),
+		deploymentNamed("default", "database", map[string]string{UpdateOrderAnnotation: "-1"}),
+		deploymentNamed("default", "admin", map[string]string{UpdateOrderAnnotation: "10"}),
+	})
+	c.Assert(err, IsNil)
+	c.Assert(waves, DeepEquals, []Wave{{Deployments: []int{2}}, {Deployments: []int{1}}, {Deployments: []int{0, 3}}})
+}
+
+func (suite *WavesSuite) TestUpdateAfter(c *C) {
+	waves, err := planWaves([]v1.Deployment{
+		deploymentNamed("default", "frontend", map[string]string{UpdateAfterAnnotation: "api, search/indexer"}),
+		deploymentNamed("default", "api", map[string]string{UpdateAfterAnnotation: "unknown"}),
+		deploymentNamed("search", "indexer", map[string]string{UpdateAfterAnnotation: "default/api"}),
+		deploymentNamed("default", "worker", nil),
+	})
+	c.Assert(err, IsNil)
+	c.Assert(waves, DeepEquals, []Wave{{Deployments: []int{1, 3}}, {Deployments: []int{2}}, {Deployments: []int{0}}})
+}
+
+func (suite *WavesSuite) TestCycle(c *C) {
+	err := CheckUpdateOrder([]v1.Deployment{
+		deploymentNamed("default", "frontend", map[string]string{UpdateAfterAnnotation: "api"}),
+		deploymentNamed("default", "api", map[string]string{UpdateAfterAnnotation: "frontend"}),
+	})
+	c.Assert(err, FitsTypeOf, &OrderError{})
+	c.Assert(err, ErrorMatches, "Deployment default/.*: The update order contains a cycle")
+}
+
+func (suite *WavesSuite) TestCycleWithUpdateOrder(c *C) {
+	err := CheckUpdateOrder([]v1.Deployment{
+		deploymentNamed("default", "frontend", map[string]string{UpdateOrderAnnotation: "2"}),
+		deploymentNamed("default", "api", map[string]string{UpdateOrderAnnotation: "1", UpdateAfterAnnotation: "frontend"}),
+	})
+	c.Assert(err, FitsTypeOf, &OrderError{})
+}
+
+func (suite *WavesSuite) TestInvalidUpdateOrder(c *C) {
+	err := CheckUpdateOrder([]v1.Deployment{deploymentNamed("default", "api", map[string]string{UpdateOrderAnnotation: "first"})})
+	c.Assert(err, ErrorMatches, `Deployment default/api: The update order "first" is not an integer`)
+}
+
+func (suite *WavesSuite) TestPlanReportsWaves(c *C) {
+	updatePlaner := &UpdatePlaner{
+		JobLister: func() []batchv1.Job { return []batchv1.Job{} },
+		DeploymentLister: func() []v1.Deployment {
+			return []v1.Deployment{
+				deploymentNamed("default", "frontend", map[string]string{UpdateAfterAnnotation: "api"}),
+				deploymentNamed("default", "api", nil),
+			}
+		},
+	}
+	updatePlan := updatePlaner.Plan(NewConfig(NewFakeKubernetesAPI().Client, NewImage("xcnt/test:1.1.0"), "stable"))
+	c.Assert(updatePlan.GetWaves(), DeepEquals, []Wave{{Deployments: []int{1}}, {Deployments: []int{0}}})
+}
+
+func (suite *WavesSuite) TestNextWaveWaitsForPreviousOne(c *C) {
+	kubernetesAPI := NewFakeKubernetesAPI()
+	kubernetesAPI.NewNamespace("default")
+	config := NewConfig(kubernetesAPI.Client, NewImage("xcnt/test:1.0.0"), "stable")
+	api := deploymentNamed("default", "api", nil)
+	frontend := deploymentNamed("default", "frontend", map[string]string{UpdateAfterAnnotation: "api"})
+	kubernetesAPI.NewDeploymentIn("default", api)
+	kubernetesAPI.NewDeploymentIn("default", frontend)
+	frontend.Annotations["xcnt.io/updated"] = "true"
+	updatePlan := &updatePlan{
+		deployments: []v1.Deployment{frontend, api},
+		jobs:        []batchv1.Job{},
+		waves:       []Wave{{Deployments: []int{1}}, {Deployments: []int{0}}},
+	}
+
+	progress := Update(updatePlan, config)
+	time.Sleep(300 * time.Millisecond)
+	current, err := config.GetDeploymentAPIFor("default").Get(context.TODO(), "frontend", metaV1.GetOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(current.Annotations["xcnt.io/updated"], Equals, "")
+	c.Assert(progress.UpdatedDeploymentsCount(), Equals, 0)
+
+	api.Status.ReadyReplicas = 1
+	kubernetesAPI.UpdateDeploymentIn("default", &api)
+	for i := 0; i < 20 && current.Annotations["xcnt.io/updated"] == ""; i++ {
+		time.Sleep(100 * time.Millisecond)
+		current, _ = config.GetDeploymentAPIFor("default").Get(context.TODO(), "frontend", metaV1.GetOptions{})
+	}
+	c.Assert(current.Annotations["xcnt.io/updated"], Equals, "true")
+	c.Assert(progress.UpdatedDeploymentsCount(), Equals, 1)
+	c.Assert(progress.Finished(), Equals, false)
+}
@@ -0,0 +1,171 @@
+package web
+
+import (
+	"errors"
+	"kubernetes-update-manager/audit"
+	"kubernetes-update-manager/auth"
+	"kubernetes-update-manager/updater"
+	"kubernetes-update-manager/updater/manager"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ReasonParam is the parameter describing why an update is rejected
+	ReasonParam = "reason"
+)
+
+// Approve represents the POST method to approve an update waiting for approval.
+// @Summary Approves an update
+// @Description approves an update waiting for approval. The update is started once the required number of principals, which must differ from the requester, approved it.
+// @Tags approvals
+// @Produce json
+// @Security ApiKeyAuth
+// @Param uuid path string true "The uuid of the update which should be approved"
+// @Success 200 {object} web.UpdateProgressSerialized
+// @Failure 400
+// @Failure 401
+// @Failure 403 {object} web.ErrorSerialized
+// @Failure 404
+// @Failure 409 {object} web.ErrorSerialized
+// @Router /updates/{uuid}/approve [post]
+func (updateHandler *UpdaterHandler) Approve(context *gin.Context) {
+	updateHandler.decide(context, audit.ActionApprove, func(updateUUID uuid.UUID, principal string) (manager.UpdateProgress, error) {
+		return updateHandler.manager.Approve(updateUUID, principal)
+	})
+}
+
+// Reject represents the POST method to reject an update waiting for approval.
+// @Summary Rejects an update
+// @Description rejects an update waiting for approval, so it is never started. The principal must differ from the requester.
+// @Tags approvals
+// @Produce json
+// @Security ApiKeyAuth
+// @Param uuid path string true "The uuid of the update which should be rejected"
+// @Param reason body string false "Why the update has been rejected"
+// @Success 200 {object} web.UpdateProgressSerialized
+// @Failure 400
+// @Failure 401
+// @Failure 403 {object} web.ErrorSerialized
+// @Failure 404
+// @Failure 409 {object} web.ErrorSerialized
+// @Router /updates/{uuid}/reject [post]
+func (updateHandler *UpdaterHandler) Reject(context *gin.Context) {
+	reason := context.PostForm(ReasonParam)
+	updateHandler.decide(context, audit.ActionReject, func(updateUUID uuid.UUID, principal string) (manager.UpdateProgress, error) {
+		return updateHandler.manager.Reject(updateUUID, principal, reason)
+	})
+}
+
+// Cancel represents the POST method to cancel an update waiting to be started.
+// @Summary Cancels an update
+// @Description cancels an update which waits for approval, its start time or conflicting updates, so it is never started. Only the requester and the approvers of the update may cancel it.
+// @Tags updates
+// @Produce json
+// @Security ApiKeyAuth
+// @Param uuid path string true "The uuid of the update which should be cancelled"
+// @Success 200 {object} web.UpdateProgressSerialized
+// @Failure 400
+// @Failure 401
+// @Failure 403 {object} web.ErrorSerialized
+// @Failure 404
+// @Failure 409 {object} web.ErrorSerialized
+// @Router /updates/{uuid}/cancel [post]
+func (updateHandler *UpdaterHandler) Cancel(context *gin.Context) {
+	updateHandler.decide(context, audit.ActionCancel, func(updateUUID uuid.UUID, principal string) (manager.UpdateProgress, error) {
+		return updateHandler.manager.Cancel(updateUUID, principal)
+	})
+}
+
+// decide runs the approval, rejection or cancellation of the update in the path by the principal of the request and
+// answers with the resulting update progress.
+func (updateHandler *UpdaterHandler) decide(context *gin.Context, action audit.Action, decision func(uuid.UUID, string) (manager.UpdateProgress, error)) {
+	defer updateHandler.manager.Cleanup()
+	entry := auditEntryFor(context, action)
+	entry.UpdateUUID = context.Param(UUIDParam)
+	defer func() { updateHandler.recordAudit(context, entry) }()
+
+	updateUUID, err := uuid.Parse(entry.UpdateUUID)
+	if err != nil {
+		context.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	existingProgress, err := updateHandler.manager.Get(updateUUID)
+	if os.IsNotExist(err) {
+		context.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	entry = withProgress(entry, existingProgress)
+	principal := principalOf(context)
+	name := ""
+	if principal != nil {
+		name = principal.Name
+		err = checkApproverScope(principal, existingProgress)
+		if err != nil {
+			abortForbidden(context, err.Error())
+			return
+		}
+	}
+	updateProgress, err := decision(updateUUID, name)
+	if err != nil {
+		abortWithDecisionError(context, err)
+		return
+	}
+	log.WithFields(log.Fields{
+		"uuid":      updateProgress.UUID().String(),
+		"principal": name,
+		"action":    action,
+		"state":     updateProgress.State(),
+	}).Info("Update decision recorded")
+	context.JSON(http.StatusOK, serializeUpdateProgress(updateProgress))
+}
+
+// checkApproverScope returns an error if the update is outside of the scope of the principal deciding about it.
+func checkApproverScope(principal *auth.Principal, updateProgress manager.UpdateProgress) error {
+	err := principal.CheckUpdate(updater.NewImage(updateProgress.Image()), updateProgress.UpdateClassifier())
+	if err != nil {
+		return err
+	}
+	return principal.CheckNamespaces(namespacesOfProgress(updateProgress))
+}
+
+// abortWithDecisionError responds to a failed approval, rejection, cancellation or rollback. Principals which must not
+// decide about the update are answered with forbidden, updates which do not wait for approval or their start, can not
+// be rolled back, whose deployments changed since the update or whose rollback conflicts with other updates with a
+// conflict.
+func abortWithDecisionError(context *gin.Context, err error) {
+	var rejection *manager.RejectionError
+	var conflict *manager.ConflictError
+	var drift *manager.DriftError
+	switch {
+	case errors.As(err, &rejection):
+		abortForbidden(context, rejection.Error())
+	case errors.Is(err, manager.ErrNotPendingApproval), errors.Is(err, manager.ErrAlreadyApproved), errors.Is(err, manager.ErrNotCancellable),
+		errors.Is(err, manager.ErrNotRollbackable), errors.As(err, &conflict), errors.As(err, &drift):
+		abortWithReason(context, http.StatusConflict, err.Error())
+	default:
+		context.AbortWithError(http.StatusInternalServerError, err)
+	}
+}
+
+func namespacesOfProgress(updateProgress manager.UpdateProgress) []string {
+	seen := map[string]bool{}
+	namespaces := make([]string, 0)
+	add := func(namespace string) {
+		if !seen[namespace] {
+			seen[namespace] = true
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	for _, deployment := range updateProgress.GetDeployments() {
+		add(deployment.Namespace)
+	}
+	for _, job := range updateProgress.GetJobs() {
+		add(job.Namespace)
+	}
+	return namespaces
+}
@@ -0,0 +1,161 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"kubernetes-update-manager/audit"
+	"kubernetes-update-manager/auth"
+	"kubernetes-update-manager/policy"
+	"kubernetes-update-manager/updater/manager"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/google/uuid"
+	. "gopkg.in/check.v1"
+)
+
+type ApprovalTestSuite struct {
+	GenericWebTestSuite
+}
+
+var _ = Suite(&ApprovalTestSuite{})
+
+func (suite *ApprovalTestSuite) SetUpTest(c *C) {
+	suite.GenericWebTestSuite.SetUpTest(c)
+	suite.config.ApprovalPolicy = &policy.ApprovalPolicy{Classifiers: map[string]policy.ApprovalRule{
+		"stable": {RequiredApprovers: 1, Approvers: []string{"release-*"}},
+	}}
+	suite.config.APIKeys = []auth.APIKey{
+		{Name: "release-manager", Key: "release-secret"},
+		{Name: "developer", Key: "developer-secret"},
+		{Name: "release-staging", Key: "staging-secret", Scope: auth.Scope{Classifiers: []string{"staging"}}},
+	}
+	suite.router, _ = getWeb(suite.config, false)
+}
+
+func (suite *ApprovalTestSuite) create(c *C) *UpdateProgressSerialized {
+	recorder := suite.serve(suite.PostRequestComplete())
+	c.Assert(recorder.Code, Equals, http.StatusCreated)
+	response := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), response), IsNil)
+	return response
+}
+
+func (suite *ApprovalTestSuite) decide(updateUUID string, decision string, apiKey string, data url.Values) *httptest.ResponseRecorder {
+	req := suite.PostRequestTo(fmt.Sprintf("/updates/%s/%s", updateUUID, decision), data)
+	req.Header.Set("Authorization", "APIKey "+apiKey)
+	return suite.serve(req)
+}
+
+func (suite *ApprovalTestSuite) TestCreateIsHeld(c *C) {
+	response := suite.create(c)
+	c.Assert(response.Status.State, Equals, string(manager.StatePendingApproval))
+	c.Assert(response.Status.Finished, Equals, false)
+	c.Assert(response.Approval, NotNil)
+	c.Assert(response.Approval.RequiredApprovers, Equals, 1)
+}
+
+func (suite *ApprovalTestSuite) TestUnprotectedClassifierIsNotHeld(c *C) {
+	data := url.Values{}
+	data.Set(ImageParam, "xcnt/test:1.0.0")
+	data.Set(UpdateClassifierParam, "develop")
+	recorder := suite.serve(suite.PostRequestWith(data))
+	c.Assert(recorder.Code, Equals, http.StatusCreated)
+	response := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Approval, IsNil)
+	c.Assert(response.Status.State, Not(Equals), string(manager.StatePendingApproval))
+}
+
+func (suite *ApprovalTestSuite) TestApprove(c *C) {
+	created := suite.create(c)
+	recorder := suite.decide(created.UUID, "approve", "release-secret", url.Values{})
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	response := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Status.State, Not(Equals), string(manager.StatePendingApproval))
+	c.Assert(response.Approval.Approvers, DeepEquals, []string{"release-manager"})
+
+	entries := suite.config.AuditLog.Query(audit.Filter{Action: audit.ActionApprove})
+	c.Assert(entries, HasLen, 1)
+	c.Assert(entries[0].Requester, Equals, "release-manager")
+	c.Assert(entries[0].Outcome, Equals, audit.OutcomeSucceeded)
+}
+
+func (suite *ApprovalTestSuite) TestApproveTwiceConflicts(c *C) {
+	created := suite.create(c)
+	c.Assert(suite.decide(created.UUID, "approve", "release-secret", url.Values{}).Code, Equals, http.StatusOK)
+	c.Assert(suite.decide(created.UUID, "approve", "release-secret", url.Values{}).Code, Equals, http.StatusConflict)
+}
+
+func (suite *ApprovalTestSuite) TestRequesterMayNotApprove(c *C) {
+	created := suite.create(c)
+	recorder := suite.decide(created.UUID, "approve", suite.config.APIKey, url.Values{})
+	c.Assert(recorder.Code, Equals, http.StatusForbidden)
+	response := &ErrorSerialized{}
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Error, Equals, DefaultAPIKeyName+" requested the update and may not approve it")
+}
+
+func (suite *ApprovalTestSuite) TestApproverNotAllowed(c *C) {
+	created := suite.create(c)
+	c.Assert(suite.decide(created.UUID, "approve", "developer-secret", url.Values{}).Code, Equals, http.StatusForbidden)
+}
+
+func (suite *ApprovalTestSuite) TestApproverOutOfScope(c *C) {
+	created := suite.create(c)
+	c.Assert(suite.decide(created.UUID, "approve", "staging-secret", url.Values{}).Code, Equals, http.StatusForbidden)
+}
+
+func (suite *ApprovalTestSuite) TestReject(c *C) {
+	created := suite.create(c)
+	data := url.Values{}
+	data.Set(ReasonParam, "Not during the sale")
+	recorder := suite.decide(created.UUID, "reject", "release-secret", data)
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	response := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Status.State, Equals, string(manager.StateRejected))
+	c.Assert(response.Status.Finished, Equals, true)
+	c.Assert(response.Status.Failed, Equals, true)
+	c.Assert(response.Approval.Rejecter, Equals, "release-manager")
+	c.Assert(response.Approval.Reason, Equals, "Not during the sale")
+
+	c.Assert(suite.decide(created.UUID, "approve", "release-secret", url.Values{}).Code, Equals, http.StatusConflict)
+}
+
+func (suite *ApprovalTestSuite) createAs(c *C, apiKey string) *UpdateProgressSerialized {
+	req := suite.PostRequestComplete()
+	req.Header.Set("Authorization", "APIKey "+apiKey)
+	recorder := suite.serve(req)
+	c.Assert(recorder.Code, Equals, http.StatusCreated)
+	response := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), response), IsNil)
+	return response
+}
+
+func (suite *ApprovalTestSuite) TestCancelOfOtherRequesterForbidden(c *C) {
+	created := suite.createAs(c, "release-secret")
+	recorder := suite.decide(created.UUID, "cancel", "developer-secret", url.Values{})
+	c.Assert(recorder.Code, Equals, http.StatusForbidden)
+	response := &ErrorSerialized{}
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Error, Equals, "developer did not request the update and is not allowed to approve it, so it may not cancel it")
+
+	c.Assert(suite.decide(created.UUID, "cancel", "release-secret", url.Values{}).Code, Equals, http.StatusOK)
+}
+
+func (suite *ApprovalTestSuite) TestApproverMayCancel(c *C) {
+	created := suite.create(c)
+	recorder := suite.decide(created.UUID, "cancel", "release-secret", url.Values{})
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	response := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Status.State, Equals, string(manager.StateCancelled))
+}
+
+func (suite *ApprovalTestSuite) TestDecideNotFound(c *C) {
+	c.Assert(suite.decide(uuid.New().String(), "approve", "release-secret", url.Values{}).Code, Equals, http.StatusNotFound)
+	c.Assert(suite.decide("abc", "reject", "release-secret", url.Values{}).Code, Equals, http.StatusBadRequest)
+}
@@ -0,0 +1,130 @@
+package web
+
+import (
+	"kubernetes-update-manager/audit"
+	"kubernetes-update-manager/updater/manager"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// AuditActionParam filters the audit entries by action.
+	AuditActionParam = "action"
+	// AuditRequesterParam filters the audit entries by requester.
+	AuditRequesterParam = "requester"
+	// AuditUpdateUUIDParam filters the audit entries by the uuid of the update.
+	AuditUpdateUUIDParam = "update_uuid"
+	// AuditSinceParam only returns audit entries recorded at or after the RFC 3339 time.
+	AuditSinceParam = "since"
+	// AuditLimitParam limits the number of returned audit entries.
+	AuditLimitParam = "limit"
+)
+
+// auditEntryFor returns an audit entry for the action with the caller of the request.
+func auditEntryFor(context *gin.Context, action audit.Action) audit.Entry {
+	entry := audit.Entry{
+		Action:   action,
+		ClientIP: context.ClientIP(),
+	}
+	if principal := principalOf(context); principal != nil {
+		entry.Requester = principal.Name
+	}
+	return entry
+}
+
+// withProgress adds the update and the workloads of the update progress to the entry.
+func withProgress(entry audit.Entry, updateProgress manager.UpdateProgress) audit.Entry {
+	entry.UpdateUUID = updateProgress.UUID().String()
+	entry.Image = updateProgress.Image()
+	entry.UpdateClassifier = updateProgress.UpdateClassifier()
+	for _, deployment := range updateProgress.GetDeployments() {
+		entry.Plan = append(entry.Plan, audit.Workload{Kind: "Deployment", Namespace: deployment.Namespace, Name: deployment.Name})
+	}
+	for _, job := range updateProgress.GetJobs() {
+		entry.Plan = append(entry.Plan, audit.Workload{Kind: "Job", Namespace: job.Namespace, Name: job.Name})
+	}
+	return entry
+}
+
+// outcomeOf returns the audit outcome of a request answered with the status code.
+func outcomeOf(statusCode int) audit.Outcome {
+	switch {
+	case statusCode < http.StatusBadRequest:
+		return audit.OutcomeSucceeded
+	case statusCode == http.StatusForbidden:
+		return audit.OutcomeRejected
+	case statusCode == http.StatusConflict:
+		return audit.OutcomeConflict
+	case statusCode == http.StatusNotFound:
+		return audit.OutcomeNotFound
+	}
+	return audit.OutcomeFailed
+}
+
+// recordAudit completes the entry with the outcome of the answered request, unless it is already set, and records it.
+func (updateHandler *UpdaterHandler) recordAudit(context *gin.Context, entry audit.Entry) {
+	if len(entry.Outcome) == 0 {
+		entry.Outcome = outcomeOf(context.Writer.Status())
+	}
+	if entry.Outcome != audit.OutcomeSucceeded && len(entry.Error) == 0 {
+		if lastError := context.Errors.Last(); lastError != nil {
+			entry.Error = lastError.Error()
+		} else {
+			entry.Error = http.StatusText(context.Writer.Status())
+		}
+	}
+	updateHandler.auditLog.Record(entry)
+}
+
+// GetAudit returns the recorded audit entries.
+// @Summary Lists audit entries
+// @Description returns the recorded create, approve, reject, cancel, abort, rollback and delete calls, newest first. Only principals without a restricted scope may query the audit log.
+// @Tags audit
+// @Produce json
+// @Security ApiKeyAuth
+// @Param action query string false "Only return entries of the action"
+// @Param requester query string false "Only return entries of the requester"
+// @Param image query string false "Only return entries for the image"
+// @Param update_classifier query string false "Only return entries for the update classifier"
+// @Param update_uuid query string false "Only return entries for the update"
+// @Param since query string false "Only return entries recorded at or after the RFC 3339 time"
+// @Param limit query int false "The maximal number of returned entries"
+// @Success 200 {array} audit.Entry
+// @Failure 400 {object} web.ErrorSerialized
+// @Failure 401
+// @Failure 403 {object} web.ErrorSerialized
+// @Router /audit [get]
+func (updateHandler *UpdaterHandler) GetAudit(context *gin.Context) {
+	principal := principalOf(context)
+	if principal != nil && principal.Restricted() {
+		abortForbidden(context, principal.Name+" is restricted to a scope and may not query the audit log")
+		return
+	}
+	filter := audit.Filter{
+		Action:           audit.Action(context.Query(AuditActionParam)),
+		Requester:        context.Query(AuditRequesterParam),
+		Image:            context.Query(ImageParam),
+		UpdateClassifier: context.Query(UpdateClassifierParam),
+		UpdateUUID:       context.Query(AuditUpdateUUIDParam),
+	}
+	if since := context.Query(AuditSinceParam); len(since) > 0 {
+		sinceTime, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			context.AbortWithStatusJSON(http.StatusBadRequest, &ErrorSerialized{Error: err.Error()})
+			return
+		}
+		filter.Since = sinceTime
+	}
+	if limit := context.Query(AuditLimitParam); len(limit) > 0 {
+		limitValue, err := strconv.Atoi(limit)
+		if err != nil || limitValue < 0 {
+			context.AbortWithStatusJSON(http.StatusBadRequest, &ErrorSerialized{Error: "The limit must be a positive number"})
+			return
+		}
+		filter.Limit = limitValue
+	}
+	context.JSON(http.StatusOK, updateHandler.auditLog.Query(filter))
+}
@@ -0,0 +1,125 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"kubernetes-update-manager/audit"
+	"kubernetes-update-manager/auth"
+	"kubernetes-update-manager/updater"
+	"net/http"
+	"net/url"
+
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type AuditTestSuite struct {
+	GenericWebTestSuite
+}
+
+var _ = Suite(&AuditTestSuite{})
+
+func (suite *AuditTestSuite) SetUpTest(c *C) {
+	suite.GenericWebTestSuite.SetUpTest(c)
+	suite.config.APIKeys = []auth.APIKey{{Name: "payments-ci", Key: "payments-secret", Scope: auth.Scope{Classifiers: []string{"staging"}}}}
+	suite.router, _ = getWeb(suite.config, false)
+}
+
+func (suite *AuditTestSuite) queryAudit(c *C, query string) []audit.Entry {
+	req, _ := http.NewRequest("GET", "/audit?"+query, nil)
+	recorder := suite.serve(suite.Authenticate(req))
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	entries := make([]audit.Entry, 0)
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), &entries), IsNil)
+	return entries
+}
+
+func (suite *AuditTestSuite) TestCreateAndDelete(c *C) {
+	namespace := &apiv1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "default"}}
+	_, err := suite.clientset.CoreV1().Namespaces().Create(context.Background(), namespace, metaV1.CreateOptions{})
+	c.Assert(err, IsNil)
+	deployment := &v1.Deployment{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:        "api",
+			Namespace:   "default",
+			Annotations: map[string]string{updater.UpdateClassifier: "stable"},
+		},
+		Spec: v1.DeploymentSpec{Template: apiv1.PodTemplateSpec{Spec: apiv1.PodSpec{
+			Containers: []apiv1.Container{{Name: "app", Image: "xcnt/test:0.9.0"}},
+		}}},
+	}
+	_, err = suite.clientset.AppsV1().Deployments("default").Create(context.Background(), deployment, metaV1.CreateOptions{})
+	c.Assert(err, IsNil)
+
+	recorder := suite.serve(suite.Authenticate(suite.PostRequestComplete()))
+	c.Assert(recorder.Code, Equals, http.StatusCreated)
+	response := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), response), IsNil)
+
+	req, _ := http.NewRequest("DELETE", fmt.Sprintf("/updates/%s", response.UUID), nil)
+	c.Assert(suite.serve(suite.Authenticate(req)).Code, Equals, http.StatusNoContent)
+
+	entries := suite.queryAudit(c, "")
+	c.Assert(entries, HasLen, 2)
+	for index, action := range []audit.Action{audit.ActionDelete, audit.ActionCreate} {
+		entry := entries[index]
+		c.Assert(entry.Action, Equals, action)
+		c.Assert(entry.Requester, Equals, DefaultAPIKeyName)
+		c.Assert(entry.UpdateUUID, Equals, response.UUID)
+		c.Assert(entry.Image, Equals, "xcnt/test:1.0.0")
+		c.Assert(entry.UpdateClassifier, Equals, "stable")
+		c.Assert(entry.Outcome, Equals, audit.OutcomeSucceeded)
+		c.Assert(entry.Plan, DeepEquals, []audit.Workload{{Kind: "Deployment", Namespace: "default", Name: "api"}})
+	}
+}
+
+func (suite *AuditTestSuite) TestRejectedCreate(c *C) {
+	data := url.Values{}
+	data.Set(ImageParam, "xcnt/test:1.0.0")
+	data.Set(UpdateClassifierParam, "stable")
+	req := suite.PostRequestWith(data)
+	req.Header.Set("Authorization", "APIKey payments-secret")
+	req.RemoteAddr = "10.0.0.1:41000"
+	c.Assert(suite.serve(req).Code, Equals, http.StatusForbidden)
+
+	entries := suite.queryAudit(c, "requester=payments-ci")
+	c.Assert(entries, HasLen, 1)
+	c.Assert(entries[0].Outcome, Equals, audit.OutcomeRejected)
+	c.Assert(entries[0].Error, Equals, "payments-ci is not allowed to update the classifier stable")
+	c.Assert(entries[0].Image, Equals, "xcnt/test:1.0.0")
+	c.Assert(entries[0].ClientIP, Equals, "10.0.0.1")
+}
+
+func (suite *AuditTestSuite) TestDeleteUnknown(c *C) {
+	req, _ := http.NewRequest("DELETE", "/updates/00000000-0000-0000-0000-000000000000", nil)
+	c.Assert(suite.serve(suite.Authenticate(req)).Code, Equals, http.StatusNoContent)
+	entries := suite.queryAudit(c, "action=delete")
+	c.Assert(entries, HasLen, 1)
+	c.Assert(entries[0].Outcome, Equals, audit.OutcomeNotFound)
+}
+
+func (suite *AuditTestSuite) TestQueryFilters(c *C) {
+	for index := 0; index < 3; index++ {
+		suite.serve(suite.Authenticate(suite.PostRequestComplete()))
+	}
+	c.Assert(suite.queryAudit(c, "limit=2"), HasLen, 2)
+	c.Assert(suite.queryAudit(c, "action=delete"), HasLen, 0)
+	c.Assert(suite.queryAudit(c, "since=2000-01-01T00:00:00Z"), HasLen, 3)
+
+	for _, query := range []string{"since=yesterday", "limit=-1", "limit=many"} {
+		req, _ := http.NewRequest("GET", "/audit?"+query, nil)
+		c.Assert(suite.serve(suite.Authenticate(req)).Code, Equals, http.StatusBadRequest)
+	}
+}
+
+func (suite *AuditTestSuite) TestQueryRestrictedPrincipal(c *C) {
+	req, _ := http.NewRequest("GET", "/audit", nil)
+	req.Header.Set("Authorization", "APIKey payments-secret")
+	c.Assert(suite.serve(req).Code, Equals, http.StatusForbidden)
+
+	req, _ = http.NewRequest("GET", "/audit", nil)
+	c.Assert(suite.serve(req).Code, Equals, http.StatusUnauthorized)
+}
@@ -0,0 +1,75 @@
+package web
+
+import (
+	"errors"
+	"kubernetes-update-manager/auth"
+	"kubernetes-update-manager/updater"
+	"kubernetes-update-manager/updater/manager"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultAPIKeyName is the name of the principal authenticated with the shared API key of the configuration.
+	DefaultAPIKeyName = "default"
+	// principalContextKey is the key the authenticated principal is stored with in the request context.
+	principalContextKey = "principal"
+)
+
+// newAuthenticator returns the authenticator accepting the credentials configured for the web interface. API keys are
+// accepted as HMAC signatures of the request and, unless signed requests are required, verbatim. The shared API key is
+// not privileged, as every pipeline holding it could otherwise override the freeze calendar.
+func newAuthenticator(config *Config) auth.Authenticator {
+	keys := append([]auth.APIKey{}, config.APIKeys...)
+	if len(config.APIKey) > 0 {
+		keys = append(keys, auth.APIKey{Name: DefaultAPIKeyName, Key: config.APIKey})
+	}
+	authenticators := auth.Authenticators{auth.NewHMACAuthenticator(keys, config.MaxClockSkew)}
+	if !config.RequireSignedRequests {
+		authenticators = append(authenticators, auth.NewAPIKeyAuthenticator(keys))
+	}
+	return append(authenticators, config.Authenticators...)
+}
+
+// RequireAuthenticator returns a middleware rejecting requests which can not be authenticated. The principal of
+// authenticated requests is stored in the request context.
+func RequireAuthenticator(authenticator auth.Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, err := authenticator.Authenticate(c.Request)
+		if err != nil {
+			if !errors.Is(err, auth.ErrUnauthenticated) {
+				log.WithError(err).Warn("Request could not be authenticated")
+			}
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Set(principalContextKey, principal)
+		c.Next()
+	}
+}
+
+// principalOf returns the authenticated principal of the request or nil if the request has not been authenticated.
+func principalOf(context *gin.Context) *auth.Principal {
+	value, ok := context.Get(principalContextKey)
+	if !ok {
+		return nil
+	}
+	principal, _ := value.(*auth.Principal)
+	return principal
+}
+
+// verifyScope returns a plan verifier rejecting plans which touch resources outside of the scope of the principal.
+func verifyScope(principal *auth.Principal) manager.PlanVerifier {
+	return func(updateConfig *updater.Config, updatePlan updater.UpdatePlan) error {
+		if principal == nil {
+			return nil
+		}
+		err := principal.CheckNamespaces(namespacesOf(updatePlan))
+		if err != nil {
+			return manager.Reject("%s", err.Error())
+		}
+		return nil
+	}
+}
@@ -0,0 +1,223 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"kubernetes-update-manager/auth"
+	"kubernetes-update-manager/updater"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"time"
+
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type AuthTestSuite struct {
+	GenericWebTestSuite
+}
+
+var _ = Suite(&AuthTestSuite{})
+
+func (suite *AuthTestSuite) SetUpTest(c *C) {
+	suite.GenericWebTestSuite.SetUpTest(c)
+	suite.config.AutoloadNamespaces = false
+	suite.config.Namespaces = []string{"payments", "search"}
+	suite.config.APIKeys = []auth.APIKey{
+		{
+			Name: "payments-ci",
+			Key:  "payments-secret",
+			Scope: auth.Scope{
+				Classifiers: []string{"stable"},
+				Namespaces:  []string{"payments"},
+				Images:      []string{"docker.io/xcnt/*"},
+			},
+		},
+	}
+	suite.router, _ = getWeb(suite.config, false)
+}
+
+func (suite *AuthTestSuite) createDeploymentIn(c *C, namespace string) {
+	deployment := &v1.Deployment{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:        "test",
+			Namespace:   namespace,
+			Annotations: map[string]string{updater.UpdateClassifier: "stable"},
+		},
+		Spec: v1.DeploymentSpec{
+			Template: apiv1.PodTemplateSpec{Spec: apiv1.PodSpec{
+				Containers: []apiv1.Container{{Name: "app", Image: "xcnt/test:0.9.9"}},
+			}},
+		},
+	}
+	_, err := suite.clientset.AppsV1().Deployments(namespace).Create(context.Background(), deployment, metaV1.CreateOptions{})
+	c.Assert(err, IsNil)
+}
+
+func (suite *AuthTestSuite) postWithKey(key string, classifier string) *http.Request {
+	data := url.Values{}
+	data.Set(ImageParam, "xcnt/test:1.0.0")
+	data.Set(UpdateClassifierParam, classifier)
+	req := suite.PostRequestWith(data)
+	req.Header.Set("Authorization", "APIKey "+key)
+	return req
+}
+
+func (suite *AuthTestSuite) errorOf(c *C) string {
+	response := &ErrorSerialized{}
+	c.Assert(json.Unmarshal(suite.recorder.Body.Bytes(), response), IsNil)
+	return response.Error
+}
+
+func (suite *AuthTestSuite) TestScopedKey(c *C) {
+	suite.createDeploymentIn(c, "payments")
+	suite.router.ServeHTTP(suite.recorder, suite.postWithKey("payments-secret", "stable"))
+	c.Assert(suite.recorder.Code, Equals, http.StatusCreated)
+	response := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(suite.recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Requester, Equals, "payments-ci")
+}
+
+func (suite *AuthTestSuite) TestSharedKey(c *C) {
+	suite.createDeploymentIn(c, "search")
+	suite.router.ServeHTTP(suite.recorder, suite.postWithKey(suite.config.APIKey, "stable"))
+	c.Assert(suite.recorder.Code, Equals, http.StatusCreated)
+	response := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(suite.recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Requester, Equals, DefaultAPIKeyName)
+}
+
+func (suite *AuthTestSuite) TestScopedKeyClassifierOutOfScope(c *C) {
+	suite.router.ServeHTTP(suite.recorder, suite.postWithKey("payments-secret", "staging"))
+	c.Assert(suite.recorder.Code, Equals, http.StatusForbidden)
+	c.Assert(suite.errorOf(c), Equals, "payments-ci is not allowed to update the classifier staging")
+}
+
+func (suite *AuthTestSuite) TestScopedKeyNamespaceOutOfScope(c *C) {
+	suite.createDeploymentIn(c, "payments")
+	suite.createDeploymentIn(c, "search")
+	suite.router.ServeHTTP(suite.recorder, suite.postWithKey("payments-secret", "stable"))
+	c.Assert(suite.recorder.Code, Equals, http.StatusForbidden)
+	c.Assert(suite.errorOf(c), Equals, "payments-ci is not allowed to update resources in the namespace search")
+}
+
+func (suite *AuthTestSuite) TestScopedKeyPlanOutOfScope(c *C) {
+	suite.createDeploymentIn(c, "search")
+	data := url.Values{}
+	data.Set(ImageParam, "xcnt/test:1.0.0")
+	data.Set(UpdateClassifierParam, "stable")
+	req := suite.PostRequestTo("/plans", data)
+	req.Header.Set("Authorization", "APIKey payments-secret")
+	suite.router.ServeHTTP(suite.recorder, req)
+	c.Assert(suite.recorder.Code, Equals, http.StatusForbidden)
+}
+
+func (suite *AuthTestSuite) TestUnknownKey(c *C) {
+	suite.router.ServeHTTP(suite.recorder, suite.postWithKey("payments-secret-2", "stable"))
+	c.Assert(suite.recorder.Code, Equals, http.StatusUnauthorized)
+}
+
+func (suite *AuthTestSuite) TestKeyWithSuffix(c *C) {
+	for _, key := range []string{suite.config.APIKey + "suffix", "payments-secret" + "suffix"} {
+		suite.recorder = httptest.NewRecorder()
+		suite.router.ServeHTTP(suite.recorder, suite.postWithKey(key, "stable"))
+		c.Assert(suite.recorder.Code, Equals, http.StatusUnauthorized)
+	}
+}
+
+func (suite *AuthTestSuite) TestOnlyScopedKeys(c *C) {
+	suite.config.APIKey = ""
+	suite.router, _ = getWeb(suite.config, false)
+	req, _ := http.NewRequest("GET", "/updates/abc", nil)
+	suite.router.ServeHTTP(suite.recorder, req)
+	c.Assert(suite.recorder.Code, Equals, http.StatusUnauthorized)
+}
+
+// tokenAuthenticator authenticates bearer tokens from a fixed map, standing in for the JWT authenticator.
+type tokenAuthenticator map[string]*auth.Principal
+
+func (authenticator tokenAuthenticator) Authenticate(request *http.Request) (*auth.Principal, error) {
+	principal, ok := authenticator[request.Header.Get("Authorization")]
+	if !ok {
+		return nil, auth.ErrUnauthenticated
+	}
+	return principal, nil
+}
+
+func (suite *AuthTestSuite) TestAdditionalAuthenticator(c *C) {
+	suite.config.Authenticators = []auth.Authenticator{tokenAuthenticator{
+		"Bearer payments-token": {Name: "repo:xcnt/payments", Scope: auth.Scope{Namespaces: []string{"payments"}}},
+	}}
+	suite.router, _ = getWeb(suite.config, false)
+	suite.createDeploymentIn(c, "payments")
+
+	req := suite.postWithKey("", "stable")
+	req.Header.Set("Authorization", "Bearer payments-token")
+	suite.router.ServeHTTP(suite.recorder, req)
+	c.Assert(suite.recorder.Code, Equals, http.StatusCreated)
+	response := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(suite.recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Requester, Equals, "repo:xcnt/payments")
+}
+
+func (suite *AuthTestSuite) TestAdditionalAuthenticatorUnknownToken(c *C) {
+	suite.config.Authenticators = []auth.Authenticator{tokenAuthenticator{}}
+	suite.router, _ = getWeb(suite.config, false)
+	req := suite.postWithKey("", "stable")
+	req.Header.Set("Authorization", "Bearer other-token")
+	suite.router.ServeHTTP(suite.recorder, req)
+	c.Assert(suite.recorder.Code, Equals, http.StatusUnauthorized)
+}
+
+func (suite *AuthTestSuite) signedPost(key string) *http.Request {
+	data := url.Values{}
+	data.Set(ImageParam, "xcnt/test:1.0.0")
+	data.Set(UpdateClassifierParam, "stable")
+	req := suite.PostRequestWith(data)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := RandStringRunes(16)
+	signature := auth.SignRequest(key, req.Method, req.URL.RequestURI(), timestamp, nonce, []byte(data.Encode()))
+	req.Header.Set("Authorization", auth.HMACScheme+" "+signature)
+	req.Header.Set(auth.TimestampHeader, timestamp)
+	req.Header.Set(auth.NonceHeader, nonce)
+	return req
+}
+
+func (suite *AuthTestSuite) TestSignedRequest(c *C) {
+	suite.createDeploymentIn(c, "payments")
+	suite.router.ServeHTTP(suite.recorder, suite.signedPost("payments-secret"))
+	c.Assert(suite.recorder.Code, Equals, http.StatusCreated)
+	response := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(suite.recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Requester, Equals, "payments-ci")
+}
+
+func (suite *AuthTestSuite) TestSignedRequestReplayed(c *C) {
+	suite.createDeploymentIn(c, "payments")
+	req := suite.signedPost("payments-secret")
+	replayed := suite.signedPost("payments-secret")
+	for _, header := range []string{"Authorization", auth.TimestampHeader, auth.NonceHeader} {
+		replayed.Header.Set(header, req.Header.Get(header))
+	}
+	suite.router.ServeHTTP(suite.recorder, req)
+	c.Assert(suite.recorder.Code, Equals, http.StatusCreated)
+	suite.recorder = httptest.NewRecorder()
+	suite.router.ServeHTTP(suite.recorder, replayed)
+	c.Assert(suite.recorder.Code, Equals, http.StatusUnauthorized)
+}
+
+func (suite *AuthTestSuite) TestRequireSignedRequests(c *C) {
+	suite.config.RequireSignedRequests = true
+	suite.router, _ = getWeb(suite.config, false)
+	suite.createDeploymentIn(c, "payments")
+	suite.router.ServeHTTP(suite.recorder, suite.postWithKey("payments-secret", "stable"))
+	c.Assert(suite.recorder.Code, Equals, http.StatusUnauthorized)
+
+	suite.recorder = httptest.NewRecorder()
+	suite.router.ServeHTTP(suite.recorder, suite.signedPost("payments-secret"))
+	c.Assert(suite.recorder.Code, Equals, http.StatusCreated)
+}
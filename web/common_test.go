@@ -2,9 +2,13 @@ package web
 
 import (
 	"fmt"
+	"kubernetes-update-manager/audit"
 	"math/rand"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -40,11 +44,37 @@ func (suite *GenericWebTestSuite) SetUpTest(c *C) {
 		Clientset:          suite.clientset,
 		AutoloadNamespaces: true,
 		APIKey:             RandStringRunes(25),
+		AuditLog:           audit.NewLog(10),
 	}
 	suite.router, _ = getWeb(suite.config, false)
 }
 
+func (suite *GenericWebTestSuite) serve(req *http.Request) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(recorder, req)
+	return recorder
+}
+
 func (suite *GenericWebTestSuite) Authenticate(req *http.Request) *http.Request {
 	req.Header.Set("Authorization", fmt.Sprintf("APIKey %s", suite.config.APIKey))
 	return req
 }
+
+func (suite *GenericWebTestSuite) PostRequestWith(data url.Values) *http.Request {
+	return suite.PostRequestTo("/updates", data)
+}
+
+func (suite *GenericWebTestSuite) PostRequestTo(path string, data url.Values) *http.Request {
+	req, _ := http.NewRequest("POST", path, strings.NewReader(data.Encode()))
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	suite.Authenticate(req)
+	return req
+}
+
+func (suite *GenericWebTestSuite) PostRequestComplete() *http.Request {
+	data := url.Values{}
+	data.Set(ImageParam, "xcnt/test:1.0.0")
+	data.Set(UpdateClassifierParam, "stable")
+	return suite.PostRequestWith(data)
+}
@@ -1,17 +1,82 @@
 package web
 
-import "k8s.io/client-go/kubernetes"
+import (
+	"kubernetes-update-manager/audit"
+	"kubernetes-update-manager/auth"
+	"kubernetes-update-manager/policy"
+	"kubernetes-update-manager/signature"
+	"kubernetes-update-manager/updater"
+	"kubernetes-update-manager/updater/manager"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// ImageVerifier checks the provenance of an image before it is rolled out.
+type ImageVerifier interface {
+	// Verify returns the digest of the verified image or an error if the image can not be verified.
+	Verify(image *updater.Image) (string, error)
+}
 
 // Config holds the necessary data for running the web interface of the update precense.
 type Config struct {
 	// Clientset holds the kubernetes configuration which should be used to access to the cluster.
 	Clientset kubernetes.Interface
 	// Namespaces ist he list of namespaces which should be searched when searching for update candidates. This is ignored if autoload
-	// namespaces is set to true
+	// namespaces is set to true or a namespace selector is configured.
 	Namespaces []string
 	// AutoloadNamespaces is a toggle scanning the cluster for all namespaces when applying the update configuration and sets all namespaces
-	// as the update candidate.
+	// as the update candidate. This is ignored if a namespace selector is configured.
 	AutoloadNamespaces bool
-	// APIKey is a pre shared key which is used to authenticate requests against the update endpoints.
+	// NamespaceSelector is a label selector choosing the namespaces which are searched for update candidates. If set, it replaces
+	// the autoload and namespaces configuration.
+	NamespaceSelector string
+	// LabelSelector is a label selector deployments and jobs need to match to be considered for an update. It is combined with
+	// the label selector passed in the update request.
+	LabelSelector string
+	// APIKey is a pre shared key which is used to authenticate requests against the update endpoints. Requests authenticated
+	// with it are not restricted to a scope but are not privileged, so they may not override the freeze calendar. Only
+	// named API keys, client certificates and token rules with a privileged scope may do so.
 	APIKey string
+	// APIKeys are named pre shared keys whose requests are restricted to the scope of the key.
+	APIKeys []auth.APIKey
+	// RequireSignedRequests rejects requests sending an API key verbatim. The keys are then only accepted as HMAC
+	// signatures of the requests.
+	RequireSignedRequests bool
+	// MaxClockSkew is the maximal age of a signed request. It defaults to auth.DefaultMaxClockSkew.
+	MaxClockSkew time.Duration
+	// AuditLog records the create, approve, reject, cancel, abort, rollback and delete calls and the promotions. If nil, the entries are only kept in memory.
+	AuditLog *audit.Log
+	// TLS configures the TLS listener of the server. If nil, the server listens on plain HTTP.
+	TLS *TLSConfig
+	// Authenticators are additional authenticators, for example for OIDC ID tokens, which are tried after the API keys.
+	Authenticators []auth.Authenticator
+	// SignaturePolicy specifies for which updates the signature of the image is verified. If nil, no signatures are checked.
+	SignaturePolicy *signature.Policy
+	// SignatureVerifier is used to check the image signatures for updates which are covered by the signature policy.
+	SignatureVerifier ImageVerifier
+	// RegistryPolicy restricts the registries and repositories of images which may be rolled out. If nil, all images are allowed.
+	RegistryPolicy *policy.RegistryPolicy
+	// ApprovalPolicy holds updates with protected update classifiers until other principals approved them. If nil, updates
+	// are started immediately.
+	ApprovalPolicy *policy.ApprovalPolicy
+	// PromotionPolicy schedules updates of further update classifiers with the image of successful updates. If nil,
+	// updates are not promoted.
+	PromotionPolicy *policy.PromotionPolicy
+	// Prometheus evaluates the queries of the metric analysis. If nil, the metrics of updated deployments are not analyzed.
+	Prometheus updater.MetricQuerier
+	// AnalysisPolicy configures the queries the deployments are analyzed with per update classifier. Without a policy,
+	// only the queries of the analysis annotations of the deployments are evaluated.
+	AnalysisPolicy *policy.AnalysisPolicy
+	// FreezeCalendar restricts when updates may be started. If nil, updates may be started at any time.
+	FreezeCalendar *policy.FreezeCalendar
+	// ConflictMode specifies if updates conflicting with a queued or running update are queued or rejected. It defaults to
+	// queue.
+	ConflictMode manager.ConflictMode
+	// RollbackPolicy specifies which deployments of a failed update are rolled back unless a deployment overrides it with
+	// its rollback policy annotation. It defaults to rollback-all.
+	RollbackPolicy updater.RollbackPolicy
+	// RollbackRetention is the time successful updates can be rolled back for after they finished. It defaults to
+	// manager.DefaultRollbackRetention.
+	RollbackRetention time.Duration
 }
@@ -0,0 +1,69 @@
+package web
+
+import (
+	"kubernetes-update-manager/policy"
+	"kubernetes-update-manager/updater"
+	"kubernetes-update-manager/updater/manager"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// OverrideFreezeParam is the parameter to start an update in an emergency even though the freeze calendar blocks it
+	OverrideFreezeParam = "override_freeze"
+)
+
+// verifyFreezeCalendar returns a plan verifier which checks the freeze calendar of the configuration at the time the
+// update is started at. Blocked updates are rejected or, if all blocking rules queue them, deferred until the calendar
+// allows them.
+func verifyFreezeCalendar(config *Config) manager.PlanVerifier {
+	return func(updateConfig *updater.Config, updatePlan updater.UpdatePlan) error {
+		startTime := time.Now()
+		if notBefore := updateConfig.GetNotBefore(); notBefore.After(startTime) {
+			startTime = notBefore
+		}
+		freeze := config.FreezeCalendar.Check(updateConfig.GetUpdateClassifier(), namespacesOf(updatePlan), startTime)
+		if freeze == nil {
+			return nil
+		}
+		if freeze.Action == policy.FreezeActionQueue {
+			return manager.Defer(freeze.Until, "%s", freeze.Reason)
+		}
+		if freeze.Until.IsZero() {
+			return manager.Reject("%s", freeze.Reason)
+		}
+		return manager.Reject("%s until %s", freeze.Reason, freeze.Until.Format(time.RFC3339))
+	}
+}
+
+// freezeOverrideFrom returns if the request overrides the freeze calendar. Only privileged principals may do so, the
+// request is aborted and false is returned for all others and for invalid values.
+func freezeOverrideFrom(context *gin.Context) (bool, bool) {
+	overrideString, ok := context.GetPostForm(OverrideFreezeParam)
+	if !ok {
+		return false, true
+	}
+	overrideFreeze, err := strconv.ParseBool(overrideString)
+	if err != nil {
+		context.AbortWithStatus(http.StatusBadRequest)
+		return false, false
+	}
+	if !overrideFreeze {
+		return false, true
+	}
+	principal := principalOf(context)
+	if principal == nil {
+		abortForbidden(context, "Only privileged principals may override the freeze calendar")
+		return false, false
+	}
+	if !principal.Privileged {
+		abortForbidden(context, principal.Name+" is not privileged and may not override the freeze calendar")
+		return false, false
+	}
+	log.WithField("principal", principal.Name).Warn("Freeze calendar overridden")
+	return true, true
+}
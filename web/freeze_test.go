@@ -0,0 +1,152 @@
+package web
+
+import (
+	"encoding/json"
+	"kubernetes-update-manager/audit"
+	"kubernetes-update-manager/auth"
+	"kubernetes-update-manager/policy"
+	"kubernetes-update-manager/updater/manager"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	. "gopkg.in/check.v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type FreezeTestSuite struct {
+	GenericWebTestSuite
+	until time.Time
+}
+
+var _ = Suite(&FreezeTestSuite{})
+
+func (suite *FreezeTestSuite) SetUpTest(c *C) {
+	suite.GenericWebTestSuite.SetUpTest(c)
+	suite.until = time.Now().Add(time.Hour).Truncate(time.Second)
+	from := metaV1.NewTime(time.Now().Add(-time.Hour))
+	until := metaV1.NewTime(suite.until)
+	suite.config.FreezeCalendar = &policy.FreezeCalendar{Rules: []policy.FreezeRule{
+		{Name: "release freeze", Classifiers: []string{"stable"}, Freezes: []policy.Window{{From: &from, Until: &until}}},
+		{Name: "staging freeze", Classifiers: []string{"staging"}, Action: policy.FreezeActionQueue, Freezes: []policy.Window{{From: &from, Until: &until}}},
+	}}
+	suite.config.APIKeys = []auth.APIKey{
+		{Name: "on-call", Key: "on-call-secret", Scope: auth.Scope{Privileged: true}},
+		{Name: "developer", Key: "developer-secret"},
+	}
+	suite.router, _ = getWeb(suite.config, false)
+}
+
+func (suite *FreezeTestSuite) post(updateClassifier string, apiKey string, overrideFreeze bool) *httptest.ResponseRecorder {
+	data := url.Values{}
+	data.Set(ImageParam, "xcnt/test:1.0.0")
+	data.Set(UpdateClassifierParam, updateClassifier)
+	if overrideFreeze {
+		data.Set(OverrideFreezeParam, "true")
+	}
+	req := suite.PostRequestWith(data)
+	req.Header.Set("Authorization", "APIKey "+apiKey)
+	recorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func (suite *FreezeTestSuite) TestFrozenUpdateIsRejected(c *C) {
+	recorder := suite.post("stable", "developer-secret", false)
+	c.Assert(recorder.Code, Equals, http.StatusForbidden)
+	response := &ErrorSerialized{}
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Error, Equals, "Updates are frozen by release freeze until "+suite.until.Format(time.RFC3339))
+
+	entries := suite.config.AuditLog.Query(audit.Filter{})
+	c.Assert(entries, HasLen, 1)
+	c.Assert(entries[0].Outcome, Equals, audit.OutcomeRejected)
+}
+
+func (suite *FreezeTestSuite) TestFrozenUpdateIsQueued(c *C) {
+	recorder := suite.post("staging", "developer-secret", false)
+	c.Assert(recorder.Code, Equals, http.StatusCreated)
+	response := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Status.State, Equals, string(manager.StateScheduled))
+	c.Assert(response.Status.Finished, Equals, false)
+	c.Assert(response.Schedule, NotNil)
+	c.Assert(response.Schedule.StartTime.Equal(suite.until), Equals, true)
+	c.Assert(response.Schedule.Reason, Equals, "Updates are frozen by staging freeze")
+}
+
+func (suite *FreezeTestSuite) TestOtherClassifiersAreNotFrozen(c *C) {
+	recorder := suite.post("develop", "developer-secret", false)
+	c.Assert(recorder.Code, Equals, http.StatusCreated)
+	response := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Schedule, IsNil)
+}
+
+func (suite *FreezeTestSuite) TestOverrideRequiresPrivilegedPrincipal(c *C) {
+	recorder := suite.post("stable", "developer-secret", true)
+	c.Assert(recorder.Code, Equals, http.StatusForbidden)
+	response := &ErrorSerialized{}
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Error, Equals, "developer is not privileged and may not override the freeze calendar")
+}
+
+func (suite *FreezeTestSuite) TestPrivilegedOverride(c *C) {
+	recorder := suite.post("stable", "on-call-secret", true)
+	c.Assert(recorder.Code, Equals, http.StatusCreated)
+	response := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Schedule, IsNil)
+
+	entries := suite.config.AuditLog.Query(audit.Filter{})
+	c.Assert(entries, HasLen, 1)
+	c.Assert(entries[0].Requester, Equals, "on-call")
+	c.Assert(entries[0].FreezeOverride, Equals, true)
+	c.Assert(entries[0].Outcome, Equals, audit.OutcomeSucceeded)
+}
+
+func (suite *FreezeTestSuite) TestInvalidOverride(c *C) {
+	data := url.Values{}
+	data.Set(ImageParam, "xcnt/test:1.0.0")
+	data.Set(UpdateClassifierParam, "stable")
+	data.Set(OverrideFreezeParam, "maybe")
+	recorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(recorder, suite.PostRequestWith(data))
+	c.Assert(recorder.Code, Equals, http.StatusBadRequest)
+}
+
+func (suite *FreezeTestSuite) TestSharedAPIKeyMayNotOverride(c *C) {
+	recorder := suite.post("stable", suite.config.APIKey, true)
+	c.Assert(recorder.Code, Equals, http.StatusForbidden)
+	response := &ErrorSerialized{}
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Error, Equals, "default is not privileged and may not override the freeze calendar")
+}
+
+func (suite *FreezeTestSuite) TestApprovedUpdateIsCheckedAgainstFreeze(c *C) {
+	suite.config.ApprovalPolicy = &policy.ApprovalPolicy{Classifiers: map[string]policy.ApprovalRule{
+		"production": {RequiredApprovers: 1},
+	}}
+	suite.router, _ = getWeb(suite.config, false)
+	recorder := suite.post("production", "developer-secret", false)
+	c.Assert(recorder.Code, Equals, http.StatusCreated)
+	created := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), created), IsNil)
+	c.Assert(created.Status.State, Equals, string(manager.StatePendingApproval))
+
+	from := metaV1.NewTime(time.Now().Add(-time.Minute))
+	until := metaV1.NewTime(suite.until)
+	suite.config.FreezeCalendar.Rules = append(suite.config.FreezeCalendar.Rules, policy.FreezeRule{
+		Name: "production freeze", Classifiers: []string{"production"}, Freezes: []policy.Window{{From: &from, Until: &until}},
+	})
+	req := suite.PostRequestTo("/updates/"+created.UUID+"/approve", url.Values{})
+	req.Header.Set("Authorization", "APIKey on-call-secret")
+	recorder = httptest.NewRecorder()
+	suite.router.ServeHTTP(recorder, req)
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	response := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Status.State, Equals, string(manager.StateFailed))
+	c.Assert(response.Status.Finished, Equals, true)
+}
@@ -0,0 +1,119 @@
+package web
+
+import (
+	"encoding/json"
+	"kubernetes-update-manager/audit"
+	"kubernetes-update-manager/policy"
+	"kubernetes-update-manager/signature"
+	"kubernetes-update-manager/updater/manager"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	. "gopkg.in/check.v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type PromotionTestSuite struct {
+	GenericWebTestSuite
+}
+
+var _ = Suite(&PromotionTestSuite{})
+
+func (suite *PromotionTestSuite) SetUpTest(c *C) {
+	suite.GenericWebTestSuite.SetUpTest(c)
+	suite.config.PromotionPolicy = &policy.PromotionPolicy{Classifiers: map[string]policy.PromotionRule{
+		"staging": {To: "stable", After: &metaV1.Duration{Duration: time.Hour}},
+	}}
+	suite.router, _ = getWeb(suite.config, false)
+}
+
+func (suite *PromotionTestSuite) get(c *C, updateUUID string) *UpdateProgressSerialized {
+	req, _ := http.NewRequest("GET", "/updates/"+updateUUID, nil)
+	recorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(recorder, suite.Authenticate(req))
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	response := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), response), IsNil)
+	return response
+}
+
+// promotedStaging creates an update of the staging update classifier and waits for its promotion to be decided.
+func (suite *PromotionTestSuite) promotedStaging(c *C) *UpdateProgressSerialized {
+	data := url.Values{}
+	data.Set(ImageParam, "xcnt/test:1.0.0")
+	data.Set(UpdateClassifierParam, "staging")
+	suite.router.ServeHTTP(suite.recorder, suite.PostRequestWith(data))
+	c.Assert(suite.recorder.Code, Equals, http.StatusCreated)
+	created := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(suite.recorder.Body.Bytes(), created), IsNil)
+
+	// The manager checks every second if the update succeeded.
+	staging := suite.get(c, created.UUID)
+	for i := 0; i < 30 && len(staging.Promotion.UpdateUUID) == 0 && len(staging.Promotion.Error) == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+		staging = suite.get(c, created.UUID)
+	}
+	c.Assert(staging.Status.Successful, Equals, true)
+	return staging
+}
+
+func (suite *PromotionTestSuite) TestPromote(c *C) {
+	data := url.Values{}
+	data.Set(ImageParam, "xcnt/test:1.0.0")
+	data.Set(UpdateClassifierParam, "staging")
+	suite.router.ServeHTTP(suite.recorder, suite.PostRequestWith(data))
+	c.Assert(suite.recorder.Code, Equals, http.StatusCreated)
+	created := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(suite.recorder.Body.Bytes(), created), IsNil)
+	c.Assert(created.Promotion, DeepEquals, &PromotionSerialized{UpdateClassifier: "stable", Delay: "1h0m0s"})
+
+	// The manager checks every second if the update succeeded.
+	staging := suite.get(c, created.UUID)
+	for i := 0; i < 30 && len(staging.Promotion.UpdateUUID) == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+		staging = suite.get(c, created.UUID)
+	}
+	c.Assert(staging.Status.Successful, Equals, true)
+	c.Assert(staging.Promotion.UpdateUUID, Not(Equals), "")
+	stable := suite.get(c, staging.Promotion.UpdateUUID)
+	c.Assert(stable.PromotedFrom, Equals, created.UUID)
+	c.Assert(stable.Promotion, IsNil)
+	c.Assert(stable.Status.State, Equals, string(manager.StateScheduled))
+	c.Assert(stable.Schedule.StartTime.Equal(staging.Status.FinishTime.Add(time.Hour)), Equals, true)
+
+	entries := suite.config.AuditLog.Query(audit.Filter{Action: audit.ActionPromote})
+	c.Assert(entries, HasLen, 1)
+	c.Assert(entries[0].UpdateUUID, Equals, stable.UUID)
+	c.Assert(entries[0].UpdateClassifier, Equals, "stable")
+	c.Assert(entries[0].Requester, Equals, DefaultAPIKeyName)
+	c.Assert(entries[0].Outcome, Equals, audit.OutcomeSucceeded)
+}
+
+func (suite *PromotionTestSuite) TestPromoteImageNotAllowed(c *C) {
+	suite.config.RegistryPolicy = &policy.RegistryPolicy{Classifiers: map[string]policy.ImageRules{
+		"stable": {Registries: []string{"eu.gcr.io"}},
+	}}
+	staging := suite.promotedStaging(c)
+	c.Assert(staging.Promotion.UpdateUUID, Equals, "")
+	c.Assert(staging.Promotion.Error, Equals, `Registry docker.io of image xcnt/test:1.0.0 is not allowed for update classifier "stable"`)
+
+	entries := suite.config.AuditLog.Query(audit.Filter{Action: audit.ActionPromote})
+	c.Assert(entries, HasLen, 1)
+	c.Assert(entries[0].Outcome, Equals, audit.OutcomeFailed)
+	c.Assert(entries[0].Error, Equals, staging.Promotion.Error)
+}
+
+func (suite *PromotionTestSuite) TestPromoteUnsignedImage(c *C) {
+	verifier := &fakeImageVerifier{err: signature.ErrNoSignature}
+	suite.config.SignatureVerifier = verifier
+	suite.config.SignaturePolicy = signature.NewPolicy(signature.ModeOff)
+	suite.config.SignaturePolicy.Classifiers["stable"] = signature.ModeEnforce
+	suite.router, _ = getWeb(suite.config, false)
+
+	staging := suite.promotedStaging(c)
+	c.Assert(staging.Promotion.UpdateUUID, Equals, "")
+	c.Assert(staging.Promotion.Error, Matches, ".*xcnt/test:1.0.0.*not been signed.*")
+	c.Assert(verifier.images, DeepEquals, []string{"xcnt/test:1.0.0"})
+}
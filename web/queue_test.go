@@ -0,0 +1,74 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"kubernetes-update-manager/updater"
+	"kubernetes-update-manager/updater/manager"
+	"net/http"
+	"net/http/httptest"
+
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type QueueTestSuite struct {
+	GenericWebTestSuite
+}
+
+var _ = Suite(&QueueTestSuite{})
+
+func (suite *QueueTestSuite) SetUpTest(c *C) {
+	suite.GenericWebTestSuite.SetUpTest(c)
+	namespace := &apiv1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "default"}}
+	_, err := suite.clientset.CoreV1().Namespaces().Create(context.Background(), namespace, metaV1.CreateOptions{})
+	c.Assert(err, IsNil)
+	deployment := &v1.Deployment{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:        "api",
+			Namespace:   "default",
+			Annotations: map[string]string{updater.UpdateClassifier: "stable"},
+		},
+		Spec: v1.DeploymentSpec{Template: apiv1.PodTemplateSpec{Spec: apiv1.PodSpec{
+			Containers: []apiv1.Container{{Name: "app", Image: "xcnt/test:0.9.0"}},
+		}}},
+		Status: v1.DeploymentStatus{Replicas: 1},
+	}
+	_, err = suite.clientset.AppsV1().Deployments("default").Create(context.Background(), deployment, metaV1.CreateOptions{})
+	c.Assert(err, IsNil)
+}
+
+func (suite *QueueTestSuite) create(c *C, statusCode int) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	suite.router.ServeHTTP(recorder, suite.PostRequestComplete())
+	c.Assert(recorder.Code, Equals, statusCode)
+	return recorder
+}
+
+func (suite *QueueTestSuite) TestConflictingUpdateIsQueued(c *C) {
+	first := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(suite.create(c, http.StatusCreated).Body.Bytes(), first), IsNil)
+	c.Assert(first.Status.State, Equals, string(manager.StateRunning))
+	c.Assert(first.Queue, IsNil)
+
+	second := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(suite.create(c, http.StatusCreated).Body.Bytes(), second), IsNil)
+	c.Assert(second.Status.State, Equals, string(manager.StateQueued))
+	c.Assert(second.Status.Finished, Equals, false)
+	c.Assert(second.Queue, NotNil)
+	c.Assert(second.Queue.Position, Equals, 1)
+	c.Assert(second.Queue.UpdateUUIDs, DeepEquals, []string{first.UUID})
+}
+
+func (suite *QueueTestSuite) TestRejectConflictingUpdate(c *C) {
+	suite.config.ConflictMode = manager.ConflictModeReject
+	suite.router, _ = getWeb(suite.config, false)
+	first := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(suite.create(c, http.StatusCreated).Body.Bytes(), first), IsNil)
+
+	response := &ErrorSerialized{}
+	c.Assert(json.Unmarshal(suite.create(c, http.StatusConflict).Body.Bytes(), response), IsNil)
+	c.Assert(response.Error, Equals, "The update conflicts with the queued or running updates "+first.UUID)
+}
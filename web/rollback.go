@@ -0,0 +1,41 @@
+package web
+
+import (
+	"kubernetes-update-manager/audit"
+	"kubernetes-update-manager/updater/manager"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Rollback represents the POST method to roll back a successful update.
+// @Summary Rolls back an update
+// @Description restores the deployments updated by a successful update to the pod templates they ran before. The rollback is tracked as an update of its own, which is returned. An update is only rolled back once and deployments changed since the update are only rolled back if forced.
+// @Tags updates
+// @Produce json
+// @Security ApiKeyAuth
+// @Param uuid path string true "The uuid of the update which should be rolled back"
+// @Param force body bool false "Discard changes of the deployments made since the update"
+// @Success 200 {object} web.UpdateProgressSerialized
+// @Failure 400
+// @Failure 401
+// @Failure 403 {object} web.ErrorSerialized
+// @Failure 404
+// @Failure 409 {object} web.ErrorSerialized
+// @Router /updates/{uuid}/rollback [post]
+func (updateHandler *UpdaterHandler) Rollback(context *gin.Context) {
+	force := false
+	if forceString, ok := context.GetPostForm(ForceParam); ok {
+		var err error
+		force, err = strconv.ParseBool(forceString)
+		if err != nil {
+			context.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+	}
+	updateHandler.decide(context, audit.ActionRollback, func(updateUUID uuid.UUID, principal string) (manager.UpdateProgress, error) {
+		return updateHandler.manager.Rollback(updateUUID, principal, force)
+	})
+}
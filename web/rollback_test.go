@@ -0,0 +1,124 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"kubernetes-update-manager/audit"
+	"kubernetes-update-manager/updater"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type RollbackTestSuite struct {
+	GenericWebTestSuite
+}
+
+var _ = Suite(&RollbackTestSuite{})
+
+func (suite *RollbackTestSuite) SetUpTest(c *C) {
+	suite.GenericWebTestSuite.SetUpTest(c)
+	namespace := &apiv1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: "default"}}
+	_, err := suite.clientset.CoreV1().Namespaces().Create(context.Background(), namespace, metaV1.CreateOptions{})
+	c.Assert(err, IsNil)
+	deployment := &v1.Deployment{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:        "api",
+			Namespace:   "default",
+			Annotations: map[string]string{updater.UpdateClassifier: "stable"},
+		},
+		Spec: v1.DeploymentSpec{Template: apiv1.PodTemplateSpec{Spec: apiv1.PodSpec{
+			Containers: []apiv1.Container{{Name: "app", Image: "xcnt/test:0.9.0"}},
+		}}},
+		Status: v1.DeploymentStatus{Replicas: 1, ReadyReplicas: 1},
+	}
+	_, err = suite.clientset.AppsV1().Deployments("default").Create(context.Background(), deployment, metaV1.CreateOptions{})
+	c.Assert(err, IsNil)
+}
+
+func (suite *RollbackTestSuite) get(c *C, updateUUID string) *UpdateProgressSerialized {
+	req, _ := http.NewRequest("GET", "/updates/"+updateUUID, nil)
+	recorder := suite.serve(suite.Authenticate(req))
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	response := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), response), IsNil)
+	return response
+}
+
+func (suite *RollbackTestSuite) image(c *C) string {
+	deployment, err := suite.clientset.AppsV1().Deployments("default").Get(context.Background(), "api", metaV1.GetOptions{})
+	c.Assert(err, IsNil)
+	return deployment.Spec.Template.Spec.Containers[0].Image
+}
+
+// succeededUpdate creates an update of the deployment and waits for it to succeed.
+func (suite *RollbackTestSuite) succeededUpdate(c *C) *UpdateProgressSerialized {
+	recorder := suite.serve(suite.PostRequestComplete())
+	c.Assert(recorder.Code, Equals, http.StatusCreated)
+	created := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), created), IsNil)
+	update := suite.get(c, created.UUID)
+	for i := 0; i < 30 && !update.Status.Finished; i++ {
+		time.Sleep(100 * time.Millisecond)
+		update = suite.get(c, created.UUID)
+	}
+	c.Assert(update.Status.Successful, Equals, true)
+	c.Assert(suite.image(c), Equals, "xcnt/test:1.0.0")
+	return update
+}
+
+func (suite *RollbackTestSuite) TestRollback(c *C) {
+	created := suite.succeededUpdate(c)
+
+	recorder := suite.serve(suite.PostRequestTo("/updates/"+created.UUID+"/rollback", url.Values{}))
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	rollback := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), rollback), IsNil)
+	c.Assert(rollback.UUID, Not(Equals), created.UUID)
+	c.Assert(rollback.RollbackOf, Equals, created.UUID)
+	for i := 0; i < 30 && !rollback.Status.Finished; i++ {
+		time.Sleep(100 * time.Millisecond)
+		rollback = suite.get(c, rollback.UUID)
+	}
+	c.Assert(rollback.Status.Successful, Equals, true)
+	c.Assert(suite.image(c), Equals, "xcnt/test:0.9.0")
+
+	entries := suite.config.AuditLog.Query(audit.Filter{Action: audit.ActionRollback})
+	c.Assert(entries, HasLen, 1)
+	c.Assert(entries[0].UpdateUUID, Equals, created.UUID)
+	c.Assert(entries[0].Outcome, Equals, audit.OutcomeSucceeded)
+
+	recorder = suite.serve(suite.PostRequestTo("/updates/"+rollback.UUID+"/rollback", url.Values{}))
+	c.Assert(recorder.Code, Equals, http.StatusConflict)
+	recorder = suite.serve(suite.PostRequestTo("/updates/"+created.UUID+"/rollback", url.Values{}))
+	c.Assert(recorder.Code, Equals, http.StatusConflict)
+}
+
+func (suite *RollbackTestSuite) TestRollbackOfChangedDeployment(c *C) {
+	created := suite.succeededUpdate(c)
+	deployment, err := suite.clientset.AppsV1().Deployments("default").Get(context.Background(), "api", metaV1.GetOptions{})
+	c.Assert(err, IsNil)
+	deployment.Spec.Template.Spec.Containers[0].Image = "xcnt/test:1.0.1"
+	_, err = suite.clientset.AppsV1().Deployments("default").Update(context.Background(), deployment, metaV1.UpdateOptions{})
+	c.Assert(err, IsNil)
+
+	recorder := suite.serve(suite.PostRequestTo("/updates/"+created.UUID+"/rollback", url.Values{}))
+	c.Assert(recorder.Code, Equals, http.StatusConflict)
+	response := &ErrorSerialized{}
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Error, Equals, "Deployment default/api has been changed since the update, force the rollback to discard the change")
+
+	recorder = suite.serve(suite.PostRequestTo("/updates/"+created.UUID+"/rollback", url.Values{ForceParam: {"true"}}))
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+}
+
+func (suite *RollbackTestSuite) TestRollbackNotFound(c *C) {
+	recorder := suite.serve(suite.PostRequestTo("/updates/"+uuid.New().String()+"/rollback", url.Values{}))
+	c.Assert(recorder.Code, Equals, http.StatusNotFound)
+}
@@ -1,10 +1,8 @@
 package web
 
 import (
-	"crypto/subtle"
+	"kubernetes-update-manager/auth"
 	"kubernetes-update-manager/updater/manager"
-	"net/http"
-	"strings"
 
 	"github.com/getsentry/raven-go"
 	"github.com/gin-contrib/sentry"
@@ -55,33 +53,25 @@ func registerRoutes(router *gin.Engine, config *Config) *manager.Manager {
 
 func registerUpdaterRoutes(router *gin.Engine, config *Config) *manager.Manager {
 	updater := NewUpdaterHandler(config)
-	authCheck := RequireAuth(config.APIKey)
+	authCheck := RequireAuthenticator(newAuthenticator(config))
 	router.GET("/updates/:uuid", authCheck, updater.GetItem)
 	router.DELETE("/updates/:uuid", authCheck, updater.Delete)
 	router.POST("/updates", authCheck, updater.Post)
+	router.POST("/updates/:uuid/approve", authCheck, updater.Approve)
+	router.POST("/updates/:uuid/reject", authCheck, updater.Reject)
+	router.POST("/updates/:uuid/cancel", authCheck, updater.Cancel)
+	router.POST("/updates/:uuid/rollback", authCheck, updater.Rollback)
+	router.POST("/plans", authCheck, updater.PostPlan)
+	router.GET("/audit", authCheck, updater.GetAudit)
 	return updater.manager
 }
 
 // RequireAuth returns a usable middleware who includes authorization checks in the given endpoint.
 func RequireAuth(apiKey string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		authorization := c.GetHeader("Authorization")
-		authorizationData := strings.SplitN(authorization, " ", 2)
-		authorizationString := authorizationData[len(authorizationData)-1]
-
-		if SecureCompare(authorizationString, apiKey) {
-			c.Next()
-		} else {
-			c.AbortWithStatus(http.StatusUnauthorized)
-		}
-	}
+	return RequireAuthenticator(auth.NewAPIKeyAuthenticator([]auth.APIKey{{Name: DefaultAPIKeyName, Key: apiKey}}))
 }
 
 // SecureCompare compares two strings in a time constant way to avoid possible timing attacks on the password check.
 func SecureCompare(left, right string) bool {
-	for len(left) < len(right) {
-		left += " "
-	}
-	left = left[:len(right)]
-	return subtle.ConstantTimeCompare([]byte(left), []byte(right)) == 1
+	return auth.SecureCompare(left, right)
 }
@@ -0,0 +1,100 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"kubernetes-update-manager/audit"
+	"kubernetes-update-manager/auth"
+	"kubernetes-update-manager/policy"
+	"kubernetes-update-manager/updater/manager"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	. "gopkg.in/check.v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type ScheduleTestSuite struct {
+	GenericWebTestSuite
+	notBefore time.Time
+}
+
+var _ = Suite(&ScheduleTestSuite{})
+
+func (suite *ScheduleTestSuite) SetUpTest(c *C) {
+	suite.GenericWebTestSuite.SetUpTest(c)
+	suite.notBefore = time.Now().Add(2 * time.Hour).Truncate(time.Second)
+	suite.config.APIKeys = []auth.APIKey{
+		{Name: "staging-ci", Key: "staging-secret", Scope: auth.Scope{Classifiers: []string{"staging"}}},
+	}
+	suite.router, _ = getWeb(suite.config, false)
+}
+
+func (suite *ScheduleTestSuite) schedule(notBefore string) *httptest.ResponseRecorder {
+	data := url.Values{}
+	data.Set(ImageParam, "xcnt/test:1.0.0")
+	data.Set(UpdateClassifierParam, "stable")
+	data.Set(NotBeforeParam, notBefore)
+	return suite.serve(suite.PostRequestWith(data))
+}
+
+func (suite *ScheduleTestSuite) create(c *C) *UpdateProgressSerialized {
+	recorder := suite.schedule(suite.notBefore.Format(time.RFC3339))
+	c.Assert(recorder.Code, Equals, http.StatusCreated)
+	response := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), response), IsNil)
+	return response
+}
+
+func (suite *ScheduleTestSuite) cancel(updateUUID string) *httptest.ResponseRecorder {
+	return suite.serve(suite.PostRequestTo(fmt.Sprintf("/updates/%s/cancel", updateUUID), url.Values{}))
+}
+
+func (suite *ScheduleTestSuite) TestScheduled(c *C) {
+	response := suite.create(c)
+	c.Assert(response.Status.State, Equals, string(manager.StateScheduled))
+	c.Assert(response.Status.Finished, Equals, false)
+	c.Assert(response.Schedule, NotNil)
+	c.Assert(response.Schedule.StartTime.Equal(suite.notBefore), Equals, true)
+}
+
+func (suite *ScheduleTestSuite) TestInvalidNotBefore(c *C) {
+	recorder := suite.schedule("tomorrow at two")
+	c.Assert(recorder.Code, Equals, http.StatusBadRequest)
+}
+
+func (suite *ScheduleTestSuite) TestCancel(c *C) {
+	created := suite.create(c)
+	recorder := suite.cancel(created.UUID)
+	c.Assert(recorder.Code, Equals, http.StatusOK)
+	response := &UpdateProgressSerialized{}
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Status.State, Equals, string(manager.StateCancelled))
+	c.Assert(response.Status.Finished, Equals, true)
+
+	entries := suite.config.AuditLog.Query(audit.Filter{Action: audit.ActionCancel})
+	c.Assert(entries, HasLen, 1)
+	c.Assert(entries[0].UpdateUUID, Equals, created.UUID)
+	c.Assert(entries[0].Outcome, Equals, audit.OutcomeSucceeded)
+
+	c.Assert(suite.cancel(created.UUID).Code, Equals, http.StatusConflict)
+}
+
+func (suite *ScheduleTestSuite) TestCancelOutOfScope(c *C) {
+	created := suite.create(c)
+	req := suite.PostRequestTo(fmt.Sprintf("/updates/%s/cancel", created.UUID), url.Values{})
+	req.Header.Set("Authorization", "APIKey staging-secret")
+	c.Assert(suite.serve(req).Code, Equals, http.StatusForbidden)
+}
+
+func (suite *ScheduleTestSuite) TestFreezeCalendarIsCheckedAtStartTime(c *C) {
+	from := metaV1.NewTime(suite.notBefore.Add(-time.Minute))
+	until := metaV1.NewTime(suite.notBefore.Add(time.Hour))
+	suite.config.FreezeCalendar = &policy.FreezeCalendar{Rules: []policy.FreezeRule{
+		{Name: "night freeze", Freezes: []policy.Window{{From: &from, Until: &until}}},
+	}}
+	suite.router, _ = getWeb(suite.config, false)
+	c.Assert(suite.schedule(suite.notBefore.Format(time.RFC3339)).Code, Equals, http.StatusForbidden)
+}
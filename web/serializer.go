@@ -1,8 +1,13 @@
 package web
 
 import (
+	"fmt"
+	"kubernetes-update-manager/updater"
 	"kubernetes-update-manager/updater/manager"
 	"time"
+
+	"github.com/google/uuid"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // ProgressCountSerialized shows the progress information of the current
@@ -36,6 +41,54 @@ type StatusSerialized struct {
 	Failed bool `json:"failed"`
 	// Successful returns if the update has been succesful
 	Successful bool `json:"successful"`
+	// State is the phase of the update, for example pending_approval, running, succeeded or failed.
+	State string `json:"state"`
+}
+
+// ApprovalSerialized describes the approvals of an update which has been held for approval.
+type ApprovalSerialized struct {
+	// RequiredApprovers is the number of approvals the update needs before it is started.
+	RequiredApprovers int `json:"required_approvers"`
+	// Approvers are the names of the principals which approved the update.
+	Approvers []string `json:"approvers"`
+	// Rejecter is the name of the principal which rejected the update.
+	Rejecter string `json:"rejecter,omitempty"`
+	// Reason describes why the update has been rejected.
+	Reason string `json:"reason,omitempty"`
+	// ExpiryTime is the time the update expires at if it has not been approved.
+	ExpiryTime time.Time `json:"expiry_time"`
+}
+
+// ScheduleSerialized describes when an update whose start has been deferred will be started.
+type ScheduleSerialized struct {
+	// StartTime is the time the update will be started at.
+	StartTime time.Time `json:"start_time"`
+	// Reason describes why the start has been deferred.
+	Reason string `json:"reason"`
+	// Error describes why the update could not be started at the start time.
+	Error string `json:"error,omitempty"`
+}
+
+// QueueSerialized describes the updates a queued update waits for.
+type QueueSerialized struct {
+	// Position is the number of unfinished updates the update waits for. It is 0 once the update has been started.
+	Position int `json:"position"`
+	// UpdateUUIDs identify the updates the update waits for.
+	UpdateUUIDs []string `json:"update_uuids"`
+	// Error describes why the update could not be started once it left the queue.
+	Error string `json:"error,omitempty"`
+}
+
+// PromotionSerialized describes the update a successful update is promoted to.
+type PromotionSerialized struct {
+	// UpdateClassifier is the update classifier the image is promoted to.
+	UpdateClassifier string `json:"update_classifier"`
+	// Delay is the time between the success of the update and the start of the promoted update.
+	Delay string `json:"delay"`
+	// UpdateUUID identifies the promoted update once the update succeeded.
+	UpdateUUID string `json:"update_uuid,omitempty"`
+	// Error describes why the update has not been promoted.
+	Error string `json:"error,omitempty"`
 }
 
 // UpdateProgressSerialized represents a serialized upgrade step
@@ -44,15 +97,152 @@ type StatusSerialized struct {
 type UpdateProgressSerialized struct {
 	// UUID returns the unique identifier of this update configuration.
 	UUID string `json:"uuid"`
+	// Requester is the name of the API key or identity which requested the update.
+	Requester string `json:"requester"`
 	// Counts returns the amount of jobs and deployments when it has been progressed
 	Counts CountSerialized `json:"counts"`
 	// Status returns the current status of the update progress.
 	Status StatusSerialized `json:"status"`
+	// Approval is only set for updates which need to be approved before they are started.
+	Approval *ApprovalSerialized `json:"approval,omitempty"`
+	// Schedule is only set for updates whose start has been deferred, for example by a freeze window.
+	Schedule *ScheduleSerialized `json:"schedule,omitempty"`
+	// Queue describes the updates the update waits for. It is omitted if the update never conflicted with another update.
+	Queue *QueueSerialized `json:"queue,omitempty"`
+	// Promotion describes the update the update is promoted to. It is omitted if the update is not promoted.
+	Promotion *PromotionSerialized `json:"promotion,omitempty"`
+	// PromotedFrom is the uuid of the update which has been promoted to the update.
+	PromotedFrom string `json:"promoted_from,omitempty"`
+	// RollbackOf is the uuid of the update which is rolled back by the update.
+	RollbackOf string `json:"rollback_of,omitempty"`
+	// RollbackJobs describes the jobs run while rolling back the update. It is omitted if no rollback job has been run.
+	RollbackJobs *RollbackJobsSerialized `json:"rollback_jobs,omitempty"`
+}
+
+// RollbackJobsSerialized describes the jobs which have been run while rolling back the update.
+type RollbackJobsSerialized struct {
+	// Total is the number of created rollback jobs.
+	Total int `json:"total"`
+	// Succeeded is the number of rollback jobs which succeeded.
+	Succeeded int `json:"succeeded"`
+	// Failed is the number of rollback jobs which failed.
+	Failed int `json:"failed"`
+}
+
+// ErrorSerialized describes why a request could not be handled.
+type ErrorSerialized struct {
+	// Error is the human readable description of the problem.
+	Error string `json:"error"`
+}
+
+// ContainerChangeSerialized describes a container of a workload which runs the updated image.
+type ContainerChangeSerialized struct {
+	// Container is the name of the container.
+	Container string `json:"container"`
+	// Type is either container or initContainer.
+	Type string `json:"type"`
+	// PreviousImage is the image the container is currently running.
+	PreviousImage string `json:"previous_image"`
+	// Image is the image the container runs after the update.
+	Image string `json:"image"`
+	// Excluded is set if the container has been excluded from the update by the workload annotations.
+	Excluded bool `json:"excluded"`
+}
+
+// WorkloadPlanSerialized describes a workload which is part of an update plan.
+type WorkloadPlanSerialized struct {
+	// Namespace of the workload
+	Namespace string `json:"namespace"`
+	// Name of the workload. For jobs this is the name of the migration job which would be created.
+	Name string `json:"name"`
+	// Containers running the updated image
+	Containers []ContainerChangeSerialized `json:"containers"`
+}
+
+// PlanSerialized is the preview of an update request.
+type PlanSerialized struct {
+	// Deployments which would be updated
+	Deployments []WorkloadPlanSerialized `json:"deployments"`
+	// Jobs which would be created
+	Jobs []WorkloadPlanSerialized `json:"jobs"`
+	// Waves are the deployments, as namespace/name, grouped in the order they would be updated in. A wave is only
+	// updated once all deployments of the previous waves are ready.
+	Waves [][]string `json:"waves"`
+	// Canary is the configuration of the canaries the deployments would be soaked in before they are updated
+	Canary *CanarySerialized `json:"canary,omitempty"`
+}
+
+// CanarySerialized describes the canaries of an update.
+type CanarySerialized struct {
+	// Weight is the percentage of the pods running the new image in the canaries
+	Weight int `json:"weight"`
+	// SoakTime is the duration the canaries have to stay healthy before the deployments are updated
+	SoakTime string `json:"soak_time"`
+	// MaxRestarts is the number of container restarts of the canary pods which are tolerated
+	MaxRestarts int32 `json:"max_restarts"`
+}
+
+func serializePlan(updatePlan updater.UpdatePlan) *PlanSerialized {
+	containers := map[string][]ContainerChangeSerialized{}
+	for _, change := range updatePlan.GetContainerChanges() {
+		key := workloadKey(change.Kind, change.Namespace, change.Name)
+		containers[key] = append(containers[key], ContainerChangeSerialized{
+			Container:     change.Container,
+			Type:          change.ContainerType,
+			PreviousImage: change.PreviousImage,
+			Image:         change.Image,
+			Excluded:      change.Excluded,
+		})
+	}
+	serializeWorkload := func(kind string, meta metaV1.Object) WorkloadPlanSerialized {
+		workloadContainers := containers[workloadKey(kind, meta.GetNamespace(), meta.GetName())]
+		if workloadContainers == nil {
+			workloadContainers = make([]ContainerChangeSerialized, 0)
+		}
+		return WorkloadPlanSerialized{
+			Namespace:  meta.GetNamespace(),
+			Name:       meta.GetName(),
+			Containers: workloadContainers,
+		}
+	}
+
+	plan := &PlanSerialized{
+		Deployments: make([]WorkloadPlanSerialized, 0),
+		Jobs:        make([]WorkloadPlanSerialized, 0),
+		Waves:       make([][]string, 0),
+	}
+	deployments := updatePlan.GetToApplyDeployments()
+	for _, deployment := range deployments {
+		plan.Deployments = append(plan.Deployments, serializeWorkload("Deployment", deployment.GetObjectMeta()))
+	}
+	for _, wave := range updatePlan.GetWaves() {
+		names := make([]string, 0, len(wave.Deployments))
+		for _, index := range wave.Deployments {
+			names = append(names, deployments[index].Namespace+"/"+deployments[index].Name)
+		}
+		plan.Waves = append(plan.Waves, names)
+	}
+	for _, job := range updatePlan.GetToCreateJobs() {
+		plan.Jobs = append(plan.Jobs, serializeWorkload("Job", job.GetObjectMeta()))
+	}
+	if canary := updatePlan.GetCanary(); canary != nil {
+		plan.Canary = &CanarySerialized{
+			Weight:      canary.Weight,
+			SoakTime:    canary.SoakTime.String(),
+			MaxRestarts: canary.MaxRestarts,
+		}
+	}
+	return plan
+}
+
+func workloadKey(kind string, namespace string, name string) string {
+	return fmt.Sprintf("%s/%s/%s", kind, namespace, name)
 }
 
 func serializeUpdateProgress(progress manager.UpdateProgress) *UpdateProgressSerialized {
-	return &UpdateProgressSerialized{
-		UUID: progress.UUID().String(),
+	serialized := &UpdateProgressSerialized{
+		UUID:      progress.UUID().String(),
+		Requester: progress.Requester(),
 		Counts: CountSerialized{
 			Deployments: ProgressCountSerialized{
 				Total:   len(progress.GetDeployments()),
@@ -68,6 +258,61 @@ func serializeUpdateProgress(progress manager.UpdateProgress) *UpdateProgressSer
 			Finished:   progress.Finished(),
 			Failed:     progress.Failed(),
 			Successful: progress.Successful(),
+			State:      string(progress.State()),
 		},
 	}
+	if approval := progress.Approval(); approval != nil {
+		serialized.Approval = &ApprovalSerialized{
+			RequiredApprovers: approval.RequiredApprovers,
+			Approvers:         approval.Approvers,
+			Rejecter:          approval.Rejecter,
+			Reason:            approval.Reason,
+			ExpiryTime:        approval.ExpiryTime,
+		}
+	}
+	if schedule := progress.Schedule(); schedule != nil {
+		serialized.Schedule = &ScheduleSerialized{
+			StartTime: schedule.StartTime,
+			Reason:    schedule.Reason,
+			Error:     schedule.Error,
+		}
+	}
+	if queue := progress.Queue(); queue != nil {
+		serialized.Queue = &QueueSerialized{
+			Position:    queue.Position,
+			UpdateUUIDs: make([]string, 0, len(queue.UpdateUUIDs)),
+			Error:       queue.Error,
+		}
+		for _, updateUUID := range queue.UpdateUUIDs {
+			serialized.Queue.UpdateUUIDs = append(serialized.Queue.UpdateUUIDs, updateUUID.String())
+		}
+	}
+	if promotion := progress.Promotion(); promotion != nil {
+		serialized.Promotion = &PromotionSerialized{
+			UpdateClassifier: promotion.UpdateClassifier,
+			Delay:            promotion.Delay.String(),
+			Error:            promotion.Error,
+		}
+		if promotion.UpdateUUID != uuid.Nil {
+			serialized.Promotion.UpdateUUID = promotion.UpdateUUID.String()
+		}
+	}
+	if promotedFrom := progress.PromotedFrom(); promotedFrom != uuid.Nil {
+		serialized.PromotedFrom = promotedFrom.String()
+	}
+	if rollbackOf := progress.RollbackOf(); rollbackOf != uuid.Nil {
+		serialized.RollbackOf = rollbackOf.String()
+	}
+	if rollbackJobs := progress.GetRollbackJobs(); len(rollbackJobs) > 0 {
+		serialized.RollbackJobs = &RollbackJobsSerialized{Total: len(rollbackJobs)}
+		for _, job := range rollbackJobs {
+			switch {
+			case job.Status.Failed > 0:
+				serialized.RollbackJobs.Failed++
+			case job.Status.Succeeded > 0:
+				serialized.RollbackJobs.Succeeded++
+			}
+		}
+	}
+	return serialized
 }
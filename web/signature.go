@@ -0,0 +1,59 @@
+package web
+
+import (
+	"kubernetes-update-manager/signature"
+	"kubernetes-update-manager/updater"
+	"kubernetes-update-manager/updater/manager"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// verifySignatures returns a plan verifier which checks the signature of the updated image according
+// to the signature policy of the configuration. The update is pinned to the digest of the verified image, so the
+// image is deployed even if its tag is moved afterwards. An update which has already been pinned is verified with its
+// digest.
+func verifySignatures(config *Config) manager.PlanVerifier {
+	return func(updateConfig *updater.Config, updatePlan updater.UpdatePlan) error {
+		image := updateConfig.GetDeployedImage()
+		mode := config.SignaturePolicy.ModeFor(updateConfig.GetUpdateClassifier(), namespacesOf(updatePlan))
+		if mode == signature.ModeOff {
+			return nil
+		}
+
+		digest, err := config.SignatureVerifier.Verify(image)
+		if err == nil {
+			updateConfig.SetImageDigest(digest)
+			return nil
+		}
+		logger := log.WithFields(log.Fields{
+			"image":            image.String(),
+			"updateClassifier": updateConfig.GetUpdateClassifier(),
+			"mode":             mode,
+		}).WithError(err)
+		if mode == signature.ModeWarn {
+			logger.Warn("Image signature could not be verified")
+			return nil
+		}
+		logger.Error("Rejecting update because the image signature could not be verified")
+		return manager.Reject("The signature of image %s could not be verified: %s", image.String(), err.Error())
+	}
+}
+
+// namespacesOf returns the distinct namespaces of all resources touched by the update plan.
+func namespacesOf(updatePlan updater.UpdatePlan) []string {
+	seen := map[string]bool{}
+	namespaces := make([]string, 0)
+	add := func(namespace string) {
+		if !seen[namespace] {
+			seen[namespace] = true
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	for _, deployment := range updatePlan.GetToApplyDeployments() {
+		add(deployment.Namespace)
+	}
+	for _, job := range updatePlan.GetToCreateJobs() {
+		add(job.Namespace)
+	}
+	return namespaces
+}
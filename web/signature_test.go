@@ -0,0 +1,106 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"kubernetes-update-manager/signature"
+	"kubernetes-update-manager/updater"
+	"net/http"
+	"net/url"
+
+	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/apps/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type fakeImageVerifier struct {
+	digest string
+	err    error
+	images []string
+}
+
+func (verifier *fakeImageVerifier) Verify(image *updater.Image) (string, error) {
+	verifier.images = append(verifier.images, image.String())
+	return verifier.digest, verifier.err
+}
+
+type SignatureTestSuite struct {
+	GenericWebTestSuite
+	verifier *fakeImageVerifier
+}
+
+var _ = Suite(&SignatureTestSuite{})
+
+func (suite *SignatureTestSuite) SetUpTest(c *C) {
+	suite.GenericWebTestSuite.SetUpTest(c)
+	suite.verifier = &fakeImageVerifier{}
+	suite.config.SignatureVerifier = suite.verifier
+	suite.config.SignaturePolicy = signature.NewPolicy(signature.ModeEnforce)
+	suite.router, _ = getWeb(suite.config, false)
+}
+
+func (suite *SignatureTestSuite) TestPostSigned(c *C) {
+	w := suite.recorder
+	suite.router.ServeHTTP(w, suite.PostRequestComplete())
+	c.Assert(w.Code, Equals, http.StatusCreated)
+	c.Assert(suite.verifier.images, DeepEquals, []string{"xcnt/test:1.0.0"})
+}
+
+func (suite *SignatureTestSuite) TestPlanPinsVerifiedDigest(c *C) {
+	suite.verifier.digest = "sha256:0123456789abcdef"
+	suite.config.AutoloadNamespaces = false
+	suite.config.Namespaces = []string{"default"}
+	deployment := &v1.Deployment{
+		ObjectMeta: metaV1.ObjectMeta{Name: "api", Namespace: "default", Annotations: map[string]string{updater.UpdateClassifier: "stable"}},
+		Spec: v1.DeploymentSpec{Template: apiv1.PodTemplateSpec{Spec: apiv1.PodSpec{
+			Containers: []apiv1.Container{{Name: "app", Image: "xcnt/test:0.9.9"}},
+		}}},
+	}
+	_, err := suite.clientset.AppsV1().Deployments("default").Create(context.Background(), deployment, metaV1.CreateOptions{})
+	c.Assert(err, IsNil)
+
+	data := url.Values{}
+	data.Set(ImageParam, "xcnt/test:1.0.0")
+	data.Set(UpdateClassifierParam, "stable")
+	w := suite.recorder
+	suite.router.ServeHTTP(w, suite.PostRequestTo("/plans", data))
+	c.Assert(w.Code, Equals, http.StatusOK)
+	response := &PlanSerialized{}
+	c.Assert(json.Unmarshal(w.Body.Bytes(), response), IsNil)
+	c.Assert(response.Deployments, HasLen, 1)
+	c.Assert(response.Deployments[0].Containers[0].Image, Equals, "xcnt/test:1.0.0@sha256:0123456789abcdef")
+	c.Assert(suite.verifier.images, DeepEquals, []string{"xcnt/test:1.0.0", "xcnt/test:1.0.0@sha256:0123456789abcdef"})
+}
+
+func (suite *SignatureTestSuite) TestPostUnsignedEnforced(c *C) {
+	w := suite.recorder
+	suite.verifier.err = signature.ErrNoSignature
+	suite.router.ServeHTTP(w, suite.PostRequestComplete())
+	c.Assert(w.Code, Equals, http.StatusForbidden)
+
+	buffer := bytes.Buffer{}
+	buffer.ReadFrom(w.Body)
+	response := &ErrorSerialized{}
+	err := json.Unmarshal(buffer.Bytes(), response)
+	c.Assert(err, IsNil)
+	c.Assert(response.Error, Matches, ".*xcnt/test:1.0.0.*not been signed.*")
+}
+
+func (suite *SignatureTestSuite) TestPostUnsignedWarned(c *C) {
+	w := suite.recorder
+	suite.verifier.err = signature.ErrNoSignature
+	suite.config.SignaturePolicy.Classifiers["stable"] = signature.ModeWarn
+	suite.router.ServeHTTP(w, suite.PostRequestComplete())
+	c.Assert(w.Code, Equals, http.StatusCreated)
+}
+
+func (suite *SignatureTestSuite) TestPostUnsignedOff(c *C) {
+	w := suite.recorder
+	suite.verifier.err = signature.ErrNoSignature
+	suite.config.SignaturePolicy.Classifiers["stable"] = signature.ModeOff
+	suite.router.ServeHTTP(w, suite.PostRequestComplete())
+	c.Assert(w.Code, Equals, http.StatusCreated)
+	c.Assert(suite.verifier.images, HasLen, 0)
+}
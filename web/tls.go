@@ -0,0 +1,159 @@
+package web
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// certificateCheckInterval is the minimal time between two checks if the certificate files have been changed.
+	certificateCheckInterval = 10 * time.Second
+)
+
+var (
+	// ErrNoClientCACertificates is returned if the client CA file does not contain any certificate.
+	ErrNoClientCACertificates = errors.New("The client CA file does not contain any PEM encoded certificate")
+)
+
+// TLSConfig configures the TLS listener of the web interface.
+type TLSConfig struct {
+	// CertFile is the path to the PEM encoded certificate chain of the server.
+	CertFile string
+	// KeyFile is the path to the PEM encoded private key of the server.
+	KeyFile string
+	// ClientCAFile is the path to the PEM encoded certificates of the CAs client certificates are verified against.
+	// If empty, client certificates are not requested.
+	ClientCAFile string
+	// RequireClientCert rejects connections without a valid client certificate. Otherwise client certificates are
+	// only verified if they are sent.
+	RequireClientCert bool
+}
+
+// NewTLSConfig returns the TLS configuration of the server. The certificate, key and client CA files are reloaded
+// once they are changed, for example when a mounted kubernetes secret is updated.
+func NewTLSConfig(config *TLSConfig) (*tls.Config, error) {
+	reloader := &certificateReloader{config: config, now: time.Now}
+	err := reloader.load()
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return reloader.current(), nil
+		},
+	}, nil
+}
+
+// certificateReloader holds the TLS configuration built from the files and rebuilds it if they are modified.
+type certificateReloader struct {
+	config      *TLSConfig
+	now         func() time.Time
+	mutex       sync.Mutex
+	tlsConfig   *tls.Config
+	modified    []time.Time
+	lastChecked time.Time
+}
+
+// current returns the TLS configuration, reloading the files if they have been changed since they were last loaded.
+// If the changed files can not be loaded, for example while a secret is only partially updated, the previous
+// configuration is kept.
+func (reloader *certificateReloader) current() *tls.Config {
+	reloader.mutex.Lock()
+	defer reloader.mutex.Unlock()
+	if reloader.now().Sub(reloader.lastChecked) < certificateCheckInterval {
+		return reloader.tlsConfig
+	}
+	reloader.lastChecked = reloader.now()
+	modified, err := reloader.modificationTimes()
+	if err != nil || equalTimes(modified, reloader.modified) {
+		return reloader.tlsConfig
+	}
+	tlsConfig, err := reloader.build()
+	if err != nil {
+		log.WithError(err).Warn("Reloading the TLS certificates failed, keeping the previous certificates")
+		return reloader.tlsConfig
+	}
+	log.Info("Reloaded the TLS certificates")
+	reloader.tlsConfig = tlsConfig
+	reloader.modified = modified
+	return reloader.tlsConfig
+}
+
+func (reloader *certificateReloader) load() error {
+	modified, err := reloader.modificationTimes()
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := reloader.build()
+	if err != nil {
+		return err
+	}
+	reloader.tlsConfig = tlsConfig
+	reloader.modified = modified
+	reloader.lastChecked = reloader.now()
+	return nil
+}
+
+func (reloader *certificateReloader) build() (*tls.Config, error) {
+	certificate, err := tls.LoadX509KeyPair(reloader.config.CertFile, reloader.config.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{certificate},
+	}
+	if len(reloader.config.ClientCAFile) == 0 {
+		return tlsConfig, nil
+	}
+	data, err := os.ReadFile(reloader.config.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s: %w", reloader.config.ClientCAFile, ErrNoClientCACertificates)
+	}
+	tlsConfig.ClientCAs = clientCAs
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	if reloader.config.RequireClientCert {
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+func (reloader *certificateReloader) modificationTimes() ([]time.Time, error) {
+	files := []string{reloader.config.CertFile, reloader.config.KeyFile}
+	if len(reloader.config.ClientCAFile) > 0 {
+		files = append(files, reloader.config.ClientCAFile)
+	}
+	modified := make([]time.Time, 0, len(files))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		modified = append(modified, info.ModTime())
+	}
+	return modified, nil
+}
+
+func equalTimes(left []time.Time, right []time.Time) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	for index := range left {
+		if !left[index].Equal(right[index]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,213 @@
+package web
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"kubernetes-update-manager/auth"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+// testCertificate is a generated certificate and key used to test the TLS listener.
+type testCertificate struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+	pem         []byte
+	keyPEM      []byte
+}
+
+func newTestCertificate(c *C, commonName string, parent *testCertificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, IsNil)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	c.Assert(err, IsNil)
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"xcnt"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signer, signerKey = parent.certificate, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	c.Assert(err, IsNil)
+	certificate, err := x509.ParseCertificate(der)
+	c.Assert(err, IsNil)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	c.Assert(err, IsNil)
+	return &testCertificate{
+		certificate: certificate,
+		key:         key,
+		pem:         pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:      pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+func (certificate *testCertificate) tlsCertificate(c *C) tls.Certificate {
+	tlsCertificate, err := tls.X509KeyPair(certificate.pem, certificate.keyPEM)
+	c.Assert(err, IsNil)
+	return tlsCertificate
+}
+
+type TLSTestSuite struct {
+	GenericWebTestSuite
+	ca        *testCertificate
+	tlsConfig *TLSConfig
+	servers   []*http.Server
+}
+
+var _ = Suite(&TLSTestSuite{})
+
+func (suite *TLSTestSuite) SetUpTest(c *C) {
+	suite.GenericWebTestSuite.SetUpTest(c)
+	suite.ca = newTestCertificate(c, "ca", nil)
+	directory := c.MkDir()
+	suite.tlsConfig = &TLSConfig{
+		CertFile:     filepath.Join(directory, "tls.crt"),
+		KeyFile:      filepath.Join(directory, "tls.key"),
+		ClientCAFile: filepath.Join(directory, "ca.crt"),
+	}
+	suite.writeServerCertificate(c, "update-manager")
+	c.Assert(os.WriteFile(suite.tlsConfig.ClientCAFile, suite.ca.pem, 0600), IsNil)
+}
+
+func (suite *TLSTestSuite) TearDownTest(c *C) {
+	for _, server := range suite.servers {
+		server.Close()
+	}
+	suite.servers = nil
+}
+
+func (suite *TLSTestSuite) writeServerCertificate(c *C, commonName string) {
+	server := newTestCertificate(c, commonName, suite.ca)
+	c.Assert(os.WriteFile(suite.tlsConfig.CertFile, server.pem, 0600), IsNil)
+	c.Assert(os.WriteFile(suite.tlsConfig.KeyFile, server.keyPEM, 0600), IsNil)
+}
+
+// serve starts the router with the TLS configuration and returns its URL.
+func (suite *TLSTestSuite) serve(c *C) string {
+	tlsConfig, err := NewTLSConfig(suite.tlsConfig)
+	c.Assert(err, IsNil)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	c.Assert(err, IsNil)
+	server := &http.Server{Handler: suite.router, ErrorLog: log.New(io.Discard, "", 0)}
+	suite.servers = append(suite.servers, server)
+	go server.Serve(listener)
+	return "https://" + listener.Addr().String()
+}
+
+func (suite *TLSTestSuite) httpClient(certificates ...tls.Certificate) *http.Client {
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(suite.ca.certificate)
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      rootCAs,
+		Certificates: certificates,
+	}}}
+}
+
+func (suite *TLSTestSuite) TestServeTLS(c *C) {
+	serverURL := suite.serve(c)
+	response, err := suite.httpClient().Get(serverURL + "/health")
+	c.Assert(err, IsNil)
+	response.Body.Close()
+	c.Assert(response.StatusCode, Equals, http.StatusNoContent)
+}
+
+func (suite *TLSTestSuite) TestClientCertificateAuthentication(c *C) {
+	suite.config.Authenticators = []auth.Authenticator{auth.NewClientCertificateAuthenticator([]auth.ClientCertificateRule{
+		{CommonName: "payments-ci", Scope: auth.Scope{Classifiers: []string{"staging"}}},
+	})}
+	suite.router, _ = getWeb(suite.config, false)
+	serverURL := suite.serve(c)
+
+	response, err := suite.httpClient().Get(serverURL + "/updates/" + "00000000-0000-0000-0000-000000000000")
+	c.Assert(err, IsNil)
+	response.Body.Close()
+	c.Assert(response.StatusCode, Equals, http.StatusUnauthorized)
+
+	clientCertificate := newTestCertificate(c, "payments-ci", suite.ca).tlsCertificate(c)
+	response, err = suite.httpClient(clientCertificate).Get(serverURL + "/updates/" + "00000000-0000-0000-0000-000000000000")
+	c.Assert(err, IsNil)
+	response.Body.Close()
+	c.Assert(response.StatusCode, Equals, http.StatusNotFound)
+}
+
+func (suite *TLSTestSuite) TestRequireClientCertificate(c *C) {
+	suite.tlsConfig.RequireClientCert = true
+	serverURL := suite.serve(c)
+	_, err := suite.httpClient().Get(serverURL + "/health")
+	c.Assert(err, NotNil)
+
+	otherCA := newTestCertificate(c, "other-ca", nil)
+	_, err = suite.httpClient(newTestCertificate(c, "payments-ci", otherCA).tlsCertificate(c)).Get(serverURL + "/health")
+	c.Assert(err, NotNil)
+
+	response, err := suite.httpClient(newTestCertificate(c, "payments-ci", suite.ca).tlsCertificate(c)).Get(serverURL + "/health")
+	c.Assert(err, IsNil)
+	response.Body.Close()
+	c.Assert(response.StatusCode, Equals, http.StatusNoContent)
+}
+
+func (suite *TLSTestSuite) TestReloadCertificate(c *C) {
+	now := time.Now()
+	reloader := &certificateReloader{config: suite.tlsConfig, now: func() time.Time { return now }}
+	c.Assert(reloader.load(), IsNil)
+	commonNameOf := func() string {
+		certificate, err := x509.ParseCertificate(reloader.current().Certificates[0].Certificate[0])
+		c.Assert(err, IsNil)
+		return certificate.Subject.CommonName
+	}
+	c.Assert(commonNameOf(), Equals, "update-manager")
+
+	suite.writeServerCertificate(c, "renewed")
+	modified := time.Now().Add(time.Minute)
+	c.Assert(os.Chtimes(suite.tlsConfig.CertFile, modified, modified), IsNil)
+	c.Assert(commonNameOf(), Equals, "update-manager")
+
+	now = now.Add(certificateCheckInterval)
+	c.Assert(commonNameOf(), Equals, "renewed")
+}
+
+func (suite *TLSTestSuite) TestReloadKeepsCertificateOnError(c *C) {
+	now := time.Now()
+	reloader := &certificateReloader{config: suite.tlsConfig, now: func() time.Time { return now }}
+	c.Assert(reloader.load(), IsNil)
+	previous := reloader.current()
+
+	c.Assert(os.WriteFile(suite.tlsConfig.KeyFile, []byte("partial"), 0600), IsNil)
+	modified := time.Now().Add(time.Minute)
+	c.Assert(os.Chtimes(suite.tlsConfig.KeyFile, modified, modified), IsNil)
+	now = now.Add(certificateCheckInterval)
+	c.Assert(reloader.current(), Equals, previous)
+}
+
+func (suite *TLSTestSuite) TestNewTLSConfigInvalid(c *C) {
+	c.Assert(os.WriteFile(suite.tlsConfig.ClientCAFile, []byte("no certificate"), 0600), IsNil)
+	_, err := NewTLSConfig(suite.tlsConfig)
+	c.Assert(err, ErrorMatches, ".*"+ErrNoClientCACertificates.Error())
+
+	suite.tlsConfig.KeyFile = filepath.Join(c.MkDir(), "missing.key")
+	_, err = NewTLSConfig(suite.tlsConfig)
+	c.Assert(err, NotNil)
+}
@@ -1,12 +1,18 @@
 package web
 
 import (
+	"errors"
+	"fmt"
+	"kubernetes-update-manager/audit"
 	"kubernetes-update-manager/updater"
 	"kubernetes-update-manager/updater/manager"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
 )
 
 const (
@@ -16,20 +22,59 @@ const (
 	ImageParam = "image"
 	// UpdateClassifierParam returns the parameter name for the update classification configuration
 	UpdateClassifierParam = "update_classifier"
+	// ForceParam is the parameter to ignore the version guards of the workloads
+	ForceParam = "force"
+	// LabelSelectorParam is the parameter restricting the update to deployments and jobs matching the label selector
+	LabelSelectorParam = "label_selector"
+	// RevisionParam is the parameter for the revision, for example the commit SHA, the update has been built from
+	RevisionParam = "revision"
+	// ChangeCauseParam is the parameter for the free-form description of the update shown in the rollout history
+	ChangeCauseParam = "change_cause"
+	// NotBeforeParam is the parameter for the RFC 3339 time the update must not be started before
+	NotBeforeParam = "not_before"
+	// CanaryWeightParam is the parameter for the percentage of the pods running the new image in canaries first
+	CanaryWeightParam = "canary_weight"
+	// CanarySoakTimeParam is the parameter for the duration the canaries have to stay healthy before they are promoted
+	CanarySoakTimeParam = "canary_soak_time"
+	// CanaryMaxRestartsParam is the parameter for the number of container restarts tolerated in the canaries
+	CanaryMaxRestartsParam = "canary_max_restarts"
+
+	defaultCanarySoakTime = 5 * time.Minute
+	standbySweepInterval  = time.Minute
 )
 
 // NewUpdaterHandler configuration configures an updaterhandler which can be used to register endpoints for gin requests.
 func NewUpdaterHandler(config *Config) *UpdaterHandler {
-	return &UpdaterHandler{
-		config:  config,
-		manager: manager.NewManager(config.Clientset),
+	updateManager := manager.NewManager(config.Clientset)
+	if config.SignaturePolicy != nil && config.SignaturePolicy.IsActive() {
+		updateManager.Verifiers = append(updateManager.Verifiers, verifySignatures(config))
+	}
+	updateManager.ApprovalPolicy = config.ApprovalPolicy
+	if len(config.ConflictMode) > 0 {
+		updateManager.ConflictMode = config.ConflictMode
+	}
+	if config.RollbackRetention > 0 {
+		updateManager.RollbackRetention = config.RollbackRetention
+	}
+	updateManager.PromotionPolicy = config.PromotionPolicy
+	auditLog := config.AuditLog
+	if auditLog == nil {
+		auditLog = audit.NewLog(audit.DefaultCapacity)
 	}
+	updateHandler := &UpdaterHandler{
+		config:   config,
+		manager:  updateManager,
+		auditLog: auditLog,
+	}
+	updateManager.Promote = updateHandler.promote
+	return updateHandler
 }
 
 // UpdaterHandler represents the state necessary in a web interface context to handle update requests.
 type UpdaterHandler struct {
-	config  *Config
-	manager *manager.Manager
+	config   *Config
+	manager  *manager.Manager
+	auditLog *audit.Log
 }
 
 // GetItem represents the get method for the specified get item.
@@ -66,42 +111,319 @@ func (updateHandler *UpdaterHandler) GetItem(context *gin.Context) {
 // @Security ApiKeyAuth
 // @Param image body string true "The image included in the update request"
 // @Param update_classifier body string true "The update classifier which should be used for searching for the update status"
+// @Param force body bool false "Ignore the semantic version guards of the workloads and allow downgrades"
+// @Param label_selector body string false "A label selector the deployments and jobs of the update need to match"
+// @Param revision body string false "The revision, for example the commit SHA, stamped on the updated deployments"
+// @Param change_cause body string false "The description of the update shown in the rollout history of the deployments"
+// @Param not_before body string false "The RFC 3339 time the update is started at. The update is scheduled until then"
+// @Param canary_weight body int false "The percentage of the pods running the new image in canaries before the deployments are updated"
+// @Param canary_soak_time body string false "The duration, for example 10m, the canaries have to stay healthy. Defaults to 5m"
+// @Param canary_max_restarts body int false "The number of container restarts of the canary pods which are tolerated. Defaults to 0"
+// @Param override_freeze body bool false "Ignore the freeze calendar in an emergency. Requires a privileged principal"
 // @Success 200 {object} web.UpdateProgressSerialized
 // @Failure 400
 // @Failure 500
 // @Failure 401
+// @Failure 403 {object} web.ErrorSerialized
+// @Failure 409 {object} web.ErrorSerialized
 // @Router /updates [post]
 func (updateHandler *UpdaterHandler) Post(context *gin.Context) {
 	manager := updateHandler.manager
 	defer manager.Cleanup()
+	entry := auditEntryFor(context, audit.ActionCreate)
+	entry.Image = context.PostForm(ImageParam)
+	entry.UpdateClassifier = context.PostForm(UpdateClassifierParam)
+	defer func() { updateHandler.recordAudit(context, entry) }()
+
+	updateConfig, ok := updateHandler.updateConfigFrom(context)
+	if !ok {
+		return
+	}
+	overrideFreeze, ok := freezeOverrideFrom(context)
+	if !ok {
+		return
+	}
+	entry.FreezeOverride = overrideFreeze
+	updateProgress, err := manager.Create(updateConfig, updateHandler.createVerifiers(context, overrideFreeze)...)
+	if err != nil {
+		abortWithCreateError(context, err)
+		return
+	}
+	entry = withProgress(entry, updateProgress)
+	message := "Update scheduled"
+	if updateProgress.Approval() != nil {
+		message = "Update held for approval"
+	} else if schedule := updateProgress.Schedule(); schedule != nil {
+		message = "Update scheduled for " + schedule.StartTime.Format(time.RFC3339)
+	} else if queue := updateProgress.Queue(); queue != nil {
+		message = fmt.Sprintf("Update queued at position %d", queue.Position)
+	}
+	log.WithFields(log.Fields{
+		"uuid":             updateProgress.UUID().String(),
+		"image":            updateConfig.GetImage().String(),
+		"updateClassifier": updateConfig.GetUpdateClassifier(),
+		"requester":        updateConfig.GetRequester(),
+		"overrideFreeze":   overrideFreeze,
+	}).Info(message)
+	context.JSON(http.StatusCreated, serializeUpdateProgress(updateProgress))
+}
+
+// createVerifiers returns the plan verifiers checking an update requested with the context in addition to the ones of
+// the manager.
+func (updateHandler *UpdaterHandler) createVerifiers(context *gin.Context, overrideFreeze bool) []manager.PlanVerifier {
+	verifiers := []manager.PlanVerifier{verifyScope(principalOf(context))}
+	if updateHandler.config.FreezeCalendar != nil && !overrideFreeze {
+		verifiers = append(verifiers, verifyFreezeCalendar(updateHandler.config))
+	}
+	return verifiers
+}
+
+// promote creates the update promoting a successful update to the update classifier of the configuration. The update
+// is checked against the registry policy and the freeze calendar, its signature is verified and it is analyzed like a
+// requested update of the update classifier.
+func (updateHandler *UpdaterHandler) promote(updateConfig *updater.Config) (manager.UpdateProgress, error) {
+	config := updateHandler.config
+	entry := audit.Entry{
+		Action:           audit.ActionPromote,
+		Requester:        updateConfig.GetRequester(),
+		Image:            updateConfig.GetImage().String(),
+		UpdateClassifier: updateConfig.GetUpdateClassifier(),
+		Outcome:          audit.OutcomeSucceeded,
+	}
+	if config.RegistryPolicy != nil {
+		err := config.RegistryPolicy.Check(updateConfig.GetImage(), updateConfig.GetUpdateClassifier())
+		if err != nil {
+			entry.Outcome = audit.OutcomeFailed
+			entry.Error = err.Error()
+			updateHandler.auditLog.Record(entry)
+			return nil, err
+		}
+	}
+	if config.Prometheus != nil {
+		updateConfig.SetAnalysis(config.AnalysisPolicy.Analysis(config.Prometheus, updateConfig.GetUpdateClassifier()))
+	}
+	var verifiers []manager.PlanVerifier
+	if config.FreezeCalendar != nil {
+		verifiers = append(verifiers, verifyFreezeCalendar(config))
+	}
+	// The signature is verified for the update classifier of the promoted update by the verifiers of the manager.
+	updateProgress, err := updateHandler.manager.Create(updateConfig, verifiers...)
+	if err != nil {
+		entry.Outcome = audit.OutcomeFailed
+		entry.Error = err.Error()
+	} else {
+		entry = withProgress(entry, updateProgress)
+	}
+	updateHandler.auditLog.Record(entry)
+	return updateProgress, err
+}
+
+// PostPlan represents the POST method to preview an update request.
+// @Summary Previews an update
+// @Description returns the workloads and containers an update request would change without applying it.
+// @Tags updates
+// @Produce json
+// @Security ApiKeyAuth
+// @Param image body string true "The image included in the update request"
+// @Param update_classifier body string true "The update classifier which should be used for searching for the update status"
+// @Param force body bool false "Ignore the semantic version guards of the workloads and allow downgrades"
+// @Param label_selector body string false "A label selector the deployments and jobs of the update need to match"
+// @Success 200 {object} web.PlanSerialized
+// @Failure 400
+// @Failure 500
+// @Failure 401
+// @Failure 403 {object} web.ErrorSerialized
+// @Failure 409 {object} web.ErrorSerialized
+// @Router /plans [post]
+func (updateHandler *UpdaterHandler) PostPlan(context *gin.Context) {
+	updateConfig, ok := updateHandler.updateConfigFrom(context)
+	if !ok {
+		return
+	}
+	updatePlan, err := updateHandler.manager.Preview(updateConfig, verifyScope(principalOf(context)))
+	if err != nil {
+		abortWithCreateError(context, err)
+		return
+	}
+	context.JSON(http.StatusOK, serializePlan(updatePlan))
+}
+
+// updateConfigFrom parses the update configuration from the form parameters of the request. If the request is not
+// valid, it is aborted and false is returned.
+func (updateHandler *UpdaterHandler) updateConfigFrom(context *gin.Context) (*updater.Config, bool) {
 	config := updateHandler.config
 	imageString, _ := context.GetPostForm(ImageParam)
 	updateClassifier, _ := context.GetPostForm(UpdateClassifierParam)
 	if len(imageString) == 0 {
 		context.AbortWithStatus(http.StatusBadRequest)
-		return
+		return nil, false
 	}
 	if len(updateClassifier) == 0 {
 		context.AbortWithStatus(http.StatusBadRequest)
-		return
+		return nil, false
 	}
-	namespaces := config.Namespaces
-	if config.AutoloadNamespaces {
+	force := false
+	if forceString, ok := context.GetPostForm(ForceParam); ok {
 		var err error
-		namespaces, err = updater.ListNamespaces(updater.NewClientsetWrapper(config.Clientset))
+		force, err = strconv.ParseBool(forceString)
 		if err != nil {
-			context.AbortWithError(http.StatusInternalServerError, err)
-			return
+			context.AbortWithStatus(http.StatusBadRequest)
+			return nil, false
 		}
 	}
-	updateConfig := updater.NewConfig(config.Clientset, updater.NewImage(imageString), updateClassifier)
-	updateConfig.SetNamespaces(namespaces)
-	updateProgress, err := manager.Create(updateConfig)
+	image := updater.NewImage(imageString)
+	if config.RegistryPolicy != nil {
+		err := config.RegistryPolicy.Check(image, updateClassifier)
+		if err != nil {
+			abortForbidden(context, err.Error())
+			return nil, false
+		}
+	}
+	principal := principalOf(context)
+	if principal != nil {
+		err := principal.CheckUpdate(image, updateClassifier)
+		if err != nil {
+			abortForbidden(context, err.Error())
+			return nil, false
+		}
+	}
+	var notBefore time.Time
+	if notBeforeString, ok := context.GetPostForm(NotBeforeParam); ok && len(notBeforeString) > 0 {
+		var err error
+		notBefore, err = time.Parse(time.RFC3339, notBeforeString)
+		if err != nil {
+			abortWithReason(context, http.StatusBadRequest, "The not_before time must be in RFC 3339 format: "+err.Error())
+			return nil, false
+		}
+	}
+	canary, ok := canaryFrom(context)
+	if !ok {
+		return nil, false
+	}
+	labelSelector, _ := context.GetPostForm(LabelSelectorParam)
+	if err := updater.ValidateLabelSelector(labelSelector); err != nil {
+		abortWithReason(context, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	namespaces, err := updateHandler.namespaces()
 	if err != nil {
 		context.AbortWithError(http.StatusInternalServerError, err)
+		return nil, false
+	}
+	updateConfig := updater.NewConfig(config.Clientset, image, updateClassifier)
+	updateConfig.SetNamespaces(namespaces)
+	updateConfig.SetLabelSelector(updater.CombineLabelSelectors(config.LabelSelector, labelSelector))
+	updateConfig.SetForce(force)
+	updateConfig.SetRevision(context.PostForm(RevisionParam))
+	updateConfig.SetChangeCause(context.PostForm(ChangeCauseParam))
+	updateConfig.SetNotBefore(notBefore)
+	updateConfig.SetCanary(canary)
+	updateConfig.SetRollbackPolicy(config.RollbackPolicy)
+	if config.Prometheus != nil {
+		updateConfig.SetAnalysis(config.AnalysisPolicy.Analysis(config.Prometheus, updateClassifier))
+	}
+	if principal != nil {
+		updateConfig.SetRequester(principal.Name)
+	}
+	return updateConfig, true
+}
+
+// canaryFrom parses the canary configuration from the form parameters of the request. Without a canary weight the
+// deployments are updated directly and nil is returned. If the parameters are not valid, the request is aborted and
+// false is returned.
+func canaryFrom(context *gin.Context) (*updater.Canary, bool) {
+	weightString, ok := context.GetPostForm(CanaryWeightParam)
+	if !ok || len(weightString) == 0 {
+		return nil, true
+	}
+	weight, err := strconv.Atoi(weightString)
+	if err != nil {
+		abortWithReason(context, http.StatusBadRequest, "The canary_weight must be an integer: "+err.Error())
+		return nil, false
+	}
+	canary := &updater.Canary{Weight: weight, SoakTime: defaultCanarySoakTime}
+	if soakTimeString, ok := context.GetPostForm(CanarySoakTimeParam); ok && len(soakTimeString) > 0 {
+		canary.SoakTime, err = time.ParseDuration(soakTimeString)
+		if err != nil {
+			abortWithReason(context, http.StatusBadRequest, "The canary_soak_time must be a duration: "+err.Error())
+			return nil, false
+		}
+	}
+	if maxRestartsString, ok := context.GetPostForm(CanaryMaxRestartsParam); ok && len(maxRestartsString) > 0 {
+		maxRestarts, err := strconv.ParseInt(maxRestartsString, 10, 32)
+		if err != nil {
+			abortWithReason(context, http.StatusBadRequest, "The canary_max_restarts must be an integer: "+err.Error())
+			return nil, false
+		}
+		canary.MaxRestarts = int32(maxRestarts)
+	}
+	if err := canary.Validate(); err != nil {
+		abortWithReason(context, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	return canary, true
+}
+
+// namespaces returns the namespaces which should be searched for update candidates.
+func (updateHandler *UpdaterHandler) namespaces() ([]string, error) {
+	return configuredNamespaces(updateHandler.config)
+}
+
+// configuredNamespaces returns the namespaces the update manager is configured to update.
+func configuredNamespaces(config *Config) ([]string, error) {
+	wrapper := updater.NewClientsetWrapper(config.Clientset)
+	if len(config.NamespaceSelector) > 0 {
+		return updater.ListNamespacesMatching(wrapper, config.NamespaceSelector)
+	}
+	if config.AutoloadNamespaces {
+		return updater.ListNamespaces(wrapper)
+	}
+	return config.Namespaces, nil
+}
+
+// SweepStandbyDeployments tears down the previous colors of the blue-green deployments in the configured namespaces
+// whose teardown delay passed. It sweeps right away, so previous colors whose teardown has been missed while the
+// update manager was not running are removed on startup, and then periodically. It never returns.
+func SweepStandbyDeployments(config *Config) {
+	for {
+		namespaces, err := configuredNamespaces(config)
+		if err == nil {
+			err = updater.SweepStandbyDeployments(updater.NewClientsetWrapper(config.Clientset), namespaces)
+		}
+		if err != nil {
+			log.WithError(err).Warn("Could not tear down the previous colors of blue-green deployments")
+		}
+		time.Sleep(standbySweepInterval)
+	}
+}
+
+// abortWithCreateError responds to a failed update creation. Rejected updates are answered with a descriptive
+// forbidden response, errors implementing updater.PlanConflict with a conflict and all other errors are treated as
+// internal errors.
+func abortWithCreateError(context *gin.Context, err error) {
+	var rejection *manager.RejectionError
+	if errors.As(err, &rejection) {
+		abortForbidden(context, rejection.Error())
 		return
 	}
-	context.JSON(http.StatusCreated, serializeUpdateProgress(updateProgress))
+	var conflict updater.PlanConflict
+	if errors.As(err, &conflict) {
+		abortWithReason(context, http.StatusConflict, conflict.Error())
+		return
+	}
+	context.AbortWithError(http.StatusInternalServerError, err)
+}
+
+// abortForbidden stops the request with a forbidden status and the passed reason in the body.
+func abortForbidden(context *gin.Context, reason string) {
+	abortWithReason(context, http.StatusForbidden, reason)
+}
+
+// abortWithReason stops the request with the status and the passed reason in the body. The reason is also attached
+// to the errors of the request, so it is available to the audit log.
+func abortWithReason(context *gin.Context, statusCode int, reason string) {
+	context.Error(errors.New(reason)).SetType(gin.ErrorTypePublic)
+	context.AbortWithStatusJSON(statusCode, &ErrorSerialized{Error: reason})
 }
 
 // Delete represents the DELETE method to remove an update request from the manager.
@@ -116,6 +438,15 @@ func (updateHandler *UpdaterHandler) Delete(context *gin.Context) {
 	manager := updateHandler.manager
 	defer manager.Cleanup()
 	uuid := context.Param(UUIDParam)
+	entry := auditEntryFor(context, audit.ActionDelete)
+	entry.UpdateUUID = uuid
+	if updateProgress, err := manager.GetByString(uuid); err == nil {
+		entry = withProgress(entry, updateProgress)
+	} else {
+		entry.Outcome = audit.OutcomeNotFound
+		entry.Error = "The update does not exist"
+	}
 	manager.DeleteByString(uuid)
 	context.Status(http.StatusNoContent)
+	updateHandler.recordAudit(context, entry)
 }
@@ -2,18 +2,24 @@ package web
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"kubernetes-update-manager/policy"
 	"kubernetes-update-manager/updater"
+	"kubernetes-update-manager/updater/manager"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
-	"strconv"
-	"strings"
 
+	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	. "gopkg.in/check.v1"
+	v1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	apiv1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type UpdaterTestSuite struct {
@@ -55,21 +61,6 @@ func (suite *UpdaterTestSuite) TestGetInvalidUUID(c *C) {
 	c.Assert(w.Code, Equals, http.StatusBadRequest)
 }
 
-func (suite *UpdaterTestSuite) PostRequestWith(data url.Values) *http.Request {
-	req, _ := http.NewRequest("POST", "/updates", strings.NewReader(data.Encode()))
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
-	suite.Authenticate(req)
-	return req
-}
-
-func (suite *UpdaterTestSuite) PostRequestComplete() *http.Request {
-	data := url.Values{}
-	data.Set(ImageParam, "xcnt/test:1.0.0")
-	data.Set(UpdateClassifierParam, "stable")
-	return suite.PostRequestWith(data)
-}
-
 func (suite *UpdaterTestSuite) TestPostUnauthorized(c *C) {
 	w := suite.recorder
 	router := suite.router
@@ -133,6 +124,109 @@ func (suite *UpdaterTestSuite) TestPostNoAutloadNamespaces(c *C) {
 	c.Assert(response.UUID, Not(Equals), uuid.Nil)
 }
 
+func (suite *UpdaterTestSuite) TestPostImageNotAllowed(c *C) {
+	w := suite.recorder
+	router := suite.router
+	suite.config.RegistryPolicy = &policy.RegistryPolicy{
+		ImageRules: policy.ImageRules{Registries: []string{"eu.gcr.io"}},
+	}
+	req := suite.PostRequestComplete()
+
+	router.ServeHTTP(w, req)
+	c.Assert(w.Code, Equals, http.StatusForbidden)
+	buffer := bytes.Buffer{}
+	buffer.ReadFrom(w.Body)
+	response := &ErrorSerialized{}
+	err := json.Unmarshal(buffer.Bytes(), response)
+	c.Assert(err, IsNil)
+	c.Assert(response.Error, Equals, "Registry docker.io of image xcnt/test:1.0.0 is not allowed")
+}
+
+func (suite *UpdaterTestSuite) TestPostImageAllowed(c *C) {
+	w := suite.recorder
+	router := suite.router
+	suite.config.RegistryPolicy = &policy.RegistryPolicy{
+		ImageRules: policy.ImageRules{Repositories: []string{"docker.io/xcnt/*"}},
+	}
+	req := suite.PostRequestComplete()
+
+	router.ServeHTTP(w, req)
+	c.Assert(w.Code, Equals, http.StatusCreated)
+}
+
+func (suite *UpdaterTestSuite) TestPostInvalidForce(c *C) {
+	w := suite.recorder
+	router := suite.router
+	data := url.Values{}
+	data.Set(ImageParam, "xcnt/test:1.0.0")
+	data.Set(UpdateClassifierParam, "stable")
+	data.Set(ForceParam, "maybe")
+	req := suite.PostRequestWith(data)
+
+	router.ServeHTTP(w, req)
+	c.Assert(w.Code, Equals, http.StatusBadRequest)
+}
+
+func (suite *UpdaterTestSuite) TestPostDowngrade(c *C) {
+	w := suite.recorder
+	router, mgr := getWeb(suite.config, false)
+	mgr.Plan = func(config *updater.Config) (updater.UpdatePlan, error) {
+		c.Assert(config.IsForced(), Equals, false)
+		return nil, &updater.VersionError{Kind: "Deployment", Namespace: "default", Name: "test", Reason: "downgrade"}
+	}
+	req := suite.PostRequestComplete()
+
+	router.ServeHTTP(w, req)
+	c.Assert(w.Code, Equals, http.StatusConflict)
+	buffer := bytes.Buffer{}
+	buffer.ReadFrom(w.Body)
+	response := &ErrorSerialized{}
+	err := json.Unmarshal(buffer.Bytes(), response)
+	c.Assert(err, IsNil)
+	c.Assert(response.Error, Equals, "Deployment default/test: downgrade")
+}
+
+func (suite *UpdaterTestSuite) TestPostForced(c *C) {
+	w := suite.recorder
+	router, mgr := getWeb(suite.config, false)
+	forced := false
+	mgr.Plan = func(config *updater.Config) (updater.UpdatePlan, error) {
+		forced = config.IsForced()
+		return updater.Plan(config)
+	}
+	data := url.Values{}
+	data.Set(ImageParam, "xcnt/test:1.0.0")
+	data.Set(UpdateClassifierParam, "stable")
+	data.Set(ForceParam, "true")
+	req := suite.PostRequestWith(data)
+
+	router.ServeHTTP(w, req)
+	c.Assert(w.Code, Equals, http.StatusCreated)
+	c.Assert(forced, Equals, true)
+}
+
+func (suite *UpdaterTestSuite) TestPostWithReleaseMetadata(c *C) {
+	w := suite.recorder
+	router, mgr := getWeb(suite.config, false)
+	var plannedConfig *updater.Config
+	mgr.Plan = func(config *updater.Config) (updater.UpdatePlan, error) {
+		plannedConfig = config
+		return updater.Plan(config)
+	}
+	data := url.Values{}
+	data.Set(ImageParam, "xcnt/test:1.0.0")
+	data.Set(UpdateClassifierParam, "stable")
+	data.Set(RevisionParam, "4e1c2a9")
+	data.Set(ChangeCauseParam, "Release 1.0.0")
+	req := suite.PostRequestWith(data)
+
+	router.ServeHTTP(w, req)
+	c.Assert(w.Code, Equals, http.StatusCreated)
+	c.Assert(plannedConfig.GetRevision(), Equals, "4e1c2a9")
+	c.Assert(plannedConfig.GetChangeCause(), Equals, "Release 1.0.0")
+	c.Assert(plannedConfig.GetUpdateUUID(), Not(Equals), "")
+}
+
 func (suite *UpdaterTestSuite) TestPostWithError(c *C) {
 	w := suite.recorder
 	req := suite.PostRequestComplete()
@@ -217,3 +311,348 @@ func (suite *UpdaterTestSuite) TestDeleteWithExisting(c *C) {
 
 	c.Assert(w.Code, Equals, http.StatusNotFound)
 }
+
+func (suite *UpdaterTestSuite) createDeployment(c *C, meta metaV1.ObjectMeta, containers ...apiv1.Container) {
+	deployment := &v1.Deployment{
+		ObjectMeta: meta,
+		Spec: v1.DeploymentSpec{
+			Template: apiv1.PodTemplateSpec{Spec: apiv1.PodSpec{Containers: containers}},
+		},
+	}
+	_, err := suite.clientset.AppsV1().Deployments(meta.Namespace).Create(context.Background(), deployment, metaV1.CreateOptions{})
+	c.Assert(err, IsNil)
+}
+
+func (suite *UpdaterTestSuite) createNamespace(c *C, name string, labels map[string]string) {
+	namespace := &apiv1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: name, Labels: labels}}
+	_, err := suite.clientset.CoreV1().Namespaces().Create(context.Background(), namespace, metaV1.CreateOptions{})
+	c.Assert(err, IsNil)
+}
+
+func (suite *UpdaterTestSuite) planDeploymentNames(c *C, data url.Values) []string {
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, suite.PostRequestTo("/plans", data))
+	c.Assert(w.Code, Equals, http.StatusOK)
+	response := &PlanSerialized{}
+	err := json.Unmarshal(w.Body.Bytes(), response)
+	c.Assert(err, IsNil)
+	names := make([]string, 0)
+	for _, deployment := range response.Deployments {
+		names = append(names, fmt.Sprintf("%s/%s", deployment.Namespace, deployment.Name))
+	}
+	return names
+}
+
+func (suite *UpdaterTestSuite) TestPostPlan(c *C) {
+	w := suite.recorder
+	router := suite.router
+	suite.config.AutoloadNamespaces = false
+	suite.config.Namespaces = []string{"default"}
+	suite.createDeployment(
+		c,
+		metaV1.ObjectMeta{
+			Name:        "test",
+			Namespace:   "default",
+			Annotations: map[string]string{updater.UpdateClassifier: "stable", updater.ExcludeContainersAnnotation: "worker"},
+		},
+		apiv1.Container{Name: "app", Image: "xcnt/test:0.9.9"},
+		apiv1.Container{Name: "worker", Image: "xcnt/test:0.9.9"},
+		apiv1.Container{Name: "proxy", Image: "xcnt/proxy:1.0.0"},
+	)
+	data := url.Values{}
+	data.Set(ImageParam, "xcnt/test:1.0.0")
+	data.Set(UpdateClassifierParam, "stable")
+	req := suite.PostRequestTo("/plans", data)
+
+	router.ServeHTTP(w, req)
+	c.Assert(w.Code, Equals, http.StatusOK)
+	response := &PlanSerialized{}
+	err := json.Unmarshal(w.Body.Bytes(), response)
+	c.Assert(err, IsNil)
+	c.Assert(response.Jobs, HasLen, 0)
+	c.Assert(response.Deployments, DeepEquals, []WorkloadPlanSerialized{
+		{
+			Namespace: "default",
+			Name:      "test",
+			Containers: []ContainerChangeSerialized{
+				{Container: "app", Type: "container", PreviousImage: "xcnt/test:0.9.9", Image: "xcnt/test:1.0.0"},
+				{Container: "worker", Type: "container", PreviousImage: "xcnt/test:0.9.9", Image: "xcnt/test:0.9.9", Excluded: true},
+			},
+		},
+	})
+
+	deployment, err := suite.clientset.AppsV1().Deployments("default").Get(context.Background(), "test", metaV1.GetOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(deployment.Spec.Template.Spec.Containers[0].Image, Equals, "xcnt/test:0.9.9")
+}
+
+func (suite *UpdaterTestSuite) TestPostPlanWaves(c *C) {
+	container := apiv1.Container{Name: "app", Image: "xcnt/test:0.9.9"}
+	suite.createNamespace(c, "default", nil)
+	suite.createDeployment(c, metaV1.ObjectMeta{
+		Name:        "frontend",
+		Namespace:   "default",
+		Annotations: map[string]string{updater.UpdateClassifier: "stable", updater.UpdateAfterAnnotation: "api"},
+	}, container)
+	suite.createDeployment(c, metaV1.ObjectMeta{
+		Name:        "api",
+		Namespace:   "default",
+		Annotations: map[string]string{updater.UpdateClassifier: "stable"},
+	}, container)
+	data := url.Values{}
+	data.Set(ImageParam, "xcnt/test:1.0.0")
+	data.Set(UpdateClassifierParam, "stable")
+	suite.router.ServeHTTP(suite.recorder, suite.PostRequestTo("/plans", data))
+	c.Assert(suite.recorder.Code, Equals, http.StatusOK)
+	response := &PlanSerialized{}
+	c.Assert(json.Unmarshal(suite.recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Waves, DeepEquals, [][]string{{"default/api"}, {"default/frontend"}})
+}
+
+func (suite *UpdaterTestSuite) TestPostUpdateOrderCycle(c *C) {
+	container := apiv1.Container{Name: "app", Image: "xcnt/test:0.9.9"}
+	suite.createNamespace(c, "default", nil)
+	for _, names := range [][]string{{"frontend", "api"}, {"api", "frontend"}} {
+		suite.createDeployment(c, metaV1.ObjectMeta{
+			Name:        names[0],
+			Namespace:   "default",
+			Annotations: map[string]string{updater.UpdateClassifier: "stable", updater.UpdateAfterAnnotation: names[1]},
+		}, container)
+	}
+	suite.router.ServeHTTP(suite.recorder, suite.PostRequestComplete())
+	c.Assert(suite.recorder.Code, Equals, http.StatusConflict)
+	response := &ErrorSerialized{}
+	c.Assert(json.Unmarshal(suite.recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Error, Matches, "Deployment default/.*: The update order contains a cycle")
+}
+
+func (suite *UpdaterTestSuite) TestPostInvalidUpdateStrategy(c *C) {
+	suite.createNamespace(c, "default", nil)
+	suite.createDeployment(c, metaV1.ObjectMeta{
+		Name:        "api",
+		Namespace:   "default",
+		Annotations: map[string]string{updater.UpdateClassifier: "stable", updater.UpdateStrategyAnnotation: updater.StrategyBlueGreen},
+	}, apiv1.Container{Name: "app", Image: "xcnt/test:0.9.9"})
+	suite.router.ServeHTTP(suite.recorder, suite.PostRequestComplete())
+	c.Assert(suite.recorder.Code, Equals, http.StatusConflict)
+	response := &ErrorSerialized{}
+	c.Assert(json.Unmarshal(suite.recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Error, Matches, "Deployment default/api: A blue-green deployment needs the services to switch .*")
+}
+
+func (suite *UpdaterTestSuite) TestPostInvalidAnalysis(c *C) {
+	suite.createNamespace(c, "default", nil)
+	suite.createDeployment(c, metaV1.ObjectMeta{
+		Name:        "api",
+		Namespace:   "default",
+		Annotations: map[string]string{updater.UpdateClassifier: "stable", updater.AnalysisAnnotation: `[{"query": "up"}]`},
+	}, apiv1.Container{Name: "app", Image: "xcnt/test:0.9.9"})
+	suite.router.ServeHTTP(suite.recorder, suite.PostRequestComplete())
+	c.Assert(suite.recorder.Code, Equals, http.StatusConflict)
+	response := &ErrorSerialized{}
+	c.Assert(json.Unmarshal(suite.recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Error, Equals, "Deployment default/api: The analysis annotation is invalid: The analysis query needs a min or max threshold")
+}
+
+func (suite *UpdaterTestSuite) TestPostInvalidSmokeCheck(c *C) {
+	suite.createNamespace(c, "default", nil)
+	suite.createDeployment(c, metaV1.ObjectMeta{
+		Name:        "api",
+		Namespace:   "default",
+		Annotations: map[string]string{updater.UpdateClassifier: "stable", updater.SmokeCheckServiceAnnotation: "api/health"},
+	}, apiv1.Container{Name: "app", Image: "xcnt/test:0.9.9"})
+	suite.router.ServeHTTP(suite.recorder, suite.PostRequestComplete())
+	c.Assert(suite.recorder.Code, Equals, http.StatusConflict)
+	response := &ErrorSerialized{}
+	c.Assert(json.Unmarshal(suite.recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Error, Equals, `Smoke check of deployment default/api: The service "api" has to be in the format service:port/path`)
+}
+
+func (suite *UpdaterTestSuite) TestPostInvalidUpdatePhase(c *C) {
+	suite.createNamespace(c, "default", nil)
+	job := &batchv1.Job{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:        "migrate",
+			Namespace:   "default",
+			Annotations: map[string]string{updater.UpdateClassifier: "stable", updater.UpdatePhaseAnnotation: "revert"},
+		},
+		Spec: batchv1.JobSpec{Template: apiv1.PodTemplateSpec{Spec: apiv1.PodSpec{
+			Containers: []apiv1.Container{{Name: "migrate", Image: "xcnt/test:0.9.9"}},
+		}}},
+	}
+	_, err := suite.clientset.BatchV1().Jobs("default").Create(context.Background(), job, metaV1.CreateOptions{})
+	c.Assert(err, IsNil)
+	suite.router.ServeHTTP(suite.recorder, suite.PostRequestComplete())
+	c.Assert(suite.recorder.Code, Equals, http.StatusConflict)
+	response := &ErrorSerialized{}
+	c.Assert(json.Unmarshal(suite.recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Error, Equals, `Job default/migrate: The update phase "revert" is neither update nor rollback`)
+}
+
+func (suite *UpdaterTestSuite) TestPostInvalidRollbackPolicy(c *C) {
+	suite.createNamespace(c, "default", nil)
+	suite.createDeployment(c, metaV1.ObjectMeta{
+		Name:        "api",
+		Namespace:   "default",
+		Annotations: map[string]string{updater.UpdateClassifier: "stable", updater.RollbackPolicyAnnotation: "never"},
+	}, apiv1.Container{Name: "app", Image: "xcnt/test:0.9.9"})
+	suite.router.ServeHTTP(suite.recorder, suite.PostRequestComplete())
+	c.Assert(suite.recorder.Code, Equals, http.StatusConflict)
+	response := &ErrorSerialized{}
+	c.Assert(json.Unmarshal(suite.recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Error, Equals, `Deployment default/api: The rollback policy "never" is neither rollback-all, rollback-failed-only nor no-rollback`)
+}
+
+func (suite *UpdaterTestSuite) TestPostPlanCanary(c *C) {
+	suite.createNamespace(c, "default", nil)
+	suite.createDeployment(c, metaV1.ObjectMeta{
+		Name:        "api",
+		Namespace:   "default",
+		Annotations: map[string]string{updater.UpdateClassifier: "stable"},
+	}, apiv1.Container{Name: "app", Image: "xcnt/test:0.9.9"})
+	data := url.Values{}
+	data.Set(ImageParam, "xcnt/test:1.0.0")
+	data.Set(UpdateClassifierParam, "stable")
+	data.Set(CanaryWeightParam, "20")
+	data.Set(CanaryMaxRestartsParam, "1")
+	suite.router.ServeHTTP(suite.recorder, suite.PostRequestTo("/plans", data))
+	c.Assert(suite.recorder.Code, Equals, http.StatusOK)
+	response := &PlanSerialized{}
+	c.Assert(json.Unmarshal(suite.recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Canary, DeepEquals, &CanarySerialized{Weight: 20, SoakTime: "5m0s", MaxRestarts: 1})
+}
+
+func (suite *UpdaterTestSuite) TestPostPlanWithoutCanary(c *C) {
+	suite.createNamespace(c, "default", nil)
+	data := url.Values{}
+	data.Set(ImageParam, "xcnt/test:1.0.0")
+	data.Set(UpdateClassifierParam, "stable")
+	suite.router.ServeHTTP(suite.recorder, suite.PostRequestTo("/plans", data))
+	c.Assert(suite.recorder.Code, Equals, http.StatusOK)
+	response := &PlanSerialized{}
+	c.Assert(json.Unmarshal(suite.recorder.Body.Bytes(), response), IsNil)
+	c.Assert(response.Canary, IsNil)
+}
+
+func (suite *UpdaterTestSuite) TestPostInvalidCanary(c *C) {
+	for _, values := range []map[string]string{
+		{CanaryWeightParam: "ten"},
+		{CanaryWeightParam: "100"},
+		{CanaryWeightParam: "10", CanarySoakTimeParam: "soon"},
+		{CanaryWeightParam: "10", CanaryMaxRestartsParam: "-1"},
+	} {
+		data := url.Values{}
+		data.Set(ImageParam, "xcnt/test:1.0.0")
+		data.Set(UpdateClassifierParam, "stable")
+		for key, value := range values {
+			data.Set(key, value)
+		}
+		recorder := httptest.NewRecorder()
+		suite.router.ServeHTTP(recorder, suite.PostRequestWith(data))
+		c.Assert(recorder.Code, Equals, http.StatusBadRequest, Commentf("%v", values))
+	}
+}
+
+func (suite *UpdaterTestSuite) TestPostPlanNoImage(c *C) {
+	w := suite.recorder
+	data := url.Values{}
+	data.Set(UpdateClassifierParam, "stable")
+	req := suite.PostRequestTo("/plans", data)
+
+	suite.router.ServeHTTP(w, req)
+	c.Assert(w.Code, Equals, http.StatusBadRequest)
+}
+
+func (suite *UpdaterTestSuite) createLabeledDeployments(c *C) {
+	annotations := map[string]string{updater.UpdateClassifier: "stable"}
+	container := apiv1.Container{Name: "app", Image: "xcnt/test:0.9.9"}
+	suite.createNamespace(c, "payments", map[string]string{"xcnt.io/updates": "enabled"})
+	suite.createNamespace(c, "search", nil)
+	suite.createDeployment(c, metaV1.ObjectMeta{
+		Name: "api", Namespace: "payments", Annotations: annotations, Labels: map[string]string{"tier": "web"},
+	}, container)
+	suite.createDeployment(c, metaV1.ObjectMeta{
+		Name: "worker", Namespace: "payments", Annotations: annotations, Labels: map[string]string{"tier": "worker"},
+	}, container)
+	suite.createDeployment(c, metaV1.ObjectMeta{
+		Name: "api", Namespace: "search", Annotations: annotations, Labels: map[string]string{"tier": "web"},
+	}, container)
+}
+
+func (suite *UpdaterTestSuite) TestPostPlanWithLabelSelector(c *C) {
+	suite.createLabeledDeployments(c)
+	data := url.Values{}
+	data.Set(ImageParam, "xcnt/test:1.0.0")
+	data.Set(UpdateClassifierParam, "stable")
+	data.Set(LabelSelectorParam, "tier=web")
+	c.Assert(suite.planDeploymentNames(c, data), DeepEquals, []string{"payments/api", "search/api"})
+}
+
+func (suite *UpdaterTestSuite) TestPostPlanWithServerLabelSelector(c *C) {
+	suite.createLabeledDeployments(c)
+	suite.config.LabelSelector = "tier in (web, worker)"
+	data := url.Values{}
+	data.Set(ImageParam, "xcnt/test:1.0.0")
+	data.Set(UpdateClassifierParam, "stable")
+	data.Set(LabelSelectorParam, "tier!=web")
+	c.Assert(suite.planDeploymentNames(c, data), DeepEquals, []string{"payments/worker"})
+}
+
+func (suite *UpdaterTestSuite) TestPostPlanWithNamespaceSelector(c *C) {
+	suite.createLabeledDeployments(c)
+	suite.config.NamespaceSelector = "xcnt.io/updates=enabled"
+	data := url.Values{}
+	data.Set(ImageParam, "xcnt/test:1.0.0")
+	data.Set(UpdateClassifierParam, "stable")
+	c.Assert(suite.planDeploymentNames(c, data), DeepEquals, []string{"payments/api", "payments/worker"})
+}
+
+func (suite *UpdaterTestSuite) TestPostInvalidLabelSelector(c *C) {
+	w := suite.recorder
+	data := url.Values{}
+	data.Set(ImageParam, "xcnt/test:1.0.0")
+	data.Set(UpdateClassifierParam, "stable")
+	data.Set(LabelSelectorParam, "tier in web")
+	req := suite.PostRequestWith(data)
+
+	suite.router.ServeHTTP(w, req)
+	c.Assert(w.Code, Equals, http.StatusBadRequest)
+}
+
+func (suite *UpdaterTestSuite) TestAbortWithCreateError(c *C) {
+	conflicts := []error{
+		&updater.VersionError{Kind: "Deployment", Namespace: "default", Name: "api", Reason: "downgrade"},
+		&updater.OrderError{Namespace: "default", Name: "api", Reason: "cycle"},
+		&updater.StrategyError{Namespace: "default", Name: "api", Reason: "no services"},
+		&updater.SmokeCheckError{Namespace: "default", Name: "api", Reason: "failed"},
+		&updater.AnalysisError{Namespace: "default", Name: "api", Reason: "invalid"},
+		&updater.PhaseError{Namespace: "default", Name: "migrate", Phase: "later"},
+		&updater.RollbackPolicyError{Namespace: "default", Name: "api", Policy: "some"},
+		&updater.RollbackImageError{Namespace: "default", Images: []string{"xcnt/test:1.0.0", "xcnt/test:0.9.0"}},
+		&manager.ConflictError{UpdateUUIDs: []uuid.UUID{uuid.New()}},
+	}
+	for _, conflict := range conflicts {
+		recorder := httptest.NewRecorder()
+		context, _ := gin.CreateTestContext(recorder)
+		abortWithCreateError(context, fmt.Errorf("planning failed: %w", conflict))
+		c.Assert(recorder.Code, Equals, http.StatusConflict, Commentf("%T", conflict))
+		response := &ErrorSerialized{}
+		c.Assert(json.Unmarshal(recorder.Body.Bytes(), response), IsNil)
+		c.Assert(response.Error, Equals, conflict.Error())
+	}
+
+	recorder := httptest.NewRecorder()
+	context, _ := gin.CreateTestContext(recorder)
+	abortWithCreateError(context, &manager.RejectionError{Reason: "not allowed"})
+	c.Assert(recorder.Code, Equals, http.StatusForbidden)
+
+	recorder = httptest.NewRecorder()
+	context, _ = gin.CreateTestContext(recorder)
+	abortWithCreateError(context, errors.New("connection refused"))
+	c.Assert(recorder.Code, Equals, http.StatusInternalServerError)
+
+	recorder = httptest.NewRecorder()
+	context, _ = gin.CreateTestContext(recorder)
+	abortWithCreateError(context, &updater.CanaryError{Namespace: "default", Name: "api", Reason: "restarted"})
+	c.Assert(recorder.Code, Equals, http.StatusInternalServerError)
+}